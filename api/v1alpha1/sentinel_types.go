@@ -17,9 +17,72 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// RotateAnnotation requests a rotation of the secret of a Sentinel. Any new value makes the
+	// operator rewrite the data of the secret from the spec, kubectl sentinel rotate sets a timestamp.
+	RotateAnnotation = "secops.kavinduxo.com/rotate"
+	// RotatedAnnotation records on the secret the value of the last rotation it was written for
+	RotatedAnnotation = "secops.kavinduxo.com/rotated"
+
+	// SecretRevealedReason is the reason of the events kubectl sentinel reveal records
+	SecretRevealedReason = "SecretRevealed"
+	// RevealedByAnnotation names on a SecretRevealed event the user the secret was revealed to,
+	// as reported by the client that recorded the event
+	RevealedByAnnotation = "secops.kavinduxo.com/revealed-by"
+	// RevealedKeysAnnotation lists on a SecretRevealed event the revealed keys
+	RevealedKeysAnnotation = "secops.kavinduxo.com/revealed-keys"
+	// AuditedAnnotation marks a SecretRevealed event the operator recorded in its audit log
+	AuditedAnnotation = "secops.kavinduxo.com/audited"
+
+	// ReplicaOfAnnotation marks the objects the operator copied into a remote cluster with the
+	// namespace/name of their Sentinel. Remote objects without it are never overwritten or deleted.
+	ReplicaOfAnnotation = "secops.kavinduxo.com/replica-of"
+)
+
+// Pod annotations of the secret injection webhook
+const (
+	// InjectLabel opts a pod in to the injection webhook with the value "true", the webhook does not
+	// see the other pods
+	InjectLabel = "sentinel.secops.kavinduxo.com/inject"
+	// InjectAnnotation names the Sentinel in the namespace of the pod whose secret is injected
+	InjectAnnotation = "sentinel.secops.kavinduxo.com/inject"
+	// InjectModeAnnotation selects how the secret is injected, InjectModeVolume (the default), InjectModeEnv or InjectModeAgent
+	InjectModeAnnotation = "sentinel.secops.kavinduxo.com/inject-mode"
+	// InjectPathAnnotation sets the directory the volume is mounted at, /var/run/secrets/sentinel by default
+	InjectPathAnnotation = "sentinel.secops.kavinduxo.com/inject-path"
+	// InjectContainersAnnotation lists the containers that receive the secret, all containers by default
+	InjectContainersAnnotation = "sentinel.secops.kavinduxo.com/inject-containers"
+	// InjectEnvPrefixAnnotation is prepended to the names of the injected environment variables
+	InjectEnvPrefixAnnotation = "sentinel.secops.kavinduxo.com/inject-env-prefix"
+	// InjectReloadProcessAnnotation names the process the sentinel-agent signals after an update
+	// in InjectModeAgent. The containers of the pod have to share their process namespace, see
+	// InjectShareProcessNamespaceAnnotation.
+	InjectReloadProcessAnnotation = "sentinel.secops.kavinduxo.com/inject-reload-process"
+	// InjectShareProcessNamespaceAnnotation allows the webhook to turn on shareProcessNamespace for the
+	// reload process with the value "true". All containers of the pod then see each other's processes.
+	InjectShareProcessNamespaceAnnotation = "sentinel.secops.kavinduxo.com/inject-share-process-namespace"
+	// InjectReloadSignalAnnotation is the signal sent to the reload process, HUP by default
+	InjectReloadSignalAnnotation = "sentinel.secops.kavinduxo.com/inject-reload-signal"
+	// InjectReloadURLAnnotation is the endpoint the sentinel-agent posts to after an update in InjectModeAgent
+	InjectReloadURLAnnotation = "sentinel.secops.kavinduxo.com/inject-reload-url"
+	// InjectedAnnotation records the Sentinel that was injected into the pod
+	InjectedAnnotation = "sentinel.secops.kavinduxo.com/injected"
+
+	// InjectModeVolume mounts the secret as a memory backed volume. Sealed secrets are opened
+	// into an emptyDir by a sentinel-decrypt init container.
+	InjectModeVolume = "volume"
+	// InjectModeEnv adds the keys of the secret as environment variables
+	InjectModeEnv = "env"
+	// InjectModeAgent writes the secret into a memory backed volume with a sentinel-agent sidecar,
+	// which replaces the files when the secret changes and tells the application to reload
+	InjectModeAgent = "agent"
+)
+
 // SentinelSpec defines the desired state of Sentinel
 type SentinelSpec struct {
 	// The following markers will use OpenAPI v3 schema to validate the value
@@ -50,6 +113,327 @@ type SentinelSpec struct {
 	// RoleBinding is optional and for the RBAC secured type
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	RoleBinding string `json:"roleBinding,omitempty"`
+
+	// CreateServiceAccount is optional and lets the operator create and own the ServiceAccount
+	// when it does not exist yet. The ServiceAccount is removed with the Sentinel.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	CreateServiceAccount bool `json:"createServiceAccount,omitempty"`
+
+	// AutomountServiceAccountToken is optional and only used with CreateServiceAccount.
+	// Set it to false to disable the token automount of the created ServiceAccount
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	AutomountServiceAccountToken *bool `json:"automountServiceAccountToken,omitempty"`
+
+	// ServiceAccountTokenSecret is optional and only used with CreateServiceAccount.
+	// It defines the name of a bound token Secret that should create for the ServiceAccount
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	ServiceAccountTokenSecret string `json:"serviceAccountTokenSecret,omitempty"`
+
+	// Approvers is optional and lists the users that may approve a SentinelAccessRequest
+	// for the RbacSecuredSecret and RbacKMSSecuredSecret types
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Approvers []string `json:"approvers,omitempty"`
+
+	// ApproverGroups is optional and lists the groups whose members may approve a SentinelAccessRequest
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	ApproverGroups []string `json:"approverGroups,omitempty"`
+
+	// BreakGlass is optional and defines an emergency access to the secret. It is applied
+	// immediately without an approval, but it is recorded, alerted and capped to a short TTL
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	BreakGlass *BreakGlassSpec `json:"breakGlass,omitempty"`
+
+	// Envelope is optional and lets the operator encrypt the values itself with AES-256-GCM
+	// before they are stored in the secret. It replaces the apiserver EncryptionConfiguration
+	// of the SecuredSecret types where the apiserver can not be configured.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Envelope *EnvelopeSpec `json:"envelope,omitempty"`
+
+	// DataFrom is optional and reads data from secret stores outside of the cluster. The sources
+	// are merged in order, later sources replace the keys of earlier ones and Data replaces both.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	DataFrom []DataFromSource `json:"dataFrom,omitempty"`
+
+	// RefreshInterval between two polls of the DataFrom sources, defaults to 5m
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+
+	// PushTo is optional and writes the data of the secret to external secret stores whenever
+	// it changes. Sealed secrets are pushed in plaintext, the stores encrypt them on their own.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	PushTo []PushTarget `json:"pushTo,omitempty"`
+
+	// Replication is optional and copies the secret into other clusters whenever it changes.
+	// Sealed secrets are copied sealed. The copies are removed with the Sentinel and when their
+	// target is removed from the spec.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Replication []ReplicationTarget `json:"replication,omitempty"`
+}
+
+// ReplicationTarget defines a remote cluster the secret is copied to
+type ReplicationTarget struct {
+	// Name identifies the cluster in the status
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// KubeconfigSecretRef selects a key of a Secret in the namespace of the Sentinel that holds
+	// the kubeconfig of the remote cluster. The credentials have to be embedded, kubeconfigs
+	// with exec plugins, auth providers or file references are refused. The server and the
+	// proxy of every cluster have to be allowed by the operator with --allowed-urls.
+	KubeconfigSecretRef corev1.SecretKeySelector `json:"kubeconfigSecretRef"`
+
+	// Namespace the copy is written to in the remote cluster, defaults to the namespace of the
+	// Sentinel. The namespace has to exist.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// RBAC also copies the Role and the RoleBinding of the Sentinel
+	// +optional
+	RBAC bool `json:"rbac,omitempty"`
+}
+
+// ReplicationStatus is the state of the copy of the secret in a remote cluster
+type ReplicationStatus struct {
+	// Name of the ReplicationTarget
+	Name string `json:"name"`
+
+	// Synced reports whether the remote cluster holds the current data of the secret
+	Synced bool `json:"synced"`
+
+	// Message describes the last sync or its error
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// SecretResourceVersion is the resource version of the secret that was copied last
+	// +optional
+	SecretResourceVersion string `json:"secretResourceVersion,omitempty"`
+
+	// ObservedGeneration is the generation of the Sentinel that was copied last
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastSyncTime is the time of the last successful sync
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// Replicated is the target as it was last replicated to, the copies are removed through it
+	// when the target is removed from the spec
+	// +optional
+	Replicated *ReplicationTarget `json:"replicated,omitempty"`
+}
+
+// Deletion policies of a PushTarget
+const (
+	// PushDeletionRetain keeps the pushed data when the Sentinel is deleted
+	PushDeletionRetain = "Retain"
+	// PushDeletionDelete removes the pushed data when the Sentinel is deleted or the target is
+	// removed from the spec
+	PushDeletionDelete = "Delete"
+)
+
+// PushTarget defines an external secret store the secret is written to. Exactly one of
+// Vault and File must be set.
+type PushTarget struct {
+	// Name identifies the target in the status
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Vault writes the data as a secret of the Vault KV version 2 secrets engine
+	// +optional
+	Vault *VaultKVTarget `json:"vault,omitempty"`
+
+	// File writes every key into a file of a directory mounted into the operator
+	// +optional
+	File *FileSource `json:"file,omitempty"`
+
+	// DeletionPolicy is Retain (the default) or Delete, which removes the pushed data with the Sentinel
+	// or when the target is removed from the spec
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// +optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// VaultKVTarget defines a secret of the Vault KV version 2 secrets engine the data is written to
+type VaultKVTarget struct {
+	// Address of Vault, for example https://vault.vault.svc:8200. It has to be allowed by the
+	// operator with --allowed-urls.
+	Address string `json:"address"`
+
+	// Mount path of the KV secrets engine, defaults to secret
+	// +optional
+	Mount string `json:"mount,omitempty"`
+
+	// Path of the secret below the mount
+	Path string `json:"path"`
+
+	// TokenSecretRef selects a key of a Secret in the namespace of the Sentinel that holds
+	// the Vault token of the operator
+	TokenSecretRef corev1.SecretKeySelector `json:"tokenSecretRef"`
+}
+
+// PushTargetStatus is the state of the data in a PushTarget
+type PushTargetStatus struct {
+	// Name of the PushTarget
+	Name string `json:"name"`
+
+	// Synced reports whether the target holds the current data of the secret
+	Synced bool `json:"synced"`
+
+	// Message describes the last push or its error
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// SecretResourceVersion is the resource version of the secret that was pushed last
+	// +optional
+	SecretResourceVersion string `json:"secretResourceVersion,omitempty"`
+
+	// ObservedGeneration is the generation of the Sentinel that was pushed last
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastPushTime is the time of the last successful push
+	// +optional
+	LastPushTime *metav1.Time `json:"lastPushTime,omitempty"`
+
+	// Pushed is the target as it was last pushed to, the data is removed through it when the
+	// target is removed from the spec with the Delete policy
+	// +optional
+	Pushed *PushTarget `json:"pushed,omitempty"`
+}
+
+// DataFromSource defines an external secret store. Exactly one of Vault, HTTP and File must be set.
+type DataFromSource struct {
+	// Prefix is added to the keys read from the source
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Vault reads a secret of the Vault KV version 2 secrets engine
+	// +optional
+	Vault *VaultKVSource `json:"vault,omitempty"`
+
+	// HTTP reads a JSON object from a HTTP endpoint
+	// +optional
+	HTTP *HTTPJSONSource `json:"http,omitempty"`
+
+	// File reads the files of a directory mounted into the operator
+	// +optional
+	File *FileSource `json:"file,omitempty"`
+}
+
+// VaultKVSource defines a secret of the Vault KV version 2 secrets engine
+type VaultKVSource struct {
+	// Address of Vault, for example https://vault.vault.svc:8200. It has to be allowed by the
+	// operator with --allowed-urls.
+	Address string `json:"address"`
+
+	// Mount path of the KV secrets engine, defaults to secret
+	// +optional
+	Mount string `json:"mount,omitempty"`
+
+	// Path of the secret below the mount
+	Path string `json:"path"`
+
+	// Version of the secret, the latest version when empty
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Version int32 `json:"version,omitempty"`
+
+	// TokenSecretRef selects a key of a Secret in the namespace of the Sentinel that holds
+	// the Vault token of the operator
+	TokenSecretRef corev1.SecretKeySelector `json:"tokenSecretRef"`
+}
+
+// HTTPJSONSource defines a HTTP endpoint returning a JSON object. The string values become
+// the keys of the secret, other values are stored as JSON. The URL has to be allowed by the
+// operator with --allowed-urls.
+type HTTPJSONSource struct {
+	// URL of the endpoint
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+
+	// Path selects a nested object with dot separated keys, the whole document when empty
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// TokenSecretRef selects a key of a Secret in the namespace of the Sentinel that is sent as bearer token
+	// +optional
+	TokenSecretRef *corev1.SecretKeySelector `json:"tokenSecretRef,omitempty"`
+}
+
+// FileSource defines a directory mounted into the operator, every file becomes a key
+type FileSource struct {
+	// Path of the directory relative to the data root of the operator, --data-from-file-root
+	Path string `json:"path"`
+}
+
+// EnvelopeSpec defines the master key that wraps the data key of the envelope encryption.
+// Exactly one of MasterKeySecretRef and VaultTransit must be set.
+type EnvelopeSpec struct {
+	// MasterKeySecretRef selects a key of a Secret in the namespace of the Sentinel that holds
+	// a 32 byte master key, raw or base64 encoded
+	// +optional
+	MasterKeySecretRef *corev1.SecretKeySelector `json:"masterKeySecretRef,omitempty"`
+
+	// VaultTransit wraps the data key with a key of the Vault transit secrets engine. Its
+	// address has to be allowed by the operator with --allowed-urls.
+	// +optional
+	VaultTransit *VaultTransitSpec `json:"vaultTransit,omitempty"`
+}
+
+// VaultTransitSpec defines a key of the Vault transit secrets engine
+type VaultTransitSpec struct {
+	// Address of Vault, for example https://vault.vault.svc:8200
+	Address string `json:"address"`
+
+	// Mount path of the transit secrets engine, defaults to transit
+	// +optional
+	Mount string `json:"mount,omitempty"`
+
+	// KeyName is the name of the transit key
+	KeyName string `json:"keyName"`
+
+	// TokenSecretRef selects a key of a Secret in the namespace of the Sentinel that holds
+	// the Vault token of the operator
+	TokenSecretRef corev1.SecretKeySelector `json:"tokenSecretRef"`
+}
+
+// BreakGlassSpec defines an emergency access to the secret of a Sentinel
+type BreakGlassSpec struct {
+	// Subject defines the User, Group or ServiceAccount that receives the emergency access
+	Subject rbacv1.Subject `json:"subject"`
+
+	// Justification explains why the emergency access is needed
+	// +kubebuilder:validation:MinLength=10
+	Justification string `json:"justification"`
+
+	// Duration of the emergency access, defaults to 15m and is capped to 1h
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// BreakGlassRecord is an entry of the break-glass history of a Sentinel
+type BreakGlassRecord struct {
+	// AccessGrant is the name of the SentinelAccessGrant created for the emergency access
+	AccessGrant string `json:"accessGrant"`
+
+	// Subject that received the emergency access
+	Subject rbacv1.Subject `json:"subject"`
+
+	// Justification given for the emergency access
+	Justification string `json:"justification"`
+
+	// Duration of the emergency access, the access grant must not differ from it
+	// +optional
+	Duration metav1.Duration `json:"duration,omitempty"`
+
+	// GrantedAt is the time when the emergency access was applied
+	GrantedAt metav1.Time `json:"grantedAt"`
+
+	// ExpiresAt is the time when the emergency access is revoked
+	ExpiresAt metav1.Time `json:"expiresAt"`
+
+	// Phase of the access grant, one of Active and Expired
+	Phase string `json:"phase,omitempty"`
 }
 
 // SentinelStatus defines the observed state of Sentinel
@@ -66,6 +450,26 @@ type SentinelStatus struct {
 	// Conditions store the status conditions of the Sentinel instances
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// BreakGlassHistory records the latest emergency accesses to the secret
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	BreakGlassHistory []BreakGlassRecord `json:"breakGlassHistory,omitempty"`
+
+	// PolicyViolations lists the SentinelPolicy rules the Sentinel does not follow
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	PolicyViolations []PolicyViolation `json:"policyViolations,omitempty"`
+
+	// LastRotationTime is the time the data of the secret was last rewritten by a rotation
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+
+	// PushTargets records the state of the PushTo targets
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	PushTargets []PushTargetStatus `json:"pushTargets,omitempty"`
+
+	// Replication records the state of the copies in the remote clusters
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Replication []ReplicationStatus `json:"replication,omitempty"`
 }
 
 //+kubebuilder:object:root=true
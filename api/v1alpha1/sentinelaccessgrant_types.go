@@ -0,0 +1,88 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SentinelAccessGrantSpec defines the desired state of SentinelAccessGrant
+type SentinelAccessGrantSpec struct {
+	// SentinelName defines the Sentinel in the same namespace whose secret should be granted
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	SentinelName string `json:"sentinelName"`
+
+	// Subject defines the User, Group or ServiceAccount that receives the access
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Subject rbacv1.Subject `json:"subject"`
+
+	// Duration defines how long the access is valid after it was granted (e.g. 30m, 2h)
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Duration metav1.Duration `json:"duration"`
+}
+
+// SentinelAccessGrantStatus defines the observed state of SentinelAccessGrant
+type SentinelAccessGrantStatus struct {
+	// Phase of the grant, one of Pending, Active, Expired and Failed
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Phase string `json:"phase,omitempty"`
+
+	// RoleBinding is the name of the scoped RoleBinding created for the grant
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	RoleBinding string `json:"roleBinding,omitempty"`
+
+	// GrantedAt is the time when the RoleBinding was created
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	GrantedAt *metav1.Time `json:"grantedAt,omitempty"`
+
+	// ExpiresAt is the time when the RoleBinding is revoked
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// Conditions store the status conditions of the SentinelAccessGrant instances
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Sentinel",type=string,JSONPath=`.spec.sentinelName`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Expires",type=string,JSONPath=`.status.expiresAt`
+
+// SentinelAccessGrant is the Schema for the sentinelaccessgrants API
+type SentinelAccessGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SentinelAccessGrantSpec   `json:"spec,omitempty"`
+	Status SentinelAccessGrantStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SentinelAccessGrantList contains a list of SentinelAccessGrant
+type SentinelAccessGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SentinelAccessGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SentinelAccessGrant{}, &SentinelAccessGrantList{})
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SentinelAccessReportSpec defines the desired state of SentinelAccessReport
+type SentinelAccessReportSpec struct {
+	// SentinelName defines the Sentinel in the same namespace whose secret is analyzed
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	SentinelName string `json:"sentinelName"`
+}
+
+// SubjectAccess describes a subject that is able to read the secret
+type SubjectAccess struct {
+	// Kind of the subject, one of User, Group and ServiceAccount
+	Kind string `json:"kind"`
+
+	// Name of the subject
+	Name string `json:"name"`
+
+	// Namespace of a ServiceAccount subject
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Verbs the subject holds on the secret, a subset of get, list and watch
+	Verbs []string `json:"verbs"`
+
+	// Via lists the RoleBindings and ClusterRoleBindings that grant the access
+	Via []string `json:"via"`
+}
+
+// SentinelAccessReportStatus defines the observed state of SentinelAccessReport
+type SentinelAccessReportStatus struct {
+	// SecretName is the name of the analyzed secret
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	SecretName string `json:"secretName,omitempty"`
+
+	// Subjects lists every subject able to get, list or watch the secret
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Subjects []SubjectAccess `json:"subjects,omitempty"`
+
+	// LastAnalyzed is the time of the latest analysis
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	LastAnalyzed *metav1.Time `json:"lastAnalyzed,omitempty"`
+
+	// Conditions store the status conditions of the SentinelAccessReport instances
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Sentinel",type=string,JSONPath=`.spec.sentinelName`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+//+kubebuilder:printcolumn:name="Analyzed",type=date,JSONPath=`.status.lastAnalyzed`
+
+// SentinelAccessReport is the Schema for the sentinelaccessreports API
+type SentinelAccessReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SentinelAccessReportSpec   `json:"spec,omitempty"`
+	Status SentinelAccessReportStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SentinelAccessReportList contains a list of SentinelAccessReport
+type SentinelAccessReportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SentinelAccessReport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SentinelAccessReport{}, &SentinelAccessReportList{})
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Annotations of a SentinelAccessRequest. RequestedBy and ApprovedBy are written by the
+// admission webhook from the user info of the request and can not be set by users.
+const (
+	// AccessRequestApprovalAnnotation is set by an approver to "approved" or "denied"
+	AccessRequestApprovalAnnotation = "secops.kavinduxo.com/approval"
+	// AccessRequestApprovedByAnnotation records the user that set the approval
+	AccessRequestApprovedByAnnotation = "secops.kavinduxo.com/approved-by"
+	// AccessRequestRequestedByAnnotation records the user that created the request
+	AccessRequestRequestedByAnnotation = "secops.kavinduxo.com/requested-by"
+
+	AccessRequestApproved = "approved"
+	AccessRequestDenied   = "denied"
+)
+
+// SentinelAccessRequestSpec defines the desired state of SentinelAccessRequest
+type SentinelAccessRequestSpec struct {
+	// SentinelName defines the Sentinel in the same namespace whose secret is requested
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	SentinelName string `json:"sentinelName"`
+
+	// Subject defines the User, Group or ServiceAccount that should receive the access
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Subject rbacv1.Subject `json:"subject"`
+
+	// Duration defines how long the access is valid once it was approved
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Duration metav1.Duration `json:"duration"`
+
+	// Reason is a human readable justification shown to the approvers
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Reason string `json:"reason,omitempty"`
+}
+
+// SentinelAccessRequestStatus defines the observed state of SentinelAccessRequest
+type SentinelAccessRequestStatus struct {
+	// Phase of the request, one of Pending, Approved, Denied and Failed
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Phase string `json:"phase,omitempty"`
+
+	// RequestedBy is the user that created the request
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	RequestedBy string `json:"requestedBy,omitempty"`
+
+	// DecidedBy is the approver that approved or denied the request
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	DecidedBy string `json:"decidedBy,omitempty"`
+
+	// AccessGrant is the name of the SentinelAccessGrant created after the approval. Once set the
+	// grant is not issued again, a new access needs a new request
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	AccessGrant string `json:"accessGrant,omitempty"`
+
+	// Conditions store the status conditions of the SentinelAccessRequest instances
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Sentinel",type=string,JSONPath=`.spec.sentinelName`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Requested By",type=string,JSONPath=`.status.requestedBy`
+//+kubebuilder:printcolumn:name="Decided By",type=string,JSONPath=`.status.decidedBy`
+
+// SentinelAccessRequest is the Schema for the sentinelaccessrequests API
+type SentinelAccessRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SentinelAccessRequestSpec   `json:"spec,omitempty"`
+	Status SentinelAccessRequestStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SentinelAccessRequestList contains a list of SentinelAccessRequest
+type SentinelAccessRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SentinelAccessRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SentinelAccessRequest{}, &SentinelAccessRequestList{})
+}
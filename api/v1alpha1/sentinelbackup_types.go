@@ -0,0 +1,172 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BackupEncryption defines the key the backups are encrypted for. Exactly one of Recipient and
+// VaultTransit has to be set. The bundle is encrypted with AES-256-GCM by a random data key,
+// which is wrapped by a key the operator can not unwrap on its own.
+type BackupEncryption struct {
+	// Recipient is a base64 encoded X25519 public key, create the key pair with
+	// kubectl sentinel backup-key. Only the holder of the private key can open the backups.
+	// +optional
+	Recipient string `json:"recipient,omitempty"`
+
+	// VaultTransit wraps the data key with a key of the Vault transit secrets engine. The token of
+	// the operator may only encrypt, for example with a policy granting update on transit/encrypt/<key>
+	// and nothing on transit/decrypt/<key>, backups with a token that can decrypt are refused.
+	// The token secret has to live in the namespace of the operator.
+	// +optional
+	VaultTransit *VaultTransitSpec `json:"vaultTransit,omitempty"`
+}
+
+// BackupLocation defines where the backups are stored. Exactly one of S3 and Volume has to be set.
+type BackupLocation struct {
+	// S3 stores the backups as objects of a bucket of an S3 compatible store
+	// +optional
+	S3 *S3Location `json:"s3,omitempty"`
+
+	// Volume stores the backups as files of a PersistentVolumeClaim mounted into the operator
+	// +optional
+	Volume *VolumeLocation `json:"volume,omitempty"`
+}
+
+// S3Location defines a bucket of an S3 compatible store
+type S3Location struct {
+	// Endpoint of the store, for example https://s3.eu-west-1.amazonaws.com or http://minio.minio.svc:9000
+	// +kubebuilder:validation:Pattern=`^https?://`
+	Endpoint string `json:"endpoint"`
+
+	// Bucket the backups are written to, it is addressed in the path style
+	Bucket string `json:"bucket"`
+
+	// Prefix of the object names
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Region the requests are signed for, defaults to us-east-1
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// CredentialsSecretRef names a Secret in the namespace of the operator with the keys
+	// accessKeyID and secretAccessKey
+	CredentialsSecretRef corev1.LocalObjectReference `json:"credentialsSecretRef"`
+}
+
+// VolumeLocation defines a directory of a PersistentVolumeClaim. The claim has to be mounted
+// into the operator at <backup volume root>/<claimName>.
+type VolumeLocation struct {
+	// ClaimName of the PersistentVolumeClaim in the namespace of the operator
+	// +kubebuilder:validation:MinLength=1
+	ClaimName string `json:"claimName"`
+
+	// Path of the directory in the volume, the root of the volume when empty
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// SentinelBackupSpec defines the desired state of SentinelBackup
+type SentinelBackupSpec struct {
+	// Interval between two backups, defaults to 24h
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Suspend stops the backups
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Suspend bool `json:"suspend,omitempty"`
+
+	// NamespaceSelector selects the namespaces whose Sentinels are exported, all namespaces when empty
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Selector selects the exported Sentinels by label, all Sentinels when empty
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Encryption defines the key the backups are encrypted for
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Encryption BackupEncryption `json:"encryption"`
+
+	// Location the backups are written to
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Location BackupLocation `json:"location"`
+
+	// SigningKeySecretRef selects a key of a Secret in the namespace of the operator that holds
+	// the key of at least 32 bytes the backups are signed with. Restores only accept backups
+	// signed with the key, give the operator of the restoring cluster a copy of the Secret.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	SigningKeySecretRef corev1.SecretKeySelector `json:"signingKeySecretRef"`
+
+	// KeepLast is the number of backups kept, older backups of this SentinelBackup are deleted
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=7
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	KeepLast int32 `json:"keepLast,omitempty"`
+}
+
+// SentinelBackupStatus defines the observed state of SentinelBackup
+type SentinelBackupStatus struct {
+	// Conditions holds the Ready condition of the latest backup
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// LastBackupTime is the time of the latest successful backup
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	LastBackupTime *metav1.Time `json:"lastBackupTime,omitempty"`
+
+	// LastBackup is the name of the latest backup in the location
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	LastBackup string `json:"lastBackup,omitempty"`
+
+	// Sentinels is the number of Sentinels in the latest backup
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Sentinels int32 `json:"sentinels,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Sentinels",type=integer,JSONPath=`.status.sentinels`
+//+kubebuilder:printcolumn:name="Last Backup",type=date,JSONPath=`.status.lastBackupTime`
+
+// SentinelBackup is the Schema for the sentinelbackups API. It periodically exports the
+// Sentinels and the objects they own into an encrypted bundle.
+type SentinelBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SentinelBackupSpec   `json:"spec,omitempty"`
+	Status SentinelBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SentinelBackupList contains a list of SentinelBackup
+type SentinelBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SentinelBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SentinelBackup{}, &SentinelBackupList{})
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FindingScannerLabel is set on every SentinelFinding to the name of the scanner that reported it
+const FindingScannerLabel = "secops.kavinduxo.com/scanner"
+
+// Severities of a SentinelFinding
+const (
+	SeverityCritical = "Critical"
+	SeverityHigh     = "High"
+	SeverityMedium   = "Medium"
+	SeverityLow      = "Low"
+)
+
+// FindingResourceRef references the object a finding is about
+type FindingResourceRef struct {
+	// APIVersion of the object
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the object
+	Kind string `json:"kind"`
+
+	// Namespace of the object, empty for cluster scoped objects
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the object
+	Name string `json:"name"`
+
+	// FieldPath points to the field of the object the finding is about,
+	// for example spec.template.spec.containers[0].env[2].value
+	// +optional
+	FieldPath string `json:"fieldPath,omitempty"`
+}
+
+// SentinelFindingSpec defines the desired state of SentinelFinding
+type SentinelFindingSpec struct {
+	// Scanner is the name of the scanner that reported the finding
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Scanner string `json:"scanner"`
+
+	// Rule is the identifier of the check that failed
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Rule string `json:"rule"`
+
+	// Severity of the finding, one of Critical, High, Medium and Low
+	// +kubebuilder:validation:Enum=Critical;High;Medium;Low
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Severity string `json:"severity"`
+
+	// Resource is the object the finding is about
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Resource FindingResourceRef `json:"resource"`
+
+	// Message describes the finding
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Message string `json:"message"`
+
+	// Remediation describes how to resolve the finding
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// SentinelFindingStatus defines the observed state of SentinelFinding
+type SentinelFindingStatus struct {
+	// FirstSeen is the time of the scan that reported the finding first
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	FirstSeen *metav1.Time `json:"firstSeen,omitempty"`
+
+	// LastSeen is the time of the latest scan that reported the finding
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	LastSeen *metav1.Time `json:"lastSeen,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Severity",type=string,JSONPath=`.spec.severity`
+//+kubebuilder:printcolumn:name="Rule",type=string,JSONPath=`.spec.rule`
+//+kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.resource.kind`
+//+kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.spec.resource.namespace`
+//+kubebuilder:printcolumn:name="Resource",type=string,JSONPath=`.spec.resource.name`
+//+kubebuilder:printcolumn:name="Field",type=string,JSONPath=`.spec.resource.fieldPath`,priority=1
+
+// SentinelFinding is the Schema for the sentinelfindings API
+type SentinelFinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SentinelFindingSpec   `json:"spec,omitempty"`
+	Status SentinelFindingStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SentinelFindingList contains a list of SentinelFinding
+type SentinelFindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SentinelFinding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SentinelFinding{}, &SentinelFindingList{})
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WebhookChannel posts the notifications as JSON to a HTTP endpoint
+type WebhookChannel struct {
+	// URL the notifications are posted to
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+
+	// HMACSecretRef selects the key the payloads are signed with in the X-Sentinel-Signature
+	// header as sha256=<hex HMAC-SHA256 of "<X-Sentinel-Timestamp>.<body>">. The secret has
+	// to live in the namespace of the operator.
+	// +optional
+	HMACSecretRef *corev1.SecretKeySelector `json:"hmacSecretRef,omitempty"`
+}
+
+// SlackChannel posts the notifications to a Slack compatible incoming webhook
+type SlackChannel struct {
+	// URLSecretRef selects the incoming webhook URL, the URL is a credential and is kept
+	// in a secret in the namespace of the operator
+	URLSecretRef corev1.SecretKeySelector `json:"urlSecretRef"`
+}
+
+// SMTPChannel mails the notifications
+type SMTPChannel struct {
+	// Host of the mail server
+	Host string `json:"host"`
+
+	// Port of the mail server, STARTTLS is required unless Insecure is set
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=587
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// From is the sender address
+	From string `json:"from"`
+
+	// To are the recipient addresses
+	// +kubebuilder:validation:MinItems=1
+	To []string `json:"to"`
+
+	// Username authenticates at the mail server with PLAIN
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// PasswordSecretRef selects the password of Username in the namespace of the operator
+	// +optional
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+
+	// Insecure allows mail servers without STARTTLS
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// NotifierRetry defines how failed deliveries are retried
+type NotifierRetry struct {
+	// MaxAttempts is the number of delivery attempts, 5 when empty
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxAttempts int32 `json:"maxAttempts,omitempty"`
+
+	// InitialBackoff is the wait before the second attempt, it doubles with every attempt, 5s when empty
+	// +optional
+	InitialBackoff *metav1.Duration `json:"initialBackoff,omitempty"`
+
+	// MaxBackoff caps the wait between two attempts, 5m when empty
+	// +optional
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+}
+
+// SentinelNotifierSpec defines the desired state of SentinelNotifier. Exactly one of
+// Webhook, Slack and SMTP has to be set.
+type SentinelNotifierSpec struct {
+	// Events are the event types sent to the channel, all types when empty
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Events []NotificationEvent `json:"events,omitempty"`
+
+	// NamespaceSelector selects the namespaces the events are sent for, all namespaces when empty
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Webhook posts the notifications to a HTTP endpoint
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Webhook *WebhookChannel `json:"webhook,omitempty"`
+
+	// Slack posts the notifications to a Slack compatible incoming webhook
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Slack *SlackChannel `json:"slack,omitempty"`
+
+	// SMTP mails the notifications
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	SMTP *SMTPChannel `json:"smtp,omitempty"`
+
+	// Retry defines how failed deliveries are retried
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Retry *NotifierRetry `json:"retry,omitempty"`
+
+	// DeduplicationWindow suppresses a notification identical to one sent within the window, 24h when empty
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	DeduplicationWindow *metav1.Duration `json:"deduplicationWindow,omitempty"`
+}
+
+// NotificationEvent is one of BreakGlass, RBACFailure, DriftCorrected, CertificateExpiring and RotationFailed
+// +kubebuilder:validation:Enum=BreakGlass;RBACFailure;DriftCorrected;CertificateExpiring;RotationFailed
+type NotificationEvent string
+
+// SentinelNotifierStatus defines the observed state of SentinelNotifier
+type SentinelNotifierStatus struct {
+	// Conditions holds the Ready condition of the channel configuration
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// LastDeliveryTime is the time of the latest successful delivery
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	LastDeliveryTime *metav1.Time `json:"lastDeliveryTime,omitempty"`
+
+	// LastError is the error of the latest failed delivery
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	LastError string `json:"lastError,omitempty"`
+
+	// Delivered counts the notifications delivered
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Delivered int64 `json:"delivered,omitempty"`
+
+	// Failed counts the notifications dropped after the last attempt
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Failed int64 `json:"failed,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Delivered",type=integer,JSONPath=`.status.delivered`
+//+kubebuilder:printcolumn:name="Failed",type=integer,JSONPath=`.status.failed`
+//+kubebuilder:printcolumn:name="Last Delivery",type=date,JSONPath=`.status.lastDeliveryTime`
+
+// SentinelNotifier is the Schema for the sentinelnotifiers API. It routes the security
+// relevant events of the Sentinels to a webhook, Slack or SMTP channel.
+type SentinelNotifier struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SentinelNotifierSpec   `json:"spec,omitempty"`
+	Status SentinelNotifierStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SentinelNotifierList contains a list of SentinelNotifier
+type SentinelNotifierList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SentinelNotifier `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SentinelNotifier{}, &SentinelNotifierList{})
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MinimumSecretTypeAnnotation sets the lowest secret type allowed in the namespace. The types
+// rank BaseSecret, RbacBaseSecret, SecuredSecret, RbacSecuredSecret, KMSSecuredSecret, RbacKMSSecuredSecret.
+const MinimumSecretTypeAnnotation = "secops.kavinduxo.com/minimum-secret-type"
+
+// BreachedPasswordsRef references a ConfigMap holding breached password hashes. The
+// keys are the first 5 hex characters of the upper case SHA-1 of a password, each value
+// lists the remaining 35 characters of the hashes with that prefix, one per line and
+// optionally followed by ":<count>", the format of the Have I Been Pwned range API.
+type BreachedPasswordsRef struct {
+	// Name of the ConfigMap
+	Name string `json:"name"`
+
+	// Namespace of the ConfigMap
+	Namespace string `json:"namespace"`
+}
+
+// PasswordPolicy defines the rules the values of the Sentinel data have to follow
+type PasswordPolicy struct {
+	// Keys are the data keys the rules apply to, all keys when empty
+	// +optional
+	Keys []string `json:"keys,omitempty"`
+
+	// MinLength is the minimum number of characters
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinLength int `json:"minLength,omitempty"`
+
+	// RequireUppercase requires at least one upper case letter
+	// +optional
+	RequireUppercase bool `json:"requireUppercase,omitempty"`
+
+	// RequireLowercase requires at least one lower case letter
+	// +optional
+	RequireLowercase bool `json:"requireLowercase,omitempty"`
+
+	// RequireDigit requires at least one digit
+	// +optional
+	RequireDigit bool `json:"requireDigit,omitempty"`
+
+	// RequireSymbol requires at least one character that is neither a letter nor a digit
+	// +optional
+	RequireSymbol bool `json:"requireSymbol,omitempty"`
+
+	// MinEntropyBits is the minimum estimated entropy, the length times
+	// the bits per character of the character classes in use
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinEntropyBits int `json:"minEntropyBits,omitempty"`
+
+	// BannedWords must not be part of a value, compared case insensitive
+	// +optional
+	BannedWords []string `json:"bannedWords,omitempty"`
+
+	// BreachedPasswords references the offline list of breached passwords
+	// +optional
+	BreachedPasswords *BreachedPasswordsRef `json:"breachedPasswords,omitempty"`
+}
+
+// Actions of a SentinelPolicy rule
+const (
+	// PolicyActionEnforce rejects the Sentinel on admission and blocks its reconciliation
+	PolicyActionEnforce = "Enforce"
+	// PolicyActionWarn admits the Sentinel with a warning to the client
+	PolicyActionWarn = "Warn"
+	// PolicyActionAudit admits the Sentinel and only records the violation
+	PolicyActionAudit = "Audit"
+)
+
+// PolicyRule is a CEL expression a Sentinel has to satisfy. The expression has access to
+// object, the Sentinel, namespaceObject, its Namespace, and request.userInfo with the
+// username and groups of the requesting user. The userInfo is empty when the rule is
+// evaluated by the controller for an existing Sentinel.
+type PolicyRule struct {
+	// Name identifies the rule in the violations
+	Name string `json:"name"`
+
+	// Expression is a CEL expression that evaluates to true for a compliant Sentinel
+	Expression string `json:"expression"`
+
+	// Message is reported when the expression evaluates to false
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Action taken on a violation, one of Enforce, Warn and Audit
+	// +kubebuilder:validation:Enum=Enforce;Warn;Audit
+	// +kubebuilder:default=Enforce
+	// +optional
+	Action string `json:"action,omitempty"`
+}
+
+// PolicyViolation is a rule of a SentinelPolicy a Sentinel does not follow
+type PolicyViolation struct {
+	// Policy is the name of the SentinelPolicy
+	Policy string `json:"policy"`
+
+	// Rule is the name of the violated rule
+	Rule string `json:"rule"`
+
+	// Action of the rule, one of Enforce, Warn and Audit
+	Action string `json:"action"`
+
+	// Message describes the violation
+	Message string `json:"message"`
+}
+
+// SentinelPolicySpec defines the desired state of SentinelPolicy
+type SentinelPolicySpec struct {
+	// NamespaceSelector selects the namespaces of the Sentinels the policy applies to, all namespaces when empty
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Password defines the rules for the values of the Sentinel data
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Password *PasswordPolicy `json:"password,omitempty"`
+
+	// Rules are CEL expressions the Sentinels have to satisfy
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Rules []PolicyRule `json:"rules,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// SentinelPolicy is the Schema for the sentinelpolicies API. The policies are
+// enforced on admission and by the Sentinel controller for existing Sentinels.
+type SentinelPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SentinelPolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SentinelPolicyList contains a list of SentinelPolicy
+type SentinelPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SentinelPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SentinelPolicy{}, &SentinelPolicyList{})
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Policies of a SentinelRestore for objects that already exist
+const (
+	// RestoreExistingSkip leaves existing Sentinels and objects untouched
+	RestoreExistingSkip = "Skip"
+	// RestoreExistingOverwrite replaces existing Sentinels and objects with the backup
+	RestoreExistingOverwrite = "Overwrite"
+)
+
+// Phases of a SentinelRestore
+const (
+	RestorePhaseCompleted = "Completed"
+	RestorePhaseFailed    = "Failed"
+)
+
+// BackupDecryption defines the key a backup is opened with. Exactly one of IdentitySecretRef
+// and VaultTransit has to be set.
+type BackupDecryption struct {
+	// IdentitySecretRef selects a key of a Secret in the namespace of the operator that holds
+	// the base64 encoded X25519 private key of the recipient of the backup. Delete the Secret
+	// once the restore completed.
+	// +optional
+	IdentitySecretRef *corev1.SecretKeySelector `json:"identitySecretRef,omitempty"`
+
+	// VaultTransit unwraps the data key with the transit key the backup was encrypted with.
+	// The token secret has to live in the namespace of the operator.
+	// +optional
+	VaultTransit *VaultTransitSpec `json:"vaultTransit,omitempty"`
+}
+
+// SentinelRestoreSpec defines the desired state of SentinelRestore
+type SentinelRestoreSpec struct {
+	// Location the backup is read from
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Location BackupLocation `json:"location"`
+
+	// Backup is the name of the backup in the location, the latest backup whose signature
+	// verifies when empty
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Backup string `json:"backup,omitempty"`
+
+	// Decryption defines the key the backup is opened with
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Decryption BackupDecryption `json:"decryption"`
+
+	// SigningKeySecretRef selects a key of a Secret in the namespace of the operator that holds
+	// the key the backups were signed with, backups with another signature are refused
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	SigningKeySecretRef corev1.SecretKeySelector `json:"signingKeySecretRef"`
+
+	// Namespaces limits the restore to the Sentinels of these namespaces, all namespaces when empty
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// ExistingPolicy is Skip (the default) or Overwrite and decides about Sentinels that exist already
+	// +kubebuilder:validation:Enum=Skip;Overwrite
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	ExistingPolicy string `json:"existingPolicy,omitempty"`
+}
+
+// SentinelRestoreStatus defines the observed state of SentinelRestore
+type SentinelRestoreStatus struct {
+	// Phase is Completed or Failed once the restore ran, a restore runs only once
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Phase string `json:"phase,omitempty"`
+
+	// Message explains the phase
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Message string `json:"message,omitempty"`
+
+	// Backup is the name of the restored backup
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Backup string `json:"backup,omitempty"`
+
+	// Restored lists the restored Sentinels as <namespace>/<name>
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Restored []string `json:"restored,omitempty"`
+
+	// Skipped lists the Sentinels that existed already as <namespace>/<name>
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Skipped []string `json:"skipped,omitempty"`
+
+	// CompletionTime is the time the restore ran
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Backup",type=string,JSONPath=`.status.backup`
+//+kubebuilder:printcolumn:name="Completed",type=date,JSONPath=`.status.completionTime`
+
+// SentinelRestore is the Schema for the sentinelrestores API. It re-creates the Sentinels of
+// a signed and encrypted backup together with their secrets and the RBAC objects they owned.
+type SentinelRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SentinelRestoreSpec   `json:"spec,omitempty"`
+	Status SentinelRestoreStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SentinelRestoreList contains a list of SentinelRestore
+type SentinelRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SentinelRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SentinelRestore{}, &SentinelRestoreList{})
+}
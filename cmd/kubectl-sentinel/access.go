@@ -0,0 +1,93 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/analyzer"
+)
+
+// runAccess prints the subjects that can read the secret of a Sentinel, either from
+// its SentinelAccessReport or, with --live, from an analysis of the current cluster RBAC.
+func runAccess(opts *globalOptions, args []string) error {
+	fs := flag.NewFlagSet("access", flag.ExitOnError)
+	opts.bindFlags(fs)
+	live := fs.Bool("live", false, "Analyze the cluster RBAC now instead of reading the SentinelAccessReport.")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of exactly one Sentinel")
+	}
+
+	c, namespace, err := opts.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	name := args[0]
+
+	var subjects []secopsv1alpha1.SubjectAccess
+	if *live {
+		sentinel := &secopsv1alpha1.Sentinel{}
+		if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, sentinel); err != nil {
+			return err
+		}
+		snapshot, err := analyzer.Load(ctx, c)
+		if err != nil {
+			return err
+		}
+		for _, access := range snapshot.WhoCanReadSecret(namespace, sentinel.Spec.SecretName) {
+			subjects = append(subjects, secopsv1alpha1.SubjectAccess{
+				Kind:      access.Subject.Kind,
+				Name:      access.Subject.Name,
+				Namespace: access.Subject.Namespace,
+				Verbs:     access.Verbs,
+				Via:       access.Via,
+			})
+		}
+	} else {
+		report := &secopsv1alpha1.SentinelAccessReport{}
+		if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, report); err != nil {
+			return err
+		}
+		subjects = report.Status.Subjects
+	}
+
+	printSubjectAccesses(subjects)
+	return nil
+}
+
+func printSubjectAccesses(subjects []secopsv1alpha1.SubjectAccess) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAMESPACE\tNAME\tVERBS\tVIA")
+	for _, subject := range subjects {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", subject.Kind, subject.Namespace, subject.Name,
+			strings.Join(subject.Verbs, ","), strings.Join(subject.Via, ","))
+	}
+	w.Flush()
+}
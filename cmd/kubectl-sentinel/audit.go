@@ -0,0 +1,68 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+)
+
+// runAuditVerify checks the hash chain of audit log files of the operator. Rotated files are
+// passed oldest first, e.g. audit.log.2 audit.log.1 audit.log. No cluster access is needed.
+func runAuditVerify(_ *globalOptions, args []string) error {
+	fs := flag.NewFlagSet("audit-verify", flag.ExitOnError)
+	keyFile := fs.String("key-file", "", "File of the HMAC key of the audit log, the log is hashed with SHA-256 without one.")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("at least one audit file is required")
+	}
+
+	var key []byte
+	if *keyFile != "" {
+		raw, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		key = []byte(strings.TrimSpace(string(raw)))
+	}
+
+	var last *audit.Record
+	for _, name := range args {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		last, err = audit.Verify(file, key, last)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if last == nil {
+		fmt.Println("no audit records")
+		return nil
+	}
+	fmt.Printf("audit chain intact up to record %d (%s)\n", last.Sequence, last.Time.Format("2006-01-02T15:04:05Z07:00"))
+	return nil
+}
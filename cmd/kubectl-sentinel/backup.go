@@ -0,0 +1,58 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+// runBackupKey creates the X25519 key pair of the SentinelBackups. The public key goes into
+// spec.encryption.recipient, the private key is kept outside of the cluster until a restore.
+func runBackupKey(_ *globalOptions, args []string) error {
+	fs := flag.NewFlagSet("backup-key", flag.ExitOnError)
+	out := fs.String("out", "", "File the private key is written to.")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("--out is required")
+	}
+
+	private, public, err := envelope.GenerateX25519()
+	if err != nil {
+		return err
+	}
+	// O_EXCL keeps an existing key, overwriting it would make its backups unreadable
+	file, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(file, private); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "private key written to %s\n", *out)
+	fmt.Println(public)
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// runCreate creates a Sentinel from flags, the values are read from literals and files
+func runCreate(opts *globalOptions, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	opts.bindFlags(fs)
+	output := bindOutputFlag(fs, "")
+	data := keyValues{}
+	var approvers, approverGroups stringList
+	secretName := fs.String("secret-name", "", "Name of the secret the Sentinel manages, defaults to the name of the Sentinel.")
+	secretType := fs.String("type", "BaseSecret", "Secret type, one of BaseSecret, RbacBaseSecret, SecuredSecret, "+
+		"RbacSecuredSecret, KMSSecuredSecret and RbacKMSSecuredSecret.")
+	serviceAccount := fs.String("service-account", "", "ServiceAccount of the RBAC secured types.")
+	role := fs.String("role", "", "Role of the RBAC secured types.")
+	roleBinding := fs.String("role-binding", "", "RoleBinding of the RBAC secured types.")
+	createServiceAccount := fs.Bool("create-service-account", false, "Let the operator create and own the ServiceAccount.")
+	masterKey := fs.String("envelope-master-key", "", "SECRET/KEY holding the master key of the envelope encryption.")
+	dryRun := fs.Bool("dry-run", false, "Print the Sentinel instead of creating it.")
+	fs.Var(data, "from-literal", "KEY=VALUE to store in the secret, can be repeated.")
+	fs.Var(fromFiles(data), "from-file", "KEY=PATH to store the content of a file in the secret, can be repeated.")
+	fs.Var(&approvers, "approver", "User that may approve access requests, can be repeated.")
+	fs.Var(&approverGroups, "approver-group", "Group whose members may approve access requests, can be repeated.")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of exactly one Sentinel")
+	}
+	if *output != "" {
+		if err := validateOutput(*output); err != nil {
+			return err
+		}
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("at least one --from-literal or --from-file is required")
+	}
+
+	name := args[0]
+	sentinel := &secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: secopsv1alpha1.SentinelSpec{
+			SecretName:           *secretName,
+			SecretType:           *secretType,
+			Data:                 data,
+			ServiceAccount:       *serviceAccount,
+			Role:                 *role,
+			RoleBinding:          *roleBinding,
+			CreateServiceAccount: *createServiceAccount,
+			Approvers:            approvers,
+			ApproverGroups:       approverGroups,
+		},
+	}
+	if sentinel.Spec.SecretName == "" {
+		sentinel.Spec.SecretName = name
+	}
+	if *masterKey != "" {
+		secret, key, ok := strings.Cut(*masterKey, "/")
+		if !ok {
+			return fmt.Errorf("expected --envelope-master-key SECRET/KEY, got %q", *masterKey)
+		}
+		sentinel.Spec.Envelope = &secopsv1alpha1.EnvelopeSpec{MasterKeySecretRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: secret}, Key: key}}
+	}
+
+	if *dryRun {
+		if *output == "" || *output == outputTable {
+			*output = outputYAML
+		}
+		if opts.namespace != "" {
+			sentinel.Namespace = opts.namespace
+		}
+		return printOutput(*output, sentinel, nil)
+	}
+
+	c, namespace, err := opts.client()
+	if err != nil {
+		return err
+	}
+	sentinel.Namespace = namespace
+	if err := c.Create(context.Background(), sentinel); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		fmt.Printf("sentinel.%s/%s created\n", secopsv1alpha1.GroupVersion.Group, sentinel.Name)
+		return nil
+	}
+	return printOutput(*output, sentinel, func(w *tabwriter.Writer) { printSentinels(w, []secopsv1alpha1.Sentinel{*sentinel}, false) })
+}
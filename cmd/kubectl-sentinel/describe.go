@@ -0,0 +1,193 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// runDescribe prints a Sentinel with its conditions, the objects it manages, the subjects
+// with access to its secret and its recent events
+func runDescribe(opts *globalOptions, args []string) error {
+	fs := flag.NewFlagSet("describe", flag.ExitOnError)
+	opts.bindFlags(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of exactly one Sentinel")
+	}
+
+	c, namespace, err := opts.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := c.Get(ctx, types.NamespacedName{Name: args[0], Namespace: namespace}, sentinel); err != nil {
+		return err
+	}
+
+	w := newTabWriter(os.Stdout)
+	fmt.Fprintf(w, "Name:\t%s\n", sentinel.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", sentinel.Namespace)
+	fmt.Fprintf(w, "Secret:\t%s\n", sentinel.Spec.SecretName)
+	fmt.Fprintf(w, "Type:\t%s\n", sentinel.Spec.SecretType)
+	fmt.Fprintf(w, "Age:\t%s\n", age(sentinel.CreationTimestamp))
+	if envelope := sentinel.Spec.Envelope; envelope != nil {
+		switch {
+		case envelope.MasterKeySecretRef != nil:
+			fmt.Fprintf(w, "Envelope:\tmaster key %s/%s\n", envelope.MasterKeySecretRef.Name, envelope.MasterKeySecretRef.Key)
+		case envelope.VaultTransit != nil:
+			fmt.Fprintf(w, "Envelope:\tvault transit %s/%s\n", envelope.VaultTransit.Address, envelope.VaultTransit.KeyName)
+		}
+	}
+	if sentinel.Status.LastRotationTime != nil {
+		fmt.Fprintf(w, "Last Rotation:\t%s ago\n", age(*sentinel.Status.LastRotationTime))
+	}
+
+	fmt.Fprintln(w, "\nConditions:")
+	fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tAGE\tMESSAGE")
+	for _, condition := range sentinel.Status.Conditions {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason,
+			age(condition.LastTransitionTime), condition.Message)
+	}
+
+	fmt.Fprintln(w, "\nManaged Objects:")
+	fmt.Fprintln(w, "  KIND\tNAME\tSTATUS")
+	for _, managed := range managedObjects(sentinel) {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", managed.kind, managed.obj.GetName(), objectStatus(ctx, c, managed.obj))
+	}
+
+	if len(sentinel.Status.PolicyViolations) > 0 {
+		fmt.Fprintln(w, "\nPolicy Violations:")
+		fmt.Fprintln(w, "  POLICY\tRULE\tACTION\tMESSAGE")
+		for _, violation := range sentinel.Status.PolicyViolations {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", violation.Policy, violation.Rule, violation.Action, violation.Message)
+		}
+	}
+
+	if len(sentinel.Status.BreakGlassHistory) > 0 {
+		fmt.Fprintln(w, "\nBreak-Glass History:")
+		fmt.Fprintln(w, "  SUBJECT\tGRANTED\tEXPIRES\tPHASE\tJUSTIFICATION")
+		for _, record := range sentinel.Status.BreakGlassHistory {
+			fmt.Fprintf(w, "  %s/%s\t%s\t%s\t%s\t%s\n", record.Subject.Kind, record.Subject.Name,
+				record.GrantedAt.Format("2006-01-02 15:04"), record.ExpiresAt.Format("2006-01-02 15:04"),
+				record.Phase, record.Justification)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println("\nAccess:")
+	report := &secopsv1alpha1.SentinelAccessReport{}
+	if err := c.Get(ctx, types.NamespacedName{Name: sentinel.Name, Namespace: namespace}, report); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		fmt.Printf("  No SentinelAccessReport, run kubectl sentinel access %s --live\n", sentinel.Name)
+	} else {
+		printSubjectAccesses(report.Status.Subjects)
+	}
+
+	return printEvents(ctx, c, sentinel)
+}
+
+type managedObject struct {
+	kind string
+	obj  client.Object
+}
+
+// managedObjects lists the objects the spec of the Sentinel names
+func managedObjects(sentinel *secopsv1alpha1.Sentinel) []managedObject {
+	objects := []managedObject{}
+	add := func(kind string, name string, obj client.Object) {
+		if name == "" {
+			return
+		}
+		obj.SetName(name)
+		obj.SetNamespace(sentinel.Namespace)
+		objects = append(objects, managedObject{kind: kind, obj: obj})
+	}
+	add("Secret", sentinel.Spec.SecretName, &corev1.Secret{})
+	add("ServiceAccount", sentinel.Spec.ServiceAccount, &corev1.ServiceAccount{})
+	add("Role", sentinel.Spec.Role, &rbacv1.Role{})
+	add("RoleBinding", sentinel.Spec.RoleBinding, &rbacv1.RoleBinding{})
+	add("Secret", sentinel.Spec.ServiceAccountTokenSecret, &corev1.Secret{})
+	return objects
+}
+
+// objectStatus reports whether a managed object exists without printing its content
+func objectStatus(ctx context.Context, c client.Client, obj client.Object) string {
+	// Only the metadata is read, the values of the secret are never fetched here
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	metadata := &metav1.PartialObjectMetadata{}
+	metadata.SetGroupVersionKind(gvk)
+	err = c.Get(ctx, client.ObjectKeyFromObject(obj), metadata)
+	switch {
+	case err == nil:
+		return "Present"
+	case apierrors.IsNotFound(err):
+		return "Missing"
+	case apierrors.IsForbidden(err):
+		return "Forbidden"
+	}
+	return "Error: " + err.Error()
+}
+
+// printEvents prints the recent events of the Sentinel, oldest first
+func printEvents(ctx context.Context, c client.Client, sentinel *secopsv1alpha1.Sentinel) error {
+	events := &corev1.EventList{}
+	if err := c.List(ctx, events, client.InNamespace(sentinel.Namespace),
+		client.MatchingFields{"involvedObject.name": sentinel.Name, "involvedObject.kind": "Sentinel"}); err != nil {
+		if apierrors.IsForbidden(err) {
+			return nil
+		}
+		return err
+	}
+	sort.Slice(events.Items, func(i, j int) bool {
+		return events.Items[i].LastTimestamp.Before(&events.Items[j].LastTimestamp)
+	})
+
+	fmt.Println("\nEvents:")
+	w := newTabWriter(os.Stdout)
+	fmt.Fprintln(w, "  TYPE\tREASON\tAGE\tMESSAGE")
+	for _, event := range events.Items {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", event.Type, event.Reason, age(event.LastTimestamp), event.Message)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// runGet lists the Sentinels of the namespace, of all namespaces or a single one
+func runGet(opts *globalOptions, args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	opts.bindFlags(fs)
+	output := bindOutputFlag(fs, outputTable)
+	allNamespaces := fs.Bool("all-namespaces", false, "List the Sentinels of all namespaces.")
+	fs.BoolVar(allNamespaces, "A", false, "Shorthand for --all-namespaces.")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("expected at most one Sentinel name")
+	}
+
+	c, namespace, err := opts.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if len(args) == 1 {
+		sentinel := &secopsv1alpha1.Sentinel{}
+		if err := c.Get(ctx, types.NamespacedName{Name: args[0], Namespace: namespace}, sentinel); err != nil {
+			return err
+		}
+		return printOutput(*output, sentinel, func(w *tabwriter.Writer) {
+			printSentinels(w, []secopsv1alpha1.Sentinel{*sentinel}, false)
+		})
+	}
+
+	sentinels := &secopsv1alpha1.SentinelList{}
+	listOpts := []client.ListOption{}
+	if !*allNamespaces {
+		listOpts = append(listOpts, client.InNamespace(namespace))
+	}
+	if err := c.List(ctx, sentinels, listOpts...); err != nil {
+		return err
+	}
+	return printOutput(*output, sentinels, func(w *tabwriter.Writer) {
+		printSentinels(w, sentinels.Items, *allNamespaces)
+	})
+}
+
+func printSentinels(w *tabwriter.Writer, sentinels []secopsv1alpha1.Sentinel, withNamespace bool) {
+	if withNamespace {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "NAME\tSECRET\tTYPE\tAVAILABLE\tVIOLATIONS\tAGE")
+	for _, sentinel := range sentinels {
+		if withNamespace {
+			fmt.Fprintf(w, "%s\t", sentinel.Namespace)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", sentinel.Name, sentinel.Spec.SecretName, sentinel.Spec.SecretType,
+			conditionStatus(sentinel.Status.Conditions, "Available"), len(sentinel.Status.PolicyViolations),
+			age(sentinel.CreationTimestamp))
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+// keyOptions select the master key of the envelope encryption outside of the cluster
+type keyOptions struct {
+	masterKeyFile  string
+	vaultAddress   string
+	vaultMount     string
+	vaultKeyName   string
+	vaultTokenFile string
+}
+
+func (o *keyOptions) bindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.masterKeyFile, "master-key-file", "", "File holding the master key, raw or base64 encoded.")
+	fs.StringVar(&o.vaultAddress, "vault-address", os.Getenv("VAULT_ADDR"), "Address of Vault for the vault-transit provider.")
+	fs.StringVar(&o.vaultMount, "vault-mount", "transit", "Mount path of the Vault transit secrets engine.")
+	fs.StringVar(&o.vaultKeyName, "vault-key-name", "", "Name of the Vault transit key.")
+	fs.StringVar(&o.vaultTokenFile, "vault-token-file", "", "File holding the Vault token, defaults to the VAULT_TOKEN variable.")
+}
+
+// isSet reports whether a master key was given on the command line
+func (o *keyOptions) isSet() bool {
+	return o.masterKeyFile != "" || o.vaultKeyName != ""
+}
+
+// vaultToken reads the token from --vault-token-file or the VAULT_TOKEN variable
+func (o *keyOptions) vaultToken() (string, error) {
+	if o.vaultTokenFile == "" {
+		return os.Getenv("VAULT_TOKEN"), nil
+	}
+	token, err := os.ReadFile(o.vaultTokenFile)
+	return strings.TrimSpace(string(token)), err
+}
+
+func (o *keyOptions) keyWrapper() (envelope.KeyWrapper, error) {
+	if o.masterKeyFile != "" {
+		key, err := os.ReadFile(o.masterKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return envelope.NewMasterKey(key)
+	}
+	if o.vaultKeyName == "" {
+		return nil, fmt.Errorf("one of --master-key-file and --vault-key-name is required")
+	}
+	token, err := o.vaultToken()
+	if err != nil {
+		return nil, err
+	}
+	return &envelope.VaultTransit{Address: o.vaultAddress, Mount: o.vaultMount, KeyName: o.vaultKeyName, Token: token}, nil
+}
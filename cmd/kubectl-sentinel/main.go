@@ -0,0 +1,171 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-sentinel is a kubectl plugin for the Sentinel custom resources.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(secopsv1alpha1.AddToScheme(scheme))
+}
+
+// command is a subcommand of the plugin
+type command struct {
+	name  string
+	usage string
+	run   func(opts *globalOptions, args []string) error
+}
+
+var commands = []command{
+	{name: "create", usage: "create NAME --secret-name NAME --type TYPE --from-literal KEY=VALUE   create a Sentinel", run: runCreate},
+	{name: "get", usage: "get [NAME] [-A] [-o table|json|yaml]   list Sentinels", run: runGet},
+	{name: "describe", usage: "describe NAME   show the conditions, managed objects and access list of a Sentinel", run: runDescribe},
+	{name: "access", usage: "access NAME [--live]   list the subjects that can read the secret of a Sentinel", run: runAccess},
+	{name: "rotate", usage: "rotate NAME [--from-literal KEY=VALUE] [--generate KEY]   rotate the secret of a Sentinel", run: runRotate},
+	{name: "reveal", usage: "reveal NAME [KEY]   print the values of the secret of a Sentinel, recorded as an event", run: runReveal},
+	{name: "seal", usage: "seal --name NAME --from-literal KEY=VALUE --master-key-file FILE   encrypt values offline into a Secret", run: runSeal},
+	{name: "status", usage: "status NAME [--watch] [-o table|json|yaml]   show the status conditions of a Sentinel", run: runStatus},
+	{name: "backup-key", usage: "backup-key --out FILE   create the key pair of SentinelBackups, prints the public key", run: runBackupKey},
+	{name: "audit-verify", usage: "audit-verify FILE... [--key-file FILE]   check the hash chain of audit log files, oldest first", run: runAuditVerify},
+}
+
+// globalOptions are the flags shared by all subcommands
+type globalOptions struct {
+	kubeconfig string
+	context    string
+	namespace  string
+}
+
+func (o *globalOptions) bindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use.")
+	fs.StringVar(&o.context, "context", "", "The name of the kubeconfig context to use.")
+	fs.StringVar(&o.namespace, "namespace", "", "The namespace of the Sentinel, defaults to the namespace of the context.")
+	fs.StringVar(&o.namespace, "n", "", "Shorthand for --namespace.")
+}
+
+// clientConfig returns the kubeconfig loader honoring --kubeconfig and --context
+func (o *globalOptions) clientConfig() clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = o.kubeconfig
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: o.context})
+}
+
+// restConfig returns the rest config and the namespace to work in
+func (o *globalOptions) restConfig() (*rest.Config, string, error) {
+	clientConfig := o.clientConfig()
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	namespace := o.namespace
+	if namespace == "" {
+		if namespace, _, err = clientConfig.Namespace(); err != nil {
+			return nil, "", err
+		}
+	}
+	return config, namespace, nil
+}
+
+// client returns a controller-runtime client and the namespace to work in
+func (o *globalOptions) client() (client.Client, string, error) {
+	config, namespace, err := o.restConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	return c, namespace, err
+}
+
+// watchClient returns a client that can also watch and the namespace to work in
+func (o *globalOptions) watchClient() (client.WithWatch, string, error) {
+	config, namespace, err := o.restConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	c, err := client.NewWithWatch(config, client.Options{Scheme: scheme})
+	return c, namespace, err
+}
+
+// parseArgs parses the flags of a subcommand also when they follow its positional
+// arguments, like kubectl does, and returns the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		// Everything after -- is positional
+		if len(args) > len(rest) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: kubectl sentinel COMMAND [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n", cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags shared by all commands: --kubeconfig, --context, -n/--namespace\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+		if err := cmd.run(&globalOptions{}, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	usage()
+	os.Exit(2)
+}
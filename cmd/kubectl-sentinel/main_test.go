@@ -0,0 +1,54 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		positional []string
+		live       bool
+		data       keyValues
+	}{
+		{"flags first", []string{"--live", "db"}, []string{"db"}, true, keyValues{}},
+		{"flags after name", []string{"db", "--live", "--from-literal", "a=b"}, []string{"db"}, true, keyValues{"a": "b"}},
+		{"interspersed", []string{"db", "--from-literal=a=b=c", "key"}, []string{"db", "key"}, false, keyValues{"a": "b=c"}},
+		{"double dash", []string{"db", "--", "--live"}, []string{"db", "--live"}, false, keyValues{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet(tt.name, flag.ContinueOnError)
+			live := fs.Bool("live", false, "")
+			data := keyValues{}
+			fs.Var(data, "from-literal", "")
+
+			positional, err := parseArgs(fs, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(positional, tt.positional) || *live != tt.live || !reflect.DeepEqual(data, tt.data) {
+				t.Fatalf("got %v live=%v data=%v", positional, *live, data)
+			}
+		})
+	}
+}
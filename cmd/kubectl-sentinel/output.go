@@ -0,0 +1,164 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+// Output formats of the -o flag
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// bindOutputFlag adds -o/--output to a subcommand
+func bindOutputFlag(fs *flag.FlagSet, defaultFormat string) *string {
+	output := fs.String("output", defaultFormat, "Output format, one of table, json and yaml.")
+	fs.StringVar(output, "o", defaultFormat, "Shorthand for --output.")
+	return output
+}
+
+func validateOutput(output string) error {
+	switch output {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q, use table, json or yaml", output)
+}
+
+// printOutput prints the value as JSON or YAML, or calls table for the table format
+func printOutput(output string, value interface{}, table func(w *tabwriter.Writer)) error {
+	if obj, ok := value.(runtime.Object); ok {
+		setTypeMeta(obj)
+	}
+	switch output {
+	case outputJSON:
+		encoded, err := json.MarshalIndent(value, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(encoded))
+	case outputYAML:
+		encoded, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(encoded))
+	default:
+		w := newTabWriter(os.Stdout)
+		table(w)
+		return w.Flush()
+	}
+	return nil
+}
+
+// setTypeMeta fills the apiVersion and kind the typed client leaves empty, also on list items
+func setTypeMeta(obj runtime.Object) {
+	if gvk, err := apiutil.GVKForObject(obj, scheme); err == nil {
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+	}
+	if items, err := meta.ExtractList(obj); err == nil {
+		for _, item := range items {
+			setTypeMeta(item)
+		}
+		_ = meta.SetList(obj, items)
+	}
+}
+
+func newTabWriter(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+}
+
+// age formats the time since t like kubectl get
+func age(t metav1.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(t.Time))
+}
+
+// conditionStatus returns the status of a condition or Unknown when it is not set
+func conditionStatus(conditions []metav1.Condition, conditionType string) string {
+	if condition := meta.FindStatusCondition(conditions, conditionType); condition != nil {
+		return string(condition.Status)
+	}
+	return string(metav1.ConditionUnknown)
+}
+
+// keyValues collects repeated KEY=VALUE flags
+type keyValues map[string]string
+
+func (kv keyValues) String() string {
+	pairs := make([]string, 0, len(kv))
+	for key := range kv {
+		pairs = append(pairs, key+"=...")
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (kv keyValues) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected KEY=VALUE, got %q", value)
+	}
+	kv[key] = val
+	return nil
+}
+
+// fromFiles reads repeated KEY=PATH flags into keyValues
+type fromFiles keyValues
+
+func (f fromFiles) String() string { return keyValues(f).String() }
+
+func (f fromFiles) Set(value string) error {
+	key, path, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected KEY=PATH, got %q", value)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	f[key] = string(content)
+	return nil
+}
+
+// stringList collects a repeated flag
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
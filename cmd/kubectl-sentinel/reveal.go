@@ -0,0 +1,201 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	authenticationv1beta1 "k8s.io/api/authentication/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+// runReveal prints the values of the secret of a Sentinel. Every reveal is recorded as a
+// SecretRevealed event on the Sentinel first, the values are not printed when that fails.
+func runReveal(opts *globalOptions, args []string) error {
+	fs := flag.NewFlagSet("reveal", flag.ExitOnError)
+	opts.bindFlags(fs)
+	keys := &keyOptions{}
+	keys.bindFlags(fs)
+	output := bindOutputFlag(fs, outputTable)
+	reason := fs.String("reason", "", "Why the secret is revealed, recorded in the event.")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("expected the name of a Sentinel and optionally a key")
+	}
+
+	c, namespace, err := opts.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := c.Get(ctx, types.NamespacedName{Name: args[0], Namespace: namespace}, sentinel); err != nil {
+		return err
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: sentinel.Spec.SecretName, Namespace: namespace}, secret); err != nil {
+		return err
+	}
+
+	data := secret.Data
+	if envelope.IsSealed(data) {
+		wrapper, err := revealKeyWrapper(ctx, c, sentinel, keys)
+		if err != nil {
+			return err
+		}
+		if data, err = envelope.Open(ctx, wrapper, data); err != nil {
+			return err
+		}
+	}
+
+	revealed := sortedKeys(data)
+	if len(args) == 2 {
+		key := args[1]
+		if _, ok := data[key]; !ok {
+			return fmt.Errorf("key %s not found in secret %s", key, secret.Name)
+		}
+		revealed = []string{key}
+	}
+
+	if err := recordReveal(ctx, c, opts, sentinel, revealed, *reason); err != nil {
+		return fmt.Errorf("not revealing the secret, the audit event could not be recorded: %w", err)
+	}
+
+	if len(args) == 2 && *output == outputTable {
+		_, err := os.Stdout.Write(data[revealed[0]])
+		return err
+	}
+	values := map[string]string{}
+	for _, key := range revealed {
+		values[key] = string(data[key])
+	}
+	return printOutput(*output, values, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "KEY\tVALUE")
+		for _, key := range revealed {
+			fmt.Fprintf(w, "%s\t%s\n", key, values[key])
+		}
+	})
+}
+
+// revealKeyWrapper uses the master key given on the command line or the one the Sentinel names
+func revealKeyWrapper(ctx context.Context, c client.Client, sentinel *secopsv1alpha1.Sentinel, keys *keyOptions) (envelope.KeyWrapper, error) {
+	if keys.isSet() {
+		return keys.keyWrapper()
+	}
+	spec := sentinel.Spec.Envelope
+	switch {
+	case spec != nil && spec.MasterKeySecretRef != nil:
+		masterKey := &corev1.Secret{}
+		if err := c.Get(ctx, types.NamespacedName{Name: spec.MasterKeySecretRef.Name, Namespace: sentinel.Namespace}, masterKey); err != nil {
+			return nil, err
+		}
+		return envelope.NewMasterKey(masterKey.Data[spec.MasterKeySecretRef.Key])
+	case spec != nil && spec.VaultTransit != nil:
+		token, err := keys.vaultToken()
+		if err != nil {
+			return nil, err
+		}
+		return &envelope.VaultTransit{Address: spec.VaultTransit.Address, Mount: spec.VaultTransit.Mount,
+			KeyName: spec.VaultTransit.KeyName, Token: token}, nil
+	}
+	return nil, fmt.Errorf("secret %s is sealed, pass --master-key-file or --vault-key-name", sentinel.Spec.SecretName)
+}
+
+// recordReveal creates a SecretRevealed event on the Sentinel naming the user and the keys
+func recordReveal(ctx context.Context, c client.Client, opts *globalOptions, sentinel *secopsv1alpha1.Sentinel,
+	keys []string, reason string) error {
+
+	user := currentUser(ctx, c, opts)
+	message := fmt.Sprintf("Secret %s (keys %s) revealed to %s with kubectl-sentinel",
+		sentinel.Spec.SecretName, strings.Join(keys, ","), user)
+	if reason != "" {
+		message += ": " + reason
+	}
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", sentinel.Name, time.Now().UnixNano()),
+			Namespace: sentinel.Namespace,
+			Annotations: map[string]string{
+				secopsv1alpha1.RevealedByAnnotation:   user,
+				secopsv1alpha1.RevealedKeysAnnotation: strings.Join(keys, ","),
+			},
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: secopsv1alpha1.GroupVersion.String(),
+			Kind:       "Sentinel",
+			Name:       sentinel.Name,
+			Namespace:  sentinel.Namespace,
+			UID:        sentinel.UID,
+		},
+		Reason:         secopsv1alpha1.SecretRevealedReason,
+		Message:        message,
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: "kubectl-sentinel"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	return c.Create(ctx, event)
+}
+
+// currentUser asks the apiserver who the user is and falls back to the kubeconfig user
+func currentUser(ctx context.Context, c client.Client, opts *globalOptions) string {
+	review := &authenticationv1beta1.SelfSubjectReview{}
+	if err := c.Create(ctx, review); err == nil && review.Status.UserInfo.Username != "" {
+		return review.Status.UserInfo.Username
+	}
+	if raw, err := opts.clientConfig().RawConfig(); err == nil {
+		contextName := opts.context
+		if contextName == "" {
+			contextName = raw.CurrentContext
+		}
+		if kubeContext, ok := raw.Contexts[contextName]; ok && kubeContext.AuthInfo != "" {
+			return "kubeconfig user " + kubeContext.AuthInfo
+		}
+	}
+	return "an unknown user"
+}
+
+func sortedKeys(data map[string][]byte) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
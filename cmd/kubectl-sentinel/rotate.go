@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"math/big"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+const generatedAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// runRotate sets new values in the spec of a Sentinel and asks the operator to rewrite its secret
+func runRotate(opts *globalOptions, args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ExitOnError)
+	opts.bindFlags(fs)
+	data := keyValues{}
+	var generate stringList
+	fs.Var(data, "from-literal", "KEY=VALUE to set in the secret, can be repeated.")
+	fs.Var(fromFiles(data), "from-file", "KEY=PATH to set the content of a file in the secret, can be repeated.")
+	fs.Var(&generate, "generate", "KEY to set to a new random value, can be repeated.")
+	length := fs.Int("length", 32, "Length of the generated values.")
+	waitFor := fs.Duration("wait", 0, "Wait up to this long for the operator to rewrite the secret.")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of exactly one Sentinel")
+	}
+
+	c, namespace, err := opts.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := c.Get(ctx, types.NamespacedName{Name: args[0], Namespace: namespace}, sentinel); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(sentinel.DeepCopy())
+
+	for _, key := range generate {
+		if data[key], err = randomValue(*length); err != nil {
+			return err
+		}
+	}
+	if sentinel.Spec.Data == nil {
+		sentinel.Spec.Data = map[string]string{}
+	}
+	for key, value := range data {
+		sentinel.Spec.Data[key] = value
+	}
+
+	requestedAt := time.Now().UTC()
+	rotation := requestedAt.Format(time.RFC3339Nano)
+	if sentinel.Annotations == nil {
+		sentinel.Annotations = map[string]string{}
+	}
+	sentinel.Annotations[secopsv1alpha1.RotateAnnotation] = rotation
+	if err := c.Patch(ctx, sentinel, patch); err != nil {
+		return err
+	}
+	fmt.Printf("sentinel.%s/%s rotation %s requested\n", secopsv1alpha1.GroupVersion.Group, sentinel.Name, rotation)
+
+	if *waitFor == 0 {
+		return nil
+	}
+	err = wait.PollUntilContextTimeout(ctx, time.Second, *waitFor, true, func(ctx context.Context) (bool, error) {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(sentinel), sentinel); err != nil {
+			return false, err
+		}
+		rotated := sentinel.Status.LastRotationTime
+		return rotated != nil && !rotated.Time.Before(requestedAt.Truncate(time.Second)), nil
+	})
+	if err != nil {
+		return fmt.Errorf("secret of Sentinel %s not rotated: %w", sentinel.Name, err)
+	}
+	fmt.Printf("secret/%s rotated\n", sentinel.Spec.SecretName)
+	return nil
+}
+
+// randomValue returns a random alphanumeric value
+func randomValue(length int) (string, error) {
+	if length <= 0 {
+		return "", fmt.Errorf("length must be positive")
+	}
+	value := make([]byte, length)
+	for i := range value {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(generatedAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		value[i] = generatedAlphabet[n.Int64()]
+	}
+	return string(value), nil
+}
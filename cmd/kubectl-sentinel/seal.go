@@ -0,0 +1,76 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+// runSeal encrypts values offline with the envelope encryption and prints a Secret manifest
+// that sentinel-decrypt and kubectl sentinel reveal can open. No cluster access is needed.
+func runSeal(_ *globalOptions, args []string) error {
+	fs := flag.NewFlagSet("seal", flag.ExitOnError)
+	keys := &keyOptions{}
+	keys.bindFlags(fs)
+	output := bindOutputFlag(fs, outputYAML)
+	data := keyValues{}
+	name := fs.String("name", "", "Name of the Secret.")
+	namespace := fs.String("namespace", "", "Namespace of the Secret.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	fs.Var(data, "from-literal", "KEY=VALUE to seal, can be repeated.")
+	fs.Var(fromFiles(data), "from-file", "KEY=PATH to seal the content of a file, can be repeated.")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *output != outputJSON && *output != outputYAML {
+		return fmt.Errorf("seal prints json or yaml, not %q", *output)
+	}
+	if *name == "" {
+		return fmt.Errorf("--name is required")
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("at least one --from-literal or --from-file is required")
+	}
+
+	wrapper, err := keys.keyWrapper()
+	if err != nil {
+		return err
+	}
+	plaintext := map[string][]byte{}
+	for key, value := range data {
+		plaintext[key] = []byte(value)
+	}
+	sealed, err := envelope.Seal(context.Background(), wrapper, plaintext)
+	if err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: *name, Namespace: *namespace},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       sealed,
+	}
+	return printOutput(*output, secret, nil)
+}
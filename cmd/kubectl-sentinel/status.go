@@ -0,0 +1,128 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// runStatus prints the status conditions of a Sentinel, with --watch every change until interrupted
+func runStatus(opts *globalOptions, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	opts.bindFlags(fs)
+	output := bindOutputFlag(fs, outputTable)
+	watchStatus := fs.Bool("watch", false, "Print the status again whenever it changes.")
+	fs.BoolVar(watchStatus, "w", false, "Shorthand for --watch.")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of exactly one Sentinel")
+	}
+
+	c, namespace, err := opts.watchClient()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	name := args[0]
+
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, sentinel); err != nil {
+		return err
+	}
+	if err := printStatus(*output, sentinel, false); err != nil {
+		return err
+	}
+	if !*watchStatus {
+		return nil
+	}
+
+	last := sentinel.Status
+	resourceVersion := sentinel.ResourceVersion
+	for {
+		watcher, err := c.Watch(ctx, &secopsv1alpha1.SentinelList{}, client.InNamespace(namespace),
+			client.MatchingFields{"metadata.name": name}, &client.ListOptions{Raw: &metav1.ListOptions{ResourceVersion: resourceVersion}})
+		if err != nil {
+			return err
+		}
+		for event := range watcher.ResultChan() {
+			switch event.Type {
+			case watch.Deleted:
+				watcher.Stop()
+				fmt.Printf("sentinel.%s/%s deleted\n", secopsv1alpha1.GroupVersion.Group, name)
+				return nil
+			case watch.Added, watch.Modified:
+				current, ok := event.Object.(*secopsv1alpha1.Sentinel)
+				if !ok {
+					continue
+				}
+				resourceVersion = current.ResourceVersion
+				if equality.Semantic.DeepEqual(last, current.Status) {
+					continue
+				}
+				last = current.Status
+				if err := printStatus(*output, current, true); err != nil {
+					watcher.Stop()
+					return err
+				}
+			}
+		}
+		watcher.Stop()
+		if ctx.Err() != nil {
+			return nil
+		}
+		// The apiserver closes watches after a while, continue from the last seen version
+		time.Sleep(time.Second)
+	}
+}
+
+// printStatus prints the conditions of the Sentinel, a watch update is prefixed by its time
+func printStatus(output string, sentinel *secopsv1alpha1.Sentinel, update bool) error {
+	if update && output == outputYAML {
+		fmt.Println("---")
+	}
+	return printOutput(output, sentinel.Status, func(w *tabwriter.Writer) {
+		if update {
+			fmt.Fprintf(w, "\n%s\n", time.Now().Format(time.RFC3339))
+		}
+		fmt.Fprintln(w, "TYPE\tSTATUS\tREASON\tAGE\tMESSAGE")
+		for _, condition := range sentinel.Status.Conditions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason,
+				age(condition.LastTransitionTime), condition.Message)
+		}
+	})
+}
@@ -18,21 +18,35 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/atrest"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
 	"github.com/kavinduxo/sentinel-operator/internal/controller"
+	"github.com/kavinduxo/sentinel-operator/internal/notify"
+	"github.com/kavinduxo/sentinel-operator/internal/policy"
+	"github.com/kavinduxo/sentinel-operator/internal/scanner"
+	sentinelwebhook "github.com/kavinduxo/sentinel-operator/internal/webhook"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -52,11 +66,72 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var notificationWebhookURL string
+	var notifierNamespace string
+	var accessReportInterval time.Duration
+	var scanInterval time.Duration
+	var scanNamespaces string
+	var compareSentinelSecrets bool
+	var etcdConfig atrest.Config
+	var etcdEndpoints string
+	var dataFromFileRoot string
+	var allowedURLs string
+	var pushToFileRoot string
+	var decryptImage string
+	var agentImage string
+	var backupNamespace string
+	var backupVolumeRoot string
+	var auditOptions auditFlags
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&notificationWebhookURL, "notification-webhook-url", "",
+		"The URL every security relevant event such as break-glass access is posted to, in addition to the SentinelNotifiers.")
+	flag.StringVar(&notifierNamespace, "notifier-secret-namespace", os.Getenv("POD_NAMESPACE"),
+		"The namespace of the secrets referenced by the SentinelNotifiers, the namespace of the operator.")
+	flag.DurationVar(&accessReportInterval, "access-report-interval", 10*time.Minute,
+		"The interval the SentinelAccessReports are refreshed in.")
+	flag.DurationVar(&scanInterval, "scan-interval", time.Hour,
+		"The interval the security scanners run in.")
+	flag.StringVar(&scanNamespaces, "scan-namespaces", "",
+		"Comma separated namespaces the secret hygiene and leak scanners inspect, all namespaces when empty.")
+	flag.BoolVar(&compareSentinelSecrets, "leak-compare-sentinel-secrets", false,
+		"Report copies of the values of Sentinel managed secrets in pod templates and ConfigMaps.")
+	flag.StringVar(&etcdEndpoints, "etcd-endpoints", "",
+		"Comma separated etcd endpoints used to verify that secrets are encrypted at rest, the check is off when empty.")
+	flag.StringVar(&etcdConfig.CertFile, "etcd-cert", "", "The client certificate file for etcd.")
+	flag.StringVar(&etcdConfig.KeyFile, "etcd-key", "", "The client key file for etcd.")
+	flag.StringVar(&etcdConfig.CAFile, "etcd-ca", "", "The CA file that verifies the etcd server.")
+	flag.StringVar(&etcdConfig.Prefix, "etcd-prefix", "/registry", "The etcd prefix of the apiserver.")
+	flag.StringVar(&dataFromFileRoot, "data-from-file-root", "",
+		"The directory the file dataFrom sources of the Sentinels are read below, file sources are disabled when empty.")
+	flag.StringVar(&allowedURLs, "allowed-urls", "",
+		"Comma separated URLs below which the HTTP and Vault addresses and the replication servers of the Sentinels may be, for example https://vault.vault.svc:8200. Those sources and targets are disabled when empty.")
+	flag.StringVar(&pushToFileRoot, "push-to-file-root", "",
+		"The directory the file pushTo targets of the Sentinels are written below, file targets are disabled when empty.")
+	flag.StringVar(&decryptImage, "decrypt-image", "docker.io/kavinduxo/sentinel-operator-decrypt:0.0.1",
+		"The sentinel-decrypt image the pod injection webhook opens sealed secrets with.")
+	flag.StringVar(&agentImage, "agent-image", "docker.io/kavinduxo/sentinel-operator-agent:0.0.1",
+		"The sentinel-agent image the pod injection webhook adds as sidecar in the agent mode.")
+	flag.StringVar(&backupNamespace, "backup-secret-namespace", os.Getenv("POD_NAMESPACE"),
+		"The namespace of the secrets referenced by the SentinelBackups and SentinelRestores, the namespace of the operator.")
+	flag.StringVar(&backupVolumeRoot, "backup-volume-root", "",
+		"The directory the backup PersistentVolumeClaims are mounted below as <root>/<claim>, volume locations are disabled when empty.")
+	flag.StringVar(&auditOptions.file, "audit-file", "",
+		"The file the audit log of the secret operations is appended to.")
+	flag.Int64Var(&auditOptions.fileMaxMegabytes, "audit-file-max-size", 100,
+		"The size in megabytes the audit file is rotated at.")
+	flag.IntVar(&auditOptions.fileMaxBackups, "audit-file-max-backups", 10,
+		"The number of rotated audit files that are kept.")
+	flag.BoolVar(&auditOptions.stdout, "audit-stdout", false, "Write the audit log to stdout.")
+	flag.StringVar(&auditOptions.syslog, "audit-syslog", "",
+		"The syslog server the audit log is sent to as RFC 5424 messages, e.g. udp://syslog:514, tcp:// or tcp+tls://.")
+	flag.StringVar(&auditOptions.webhookURL, "audit-webhook-url", "",
+		"The URL every audit record is posted to.")
+	flag.StringVar(&auditOptions.keyFile, "audit-hmac-key-file", "",
+		"The file of the key the audit records are chained with as HMAC-SHA256, plain SHA-256 when empty.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,8 +140,18 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	auditLogger, err := auditOptions.logger()
+	if err != nil {
+		setupLog.Error(err, "unable to set up the audit log")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
+		Scheme: scheme,
+		// Events are only watched to record the reveals of secrets in the audit log
+		Cache: cache.Options{ByObject: map[client.Object]cache.ByObject{
+			&corev1.Event{}: {Field: controller.RevealEventSelector},
+		}},
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
@@ -89,13 +174,150 @@ func main() {
 		os.Exit(1)
 	}
 
+	var defaultNotifier notify.Notifier
+	if notificationWebhookURL != "" {
+		defaultNotifier = notify.NewWebhookNotifier(notificationWebhookURL)
+	}
+	notifier := notify.NewDispatcher(mgr.GetClient(), notifierNamespace, defaultNotifier)
+	if err := mgr.Add(notifier); err != nil {
+		setupLog.Error(err, "unable to add notification dispatcher")
+		os.Exit(1)
+	}
+
+	policyEvaluator, err := policy.NewEvaluator(mgr.GetAPIReader())
+	if err != nil {
+		setupLog.Error(err, "unable to create policy evaluator")
+		os.Exit(1)
+	}
+
+	var atRestVerifier *atrest.Verifier
+	if etcdEndpoints != "" {
+		etcdConfig.Endpoints = strings.Split(etcdEndpoints, ",")
+		if atRestVerifier, err = atrest.NewVerifier(etcdConfig); err != nil {
+			setupLog.Error(err, "unable to connect to etcd")
+			os.Exit(1)
+		}
+	}
+
+	var sentinelAllowedURLs []string
+	if allowedURLs != "" {
+		sentinelAllowedURLs = strings.Split(allowedURLs, ",")
+	}
+
 	if err = (&controller.SentinelReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("sentinel-controller"),
+		Notifier:       notifier,
+		Policy:         policyEvaluator,
+		AtRestVerifier: atRestVerifier,
+		Audit:          auditLogger,
+		// The file and HTTP sources read with the permissions of the operator, they are opt in
+		DataFromFileRoot: dataFromFileRoot,
+		AllowedURLs:      sentinelAllowedURLs,
+		PushToFileRoot:   pushToFileRoot,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Sentinel")
 		os.Exit(1)
 	}
+	if err = (&controller.SentinelAccessGrantReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("sentinelaccessgrant-controller"),
+		Audit:    auditLogger,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SentinelAccessGrant")
+		os.Exit(1)
+	}
+	if auditLogger != nil {
+		if err = (&controller.SecretRevealAuditReconciler{
+			Client: mgr.GetClient(),
+			Audit:  auditLogger,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "SecretRevealAudit")
+			os.Exit(1)
+		}
+	}
+	// The admission webhooks record who approved an access request, without them
+	// the approval annotations are set by the clients and decisions are refused
+	enableWebhooks := os.Getenv("ENABLE_WEBHOOKS") != "false"
+	if err = (&controller.SentinelAccessRequestReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Recorder:        mgr.GetEventRecorderFor("sentinelaccessrequest-controller"),
+		ApprovalWebhook: enableWebhooks,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SentinelAccessRequest")
+		os.Exit(1)
+	}
+	if err = (&controller.SentinelAccessReportReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Interval: accessReportInterval,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SentinelAccessReport")
+		os.Exit(1)
+	}
+	if err = (&controller.SentinelNotifierReconciler{
+		Client:    mgr.GetClient(),
+		Namespace: notifierNamespace,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SentinelNotifier")
+		os.Exit(1)
+	}
+	if err = (&controller.SentinelBackupReconciler{
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Recorder:   mgr.GetEventRecorderFor("sentinelbackup-controller"),
+		Audit:      auditLogger,
+		Namespace:  backupNamespace,
+		VolumeRoot: backupVolumeRoot,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SentinelBackup")
+		os.Exit(1)
+	}
+	if err = (&controller.SentinelRestoreReconciler{
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Recorder:   mgr.GetEventRecorderFor("sentinelrestore-controller"),
+		Audit:      auditLogger,
+		Namespace:  backupNamespace,
+		VolumeRoot: backupVolumeRoot,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SentinelRestore")
+		os.Exit(1)
+	}
+	var namespaces []string
+	if scanNamespaces != "" {
+		namespaces = strings.Split(scanNamespaces, ",")
+	}
+	if err := mgr.Add(&scanner.Runner{
+		Client: mgr.GetClient(),
+		Reader: mgr.GetAPIReader(),
+		Scanners: []scanner.Scanner{
+			&scanner.RBACScanner{},
+			&scanner.SecretHygieneScanner{Namespaces: namespaces},
+			&scanner.LeakScanner{Namespaces: namespaces, CompareSentinelSecrets: compareSentinelSecrets},
+		},
+		Interval: scanInterval,
+		Notifier: notifier,
+	}); err != nil {
+		setupLog.Error(err, "unable to add scanner")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		mgr.GetWebhookServer().Register(sentinelwebhook.AccessRequestPath, &webhook.Admission{
+			Handler: sentinelwebhook.NewAccessRequestApprover(mgr.GetClient(), mgr.GetScheme()),
+		})
+		policyValidator := sentinelwebhook.NewSentinelPolicyValidator(policyEvaluator, mgr.GetScheme())
+		policyValidator.Audit = auditLogger
+		mgr.GetWebhookServer().Register(sentinelwebhook.SentinelPolicyPath, &webhook.Admission{
+			Handler: policyValidator,
+		})
+		mgr.GetWebhookServer().Register(sentinelwebhook.PodInjectorPath, &webhook.Admission{
+			Handler: sentinelwebhook.NewPodInjector(mgr.GetClient(), mgr.GetScheme(), decryptImage, agentImage),
+		})
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -108,8 +330,79 @@ func main() {
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctrl.SetupSignalHandler())
+	if atRestVerifier != nil {
+		if closeErr := atRestVerifier.Close(); closeErr != nil {
+			setupLog.Error(closeErr, "unable to close the etcd connection")
+		}
+	}
+	if err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// auditFlags configures the sinks of the audit log
+type auditFlags struct {
+	file             string
+	fileMaxMegabytes int64
+	fileMaxBackups   int
+	stdout           bool
+	syslog           string
+	webhookURL       string
+	keyFile          string
+}
+
+// logger returns the audit logger of the configured sinks, nil without sinks. The chain continues
+// after the last record of the audit file.
+func (f *auditFlags) logger() (*audit.Logger, error) {
+	var sinks []audit.Sink
+	if f.file != "" {
+		sinks = append(sinks, &audit.FileSink{Path: f.file, MaxBytes: f.fileMaxMegabytes << 20, MaxBackups: f.fileMaxBackups})
+	}
+	if f.stdout {
+		sinks = append(sinks, audit.NewStdoutSink())
+	}
+	if f.syslog != "" {
+		address, err := url.Parse(f.syslog)
+		if err != nil || address.Host == "" {
+			return nil, fmt.Errorf("invalid syslog address %q", f.syslog)
+		}
+		switch address.Scheme {
+		case "udp", "tcp", "tcp+tls":
+		default:
+			return nil, fmt.Errorf("unsupported syslog transport %q", address.Scheme)
+		}
+		hostname, _ := os.Hostname()
+		sinks = append(sinks, &audit.SyslogSink{Network: address.Scheme, Address: address.Host,
+			Hostname: hostname, AppName: "sentinel-operator", Timeout: 5 * time.Second})
+	}
+	if f.webhookURL != "" {
+		sinks = append(sinks, audit.NewWebhookSink(f.webhookURL))
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+
+	var key []byte
+	if f.keyFile != "" {
+		raw, err := os.ReadFile(f.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key = []byte(strings.TrimSpace(string(raw))); len(key) == 0 {
+			return nil, fmt.Errorf("audit key file %s is empty", f.keyFile)
+		}
+	}
+	logger := audit.NewLogger(key, sinks...)
+	if f.file != "" {
+		last, err := audit.LastRecord(f.file)
+		if err != nil {
+			return nil, err
+		}
+		if last != nil {
+			logger.Resume(last)
+		}
+	}
+	return logger, nil
+}
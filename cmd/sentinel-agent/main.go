@@ -0,0 +1,156 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// sentinel-agent keeps the values of a Sentinel managed Secret up to date in a shared volume.
+// It runs as a sidecar with the ServiceAccount of the pod, watches the Secret, opens it when it
+// is sealed and replaces the files atomically. The application is told about the new values with
+// a signal or a request to a reload endpoint, so that it does not need a restart.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/kavinduxo/sentinel-operator/internal/agent"
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+// namespaceFile holds the namespace of the pod next to the ServiceAccount token
+const namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+func main() {
+	var (
+		namespace      string
+		secretName     string
+		output         string
+		fileMode       uint
+		masterKeyFile  string
+		vaultAddress   string
+		vaultMount     string
+		vaultKeyName   string
+		vaultTokenFile string
+		signalProcess  string
+		signalName     string
+		reloadURL      string
+		resyncInterval time.Duration
+		once           bool
+		timeout        time.Duration
+	)
+	flag.StringVar(&namespace, "namespace", podNamespace(), "Namespace of the Secret, defaults to the namespace of the pod.")
+	flag.StringVar(&secretName, "secret", "", "Name of the Secret managed by the Sentinel.")
+	flag.StringVar(&output, "output", "/run/sentinel/secrets", "Directory the values are written to, "+
+		"it should be an emptyDir with medium Memory.")
+	flag.UintVar(&fileMode, "file-mode", 0o440, "Permissions of the written files, use 0440 with an fsGroup "+
+		"when the application runs as another user.")
+	flag.StringVar(&masterKeyFile, "master-key-file", "", "File holding the master key of a sealed Secret, raw or base64 encoded.")
+	flag.StringVar(&vaultAddress, "vault-address", os.Getenv("VAULT_ADDR"), "Address of Vault for the vault-transit provider.")
+	flag.StringVar(&vaultMount, "vault-mount", "transit", "Mount path of the Vault transit secrets engine.")
+	flag.StringVar(&vaultKeyName, "vault-key-name", "", "Name of the Vault transit key of a sealed Secret.")
+	flag.StringVar(&vaultTokenFile, "vault-token-file", "", "File holding the Vault token, defaults to the VAULT_TOKEN variable.")
+	flag.StringVar(&signalProcess, "signal-process", "", "Name of the application process to signal after an update, "+
+		"the pod needs shareProcessNamespace and the agent the same user as the application.")
+	flag.StringVar(&signalName, "signal", "HUP", "Signal sent to the application process.")
+	flag.StringVar(&reloadURL, "reload-url", "", "URL the agent posts to after an update, e.g. http://localhost:9090/-/reload.")
+	flag.DurationVar(&resyncInterval, "resync-interval", time.Minute, "Wait before the Secret is read again after "+
+		"the watch failed.")
+	flag.BoolVar(&once, "once", false, "Write the Secret and exit, to run the agent as an init container.")
+	flag.DurationVar(&timeout, "timeout", 30*time.Second, "Timeout of the first write when --once is set.")
+	flag.Parse()
+
+	if secretName == "" || namespace == "" {
+		exit(2, fmt.Errorf("--secret and --namespace are required"))
+	}
+
+	var wrapper envelope.KeyWrapper
+	if masterKeyFile != "" || vaultKeyName != "" {
+		var err error
+		if wrapper, err = envelope.KeyWrapperFromFiles(masterKeyFile, vaultAddress, vaultMount, vaultKeyName, vaultTokenFile); err != nil {
+			exit(2, err)
+		}
+	}
+
+	var reloaders []agent.Reloader
+	if signalProcess != "" {
+		sig := unix.SignalNum("SIG" + strings.TrimPrefix(strings.ToUpper(signalName), "SIG"))
+		if sig == 0 {
+			exit(2, fmt.Errorf("Unknown signal %s", signalName))
+		}
+		reloaders = append(reloaders, &agent.SignalReloader{Process: signalProcess, Signal: sig})
+	}
+	if reloadURL != "" {
+		reloaders = append(reloaders, &agent.HTTPReloader{URL: reloadURL})
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		exit(2, err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		exit(2, err)
+	}
+
+	a := &agent.Agent{
+		Client:         client,
+		Namespace:      namespace,
+		SecretName:     secretName,
+		Dir:            output,
+		FileMode:       os.FileMode(fileMode),
+		Wrapper:        wrapper,
+		Reloaders:      reloaders,
+		ResyncInterval: resyncInterval,
+	}
+
+	if once {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if _, err := a.Sync(ctx); err != nil {
+			exit(1, err)
+		}
+		fmt.Printf("Wrote the Secret %s/%s into %s\n", namespace, secretName, output)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := a.Run(ctx); err != nil {
+		exit(1, err)
+	}
+}
+
+// podNamespace reads the namespace of the pod, POD_NAMESPACE takes precedence
+func podNamespace() string {
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	namespace, _ := os.ReadFile(namespaceFile)
+	return strings.TrimSpace(string(namespace))
+}
+
+func exit(code int, err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	os.Exit(code)
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// sentinel-api serves the UI and a REST API for Sentinels. It replaces the Node proxy of the UI,
+// authenticates every caller and impersonates them towards the apiserver. With --grpc-bind-address
+// it also serves the gRPC SentinelService to platform services that authenticate with mTLS.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/apiserver"
+	"github.com/kavinduxo/sentinel-operator/pkg/sentinelpb"
+)
+
+var (
+	scheme   = runtime.NewScheme()
+	setupLog = ctrl.Log.WithName("setup")
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(secopsv1alpha1.AddToScheme(scheme))
+}
+
+func main() {
+	var bindAddress string
+	var certFile string
+	var keyFile string
+	var staticDir string
+	var tokenReview bool
+	var tokenAudiences string
+	var oidcIssuerURL string
+	var oidcClientID string
+	var oidcClientSecretFile string
+	var oidcRedirectURL string
+	var insecureCookies bool
+	var statusStream bool
+	var grpcBindAddress string
+	var grpcClientCAFile string
+	oidcAuthenticator := &apiserver.OIDCAuthenticator{}
+	flag.StringVar(&bindAddress, "bind-address", ":8443", "The address the API server binds to.")
+	flag.StringVar(&certFile, "tls-cert-file", "", "The TLS certificate file, the server uses plain HTTP when empty.")
+	flag.StringVar(&keyFile, "tls-private-key-file", "", "The TLS private key file.")
+	flag.StringVar(&staticDir, "static-dir", "/ui", "The directory of the production build of the UI, not served when empty.")
+	flag.BoolVar(&tokenReview, "token-review", true, "Authenticate bearer tokens with a TokenReview.")
+	flag.StringVar(&tokenAudiences, "token-audiences", "", "Comma separated audiences the reviewed tokens must be issued for.")
+	flag.StringVar(&oidcIssuerURL, "oidc-issuer-url", "", "The OpenID Connect issuer, OIDC is off when empty.")
+	flag.StringVar(&oidcClientID, "oidc-client-id", "", "The client ID the ID tokens must be issued for.")
+	flag.StringVar(&oidcClientSecretFile, "oidc-client-secret-file", "", "The file holding the client secret for the browser login.")
+	flag.StringVar(&oidcRedirectURL, "oidc-redirect-url", "", "The URL of /auth/callback of this server, the browser login is off when empty.")
+	flag.StringVar(&oidcAuthenticator.UsernameClaim, "oidc-username-claim", "sub", "The claim of the ID token used as user name.")
+	flag.StringVar(&oidcAuthenticator.UsernamePrefix, "oidc-username-prefix", "", "The prefix of OIDC user names, use the one of the apiserver.")
+	flag.StringVar(&oidcAuthenticator.GroupsClaim, "oidc-groups-claim", "", "The claim of the ID token used as groups.")
+	flag.StringVar(&oidcAuthenticator.GroupsPrefix, "oidc-groups-prefix", "", "The prefix of OIDC groups, use the one of the apiserver.")
+	flag.BoolVar(&insecureCookies, "insecure-cookies", false, "Send the session cookie over plain HTTP, only for local development.")
+	flag.StringVar(&grpcBindAddress, "grpc-bind-address", "",
+		"The address the gRPC SentinelService binds to, the service is disabled when empty.")
+	flag.StringVar(&grpcClientCAFile, "grpc-client-ca-file", "",
+		"The CA bundle that verifies the client certificates of the gRPC SentinelService.")
+	flag.BoolVar(&statusStream, "status-stream", true,
+		"Serve the live status of Sentinels from a cache of Sentinels and their events.")
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	ctx := ctrl.SetupSignalHandler()
+	config := ctrl.GetConfigOrDie()
+
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		os.Exit(1)
+	}
+
+	var authenticators apiserver.Authenticators
+	var login *apiserver.OIDCLogin
+	if oidcIssuerURL != "" {
+		provider, err := oidc.NewProvider(ctx, oidcIssuerURL)
+		if err != nil {
+			setupLog.Error(err, "unable to discover the OIDC issuer")
+			os.Exit(1)
+		}
+		oidcAuthenticator.Verifier = provider.Verifier(&oidc.Config{ClientID: oidcClientID})
+		authenticators = append(authenticators, oidcAuthenticator)
+
+		if oidcRedirectURL != "" {
+			clientSecret, err := os.ReadFile(oidcClientSecretFile)
+			if err != nil {
+				setupLog.Error(err, "unable to read the OIDC client secret")
+				os.Exit(1)
+			}
+			login = apiserver.NewOIDCLogin(provider, oidcClientID, strings.TrimSpace(string(clientSecret)), oidcRedirectURL)
+			login.InsecureCookies = insecureCookies
+		}
+	}
+	if tokenReview {
+		reviewer := &apiserver.TokenReviewAuthenticator{Client: c}
+		if tokenAudiences != "" {
+			reviewer.Audiences = strings.Split(tokenAudiences, ",")
+		}
+		authenticators = append(authenticators, reviewer)
+	}
+	if len(authenticators) == 0 {
+		setupLog.Error(errors.New("no authenticator"), "enable --token-review or set --oidc-issuer-url")
+		os.Exit(1)
+	}
+
+	server, err := apiserver.New(config, scheme, authenticators)
+	if err != nil {
+		setupLog.Error(err, "unable to create API server")
+		os.Exit(1)
+	}
+	server.Login = login
+	server.StaticDir = staticDir
+	if statusStream {
+		streamer, err := apiserver.NewStreamer(config, scheme)
+		if err != nil {
+			setupLog.Error(err, "unable to create status streamer")
+			os.Exit(1)
+		}
+		go func() {
+			if err := streamer.Start(ctx); err != nil {
+				setupLog.Error(err, "problem running status streamer")
+				os.Exit(1)
+			}
+		}()
+		server.Streamer = streamer
+	}
+
+	if grpcBindAddress != "" {
+		grpcServer, err := newGRPCServer(c, server, certFile, keyFile, grpcClientCAFile)
+		if err != nil {
+			setupLog.Error(err, "unable to create gRPC server")
+			os.Exit(1)
+		}
+		listener, err := net.Listen("tcp", grpcBindAddress)
+		if err != nil {
+			setupLog.Error(err, "unable to listen for gRPC", "address", grpcBindAddress)
+			os.Exit(1)
+		}
+		go func() {
+			<-ctx.Done()
+			grpcServer.GracefulStop()
+		}()
+		go func() {
+			setupLog.Info("starting gRPC server", "address", grpcBindAddress)
+			if err := grpcServer.Serve(listener); err != nil {
+				setupLog.Error(err, "problem running gRPC server")
+				os.Exit(1)
+			}
+		}()
+	}
+
+	httpServer := &http.Server{
+		Addr:              bindAddress,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	setupLog.Info("starting API server", "address", bindAddress, "tls", certFile != "")
+	if certFile != "" {
+		err = httpServer.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		setupLog.Error(err, "problem running API server")
+		os.Exit(1)
+	}
+}
+
+// newGRPCServer returns the SentinelService that requires client certificates of the CA bundle and
+// impersonates its callers like the HTTP server
+func newGRPCServer(c client.Client, server *apiserver.Server, certFile, keyFile, clientCAFile string) (*grpc.Server, error) {
+	if certFile == "" || clientCAFile == "" {
+		return nil, errors.New("the gRPC server needs --tls-cert-file and --grpc-client-ca-file")
+	}
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(bundle) {
+		return nil, errors.New("no certificate in the client CA bundle")
+	}
+
+	service := &apiserver.GRPCServer{Client: c, ClientFor: server.ClientFor, Streamer: server.Streamer}
+	grpcServer := grpc.NewServer(service.ServerOptions(&tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	})...)
+	sentinelpb.RegisterSentinelServiceServer(grpcServer, service)
+	return grpcServer, nil
+}
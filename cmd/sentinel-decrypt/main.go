@@ -0,0 +1,70 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// sentinel-decrypt opens a Secret sealed by the envelope encryption of a Sentinel. It runs as
+// an init container, reads the sealed Secret from a volume and writes the plaintext values into
+// a memory backed emptyDir that is shared with the application containers.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+func main() {
+	var (
+		input          string
+		output         string
+		masterKeyFile  string
+		vaultAddress   string
+		vaultMount     string
+		vaultKeyName   string
+		vaultTokenFile string
+		fileMode       uint
+		timeout        time.Duration
+	)
+	flag.StringVar(&input, "input", "/etc/sentinel/sealed", "Directory the sealed Secret is mounted at.")
+	flag.StringVar(&output, "output", "/run/sentinel/secrets", "Directory the plaintext values are written to, "+
+		"it should be an emptyDir with medium Memory.")
+	flag.StringVar(&masterKeyFile, "master-key-file", "", "File holding the master key, raw or base64 encoded.")
+	flag.StringVar(&vaultAddress, "vault-address", os.Getenv("VAULT_ADDR"), "Address of Vault for the vault-transit provider.")
+	flag.StringVar(&vaultMount, "vault-mount", "transit", "Mount path of the Vault transit secrets engine.")
+	flag.StringVar(&vaultKeyName, "vault-key-name", "", "Name of the Vault transit key.")
+	flag.StringVar(&vaultTokenFile, "vault-token-file", "", "File holding the Vault token, defaults to the VAULT_TOKEN variable.")
+	flag.UintVar(&fileMode, "file-mode", 0o400, "Permissions of the written files, use 0440 with an fsGroup "+
+		"when the application runs as another user.")
+	flag.DurationVar(&timeout, "timeout", 30*time.Second, "Timeout to open the Secret.")
+	flag.Parse()
+
+	wrapper, err := envelope.KeyWrapperFromFiles(masterKeyFile, vaultAddress, vaultMount, vaultKeyName, vaultTokenFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := envelope.OpenDir(ctx, wrapper, input, output, os.FileMode(fileMode)); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Opened the sealed Secret in %s into %s\n", input, output)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package agent keeps the files of a Sentinel managed Secret up to date inside a pod. It runs
+// as a sidecar with the ServiceAccount of the pod, so it reads only what the Sentinel grants.
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+// defaultResyncInterval is used when the Agent has no ResyncInterval
+const defaultResyncInterval = time.Minute
+
+// Agent writes the data of a Secret into a directory whenever the Secret changes
+type Agent struct {
+	Client     kubernetes.Interface
+	Namespace  string
+	SecretName string
+	// Dir receives the files, an emptyDir with medium Memory shared with the application
+	Dir      string
+	FileMode os.FileMode
+	// Wrapper opens sealed Secrets, the Secret is written as it is when nil
+	Wrapper envelope.KeyWrapper
+	// Reloaders are notified after every update except the first one
+	Reloaders []Reloader
+	// ResyncInterval is the wait before the Secret is read again after the watch failed,
+	// it turns the agent into a poller when the ServiceAccount may not watch the Secret
+	ResyncInterval time.Duration
+
+	version string
+}
+
+// Sync writes the Secret when its resource version differs from the written one. It
+// reports whether the files changed.
+func (a *Agent) Sync(ctx context.Context) (bool, error) {
+	secret, err := a.Client.CoreV1().Secrets(a.Namespace).Get(ctx, a.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	return a.apply(ctx, secret)
+}
+
+// Run syncs the Secret and follows its changes until the context ends
+func (a *Agent) Run(ctx context.Context) error {
+	interval := a.ResyncInterval
+	if interval <= 0 {
+		interval = defaultResyncInterval
+	}
+
+	for {
+		if _, err := a.Sync(ctx); err != nil {
+			log.Printf("Failed to read secret %s/%s: %v", a.Namespace, a.SecretName, err)
+		} else if err := a.watch(ctx); err != nil {
+			log.Printf("Watch of secret %s/%s ended: %v", a.Namespace, a.SecretName, err)
+		} else {
+			// The apiserver closed the watch, start the next one right away
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// watch follows the Secret from the written version until the watch ends
+func (a *Agent) watch(ctx context.Context) error {
+	w, err := a.Client.CoreV1().Secrets(a.Namespace).Watch(ctx, metav1.ListOptions{
+		FieldSelector:   fields.OneTermEqualSelector("metadata.name", a.SecretName).String(),
+		ResourceVersion: a.version,
+	})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return nil
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				secret, ok := event.Object.(*corev1.Secret)
+				if !ok {
+					continue
+				}
+				if _, err := a.apply(ctx, secret); err != nil {
+					return err
+				}
+			case watch.Deleted:
+				// The application keeps the last data until the Sentinel recreates the Secret
+				log.Printf("Secret %s/%s was deleted, keeping the files", a.Namespace, a.SecretName)
+			case watch.Error:
+				return apierrors.FromObject(event.Object)
+			}
+		}
+	}
+}
+
+// apply writes a version of the Secret and notifies the reloaders
+func (a *Agent) apply(ctx context.Context, secret *corev1.Secret) (bool, error) {
+	if secret.ResourceVersion == a.version {
+		return false, nil
+	}
+
+	data := secret.Data
+	if envelope.IsSealed(data) {
+		if a.Wrapper == nil {
+			return false, fmt.Errorf("Secret %s/%s is sealed and no key is configured", a.Namespace, a.SecretName)
+		}
+		opened, err := envelope.Open(ctx, a.Wrapper, data)
+		if err != nil {
+			return false, err
+		}
+		data = opened
+	}
+	if err := envelope.WriteDirAtomic(a.Dir, data, a.FileMode); err != nil {
+		return false, err
+	}
+
+	first := a.version == ""
+	a.version = secret.ResourceVersion
+	log.Printf("Wrote version %s of secret %s/%s to %s", secret.ResourceVersion, a.Namespace, a.SecretName, a.Dir)
+	if first {
+		// The application reads the files when it starts
+		return true, nil
+	}
+	for _, reloader := range a.Reloaders {
+		if err := reloader.Reload(ctx); err != nil {
+			log.Printf("Reload failed: %v", err)
+		}
+	}
+	return true, nil
+}
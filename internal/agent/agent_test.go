@@ -0,0 +1,134 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+type countingReloader struct {
+	count int
+}
+
+func (c *countingReloader) Reload(_ context.Context) error {
+	c.count++
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	key, err := envelope.NewMasterKey([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := envelope.Seal(ctx, key, map[string][]byte{"password": []byte("one")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "apps", ResourceVersion: "1"},
+		Data:       sealed,
+	}
+	client := fake.NewSimpleClientset(secret)
+	reloader := &countingReloader{}
+	agent := &Agent{
+		Client:     client,
+		Namespace:  "apps",
+		SecretName: "db",
+		Dir:        t.TempDir(),
+		FileMode:   0o400,
+		Wrapper:    key,
+		Reloaders:  []Reloader{reloader},
+	}
+
+	assertPassword := func(want string) {
+		t.Helper()
+		password, err := os.ReadFile(filepath.Join(agent.Dir, "password"))
+		if err != nil || string(password) != want {
+			t.Fatalf("password %q, %v", password, err)
+		}
+	}
+
+	if changed, err := agent.Sync(ctx); err != nil || !changed {
+		t.Fatalf("first sync changed %v, %v", changed, err)
+	}
+	assertPassword("one")
+	if reloader.count != 0 {
+		t.Fatal("application reloaded after the first write")
+	}
+	if changed, err := agent.Sync(ctx); err != nil || changed {
+		t.Fatalf("sync of the same version changed %v, %v", changed, err)
+	}
+
+	secret.Data, err = envelope.Seal(ctx, key, map[string][]byte{"password": []byte("two")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.ResourceVersion = "2"
+	if _, err := client.CoreV1().Secrets("apps").Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := agent.Sync(ctx); err != nil || !changed {
+		t.Fatalf("sync of a new version changed %v, %v", changed, err)
+	}
+	assertPassword("two")
+	if reloader.count != 1 {
+		t.Fatalf("application reloaded %d times", reloader.count)
+	}
+
+	agent.Wrapper = nil
+	secret.ResourceVersion = "3"
+	if _, err := client.CoreV1().Secrets("apps").Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := agent.Sync(ctx); err == nil {
+		t.Fatal("sealed secret written without a key")
+	}
+	assertPassword("two")
+}
+
+func TestHTTPReloader(t *testing.T) {
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	reloader := &HTTPReloader{URL: server.URL + "/-/reload"}
+	if err := reloader.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	status = http.StatusInternalServerError
+	if err := reloader.Reload(context.Background()); err == nil {
+		t.Fatal("failed reload reported as success")
+	}
+}
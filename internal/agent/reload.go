@@ -0,0 +1,103 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Reloader tells the application that its secret files changed
+type Reloader interface {
+	Reload(ctx context.Context) error
+}
+
+// SignalReloader signals the processes with the given name. The pod needs
+// shareProcessNamespace, so that the sidecar sees the processes of the application.
+type SignalReloader struct {
+	// Process is the name of the executable as found in /proc/<pid>/comm
+	Process string
+	Signal  syscall.Signal
+	// ProcRoot defaults to /proc
+	ProcRoot string
+}
+
+// Reload implements Reloader
+func (s *SignalReloader) Reload(_ context.Context) error {
+	root := s.ProcRoot
+	if root == "" {
+		root = "/proc"
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	signaled := 0
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(root, entry.Name(), "comm"))
+		if err != nil || strings.TrimSpace(string(comm)) != s.Process {
+			continue
+		}
+		if err := syscall.Kill(pid, s.Signal); err != nil {
+			return fmt.Errorf("Failed to signal process %d: %w", pid, err)
+		}
+		signaled++
+	}
+	if signaled == 0 {
+		return fmt.Errorf("No process %s found, is shareProcessNamespace set on the pod?", s.Process)
+	}
+	return nil
+}
+
+// HTTPReloader posts to a reload endpoint of the application
+type HTTPReloader struct {
+	URL    string
+	Client *http.Client
+}
+
+// Reload implements Reloader
+func (h *HTTPReloader) Reload(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, nil)
+	if err != nil {
+		return err
+	}
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Reload hook %s returned %s", h.URL, response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package analyzer resolves the cluster RBAC to answer who can read a secret.
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReadVerbs are the verbs that expose the content of a secret
+var ReadVerbs = []string{"get", "list", "watch"}
+
+// Snapshot holds all RBAC objects of the cluster at one point in time
+type Snapshot struct {
+	Roles               []rbacv1.Role
+	ClusterRoles        []rbacv1.ClusterRole
+	RoleBindings        []rbacv1.RoleBinding
+	ClusterRoleBindings []rbacv1.ClusterRoleBinding
+
+	clusterRoleRules map[string][]rbacv1.PolicyRule
+}
+
+// SubjectAccess is a subject together with the verbs it holds on a secret
+// and the bindings the access is granted through.
+type SubjectAccess struct {
+	Subject rbacv1.Subject
+	Verbs   []string
+	Via     []string
+}
+
+// Load reads all Roles, ClusterRoles, RoleBindings and ClusterRoleBindings
+func Load(ctx context.Context, c client.Reader) (*Snapshot, error) {
+	roles := &rbacv1.RoleList{}
+	if err := c.List(ctx, roles); err != nil {
+		return nil, err
+	}
+	clusterRoles := &rbacv1.ClusterRoleList{}
+	if err := c.List(ctx, clusterRoles); err != nil {
+		return nil, err
+	}
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := c.List(ctx, roleBindings); err != nil {
+		return nil, err
+	}
+	clusterRoleBindings := &rbacv1.ClusterRoleBindingList{}
+	if err := c.List(ctx, clusterRoleBindings); err != nil {
+		return nil, err
+	}
+
+	return NewSnapshot(roles.Items, clusterRoles.Items, roleBindings.Items, clusterRoleBindings.Items), nil
+}
+
+// NewSnapshot returns a Snapshot of the given objects with aggregated ClusterRoles resolved
+func NewSnapshot(roles []rbacv1.Role, clusterRoles []rbacv1.ClusterRole,
+	roleBindings []rbacv1.RoleBinding, clusterRoleBindings []rbacv1.ClusterRoleBinding) *Snapshot {
+
+	s := &Snapshot{
+		Roles:               roles,
+		ClusterRoles:        clusterRoles,
+		RoleBindings:        roleBindings,
+		ClusterRoleBindings: clusterRoleBindings,
+		clusterRoleRules:    map[string][]rbacv1.PolicyRule{},
+	}
+	for i := range clusterRoles {
+		s.clusterRoleRules[clusterRoles[i].Name] = s.resolveClusterRole(&clusterRoles[i], map[string]bool{})
+	}
+	return s
+}
+
+// resolveClusterRole returns the own rules of a ClusterRole and the rules of every
+// ClusterRole selected by its aggregation rule. The aggregation controller usually
+// copies them, but a freshly created or tampered role may not reflect that yet.
+func (s *Snapshot) resolveClusterRole(clusterRole *rbacv1.ClusterRole, visited map[string]bool) []rbacv1.PolicyRule {
+	if visited[clusterRole.Name] {
+		return nil
+	}
+	visited[clusterRole.Name] = true
+
+	rules := append([]rbacv1.PolicyRule{}, clusterRole.Rules...)
+	if clusterRole.AggregationRule == nil {
+		return rules
+	}
+
+	for _, labelSelector := range clusterRole.AggregationRule.ClusterRoleSelectors {
+		selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
+		if err != nil {
+			continue
+		}
+		for i := range s.ClusterRoles {
+			aggregated := &s.ClusterRoles[i]
+			if aggregated.Name == clusterRole.Name || !selector.Matches(labels.Set(aggregated.Labels)) {
+				continue
+			}
+			rules = append(rules, s.resolveClusterRole(aggregated, visited)...)
+		}
+	}
+	return rules
+}
+
+// ClusterRoleRules returns the resolved rules of a ClusterRole
+func (s *Snapshot) ClusterRoleRules(name string) []rbacv1.PolicyRule {
+	return s.clusterRoleRules[name]
+}
+
+func (s *Snapshot) roleRules(namespace string, name string) []rbacv1.PolicyRule {
+	for i := range s.Roles {
+		if s.Roles[i].Namespace == namespace && s.Roles[i].Name == name {
+			return s.Roles[i].Rules
+		}
+	}
+	return nil
+}
+
+// WhoCanReadSecret returns every subject that can get, list or watch the secret
+func (s *Snapshot) WhoCanReadSecret(namespace string, name string) []SubjectAccess {
+	accesses := map[string]*SubjectAccess{}
+
+	grant := func(subjects []rbacv1.Subject, bindingNamespace string, verbs []string, via string) {
+		if len(verbs) == 0 {
+			return
+		}
+		for _, subject := range subjects {
+			if subject.Kind == rbacv1.ServiceAccountKind && subject.Namespace == "" {
+				subject.Namespace = bindingNamespace
+			}
+			key := fmt.Sprintf("%s/%s/%s", subject.Kind, subject.Namespace, subject.Name)
+			access, ok := accesses[key]
+			if !ok {
+				access = &SubjectAccess{Subject: subject}
+				accesses[key] = access
+			}
+			access.Verbs = union(access.Verbs, verbs)
+			access.Via = union(access.Via, []string{via})
+		}
+	}
+
+	for _, binding := range s.ClusterRoleBindings {
+		if binding.RoleRef.Kind != "ClusterRole" {
+			continue
+		}
+		verbs := SecretVerbs(s.ClusterRoleRules(binding.RoleRef.Name), name)
+		grant(binding.Subjects, "", verbs, fmt.Sprintf("ClusterRoleBinding/%s", binding.Name))
+	}
+
+	for _, binding := range s.RoleBindings {
+		if binding.Namespace != namespace {
+			continue
+		}
+		var rules []rbacv1.PolicyRule
+		switch binding.RoleRef.Kind {
+		case "ClusterRole":
+			rules = s.ClusterRoleRules(binding.RoleRef.Name)
+		case "Role":
+			rules = s.roleRules(binding.Namespace, binding.RoleRef.Name)
+		}
+		verbs := SecretVerbs(rules, name)
+		grant(binding.Subjects, binding.Namespace, verbs, fmt.Sprintf("RoleBinding/%s/%s", binding.Namespace, binding.Name))
+	}
+
+	result := make([]SubjectAccess, 0, len(accesses))
+	for _, access := range accesses {
+		sort.Strings(access.Verbs)
+		sort.Strings(access.Via)
+		result = append(result, *access)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i].Subject, result[j].Subject
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return result
+}
+
+// SecretVerbs returns the read verbs the rules allow on the named secret
+func SecretVerbs(rules []rbacv1.PolicyRule, secretName string) []string {
+	var verbs []string
+	for _, rule := range rules {
+		if !matches(rule.APIGroups, "") || !matches(rule.Resources, "secrets") {
+			continue
+		}
+		for _, verb := range ReadVerbs {
+			if !matches(rule.Verbs, verb) {
+				continue
+			}
+			// list and watch honor resourceNames through a metadata.name field selector
+			if len(rule.ResourceNames) > 0 && !contains(rule.ResourceNames, secretName) {
+				continue
+			}
+			verbs = union(verbs, []string{verb})
+		}
+	}
+	return verbs
+}
+
+func matches(values []string, value string) bool {
+	return contains(values, rbacv1.ResourceAll) || contains(values, value)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func union(a []string, b []string) []string {
+	for _, v := range b {
+		if !contains(a, v) {
+			a = append(a, v)
+		}
+	}
+	return a
+}
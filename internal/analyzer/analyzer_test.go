@@ -0,0 +1,120 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWhoCanReadSecret(t *testing.T) {
+	clusterRoles := []rbacv1.ClusterRole{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		},
+		{
+			// Aggregated role without the copied rules
+			ObjectMeta: metav1.ObjectMeta{Name: "secret-auditor"},
+			AggregationRule: &rbacv1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{
+				{MatchLabels: map[string]string{"aggregate-to-auditor": "true"}},
+			}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "secret-lister", Labels: map[string]string{"aggregate-to-auditor": "true"}},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"list"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "configmap-reader"},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}},
+		},
+	}
+	roles := []rbacv1.Role{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-reader", Namespace: "prod"},
+			Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"},
+				ResourceNames: []string{"db"}, Verbs: []string{"get"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "cache-reader", Namespace: "prod"},
+			Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"},
+				ResourceNames: []string{"cache"}, Verbs: []string{"get"}}},
+		},
+	}
+	clusterRoleBindings := []rbacv1.ClusterRoleBinding{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "admins"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:masters"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "config"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "configmap-reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "viewer"}},
+		},
+	}
+	roleBindings := []rbacv1.RoleBinding{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "prod"},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "db-reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "app"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "cache", Namespace: "prod"},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "cache-reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "cache"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "auditors", Namespace: "prod"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-auditor"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "auditor"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "auditors", Namespace: "dev"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-auditor"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "dev-auditor"}},
+		},
+	}
+
+	snapshot := NewSnapshot(roles, clusterRoles, roleBindings, clusterRoleBindings)
+	got := snapshot.WhoCanReadSecret("prod", "db")
+
+	want := []SubjectAccess{
+		{
+			Subject: rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "system:masters"},
+			Verbs:   []string{"get", "list", "watch"},
+			Via:     []string{"ClusterRoleBinding/admins"},
+		},
+		{
+			Subject: rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "app", Namespace: "prod"},
+			Verbs:   []string{"get"},
+			Via:     []string{"RoleBinding/prod/app"},
+		},
+		{
+			Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "auditor"},
+			Verbs:   []string{"list"},
+			Via:     []string{"RoleBinding/prod/auditors"},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("WhoCanReadSecret() =\n%+v\nwant\n%+v", got, want)
+	}
+}
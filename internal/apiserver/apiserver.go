@@ -0,0 +1,259 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package apiserver serves the UI and a narrow REST API for Sentinels. Every API request is
+// sent to the apiserver impersonating the authenticated caller, so RBAC applies per user.
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+const (
+	// APIPrefix is the path prefix of the REST API
+	APIPrefix = "/api/v1/"
+	// maxBodyBytes limits the size of a request body
+	maxBodyBytes = 1 << 20
+	// redacted replaces the values of spec.data in responses
+	redacted = "REDACTED"
+)
+
+// Server serves the UI and the REST API
+type Server struct {
+	// Authenticator identifies the callers of the API
+	Authenticator Authenticator
+	// Login is optional and lets browsers log in with OpenID Connect
+	Login *OIDCLogin
+	// StaticDir holds the production build of the UI, the UI is not served when empty
+	StaticDir string
+	// Streamer is optional and serves the status streams of Sentinels
+	Streamer *Streamer
+
+	// clientFor returns a client that impersonates the user
+	clientFor func(user *authenticationv1.UserInfo) (client.Client, error)
+}
+
+// New returns a Server that impersonates the callers with the identity of config, which
+// needs the impersonate verb on users, groups, uids and userextras
+func New(config *rest.Config, scheme *runtime.Scheme, authenticator Authenticator) (*Server, error) {
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+	// The RESTMapper is shared, discovery does not depend on the caller
+	mapper, err := apiutil.NewDynamicRESTMapper(config, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		Authenticator: authenticator,
+		clientFor: func(user *authenticationv1.UserInfo) (client.Client, error) {
+			impersonating := rest.CopyConfig(config)
+			impersonating.Impersonate = rest.ImpersonationConfig{
+				UserName: user.Username,
+				UID:      user.UID,
+				Groups:   user.Groups,
+				Extra:    map[string][]string{},
+			}
+			for key, values := range user.Extra {
+				impersonating.Impersonate.Extra[key] = values
+			}
+			return client.New(impersonating, client.Options{Scheme: scheme, Mapper: mapper})
+		},
+	}, nil
+}
+
+// ClientFor returns a client that impersonates the user
+func (s *Server) ClientFor(user *authenticationv1.UserInfo) (client.Client, error) {
+	return s.clientFor(user)
+}
+
+// Handler returns the routes of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(APIPrefix, s.serveAPI)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("ok")) })
+	if s.Login != nil {
+		mux.HandleFunc("/auth/login", s.Login.login)
+		mux.HandleFunc("/auth/callback", s.Login.callback)
+		mux.HandleFunc("/auth/logout", s.Login.logout)
+	}
+	if s.StaticDir != "" {
+		mux.HandleFunc("/", s.serveUI)
+	}
+	return mux
+}
+
+// serveAPI authenticates the caller and routes
+//
+//	GET    /api/v1/namespaces/{namespace}/sentinels
+//	POST   /api/v1/namespaces/{namespace}/sentinels
+//	GET    /api/v1/namespaces/{namespace}/sentinels/{name}
+//	GET    /api/v1/namespaces/{namespace}/sentinels/{name}/status
+//	DELETE /api/v1/namespaces/{namespace}/sentinels/{name}
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	user, err := s.authenticate(r)
+	if err != nil {
+		log.FromContext(r.Context()).Info("Authentication failed", "path", r.URL.Path, "reason", err.Error())
+		writeError(w, apierrors.NewUnauthorized("Authentication required"))
+		return
+	}
+	c, err := s.clientFor(user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// namespaces/{namespace}/sentinels[/{name}[/status|/watch]]
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, APIPrefix), "/"), "/")
+	if len(parts) < 3 || len(parts) > 5 || parts[0] != "namespaces" || parts[2] != "sentinels" || parts[1] == "" {
+		writeError(w, apierrors.NewNotFound(secopsv1alpha1.GroupVersion.WithResource("sentinels").GroupResource(), r.URL.Path))
+		return
+	}
+	handler := sentinelHandler{client: c, user: user, namespace: parts[1]}
+
+	switch {
+	case len(parts) == 3 && r.Method == http.MethodGet:
+		handler.list(w, r)
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		handler.create(w, r)
+	case len(parts) == 4 && r.Method == http.MethodGet:
+		handler.get(w, r, parts[3], false)
+	case len(parts) == 5 && parts[4] == "status" && r.Method == http.MethodGet:
+		handler.get(w, r, parts[3], true)
+	case len(parts) == 5 && parts[4] == "watch" && r.Method == http.MethodGet && s.Streamer != nil:
+		handler.watch(w, r, parts[3], s.Streamer)
+	case len(parts) == 4 && r.Method == http.MethodDelete:
+		handler.delete(w, r, parts[3])
+	default:
+		writeError(w, apierrors.NewMethodNotSupported(secopsv1alpha1.GroupVersion.WithResource("sentinels").GroupResource(), r.Method))
+	}
+}
+
+func (s *Server) authenticate(r *http.Request) (*authenticationv1.UserInfo, error) {
+	token, err := requestToken(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.Authenticator.Authenticate(r.Context(), token)
+}
+
+// serveUI serves the static build, unknown paths get index.html for the client side routing
+func (s *Server) serveUI(w http.ResponseWriter, r *http.Request) {
+	name := filepath.Join(s.StaticDir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+	if info, err := os.Stat(name); err != nil || info.IsDir() {
+		name = filepath.Join(s.StaticDir, "index.html")
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	http.ServeFile(w, r, name)
+}
+
+// writeJSON writes the value, objects get their apiVersion and kind
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes a metav1.Status like the apiserver does
+func writeError(w http.ResponseWriter, err error) {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		status = apierrors.NewInternalError(err)
+	}
+	apiStatus := status.Status()
+	apiStatus.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Status"}
+	code := int(apiStatus.Code)
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+	writeJSON(w, code, apiStatus)
+}
+
+// redact hides the values of the spec, only the keys are returned to the UI
+func redact(sentinel *secopsv1alpha1.Sentinel) {
+	for key := range sentinel.Spec.Data {
+		sentinel.Spec.Data[key] = redacted
+	}
+	sentinel.ManagedFields = nil
+	delete(sentinel.Annotations, "kubectl.kubernetes.io/last-applied-configuration")
+}
+
+// setKind fills the apiVersion and kind the typed client leaves empty
+func setKind(obj runtime.Object) {
+	gvk := secopsv1alpha1.GroupVersion.WithKind("Sentinel")
+	if _, ok := obj.(*secopsv1alpha1.SentinelList); ok {
+		gvk.Kind = "SentinelList"
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	if items, err := meta.ExtractList(obj); err == nil {
+		for _, item := range items {
+			setKind(item)
+		}
+	}
+}
+
+// decodeSentinel reads the Sentinel of a create request, only name, labels and spec are used
+func decodeSentinel(r *http.Request, namespace string) (*secopsv1alpha1.Sentinel, error) {
+	if mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";"); strings.TrimSpace(mediaType) != "application/json" {
+		// Requiring JSON also keeps cross-site form posts out
+		return nil, &apierrors.StatusError{ErrStatus: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusUnsupportedMediaType,
+			Reason:  metav1.StatusReasonUnsupportedMediaType,
+			Message: fmt.Sprintf("Content type %q is not supported, use application/json", mediaType),
+		}}
+	}
+	body := &secopsv1alpha1.Sentinel{}
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(body); err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("Invalid Sentinel: %v", err))
+	}
+	return &secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:         body.Name,
+			GenerateName: body.GenerateName,
+			Namespace:    namespace,
+			Labels:       body.Labels,
+		},
+		Spec: body.Spec,
+	}, nil
+}
@@ -0,0 +1,510 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/pkg/sentinelpb"
+)
+
+// tokens authenticates fixed tokens
+type tokens map[string]*authenticationv1.UserInfo
+
+func (t tokens) Authenticate(_ context.Context, token string) (*authenticationv1.UserInfo, error) {
+	if user, ok := t[token]; ok {
+		return user, nil
+	}
+	return nil, fmt.Errorf("unknown token")
+}
+
+func newTestServer(t *testing.T, impersonated *[]string) (*Server, client.Client) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(secopsv1alpha1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod"},
+		Spec:       secopsv1alpha1.SentinelSpec{SecretName: "db", SecretType: "BaseSecret", Data: map[string]string{"password": "s3cr3t"}},
+		Status: secopsv1alpha1.SentinelStatus{Conditions: []metav1.Condition{
+			{Type: "Available", Status: metav1.ConditionTrue, Reason: "Reconciling"}}},
+	}).Build()
+
+	return &Server{
+		Authenticator: tokens{"alice-token": {Username: "alice", Groups: []string{"dev"}}},
+		clientFor: func(user *authenticationv1.UserInfo) (client.Client, error) {
+			*impersonated = append(*impersonated, user.Username)
+			return c, nil
+		},
+	}, c
+}
+
+func TestSentinelAPI(t *testing.T) {
+	var impersonated []string
+	server, c := newTestServer(t, &impersonated)
+	handler := server.Handler()
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		token       string
+		contentType string
+		body        string
+		code        int
+		contains    string
+		excludes    string
+	}{
+		{name: "no token", method: http.MethodGet, path: "/api/v1/namespaces/prod/sentinels", code: http.StatusUnauthorized},
+		{name: "unknown token", method: http.MethodGet, path: "/api/v1/namespaces/prod/sentinels", token: "mallory", code: http.StatusUnauthorized},
+		{name: "list", method: http.MethodGet, path: "/api/v1/namespaces/prod/sentinels", token: "alice-token",
+			code: http.StatusOK, contains: `"kind":"SentinelList"`, excludes: "s3cr3t"},
+		{name: "get", method: http.MethodGet, path: "/api/v1/namespaces/prod/sentinels/db", token: "alice-token",
+			code: http.StatusOK, contains: redacted, excludes: "s3cr3t"},
+		{name: "status", method: http.MethodGet, path: "/api/v1/namespaces/prod/sentinels/db/status", token: "alice-token",
+			code: http.StatusOK, contains: `"type":"Available"`},
+		{name: "not found", method: http.MethodGet, path: "/api/v1/namespaces/prod/sentinels/other", token: "alice-token",
+			code: http.StatusNotFound, contains: `"reason":"NotFound"`},
+		{name: "unknown path", method: http.MethodGet, path: "/api/v1/namespaces/prod/secrets", token: "alice-token",
+			code: http.StatusNotFound},
+		{name: "create", method: http.MethodPost, path: "/api/v1/namespaces/prod/sentinels", token: "alice-token",
+			contentType: "application/json",
+			body:        `{"apiVersion":"secops.kavinduxo.com/v1alpha1","kind":"Sentinel","metadata":{"name":"api","namespace":"other"},"spec":{"secretName":"api","secretType":"BaseSecret","data":{"token":"abc123"}}}`,
+			code:        http.StatusCreated, contains: `"namespace":"prod"`, excludes: "abc123"},
+		{name: "create form post", method: http.MethodPost, path: "/api/v1/namespaces/prod/sentinels", token: "alice-token",
+			contentType: "application/x-www-form-urlencoded", body: "name=x", code: http.StatusUnsupportedMediaType},
+		{name: "create unknown field", method: http.MethodPost, path: "/api/v1/namespaces/prod/sentinels", token: "alice-token",
+			contentType: "application/json", body: `{"metadata":{"name":"x"},"spec":{"secretName":"x","secretType":"BaseSecret","bogus":1}}`,
+			code: http.StatusBadRequest},
+		{name: "delete", method: http.MethodDelete, path: "/api/v1/namespaces/prod/sentinels/db", token: "alice-token",
+			code: http.StatusOK, contains: `"status":"Success"`},
+		{name: "put", method: http.MethodPut, path: "/api/v1/namespaces/prod/sentinels/db", token: "alice-token",
+			code: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("code = %d, want %d: %s", rec.Code, tt.code, rec.Body.String())
+			}
+			if tt.contains != "" && !strings.Contains(rec.Body.String(), tt.contains) {
+				t.Fatalf("body does not contain %s: %s", tt.contains, rec.Body.String())
+			}
+			if tt.excludes != "" && strings.Contains(rec.Body.String(), tt.excludes) {
+				t.Fatalf("body contains %s: %s", tt.excludes, rec.Body.String())
+			}
+		})
+	}
+
+	created := &secopsv1alpha1.Sentinel{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "api", Namespace: "prod"}, created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Spec.Data["token"] != "abc123" {
+		t.Fatalf("stored data %v", created.Spec.Data)
+	}
+	for _, user := range impersonated {
+		if user != "alice" {
+			t.Fatalf("impersonated %s", user)
+		}
+	}
+}
+
+func TestSessionCookie(t *testing.T) {
+	var impersonated []string
+	server, _ := newTestServer(t, &impersonated)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/prod/sentinels", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: "alice-token"})
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || len(impersonated) != 1 {
+		t.Fatalf("code = %d, impersonated %v", rec.Code, impersonated)
+	}
+}
+
+func TestServeUI(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>sentinel</html>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{StaticDir: dir, Authenticator: tokens{}}
+	for _, path := range []string{"/", "/sentinels/new", "/static/js/missing.js"} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "sentinel") {
+			t.Fatalf("%s: code = %d, body %s", path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestTokenReviewAuthenticator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(_ context.Context, _ client.WithWatch, obj client.Object, _ ...client.CreateOption) error {
+			review := obj.(*authenticationv1.TokenReview)
+			if review.Spec.Token == "valid" {
+				review.Status = authenticationv1.TokenReviewStatus{Authenticated: true,
+					User: authenticationv1.UserInfo{Username: "system:serviceaccount:prod:ci"}}
+			}
+			return nil
+		},
+	}).Build()
+
+	reviewer := &TokenReviewAuthenticator{Client: c}
+	user, err := reviewer.Authenticate(context.Background(), "valid")
+	if err != nil || user.Username != "system:serviceaccount:prod:ci" {
+		t.Fatalf("user %v, %v", user, err)
+	}
+	if _, err := reviewer.Authenticate(context.Background(), "invalid"); err == nil {
+		t.Fatal("invalid token accepted")
+	}
+
+	encoded, _ := json.Marshal(user)
+	if !strings.Contains(string(encoded), "ci") {
+		t.Fatal(string(encoded))
+	}
+}
+
+func TestStatusStream(t *testing.T) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(secopsv1alpha1.AddToScheme(scheme))
+	sentinel := &secopsv1alpha1.Sentinel{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sentinel, &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "db.1", Namespace: "prod"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Sentinel", Namespace: "prod", Name: "db"},
+		Type:           corev1.EventTypeNormal, Reason: "SecretCreated", Message: "Created Secret db",
+	}).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if review, ok := obj.(*authorizationv1.SelfSubjectAccessReview); ok {
+				review.Status.Allowed = review.Spec.ResourceAttributes.Resource == "events"
+				return nil
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}).Build()
+
+	streamer := &Streamer{Reader: c}
+	server := &Server{
+		Authenticator: tokens{"alice-token": {Username: "alice"}},
+		Streamer:      streamer,
+		clientFor:     func(*authenticationv1.UserInfo) (client.Client, error) { return c, nil },
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	open := func(path string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer alice-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := open("/api/v1/namespaces/prod/sentinels/other/watch"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown Sentinel: %d", resp.StatusCode)
+	}
+
+	resp := open("/api/v1/namespaces/prod/sentinels/db/watch")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("%d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(resp.Body)
+	next := func(event string, contains string) {
+		t.Helper()
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("%v after %q", err, lines)
+			}
+			if line == "\n" {
+				break
+			}
+			lines = append(lines, strings.TrimSpace(line))
+		}
+		message := strings.Join(lines, "\n")
+		if !strings.HasPrefix(message, "event: "+event+"\n") || !strings.Contains(message, contains) {
+			t.Fatalf("expected %s with %s, got %q", event, contains, message)
+		}
+	}
+
+	next("status", `"phase":"Reconciling"`)
+	next("event", `"reason":"SecretCreated"`)
+
+	sentinel.Status.Conditions = []metav1.Condition{
+		{Type: "RBAC-Failed", Status: metav1.ConditionFalse, Reason: "Binding", Message: "Role missing",
+			LastTransitionTime: metav1.Now()},
+		{Type: "Available", Status: metav1.ConditionFalse, Reason: "Reconciling", Message: "Failed to bind"},
+	}
+	streamer.onSentinel(sentinel, false)
+	next("status", `"phase":"Failed"`)
+	streamer.onEvent(&corev1.Event{
+		InvolvedObject: corev1.ObjectReference{Kind: "Sentinel", Namespace: "prod", Name: "db"},
+		Type:           corev1.EventTypeWarning, Reason: "RoleBindingFailed", Message: "Role missing",
+	})
+	next("event", `"type":"Warning"`)
+	streamer.onSentinel(&secopsv1alpha1.Sentinel{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "prod"}}, true)
+	streamer.onSentinel(sentinel, true)
+	next("status", `"phase":"Deleted"`)
+	if _, err := reader.ReadString('\n'); err == nil {
+		t.Fatal("stream not closed after the deletion")
+	}
+}
+
+func TestGRPCServer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(secopsv1alpha1.AddToScheme(scheme))
+	// ci may manage Sentinels of prod and list findings, everyone else nothing
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&secopsv1alpha1.SentinelFinding{ObjectMeta: metav1.ObjectMeta{Name: "leak"},
+			Spec: secopsv1alpha1.SentinelFindingSpec{Severity: "Critical", Rule: "env-secret",
+				Resource: secopsv1alpha1.FindingResourceRef{Kind: "Deployment", Namespace: "prod", Name: "web"}}},
+		&secopsv1alpha1.SentinelFinding{ObjectMeta: metav1.ObjectMeta{Name: "rbac"},
+			Spec: secopsv1alpha1.SentinelFindingSpec{Severity: "Low", Rule: "wildcard",
+				Resource: secopsv1alpha1.FindingResourceRef{Kind: "Role", Namespace: "prod", Name: "admin"}}},
+	).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+				attributes := review.Spec.ResourceAttributes
+				review.Status.Allowed = review.Spec.User == "ci" && attributes.Group == secopsv1alpha1.GroupVersion.Group &&
+					(attributes.Namespace == "prod" || attributes.Resource == "sentinelfindings")
+				return nil
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+		// Findings are listed in pages of their names like the apiserver does
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			findings, ok := list.(*secopsv1alpha1.SentinelFindingList)
+			if !ok {
+				return c.List(ctx, list, opts...)
+			}
+			listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+			all := &secopsv1alpha1.SentinelFindingList{}
+			if err := c.List(ctx, all); err != nil {
+				return err
+			}
+			sort.Slice(all.Items, func(i, j int) bool { return all.Items[i].Name < all.Items[j].Name })
+			findings.Items, findings.Continue = nil, ""
+			for _, finding := range all.Items {
+				if finding.Name <= listOpts.Continue {
+					continue
+				}
+				if listOpts.Limit > 0 && int64(len(findings.Items)) == listOpts.Limit {
+					findings.Continue = findings.Items[len(findings.Items)-1].Name
+					break
+				}
+				findings.Items = append(findings.Items, finding)
+			}
+			return nil
+		},
+	}).Build()
+	var impersonated []string
+	server := &GRPCServer{Client: c, ClientFor: func(user *authenticationv1.UserInfo) (client.Client, error) {
+		impersonated = append(impersonated, user.Username)
+		return c, nil
+	}}
+	ci := context.WithValue(context.Background(), userKey{}, &authenticationv1.UserInfo{Username: "ci", Groups: []string{"platform"}})
+	mallory := context.WithValue(context.Background(), userKey{}, &authenticationv1.UserInfo{Username: "mallory"})
+
+	created, err := server.CreateSentinel(ci, &sentinelpb.CreateSentinelRequest{Sentinel: &sentinelpb.Sentinel{
+		Namespace: "prod", Name: "db",
+		Spec: &sentinelpb.SentinelSpec{SecretName: "db", SecretType: "BaseSecret", Data: map[string]string{"password": "s3cr3t"}},
+	}})
+	if err != nil || created.GetSpec().GetData()["password"] != redacted || created.GetStatus().GetPhase() != phaseReconciling {
+		t.Fatalf("create: %v %v", created, err)
+	}
+	if _, err := server.CreateSentinel(ci, &sentinelpb.CreateSentinelRequest{Sentinel: &sentinelpb.Sentinel{
+		Namespace: "kube-system", Name: "db", Spec: &sentinelpb.SentinelSpec{SecretName: "db"},
+	}}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("create in kube-system: %v", err)
+	}
+	if _, err := server.GetSentinel(mallory, &sentinelpb.GetSentinelRequest{Namespace: "prod", Name: "db"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("get as mallory: %v", err)
+	}
+	if _, err := server.GetSentinel(ci, &sentinelpb.GetSentinelRequest{Namespace: "prod", Name: "other"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("get unknown: %v", err)
+	}
+	if _, err := server.GetSentinel(context.Background(), &sentinelpb.GetSentinelRequest{Namespace: "prod", Name: "db"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("get without user: %v", err)
+	}
+
+	if _, err := server.RotateSecret(ci, &sentinelpb.RotateSecretRequest{Namespace: "prod", Name: "db",
+		GenerateKeys: []string{"password"}, Length: 20}); err != nil {
+		t.Fatal(err)
+	}
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "prod", Name: "db"}, sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if value := sentinel.Spec.Data["password"]; len(value) != 20 || value == "s3cr3t" || sentinel.Annotations[secopsv1alpha1.RotateAnnotation] == "" {
+		t.Fatalf("not rotated: %v %v", sentinel.Spec.Data, sentinel.Annotations)
+	}
+	if _, err := server.RotateSecret(ci, &sentinelpb.RotateSecretRequest{Namespace: "prod", Name: "db"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("rotate without data: %v", err)
+	}
+	if _, err := server.RotateSecret(ci, &sentinelpb.RotateSecretRequest{Namespace: "prod", Name: "db",
+		GenerateKeys: []string{"password"}, Length: 1 << 30}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("rotate with a huge length: %v", err)
+	}
+	// The writes were sent as the caller
+	if strings.Join(impersonated, ",") != "ci,ci" {
+		t.Errorf("impersonated %v", impersonated)
+	}
+
+	findings, err := server.ListFindings(ci, &sentinelpb.ListFindingsRequest{Namespace: "prod", Severities: []string{"Critical", "High"}})
+	if err != nil || len(findings.GetFindings()) != 1 || findings.GetFindings()[0].GetName() != "leak" {
+		t.Fatalf("findings: %v %v", findings, err)
+	}
+	// The filtered finding of the first listed page does not leave the page empty
+	findings, err = server.ListFindings(ci, &sentinelpb.ListFindingsRequest{Severities: []string{"Low"}, PageSize: 1})
+	if err != nil || len(findings.GetFindings()) != 1 || findings.GetFindings()[0].GetName() != "rbac" {
+		t.Fatalf("paged findings: %v %v", findings, err)
+	}
+	if _, err := server.ListFindings(mallory, &sentinelpb.ListFindingsRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("findings as mallory: %v", err)
+	}
+}
+
+// watchStream collects the messages sent on a WatchSentinel stream
+type watchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *sentinelpb.SentinelEvent
+}
+
+func (w *watchStream) Context() context.Context { return w.ctx }
+
+func (w *watchStream) Send(event *sentinelpb.SentinelEvent) error {
+	w.sent <- event
+	return nil
+}
+
+func TestGRPCWatchSentinel(t *testing.T) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(secopsv1alpha1.AddToScheme(scheme))
+	sentinel := &secopsv1alpha1.Sentinel{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod"}}
+	// Everyone may watch Sentinels, only ci may list events
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sentinel).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+				attributes := review.Spec.ResourceAttributes
+				review.Status.Allowed = attributes.Resource == "sentinels" ||
+					(review.Spec.User == "ci" && attributes.Resource == "events" && attributes.Group == "" && attributes.Verb == "list")
+				return nil
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}).Build()
+	streamer := &Streamer{Reader: c}
+	server := &GRPCServer{Client: c, Streamer: streamer}
+
+	watch := func(username string) []*sentinelpb.SentinelEvent {
+		t.Helper()
+		ctx := context.WithValue(context.Background(), userKey{}, &authenticationv1.UserInfo{Username: username})
+		stream := &watchStream{ctx: ctx, sent: make(chan *sentinelpb.SentinelEvent, 10)}
+		done := make(chan error)
+		go func() {
+			done <- server.WatchSentinel(&sentinelpb.WatchSentinelRequest{Namespace: "prod", Name: "db"}, stream)
+		}()
+
+		// The initial status is sent after the subscription
+		sent := []*sentinelpb.SentinelEvent{<-stream.sent}
+		streamer.onEvent(&corev1.Event{
+			InvolvedObject: corev1.ObjectReference{Kind: "Sentinel", Namespace: "prod", Name: "db"},
+			Type:           corev1.EventTypeWarning, Reason: "RoleBindingFailed", Message: "Role missing",
+		})
+		streamer.onSentinel(sentinel, true)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		close(stream.sent)
+		for event := range stream.sent {
+			sent = append(sent, event)
+		}
+		return sent
+	}
+
+	if sent := watch("ci"); len(sent) != 3 || sent[1].GetEvent().GetReason() != "RoleBindingFailed" || !sent[2].GetDeleted() {
+		t.Fatalf("watch as ci: %v", sent)
+	}
+	if sent := watch("viewer"); len(sent) != 2 || sent[0].GetStatus() == nil || !sent[1].GetDeleted() {
+		t.Fatalf("watch without access to events: %v", sent)
+	}
+}
+
+func TestAuthenticatePeer(t *testing.T) {
+	certificate := &x509.Certificate{Subject: pkix.Name{CommonName: "ci", Organization: []string{"platform"}}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{certificate}}}}})
+	ctx, err := authenticatePeer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user := ctx.Value(userKey{}).(*authenticationv1.UserInfo); user.Username != "ci" || user.Groups[0] != "platform" {
+		t.Fatalf("user %v", user)
+	}
+
+	unverified := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}})
+	if _, err := authenticatePeer(unverified); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("unverified peer: %v", err)
+	}
+}
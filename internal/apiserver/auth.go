@@ -0,0 +1,149 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// errNoCredentials is returned when the request carries neither a bearer token nor a session
+var errNoCredentials = errors.New("No credentials in the request")
+
+// Authenticator identifies the user of a request
+type Authenticator interface {
+	// Authenticate returns the user of the token, or an error when the token is not valid
+	Authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error)
+}
+
+// Authenticators accepts a token when any of its authenticators does, in order
+type Authenticators []Authenticator
+
+// Authenticate implements Authenticator
+func (a Authenticators) Authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	var errs []error
+	for _, authenticator := range a {
+		user, err := authenticator.Authenticate(ctx, token)
+		if err == nil {
+			return user, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// requestToken returns the bearer token of the request or the ID token of the session cookie
+func requestToken(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return "", fmt.Errorf("Authorization header is not a bearer token")
+		}
+		return token, nil
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+	return "", errNoCredentials
+}
+
+// TokenReviewAuthenticator asks the apiserver to review the token, so any token the cluster
+// accepts, such as ServiceAccount tokens, identifies the user
+type TokenReviewAuthenticator struct {
+	// Client creates the TokenReviews with the identity of the API server
+	Client client.Client
+	// Audiences the token must be issued for, the apiserver audience when empty
+	Audiences []string
+}
+
+// Authenticate implements Authenticator
+func (a *TokenReviewAuthenticator) Authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	review := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: a.Audiences}}
+	if err := a.Client.Create(ctx, review); err != nil {
+		return nil, err
+	}
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return nil, fmt.Errorf("Token rejected: %s", review.Status.Error)
+		}
+		return nil, fmt.Errorf("Token rejected")
+	}
+	return &review.Status.User, nil
+}
+
+// OIDCAuthenticator verifies ID tokens of an OpenID Connect issuer, with the same claim
+// mapping flags as the --oidc flags of the apiserver
+type OIDCAuthenticator struct {
+	Verifier *oidc.IDTokenVerifier
+	// UsernameClaim is the claim holding the user name, defaults to sub
+	UsernameClaim string
+	// UsernamePrefix is prepended to the user name to keep it apart from other users
+	UsernamePrefix string
+	// GroupsClaim is the claim holding the groups, no groups are mapped when empty
+	GroupsClaim string
+	// GroupsPrefix is prepended to every group
+	GroupsPrefix string
+}
+
+// Authenticate implements Authenticator
+func (a *OIDCAuthenticator) Authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	idToken, err := a.Verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string]interface{}{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	usernameClaim := a.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = "sub"
+	}
+	username, _ := claims[usernameClaim].(string)
+	if username == "" {
+		return nil, fmt.Errorf("Claim %s not found in the ID token", usernameClaim)
+	}
+	if usernameClaim == "email" {
+		// Like the apiserver, an unverified email is no identity
+		if verified, ok := claims["email_verified"].(bool); ok && !verified {
+			return nil, fmt.Errorf("Email %s of the ID token is not verified", username)
+		}
+	}
+
+	user := &authenticationv1.UserInfo{Username: a.UsernamePrefix + username, UID: idToken.Subject}
+	if a.GroupsClaim != "" {
+		switch groups := claims[a.GroupsClaim].(type) {
+		case string:
+			user.Groups = append(user.Groups, a.GroupsPrefix+groups)
+		case []interface{}:
+			for _, group := range groups {
+				if name, ok := group.(string); ok {
+					user.Groups = append(user.Groups, a.GroupsPrefix+name)
+				}
+			}
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,527 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"math/big"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/pkg/sentinelpb"
+)
+
+const (
+	// generatedAlphabet is the alphabet of the generated secret values
+	generatedAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// defaultGeneratedLength is the length of generated values when the request has none
+	defaultGeneratedLength = 32
+	// maxGeneratedLength is the longest value RotateSecret generates
+	maxGeneratedLength = 4096
+)
+
+type userKey struct{}
+
+// GRPCServer implements the SentinelService. Every call is reviewed with a SubjectAccessReview,
+// the writes are then sent impersonating the caller like the HTTP API does, so admission and
+// the audit log of the apiserver see the user.
+type GRPCServer struct {
+	sentinelpb.UnimplementedSentinelServiceServer
+
+	// Client acts with the identity of the API server
+	Client client.Client
+	// ClientFor returns a client that impersonates the user, see Server.ClientFor
+	ClientFor func(user *authenticationv1.UserInfo) (client.Client, error)
+	// Streamer is optional and serves WatchSentinel
+	Streamer *Streamer
+}
+
+// ServerOptions returns the options of a grpc.Server that requires a client certificate
+// verified by tlsConfig and authenticates the caller with it
+func (s *GRPCServer) ServerOptions(tlsConfig *tls.Config) []grpc.ServerOption {
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return []grpc.ServerOption{
+		grpc.Creds(credentials.NewTLS(tlsConfig)),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, err := authenticatePeer(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := authenticatePeer(stream.Context())
+			if err != nil {
+				return err
+			}
+			return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+		}),
+	}
+}
+
+// authenticatedStream carries the user in the context of the stream
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticatePeer reads the user of the verified client certificate like the apiserver does,
+// the common name is the user name and the organizations are the groups
+func authenticatePeer(ctx context.Context) (context.Context, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Client certificate required")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "Client certificate required")
+	}
+	subject := tlsInfo.State.VerifiedChains[0][0].Subject
+	if subject.CommonName == "" {
+		return nil, status.Error(codes.Unauthenticated, "Client certificate without common name")
+	}
+	user := &authenticationv1.UserInfo{Username: subject.CommonName, Groups: subject.Organization}
+	return context.WithValue(ctx, userKey{}, user), nil
+}
+
+// authorize reviews the access of the caller with a SubjectAccessReview
+func (s *GRPCServer) authorize(ctx context.Context, attributes authorizationv1.ResourceAttributes) error {
+	user, ok := ctx.Value(userKey{}).(*authenticationv1.UserInfo)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "Authentication required")
+	}
+	attributes.Group = secopsv1alpha1.GroupVersion.Group
+	attributes.Version = secopsv1alpha1.GroupVersion.Version
+	allowed, err := s.allowed(ctx, user, attributes)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "SubjectAccessReview Failed.")
+		return status.Error(codes.Internal, "Failed to authorize the request")
+	}
+	if !allowed {
+		return status.Errorf(codes.PermissionDenied, "User %q cannot %s %s in the namespace %q",
+			user.Username, attributes.Verb, attributes.Resource, attributes.Namespace)
+	}
+	return nil
+}
+
+// userClient returns the client impersonating the caller
+func (s *GRPCServer) userClient(ctx context.Context) (client.Client, error) {
+	user, ok := ctx.Value(userKey{}).(*authenticationv1.UserInfo)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Authentication required")
+	}
+	c, err := s.ClientFor(user)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Impersonating Client Creation Failed.")
+		return nil, status.Error(codes.Internal, "Failed to impersonate the caller")
+	}
+	return c, nil
+}
+
+// allowed asks the apiserver whether the user may access the resource of the attributes
+func (s *GRPCServer) allowed(ctx context.Context, user *authenticationv1.UserInfo,
+	attributes authorizationv1.ResourceAttributes) (bool, error) {
+
+	review := &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+		ResourceAttributes: &attributes,
+		User:               user.Username,
+		Groups:             user.Groups,
+	}}
+	if err := s.Client.Create(ctx, review); err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
+
+// CreateSentinel creates a Sentinel from the namespace, name, labels and spec of the request
+func (s *GRPCServer) CreateSentinel(ctx context.Context, req *sentinelpb.CreateSentinelRequest) (*sentinelpb.Sentinel, error) {
+	in := req.GetSentinel()
+	if in.GetNamespace() == "" || in.GetName() == "" || in.GetSpec() == nil {
+		return nil, status.Error(codes.InvalidArgument, "Namespace, name and spec of the Sentinel are required")
+	}
+	if err := s.authorize(ctx, authorizationv1.ResourceAttributes{
+		Verb: "create", Resource: "sentinels", Namespace: in.GetNamespace()}); err != nil {
+		return nil, err
+	}
+	c, err := s.userClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sentinel := &secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{Namespace: in.GetNamespace(), Name: in.GetName(), Labels: in.GetLabels()},
+		Spec:       sentinelSpecFromProto(in.GetSpec()),
+	}
+	if err := c.Create(ctx, sentinel); err != nil {
+		return nil, grpcError(err)
+	}
+	return sentinelToProto(sentinel), nil
+}
+
+// GetSentinel returns a Sentinel with the values of its data redacted
+func (s *GRPCServer) GetSentinel(ctx context.Context, req *sentinelpb.GetSentinelRequest) (*sentinelpb.Sentinel, error) {
+	if err := s.authorize(ctx, authorizationv1.ResourceAttributes{
+		Verb: "get", Resource: "sentinels", Namespace: req.GetNamespace(), Name: req.GetName()}); err != nil {
+		return nil, err
+	}
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: req.GetNamespace(), Name: req.GetName()}, sentinel); err != nil {
+		return nil, grpcError(err)
+	}
+	return sentinelToProto(sentinel), nil
+}
+
+// WatchSentinel streams the status and the events of a Sentinel until it is deleted. The caller
+// must be able to watch the Sentinel, events are only sent when the caller may list them.
+func (s *GRPCServer) WatchSentinel(req *sentinelpb.WatchSentinelRequest, stream sentinelpb.SentinelService_WatchSentinelServer) error {
+	ctx := stream.Context()
+	if s.Streamer == nil {
+		return status.Error(codes.Unimplemented, "Status streams are disabled")
+	}
+	if err := s.authorize(ctx, authorizationv1.ResourceAttributes{
+		Verb: "watch", Resource: "sentinels", Namespace: req.GetNamespace(), Name: req.GetName()}); err != nil {
+		return err
+	}
+	user := ctx.Value(userKey{}).(*authenticationv1.UserInfo)
+	includeEvents, err := s.allowed(ctx, user, authorizationv1.ResourceAttributes{
+		Verb: "list", Resource: "events", Namespace: req.GetNamespace()})
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to review the access to events")
+	}
+
+	key := types.NamespacedName{Namespace: req.GetNamespace(), Name: req.GetName()}
+	messages, unsubscribe := s.Streamer.subscribe(key)
+	defer unsubscribe()
+
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := s.Client.Get(ctx, key, sentinel); err != nil {
+		return grpcError(err)
+	}
+	if err := stream.Send(&sentinelpb.SentinelEvent{Change: &sentinelpb.SentinelEvent_Status{
+		Status: sentinelStatusToProto(statusUpdate(sentinel), sentinel.Status.LastRotationTime)}}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case message := <-messages:
+			event := &sentinelpb.SentinelEvent{}
+			switch data := message.data.(type) {
+			case StatusUpdate:
+				if data.Phase == phaseDeleted {
+					return stream.Send(&sentinelpb.SentinelEvent{Change: &sentinelpb.SentinelEvent_Deleted{Deleted: true}})
+				}
+				event.Change = &sentinelpb.SentinelEvent_Status{Status: sentinelStatusToProto(data, nil)}
+			case EventUpdate:
+				if !includeEvents {
+					continue
+				}
+				event.Change = &sentinelpb.SentinelEvent_Event{Event: &sentinelpb.Event{
+					Type: data.Type, Reason: data.Reason, Message: data.Message, Count: data.Count,
+					Time: timestamp(&data.Time)}}
+			default:
+				continue
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// RotateSecret sets keys of the data and requests a rotation of the secret like kubectl-sentinel rotate
+func (s *GRPCServer) RotateSecret(ctx context.Context, req *sentinelpb.RotateSecretRequest) (*sentinelpb.Sentinel, error) {
+	if len(req.GetData()) == 0 && len(req.GetGenerateKeys()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Data or generate keys are required")
+	}
+	if err := s.authorize(ctx, authorizationv1.ResourceAttributes{
+		Verb: "patch", Resource: "sentinels", Namespace: req.GetNamespace(), Name: req.GetName()}); err != nil {
+		return nil, err
+	}
+	length := int(req.GetLength())
+	if length == 0 {
+		length = defaultGeneratedLength
+	}
+	if length < 0 || length > maxGeneratedLength {
+		return nil, status.Errorf(codes.InvalidArgument, "Length must be between 1 and %d", maxGeneratedLength)
+	}
+	c, err := s.userClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: req.GetNamespace(), Name: req.GetName()}, sentinel); err != nil {
+		return nil, grpcError(err)
+	}
+	patch := client.MergeFrom(sentinel.DeepCopy())
+	if sentinel.Spec.Data == nil {
+		sentinel.Spec.Data = map[string]string{}
+	}
+	for key, value := range req.GetData() {
+		sentinel.Spec.Data[key] = value
+	}
+	for _, key := range req.GetGenerateKeys() {
+		value, err := randomValue(length)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		sentinel.Spec.Data[key] = value
+	}
+	if sentinel.Annotations == nil {
+		sentinel.Annotations = map[string]string{}
+	}
+	sentinel.Annotations[secopsv1alpha1.RotateAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+	if err := c.Patch(ctx, sentinel, patch); err != nil {
+		return nil, grpcError(err)
+	}
+	return sentinelToProto(sentinel), nil
+}
+
+// ListFindings lists the SentinelFindings, optionally of one namespace and of some severities. The
+// filters are applied to the listed pages, so pages are listed until the page size is filled.
+func (s *GRPCServer) ListFindings(ctx context.Context, req *sentinelpb.ListFindingsRequest) (*sentinelpb.ListFindingsResponse, error) {
+	if err := s.authorize(ctx, authorizationv1.ResourceAttributes{Verb: "list", Resource: "sentinelfindings"}); err != nil {
+		return nil, err
+	}
+	severities := map[string]bool{}
+	for _, severity := range req.GetSeverities() {
+		severities[severity] = true
+	}
+
+	resp := &sentinelpb.ListFindingsResponse{NextPageToken: req.GetPageToken()}
+	for {
+		findings := &secopsv1alpha1.SentinelFindingList{}
+		opts := []client.ListOption{client.Continue(resp.NextPageToken)}
+		if req.GetPageSize() > 0 {
+			// Never list more than the page still takes, the continue token then resumes exactly
+			opts = append(opts, client.Limit(int64(int(req.GetPageSize())-len(resp.Findings))))
+		}
+		if err := s.Client.List(ctx, findings, opts...); err != nil {
+			return nil, grpcError(err)
+		}
+		resp.NextPageToken = findings.Continue
+		for i := range findings.Items {
+			if finding := &findings.Items[i]; findingMatches(finding, req.GetNamespace(), severities) {
+				resp.Findings = append(resp.Findings, findingToProto(finding))
+			}
+		}
+		if resp.NextPageToken == "" || (req.GetPageSize() > 0 && len(resp.Findings) >= int(req.GetPageSize())) {
+			return resp, nil
+		}
+	}
+}
+
+func findingMatches(finding *secopsv1alpha1.SentinelFinding, namespace string, severities map[string]bool) bool {
+	if namespace != "" && finding.Spec.Resource.Namespace != namespace {
+		return false
+	}
+	return len(severities) == 0 || severities[finding.Spec.Severity]
+}
+
+func findingToProto(finding *secopsv1alpha1.SentinelFinding) *sentinelpb.Finding {
+	return &sentinelpb.Finding{
+		Name:     finding.Name,
+		Scanner:  finding.Spec.Scanner,
+		Rule:     finding.Spec.Rule,
+		Severity: finding.Spec.Severity,
+		Resource: &sentinelpb.FindingResource{
+			ApiVersion: finding.Spec.Resource.APIVersion,
+			Kind:       finding.Spec.Resource.Kind,
+			Namespace:  finding.Spec.Resource.Namespace,
+			Name:       finding.Spec.Resource.Name,
+			FieldPath:  finding.Spec.Resource.FieldPath,
+		},
+		Message:     finding.Spec.Message,
+		Remediation: finding.Spec.Remediation,
+		FirstSeen:   timestamp(finding.Status.FirstSeen),
+		LastSeen:    timestamp(finding.Status.LastSeen),
+	}
+}
+
+// grpcError maps the errors of the Kubernetes API to gRPC status codes
+func grpcError(err error) error {
+	var apiStatus apierrors.APIStatus
+	if !errors.As(err, &apiStatus) {
+		return status.Error(codes.Internal, err.Error())
+	}
+	code := codes.Unknown
+	switch {
+	case apierrors.IsNotFound(err):
+		code = codes.NotFound
+	case apierrors.IsAlreadyExists(err):
+		code = codes.AlreadyExists
+	case apierrors.IsForbidden(err):
+		code = codes.PermissionDenied
+	case apierrors.IsUnauthorized(err):
+		code = codes.Unauthenticated
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		code = codes.InvalidArgument
+	case apierrors.IsConflict(err):
+		code = codes.Aborted
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err), apierrors.IsTooManyRequests(err):
+		code = codes.Unavailable
+	case apierrors.IsResourceExpired(err):
+		code = codes.FailedPrecondition
+	}
+	return status.Error(code, apiStatus.Status().Message)
+}
+
+func sentinelSpecFromProto(in *sentinelpb.SentinelSpec) secopsv1alpha1.SentinelSpec {
+	spec := secopsv1alpha1.SentinelSpec{
+		SecretName:           in.GetSecretName(),
+		Data:                 in.GetData(),
+		SecretType:           in.GetSecretType(),
+		ServiceAccount:       in.GetServiceAccount(),
+		Role:                 in.GetRole(),
+		RoleBinding:          in.GetRoleBinding(),
+		CreateServiceAccount: in.GetCreateServiceAccount(),
+		Approvers:            in.GetApprovers(),
+		ApproverGroups:       in.GetApproverGroups(),
+	}
+	if envelope := in.GetEnvelope(); envelope != nil {
+		spec.Envelope = &secopsv1alpha1.EnvelopeSpec{}
+		if ref := envelope.GetMasterKeySecretRef(); ref != nil {
+			spec.Envelope.MasterKeySecretRef = secretKeySelector(ref)
+		}
+		if transit := envelope.GetVaultTransit(); transit != nil {
+			spec.Envelope.VaultTransit = &secopsv1alpha1.VaultTransitSpec{
+				Address: transit.GetAddress(),
+				Mount:   transit.GetMount(),
+				KeyName: transit.GetKeyName(),
+			}
+			if ref := transit.GetTokenSecretRef(); ref != nil {
+				spec.Envelope.VaultTransit.TokenSecretRef = *secretKeySelector(ref)
+			}
+		}
+	}
+	return spec
+}
+
+func secretKeySelector(in *sentinelpb.SecretKeySelector) *corev1.SecretKeySelector {
+	return &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: in.GetName()}, Key: in.GetKey()}
+}
+
+func protoSecretKeySelector(in *corev1.SecretKeySelector) *sentinelpb.SecretKeySelector {
+	return &sentinelpb.SecretKeySelector{Name: in.Name, Key: in.Key}
+}
+
+// sentinelToProto converts a Sentinel, the values of its data are redacted
+func sentinelToProto(sentinel *secopsv1alpha1.Sentinel) *sentinelpb.Sentinel {
+	redact(sentinel)
+	spec := &sentinelpb.SentinelSpec{
+		SecretName:           sentinel.Spec.SecretName,
+		Data:                 sentinel.Spec.Data,
+		SecretType:           sentinel.Spec.SecretType,
+		ServiceAccount:       sentinel.Spec.ServiceAccount,
+		Role:                 sentinel.Spec.Role,
+		RoleBinding:          sentinel.Spec.RoleBinding,
+		CreateServiceAccount: sentinel.Spec.CreateServiceAccount,
+		Approvers:            sentinel.Spec.Approvers,
+		ApproverGroups:       sentinel.Spec.ApproverGroups,
+	}
+	if envelope := sentinel.Spec.Envelope; envelope != nil {
+		spec.Envelope = &sentinelpb.EnvelopeSpec{}
+		if envelope.MasterKeySecretRef != nil {
+			spec.Envelope.MasterKeySecretRef = protoSecretKeySelector(envelope.MasterKeySecretRef)
+		}
+		if transit := envelope.VaultTransit; transit != nil {
+			spec.Envelope.VaultTransit = &sentinelpb.VaultTransitSpec{
+				Address:        transit.Address,
+				Mount:          transit.Mount,
+				KeyName:        transit.KeyName,
+				TokenSecretRef: protoSecretKeySelector(&transit.TokenSecretRef),
+			}
+		}
+	}
+	return &sentinelpb.Sentinel{
+		Namespace:         sentinel.Namespace,
+		Name:              sentinel.Name,
+		Uid:               string(sentinel.UID),
+		ResourceVersion:   sentinel.ResourceVersion,
+		Labels:            sentinel.Labels,
+		CreationTimestamp: timestamp(&sentinel.CreationTimestamp),
+		Spec:              spec,
+		Status:            sentinelStatusToProto(statusUpdate(sentinel), sentinel.Status.LastRotationTime),
+	}
+}
+
+func sentinelStatusToProto(update StatusUpdate, lastRotationTime *metav1.Time) *sentinelpb.SentinelStatus {
+	out := &sentinelpb.SentinelStatus{Phase: update.Phase, LastRotationTime: timestamp(lastRotationTime)}
+	for _, condition := range update.Conditions {
+		out.Conditions = append(out.Conditions, &sentinelpb.Condition{
+			Type:               condition.Type,
+			Status:             string(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			ObservedGeneration: condition.ObservedGeneration,
+			LastTransitionTime: timestamp(&condition.LastTransitionTime),
+		})
+	}
+	for _, violation := range update.PolicyViolations {
+		out.PolicyViolations = append(out.PolicyViolations, &sentinelpb.PolicyViolation{
+			Policy: violation.Policy, Rule: violation.Rule, Action: violation.Action, Message: violation.Message,
+		})
+	}
+	return out
+}
+
+func timestamp(t *metav1.Time) *timestamppb.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
+
+// randomValue returns a random alphanumeric value
+func randomValue(length int) (string, error) {
+	if length <= 0 {
+		return "", errors.New("Length must be positive")
+	}
+	value := make([]byte, length)
+	for i := range value {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(generatedAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		value[i] = generatedAlphabet[n.Int64()]
+	}
+	return string(value), nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// sessionCookie holds the ID token of a user logged in through the browser
+	sessionCookie = "sentinel_session"
+	// stateCookie binds the authorization response to the browser that started the login
+	stateCookie = "sentinel_oidc_state"
+)
+
+// OIDCLogin runs the authorization code flow for the UI and keeps the ID token in an
+// HttpOnly cookie, the API reads it like a bearer token
+type OIDCLogin struct {
+	OAuth2   oauth2.Config
+	Verifier *oidc.IDTokenVerifier
+	// InsecureCookies drops the Secure flag of the cookies, only for a local plain HTTP setup
+	InsecureCookies bool
+}
+
+// NewOIDCLogin configures the login with the endpoints of the issuer
+func NewOIDCLogin(provider *oidc.Provider, clientID string, clientSecret string, redirectURL string) *OIDCLogin {
+	return &OIDCLogin{
+		OAuth2: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile", "groups"},
+		},
+		Verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+	}
+}
+
+// login redirects to the issuer
+func (l *OIDCLogin) login(w http.ResponseWriter, r *http.Request) {
+	state := make([]byte, 32)
+	if _, err := rand.Read(state); err != nil {
+		http.Error(w, "Failed to start the login", http.StatusInternalServerError)
+		return
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(state)
+	http.SetCookie(w, l.cookie(stateCookie, encoded, 10*time.Minute))
+	http.Redirect(w, r, l.OAuth2.AuthCodeURL(encoded), http.StatusFound)
+}
+
+// callback exchanges the authorization code for the ID token and starts the session
+func (l *OIDCLogin) callback(w http.ResponseWriter, r *http.Request) {
+	log := log.FromContext(r.Context())
+
+	state, err := r.Cookie(stateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(state.Value), []byte(r.URL.Query().Get("state"))) != 1 {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, l.cookie(stateCookie, "", -1))
+
+	token, err := l.OAuth2.Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		log.Error(err, "OIDC code exchange failed")
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		http.Error(w, "Login failed, the issuer returned no ID token", http.StatusUnauthorized)
+		return
+	}
+	idToken, err := l.Verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		log.Error(err, "OIDC ID token verification failed")
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	http.SetCookie(w, l.cookie(sessionCookie, rawIDToken, time.Until(idToken.Expiry)))
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// logout ends the session
+func (l *OIDCLogin) logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, l.cookie(sessionCookie, "", -1))
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// cookie is HttpOnly and SameSite=Lax, so neither scripts nor cross-site posts can use it
+func (l *OIDCLogin) cookie(name string, value string, maxAge time.Duration) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   !l.InsecureCookies,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(maxAge.Seconds()),
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
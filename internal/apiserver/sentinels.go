@@ -0,0 +1,96 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"net/http"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// sentinelHandler serves the Sentinels of a namespace with the client of the caller
+type sentinelHandler struct {
+	client    client.Client
+	user      *authenticationv1.UserInfo
+	namespace string
+}
+
+func (h *sentinelHandler) list(w http.ResponseWriter, r *http.Request) {
+	sentinels := &secopsv1alpha1.SentinelList{}
+	if err := h.client.List(r.Context(), sentinels, client.InNamespace(h.namespace)); err != nil {
+		writeError(w, err)
+		return
+	}
+	for i := range sentinels.Items {
+		redact(&sentinels.Items[i])
+	}
+	setKind(sentinels)
+	writeJSON(w, http.StatusOK, sentinels)
+}
+
+func (h *sentinelHandler) create(w http.ResponseWriter, r *http.Request) {
+	sentinel, err := decodeSentinel(r, h.namespace)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.client.Create(r.Context(), sentinel); err != nil {
+		writeError(w, err)
+		return
+	}
+	log.FromContext(r.Context()).Info("Sentinel created", "Sentinel.Namespace", h.namespace,
+		"Sentinel.Name", sentinel.Name, "user", h.user.Username)
+
+	redact(sentinel)
+	setKind(sentinel)
+	writeJSON(w, http.StatusCreated, sentinel)
+}
+
+func (h *sentinelHandler) get(w http.ResponseWriter, r *http.Request, name string, statusOnly bool) {
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := h.client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: h.namespace}, sentinel); err != nil {
+		writeError(w, err)
+		return
+	}
+	if statusOnly {
+		writeJSON(w, http.StatusOK, sentinel.Status)
+		return
+	}
+	redact(sentinel)
+	setKind(sentinel)
+	writeJSON(w, http.StatusOK, sentinel)
+}
+
+func (h *sentinelHandler) delete(w http.ResponseWriter, r *http.Request, name string) {
+	sentinel := &secopsv1alpha1.Sentinel{}
+	sentinel.Name = name
+	sentinel.Namespace = h.namespace
+	if err := h.client.Delete(r.Context(), sentinel); err != nil {
+		writeError(w, err)
+		return
+	}
+	log.FromContext(r.Context()).Info("Sentinel deleted", "Sentinel.Namespace", h.namespace,
+		"Sentinel.Name", name, "user", h.user.Username)
+	writeJSON(w, http.StatusOK, &metav1.Status{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+		Status: metav1.StatusSuccess, Details: &metav1.StatusDetails{Name: name, Kind: "sentinels"}})
+}
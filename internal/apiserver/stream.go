@@ -0,0 +1,333 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+const (
+	// heartbeatInterval keeps idle streams open through proxies
+	heartbeatInterval = 15 * time.Second
+	// subscriberBuffer is the number of messages a slow stream may fall behind
+	subscriberBuffer = 64
+
+	// Phases of the status stream
+	phaseReconciling = "Reconciling"
+	phaseAvailable   = "Available"
+	phaseFailed      = "Failed"
+	phaseDeleted     = "Deleted"
+)
+
+// StatusUpdate is the data of a status message of the stream
+type StatusUpdate struct {
+	// Phase is Reconciling until the Available condition is True (Available) or False (Failed)
+	Phase string `json:"phase"`
+	// Message of the condition that changed last
+	Message          string                           `json:"message,omitempty"`
+	Conditions       []metav1.Condition               `json:"conditions,omitempty"`
+	PolicyViolations []secopsv1alpha1.PolicyViolation `json:"policyViolations,omitempty"`
+	ResourceVersion  string                           `json:"resourceVersion,omitempty"`
+}
+
+// EventUpdate is the data of an event message of the stream
+type EventUpdate struct {
+	Type    string      `json:"type"`
+	Reason  string      `json:"reason"`
+	Message string      `json:"message"`
+	Count   int32       `json:"count,omitempty"`
+	Time    metav1.Time `json:"time"`
+}
+
+// streamMessage is a server-sent event
+type streamMessage struct {
+	event string
+	data  interface{}
+}
+
+// Streamer fans the changes of an informer cache of Sentinels and their Events out to the
+// status streams of the API
+type Streamer struct {
+	// Reader reads the current Events, usually the cache
+	Reader client.Reader
+
+	informers   cache.Informers
+	mu          sync.Mutex
+	subscribers map[types.NamespacedName]map[chan streamMessage]struct{}
+}
+
+// NewStreamer creates the cache of the Streamer. Only Events of Sentinels are cached and the
+// values of spec.data are dropped before Sentinels enter the cache.
+func NewStreamer(config *rest.Config, scheme *runtime.Scheme) (*Streamer, error) {
+	informers, err := cache.New(config, cache.Options{
+		Scheme: scheme,
+		ByObject: map[client.Object]cache.ByObject{
+			&secopsv1alpha1.Sentinel{}: {Transform: func(obj interface{}) (interface{}, error) {
+				if sentinel, ok := obj.(*secopsv1alpha1.Sentinel); ok {
+					sentinel.Spec.Data = nil
+					sentinel.ManagedFields = nil
+				}
+				return obj, nil
+			}},
+			&corev1.Event{}: {Field: fields.OneTermEqualSelector("involvedObject.kind", "Sentinel")},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Streamer{Reader: informers, informers: informers}, nil
+}
+
+// Start registers the event handlers and runs the cache until the context is done
+func (s *Streamer) Start(ctx context.Context) error {
+	sentinelInformer, err := s.informers.GetInformer(ctx, &secopsv1alpha1.Sentinel{})
+	if err != nil {
+		return err
+	}
+	if _, err := sentinelInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { s.onSentinel(obj, false) },
+		UpdateFunc: func(_, obj interface{}) { s.onSentinel(obj, false) },
+		DeleteFunc: func(obj interface{}) { s.onSentinel(obj, true) },
+	}); err != nil {
+		return err
+	}
+
+	eventInformer, err := s.informers.GetInformer(ctx, &corev1.Event{})
+	if err != nil {
+		return err
+	}
+	if _, err := eventInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    s.onEvent,
+		UpdateFunc: func(_, obj interface{}) { s.onEvent(obj) },
+	}); err != nil {
+		return err
+	}
+	return s.informers.Start(ctx)
+}
+
+func (s *Streamer) onSentinel(obj interface{}, deleted bool) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	sentinel, ok := obj.(*secopsv1alpha1.Sentinel)
+	if !ok {
+		return
+	}
+	key := types.NamespacedName{Namespace: sentinel.Namespace, Name: sentinel.Name}
+	if deleted {
+		s.publish(key, streamMessage{event: "status", data: StatusUpdate{Phase: phaseDeleted}})
+		return
+	}
+	s.publish(key, streamMessage{event: "status", data: statusUpdate(sentinel)})
+}
+
+func (s *Streamer) onEvent(obj interface{}) {
+	event, ok := obj.(*corev1.Event)
+	if !ok || event.InvolvedObject.Kind != "Sentinel" {
+		return
+	}
+	key := types.NamespacedName{Namespace: event.InvolvedObject.Namespace, Name: event.InvolvedObject.Name}
+	s.publish(key, streamMessage{event: "event", data: eventUpdate(event)})
+}
+
+// subscribe returns the channel of the messages of a Sentinel and a function to unsubscribe
+func (s *Streamer) subscribe(key types.NamespacedName) (chan streamMessage, func()) {
+	ch := make(chan streamMessage, subscriberBuffer)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subscribers == nil {
+		s.subscribers = map[types.NamespacedName]map[chan streamMessage]struct{}{}
+	}
+	if s.subscribers[key] == nil {
+		s.subscribers[key] = map[chan streamMessage]struct{}{}
+	}
+	s.subscribers[key][ch] = struct{}{}
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers[key], ch)
+		if len(s.subscribers[key]) == 0 {
+			delete(s.subscribers, key)
+		}
+	}
+}
+
+// publish never blocks the informer, a stream that fell behind loses messages
+func (s *Streamer) publish(key types.NamespacedName, message streamMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers[key] {
+		select {
+		case ch <- message:
+		default:
+		}
+	}
+}
+
+// statusUpdate derives the phase from the Available condition
+func statusUpdate(sentinel *secopsv1alpha1.Sentinel) StatusUpdate {
+	update := StatusUpdate{
+		Phase:            phaseReconciling,
+		Conditions:       sentinel.Status.Conditions,
+		PolicyViolations: sentinel.Status.PolicyViolations,
+		ResourceVersion:  sentinel.ResourceVersion,
+	}
+	if available := meta.FindStatusCondition(sentinel.Status.Conditions, "Available"); available != nil {
+		switch available.Status {
+		case metav1.ConditionTrue:
+			update.Phase = phaseAvailable
+		case metav1.ConditionFalse:
+			update.Phase = phaseFailed
+		}
+	}
+	var latest *metav1.Condition
+	for i := range sentinel.Status.Conditions {
+		condition := &sentinel.Status.Conditions[i]
+		if latest == nil || latest.LastTransitionTime.Before(&condition.LastTransitionTime) {
+			latest = condition
+		}
+	}
+	if latest != nil {
+		update.Message = fmt.Sprintf("%s: %s", latest.Type, latest.Message)
+	}
+	return update
+}
+
+func eventUpdate(event *corev1.Event) EventUpdate {
+	at := event.LastTimestamp
+	if at.IsZero() {
+		at = metav1.NewTime(event.EventTime.Time)
+	}
+	return EventUpdate{Type: event.Type, Reason: event.Reason, Message: event.Message, Count: event.Count, Time: at}
+}
+
+// watch streams the status and the Events of a Sentinel as server-sent events. The caller
+// must be able to get the Sentinel, Events are only sent when the caller may list them.
+func (h *sentinelHandler) watch(w http.ResponseWriter, r *http.Request, name string, streamer *Streamer) {
+	log := log.FromContext(r.Context())
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, fmt.Errorf("Streaming is not supported by the connection"))
+		return
+	}
+	key := types.NamespacedName{Namespace: h.namespace, Name: name}
+
+	// Subscribe first, so no change between the initial read and the stream is lost
+	messages, unsubscribe := streamer.subscribe(key)
+	defer unsubscribe()
+
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := h.client.Get(r.Context(), key, sentinel); err != nil {
+		writeError(w, err)
+		return
+	}
+	includeEvents, err := h.allowed(r.Context(), "list", "", "events")
+	if err != nil {
+		log.Error(err, "Failed to review the access to events")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(message streamMessage) bool {
+		if message.event == "event" && !includeEvents {
+			return true
+		}
+		data, err := json.Marshal(message.data)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.event, data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	send(streamMessage{event: "status", data: statusUpdate(sentinel)})
+	if includeEvents {
+		events := &corev1.EventList{}
+		if err := streamer.Reader.List(r.Context(), events, client.InNamespace(h.namespace)); err == nil {
+			sort.Slice(events.Items, func(i, j int) bool {
+				return eventUpdate(&events.Items[i]).Time.Time.Before(eventUpdate(&events.Items[j]).Time.Time)
+			})
+			for i := range events.Items {
+				if events.Items[i].InvolvedObject.Kind == "Sentinel" && events.Items[i].InvolvedObject.Name == name {
+					send(streamMessage{event: "event", data: eventUpdate(&events.Items[i])})
+				}
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case message := <-messages:
+			if !send(message) {
+				return
+			}
+			if update, ok := message.data.(StatusUpdate); ok && update.Phase == phaseDeleted {
+				return
+			}
+		}
+	}
+}
+
+// allowed asks the apiserver whether the caller may use the verb on the resource of the namespace
+func (h *sentinelHandler) allowed(ctx context.Context, verb string, group string, resource string) (bool, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace: h.namespace, Verb: verb, Group: group, Resource: resource,
+		},
+	}}
+	if err := h.client.Create(ctx, review); err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package atrest verifies that the apiserver stores Secrets encrypted in etcd.
+package atrest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// defaultPrefix is the etcd prefix of the apiserver, see its --etcd-prefix flag
+const defaultPrefix = "/registry"
+
+// encryptedPrefixes are written by the apiserver in front of values it encrypted,
+// followed by the name of the key. The identity provider writes no prefix.
+var encryptedPrefixes = []string{
+	"k8s:enc:aescbc:v1:",
+	"k8s:enc:aesgcm:v1:",
+	"k8s:enc:secretbox:v1:",
+	"k8s:enc:kms:v1:",
+	"k8s:enc:kms:v2:",
+}
+
+// KV reads a raw value from etcd
+type KV interface {
+	// Get returns the value of the key, found is false when the key does not exist
+	Get(ctx context.Context, key string) (value []byte, found bool, err error)
+}
+
+// Result of the verification of a Secret
+type Result struct {
+	// Encrypted is true when the value carries the prefix of an encrypting provider
+	Encrypted bool
+	// Provider is the provider that encrypted the value, for example aescbc or kms:v2
+	Provider string
+}
+
+// Verifier checks the raw etcd values of Secrets
+type Verifier struct {
+	KV KV
+	// Prefix is the etcd prefix of the apiserver, defaults to /registry
+	Prefix string
+}
+
+// Verify reads the raw value of the Secret and reports whether it is encrypted
+func (v *Verifier) Verify(ctx context.Context, namespace string, name string) (Result, error) {
+	prefix := v.Prefix
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+	key := path.Join(prefix, "secrets", namespace, name)
+
+	value, found, err := v.KV.Get(ctx, key)
+	if err != nil {
+		return Result{}, err
+	}
+	if !found {
+		return Result{}, fmt.Errorf("Key %s not found in etcd", key)
+	}
+	return Inspect(value), nil
+}
+
+// Close releases the connection of the KV, if it holds one
+func (v *Verifier) Close() error {
+	if closer, ok := v.KV.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Inspect returns the verification result of a raw etcd value
+func Inspect(value []byte) Result {
+	for _, p := range encryptedPrefixes {
+		if bytes.HasPrefix(value, []byte(p)) {
+			provider := strings.TrimSuffix(strings.TrimPrefix(p, "k8s:enc:"), ":")
+			return Result{Encrypted: true, Provider: strings.TrimSuffix(provider, ":v1")}
+		}
+	}
+	return Result{}
+}
+
+// Config of the etcd connection
+type Config struct {
+	Endpoints []string
+	// CertFile and KeyFile are the client certificate, CAFile verifies the server
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// Prefix is the etcd prefix of the apiserver, defaults to /registry
+	Prefix string
+	// DialTimeout defaults to 5s
+	DialTimeout time.Duration
+}
+
+// etcdKV reads through an etcd v3 client
+type etcdKV struct {
+	client *clientv3.Client
+}
+
+// Get implements KV
+func (e *etcdKV) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	resp, err := e.client.Get(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, false, nil
+	}
+	return resp.Kvs[0].Value, true, nil
+}
+
+// Close implements io.Closer
+func (e *etcdKV) Close() error {
+	return e.client.Close()
+}
+
+// NewVerifier connects to etcd and returns a Verifier reading through the connection.
+// The connection is released by Close.
+func NewVerifier(config Config) (*Verifier, error) {
+	dialTimeout := config.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = 5 * time.Second
+	}
+
+	clientConfig := clientv3.Config{
+		Endpoints:   config.Endpoints,
+		DialTimeout: dialTimeout,
+	}
+	if config.CertFile != "" || config.CAFile != "" {
+		tlsInfo := transport.TLSInfo{
+			CertFile:      config.CertFile,
+			KeyFile:       config.KeyFile,
+			TrustedCAFile: config.CAFile,
+		}
+		tlsConfig, err := tlsInfo.ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+		clientConfig.TLS = tlsConfig
+	}
+
+	client, err := clientv3.New(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{KV: &etcdKV{client: client}, Prefix: config.Prefix}, nil
+}
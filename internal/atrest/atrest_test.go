@@ -0,0 +1,206 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package atrest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// fakeKV serves raw values as a local etcd would
+type fakeKV map[string][]byte
+
+func (f fakeKV) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, ok := f[key]
+	return value, ok, nil
+}
+
+func TestVerify(t *testing.T) {
+	verifier := &Verifier{KV: fakeKV{
+		"/registry/secrets/prod/plain":  []byte("k8s\x00\n\x0c\n\x02v1\x12\x06Secret"),
+		"/registry/secrets/prod/aescbc": []byte("k8s:enc:aescbc:v1:key1:\x8f\x02"),
+		"/registry/secrets/prod/kms":    []byte("k8s:enc:kms:v2:vault:\x0a\x20"),
+	}}
+
+	tests := []struct {
+		name string
+		want Result
+	}{
+		{name: "plain", want: Result{}},
+		{name: "aescbc", want: Result{Encrypted: true, Provider: "aescbc"}},
+		{name: "kms", want: Result{Encrypted: true, Provider: "kms:v2"}},
+	}
+	for _, tt := range tests {
+		got, err := verifier.Verify(context.Background(), "prod", tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Verify(%s) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := verifier.Verify(context.Background(), "prod", "missing"); err == nil {
+		t.Errorf("Verify() of a missing key succeeded")
+	}
+}
+
+// writeCertificate writes a certificate signed by the parent, or self signed without a
+// parent, and its key as PEM files of the directory
+func writeCertificate(t *testing.T, dir string, name string, template *x509.Certificate,
+	parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// TestVerifierEtcd reads the raw values through NewVerifier from an embedded etcd that
+// requires client certificates, as the etcd of a control plane does
+func TestVerifierEtcd(t *testing.T) {
+	dir := t.TempDir()
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := time.Now().Add(time.Hour)
+	ca, caKey := writeCertificate(t, dir, "ca", &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "etcd-ca"},
+		NotBefore: notBefore, NotAfter: notAfter,
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}, nil, nil)
+	writeCertificate(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "etcd"},
+		NotBefore: notBefore, NotAfter: notAfter, IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage: x509.KeyUsageDigitalSignature, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	writeCertificate(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "sentinel-operator"},
+		NotBefore: notBefore, NotAfter: notAfter,
+		KeyUsage: x509.KeyUsageDigitalSignature, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	config := embed.NewConfig()
+	config.Dir = filepath.Join(dir, "etcd")
+	config.LogLevel = "error"
+	clientURL, _ := url.Parse("https://127.0.0.1:0")
+	peerURL, _ := url.Parse("http://127.0.0.1:0")
+	config.LCUrls, config.ACUrls = []url.URL{*clientURL}, []url.URL{*clientURL}
+	config.LPUrls, config.APUrls = []url.URL{*peerURL}, []url.URL{*peerURL}
+	config.InitialCluster = config.InitialClusterFromName(config.Name)
+	config.ClientTLSInfo.CertFile = filepath.Join(dir, "server.crt")
+	config.ClientTLSInfo.KeyFile = filepath.Join(dir, "server.key")
+	config.ClientTLSInfo.TrustedCAFile = filepath.Join(dir, "ca.crt")
+	config.ClientTLSInfo.ClientCertAuth = true
+
+	etcd, err := embed.StartEtcd(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer etcd.Close()
+	select {
+	case <-etcd.Server.ReadyNotify():
+	case <-time.After(30 * time.Second):
+		t.Fatal("etcd did not start")
+	}
+	endpoint := "https://" + etcd.Clients[0].Addr().String()
+
+	verifier, err := NewVerifier(Config{
+		Endpoints: []string{endpoint},
+		CertFile:  filepath.Join(dir, "client.crt"),
+		KeyFile:   filepath.Join(dir, "client.key"),
+		CAFile:    filepath.Join(dir, "ca.crt"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := verifier.KV.(*etcdKV).client
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for key, value := range map[string]string{
+		"/registry/secrets/prod/plain":  "k8s\x00\n\x0c\n\x02v1\x12\x06Secret",
+		"/registry/secrets/prod/aesgcm": "k8s:enc:aesgcm:v1:key1:\x8f\x02",
+	} {
+		if _, err := client.Put(ctx, key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, err := verifier.Verify(ctx, "prod", "plain"); err != nil || got.Encrypted {
+		t.Errorf("Verify(plain) = %+v, %v", got, err)
+	}
+	if got, err := verifier.Verify(ctx, "prod", "aesgcm"); err != nil || got != (Result{Encrypted: true, Provider: "aesgcm"}) {
+		t.Errorf("Verify(aesgcm) = %+v, %v", got, err)
+	}
+	if _, err := verifier.Verify(ctx, "prod", "missing"); err == nil {
+		t.Errorf("Verify() of a missing key succeeded")
+	}
+
+	// Without a client certificate etcd refuses the connection
+	anonymous, err := NewVerifier(Config{Endpoints: []string{endpoint}, CAFile: filepath.Join(dir, "ca.crt"), DialTimeout: time.Second})
+	if err == nil {
+		defer anonymous.Close()
+		shortCtx, shortCancel := context.WithTimeout(ctx, 2*time.Second)
+		defer shortCancel()
+		if _, err := anonymous.Verify(shortCtx, "prod", "plain"); err == nil {
+			t.Errorf("Verify() without a client certificate succeeded")
+		}
+	}
+
+	if err := verifier.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(ctx, "prod", "plain"); err == nil {
+		t.Errorf("Verify() after Close succeeded")
+	}
+}
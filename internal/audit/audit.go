@@ -0,0 +1,237 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit records the operations on Sentinel secrets as a tamper-evident log. Every record
+// carries the hash of its predecessor, so removing, reordering or changing a record breaks the
+// chain. With a key the hashes are HMACs and can not be recomputed by whoever edits the log.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Actions of the records
+const (
+	ActionCreate       = "create"
+	ActionUpdate       = "update"
+	ActionRotate       = "rotate"
+	ActionReveal       = "reveal"
+	ActionAccessGrant  = "access-grant"
+	ActionAccessRevoke = "access-revoke"
+	ActionPush         = "push"
+	ActionBackup       = "backup"
+	ActionRestore      = "restore"
+	ActionReplicate    = "replicate"
+)
+
+// ActorOperator is the actor of the operations the operator performs on its own
+const ActorOperator = "sentinel-operator"
+
+// Object identifies the object of a record
+type Object struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// Record is an entry of the audit log
+type Record struct {
+	// Sequence numbers the records of the chain starting with 1
+	Sequence uint64    `json:"sequence"`
+	Time     time.Time `json:"time"`
+	// Actor is the user that performed the operation
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	Object Object `json:"object"`
+	// PreviousContentHash and NewContentHash are the ContentHash of the secret data before
+	// and after the operation, the values themselves are never recorded
+	PreviousContentHash string            `json:"previousContentHash,omitempty"`
+	NewContentHash      string            `json:"newContentHash,omitempty"`
+	Details             map[string]string `json:"details,omitempty"`
+	// PreviousHash is the Hash of the previous record, empty for the first record
+	PreviousHash string `json:"previousHash"`
+	// Hash covers all other fields of the record
+	Hash string `json:"hash"`
+}
+
+// Sink ships records. Line is the JSON encoding of the record the hash was computed for.
+type Sink interface {
+	Write(ctx context.Context, record *Record, line []byte) error
+}
+
+// Logger chains records and writes them to its sinks
+type Logger struct {
+	sinks []Sink
+	key   []byte
+	now   func() time.Time
+
+	mu       sync.Mutex
+	sequence uint64
+	last     string
+}
+
+// NewLogger returns a Logger. The hashes are HMAC-SHA256 with the key, or SHA-256 without one.
+func NewLogger(key []byte, sinks ...Sink) *Logger {
+	return &Logger{sinks: sinks, key: key, now: time.Now}
+}
+
+// Resume continues the chain after the last record of an earlier run
+func (l *Logger) Resume(last *Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sequence = last.Sequence
+	l.last = last.Hash
+}
+
+// Log completes the record with the sequence, time and hashes and writes it to all sinks.
+// The chain advances even when a sink fails, the other sinks hold the record.
+func (l *Logger) Log(ctx context.Context, record Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sequence++
+	record.Sequence = l.sequence
+	record.Time = l.now().UTC()
+	record.PreviousHash = l.last
+	hash, err := recordHash(l.key, &record)
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+	l.last = hash
+
+	line, err := json.Marshal(&record)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, sink := range l.sinks {
+		if err := sink.Write(ctx, &record, line); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ContentHash hashes secret data without revealing it. Use a key, plain SHA-256 hashes of weak
+// values can be guessed.
+func (l *Logger) ContentHash(data map[string][]byte) string {
+	if data == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h, prefix := newHash(l.key)
+	for _, key := range keys {
+		// Length prefixes keep the encoding unambiguous
+		writeField(h, []byte(key))
+		writeField(h, data[key])
+	}
+	return prefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// StringContentHash is ContentHash of string values such as the data of a Sentinel spec
+func (l *Logger) StringContentHash(data map[string]string) string {
+	if data == nil {
+		return ""
+	}
+	bytes := make(map[string][]byte, len(data))
+	for key, value := range data {
+		bytes[key] = []byte(value)
+	}
+	return l.ContentHash(bytes)
+}
+
+func newHash(key []byte) (hash.Hash, string) {
+	if len(key) > 0 {
+		return hmac.New(sha256.New, key), "hmac-sha256:"
+	}
+	return sha256.New(), "sha256:"
+}
+
+func writeField(h hash.Hash, value []byte) {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(value)))
+	h.Write(length[:])
+	h.Write(value)
+}
+
+// recordHash hashes the JSON encoding of the record without its hash
+func recordHash(key []byte, record *Record) (string, error) {
+	unhashed := *record
+	unhashed.Hash = ""
+	encoded, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+	h, prefix := newHash(key)
+	h.Write(encoded)
+	return prefix + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Verify checks the chain of the JSON lines of r. Previous is the last record of the preceding
+// part of the log, nil when r starts the log. It returns the last record of r.
+func Verify(r io.Reader, key []byte, previous *Record) (*Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	last := previous
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		record := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return last, fmt.Errorf("Line %d is not a record: %w", line, err)
+		}
+		hash, err := recordHash(key, record)
+		if err != nil {
+			return last, err
+		}
+		if !hmac.Equal([]byte(hash), []byte(record.Hash)) {
+			return last, fmt.Errorf("Record %d (line %d) was modified", record.Sequence, line)
+		}
+		if last != nil {
+			if record.Sequence != last.Sequence+1 {
+				return last, fmt.Errorf("Records %d to %d are missing before line %d", last.Sequence+1, record.Sequence-1, line)
+			}
+			if record.PreviousHash != last.Hash {
+				return last, fmt.Errorf("Record %d (line %d) does not follow record %d", record.Sequence, line, last.Sequence)
+			}
+		} else if record.Sequence == 1 && record.PreviousHash != "" {
+			return last, fmt.Errorf("Record 1 (line %d) has a predecessor", line)
+		}
+		last = record
+	}
+	return last, scanner.Err()
+}
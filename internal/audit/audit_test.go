@@ -0,0 +1,162 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func logRecords(t *testing.T, logger *Logger, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := logger.Log(context.Background(), Record{
+			Actor:          "alice",
+			Action:         ActionRotate,
+			Object:         Object{APIVersion: "secops.kavinduxo.com/v1alpha1", Kind: "Sentinel", Namespace: "prod", Name: "db"},
+			NewContentHash: logger.ContentHash(map[string][]byte{"password": {byte(i)}}),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	key := []byte("audit-key")
+	var out bytes.Buffer
+	logRecords(t, NewLogger(key, &WriterSink{Writer: &out}), 4)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+
+	tests := []struct {
+		name  string
+		lines []string
+		key   []byte
+		err   string
+	}{
+		{name: "intact", lines: lines, key: key},
+		{name: "wrong key", lines: lines, key: []byte("other"), err: "modified"},
+		{name: "modified", lines: []string{lines[0], strings.Replace(lines[1], "alice", "bob", 1), lines[2]}, key: key, err: "modified"},
+		{name: "deleted", lines: []string{lines[0], lines[2], lines[3]}, key: key, err: "missing"},
+		{name: "reordered", lines: []string{lines[0], lines[2], lines[1]}, key: key, err: "missing"},
+		{name: "truncated start", lines: lines[1:], key: key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(strings.NewReader(strings.Join(tt.lines, "\n")), tt.key, nil)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected %q, got %v", tt.err, err)
+			}
+		})
+	}
+
+	record := &Record{}
+	if err := json.Unmarshal([]byte(lines[0]), record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Sequence != 1 || record.PreviousHash != "" || !strings.HasPrefix(record.NewContentHash, "hmac-sha256:") {
+		t.Fatalf("first record %+v", record)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink := &FileSink{Path: path, MaxBytes: 1024, MaxBackups: 10}
+	logRecords(t, NewLogger(nil, sink), 5)
+	sink.Close()
+
+	// A restarted logger continues the chain of the file
+	last, err := LastRecord(path)
+	if err != nil || last == nil || last.Sequence != 5 {
+		t.Fatalf("last record %v, %v", last, err)
+	}
+	logger := NewLogger(nil, sink)
+	logger.Resume(last)
+	logRecords(t, logger, 5)
+	sink.Close()
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) == 0 {
+		t.Fatal("file not rotated")
+	}
+	// Verify the oldest backup first
+	var previous *Record
+	for i := len(backups); i >= 0; i-- {
+		name := path
+		if i > 0 {
+			name = backups[i-1]
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		previous, err = Verify(file, nil, previous)
+		file.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if previous.Sequence != 10 {
+		t.Fatalf("last sequence %d", previous.Sequence)
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink := &SyslogSink{Network: "udp", Address: conn.LocalAddr().String(), Hostname: "node 1", AppName: "sentinel-operator"}
+	defer sink.Close()
+	logRecords(t, NewLogger(nil, sink), 1)
+
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := string(buf[:n])
+	if !strings.HasPrefix(message, "<85>1 ") || !strings.Contains(message, " node1 sentinel-operator ") ||
+		!strings.Contains(message, ` rotate [audit@32473 sequence="1" hash="sha256:`) || !strings.HasSuffix(message, "}") {
+		t.Fatal(message)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	logRecords(t, NewLogger(nil, NewWebhookSink(server.URL)), 1)
+	if _, err := Verify(bytes.NewReader(received), nil, nil); err != nil || len(received) == 0 {
+		t.Fatalf("%s: %v", received, err)
+	}
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WriterSink writes one record per line, NewStdoutSink writes to stdout
+type WriterSink struct {
+	Writer io.Writer
+	mu     sync.Mutex
+}
+
+// NewStdoutSink returns a WriterSink of stdout
+func NewStdoutSink() *WriterSink {
+	return &WriterSink{Writer: os.Stdout}
+}
+
+// Write implements Sink
+func (s *WriterSink) Write(_ context.Context, _ *Record, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.Writer.Write(append(line, '\n'))
+	return err
+}
+
+// FileSink appends records to a local file. The file is rotated to Path.1 once it reaches
+// MaxBytes, Path.1 to Path.2 and so on up to MaxBackups.
+type FileSink struct {
+	Path       string
+	MaxBytes   int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Write implements Sink
+func (s *FileSink) Write(_ context.Context, _ *Record, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file != nil && s.MaxBytes > 0 && s.size+int64(len(line))+1 > s.MaxBytes && s.size > 0 {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if s.file == nil {
+		file, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return err
+		}
+		s.file, s.size = file, info.Size()
+	}
+	n, err := s.file.Write(append(line, '\n'))
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file, s.size = nil, 0
+	if s.MaxBackups <= 0 {
+		return os.Remove(s.Path)
+	}
+	for i := s.MaxBackups - 1; i > 0; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", s.Path, i), fmt.Sprintf("%s.%d", s.Path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(s.Path, s.Path+".1")
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// LastRecord returns the last record of the file to resume the chain, nil when the file is
+// missing or empty
+func LastRecord(path string) (*Record, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var last []byte
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+	if err := scanner.Err(); err != nil || last == nil {
+		return nil, err
+	}
+	record := &Record{}
+	if err := json.Unmarshal(last, record); err != nil {
+		return nil, fmt.Errorf("Last line of %s is not a record: %w", path, err)
+	}
+	return record, nil
+}
+
+// SyslogSink sends records as RFC 5424 messages with the facility authpriv. TCP connections use
+// the octet counting framing of RFC 6587.
+type SyslogSink struct {
+	// Network is udp, tcp or tcp+tls
+	Network  string
+	Address  string
+	Hostname string
+	AppName  string
+	Timeout  time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// syslogPriority is the facility authpriv (10) with the severity notice (5)
+const syslogPriority = 10*8 + 5
+
+// syslogEnterpriseID is the private enterprise number of the structured data of the records,
+// 32473 is reserved for documentation
+const syslogEnterpriseID = 32473
+
+// Write implements Sink
+func (s *SyslogSink) Write(ctx context.Context, record *Record, line []byte) error {
+	message := s.format(record, line)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A broken connection is redialed once
+	for attempt := 0; ; attempt++ {
+		if s.conn == nil {
+			conn, err := s.dial(ctx)
+			if err != nil {
+				return err
+			}
+			s.conn = conn
+		}
+		if s.Timeout > 0 {
+			_ = s.conn.SetWriteDeadline(time.Now().Add(s.Timeout))
+		}
+		_, err := s.conn.Write(message)
+		if err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+func (s *SyslogSink) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.Timeout}
+	if s.Network == "tcp+tls" {
+		return (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, "tcp", s.Address)
+	}
+	return dialer.DialContext(ctx, s.Network, s.Address)
+}
+
+// format returns the RFC 5424 message of the record, framed for stream transports
+func (s *SyslogSink) format(record *Record, line []byte) []byte {
+	hostname := syslogValue(s.Hostname, 255)
+	appName := syslogValue(s.AppName, 48)
+	msg := fmt.Sprintf("<%d>1 %s %s %s %d %s [audit@%d sequence=\"%d\" hash=\"%s\"] ",
+		syslogPriority, record.Time.Format(time.RFC3339Nano), hostname, appName, os.Getpid(),
+		syslogValue(record.Action, 32), syslogEnterpriseID, record.Sequence, record.Hash)
+	message := append([]byte(msg), line...)
+	if s.Network == "udp" {
+		return message
+	}
+	return append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+}
+
+// syslogValue returns the nil value - for empty header fields and drops characters RFC 5424
+// does not allow in them
+func syslogValue(value string, max int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(value) > max {
+		value = value[:max]
+	}
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// Close closes the connection
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// WebhookSink posts every record as JSON to a HTTP endpoint
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookSink returns a WebhookSink with a bounded request timeout
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Write implements Sink
+func (s *WebhookSink) Write(ctx context.Context, _ *Record, line []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(line))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Audit webhook %s returned %s", s.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package backup implements the encrypted bundles of the SentinelBackups and the stores they
+// are written to. A bundle holds the Sentinels and the objects they own. It is sealed with the
+// envelope encryption, so the data key is wrapped for a key the operator can not unwrap, and
+// signed with a key only the operators hold, so a bundle planted in the store is not restored.
+package backup
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+const (
+	// Version of the bundle format
+	Version = 1
+	// bundleKey is the envelope key the bundle is sealed under
+	bundleKey = "bundle.json"
+	// timeFormat of the backup names, it sorts in time order
+	timeFormat = "20060102T150405Z"
+	// nameSuffix ends the names of the backups
+	nameSuffix = ".json"
+	// MinSigningKeyLength is the minimum length of the keys the backups are signed with
+	MinSigningKeyLength = 32
+)
+
+// ErrUnverified is returned by Open for a backup whose signature does not match
+var ErrUnverified = errors.New("Backup signature does not verify")
+
+// signedBackup is the stored form of a backup. The signature covers the name, so a backup can
+// not be replayed under another name, for example one that sorts as the latest.
+type signedBackup struct {
+	Name      string          `json:"name"`
+	Sealed    json.RawMessage `json:"sealed"`
+	Signature []byte          `json:"signature"`
+}
+
+// Bundle is the content of a backup
+type Bundle struct {
+	Version int         `json:"version"`
+	Created metav1.Time `json:"created"`
+	Entries []Entry     `json:"entries"`
+}
+
+// Entry is a Sentinel and the objects it owned
+type Entry struct {
+	Sentinel        secopsv1alpha1.Sentinel `json:"sentinel"`
+	Secrets         []corev1.Secret         `json:"secrets,omitempty"`
+	ServiceAccounts []corev1.ServiceAccount `json:"serviceAccounts,omitempty"`
+	Roles           []rbacv1.Role           `json:"roles,omitempty"`
+	RoleBindings    []rbacv1.RoleBinding    `json:"roleBindings,omitempty"`
+}
+
+// Seal encrypts the bundle for the wrapper and signs it with the key under the name it is stored as
+func Seal(ctx context.Context, wrapper envelope.KeyWrapper, signingKey []byte, name string, bundle *Bundle) ([]byte, error) {
+	if len(signingKey) < MinSigningKeyLength {
+		return nil, fmt.Errorf("Signing key must have at least %d bytes", MinSigningKeyLength)
+	}
+	raw, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := envelope.Seal(ctx, wrapper, map[string][]byte{bundleKey: raw})
+	if err != nil {
+		return nil, err
+	}
+	sealedJSON, err := json.Marshal(sealed)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&signedBackup{Name: name, Sealed: sealedJSON, Signature: sign(signingKey, name, sealedJSON)})
+}
+
+// Open verifies that the backup was signed with the key under the name and decrypts it, a
+// backup that does not verify fails with ErrUnverified before anything is decrypted
+func Open(ctx context.Context, wrapper envelope.KeyWrapper, signingKey []byte, name string, data []byte) (*Bundle, error) {
+	if len(signingKey) < MinSigningKeyLength {
+		return nil, fmt.Errorf("Signing key must have at least %d bytes", MinSigningKeyLength)
+	}
+	signed := &signedBackup{}
+	if err := json.Unmarshal(data, signed); err != nil {
+		return nil, fmt.Errorf("Invalid backup: %w", err)
+	}
+	if signed.Name != name || !hmac.Equal(signed.Signature, sign(signingKey, signed.Name, signed.Sealed)) {
+		return nil, ErrUnverified
+	}
+	sealed := map[string][]byte{}
+	if err := json.Unmarshal(signed.Sealed, &sealed); err != nil {
+		return nil, fmt.Errorf("Invalid backup: %w", err)
+	}
+	opened, err := envelope.Open(ctx, wrapper, sealed)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &Bundle{}
+	if err := json.Unmarshal(opened[bundleKey], bundle); err != nil {
+		return nil, fmt.Errorf("Invalid backup bundle: %w", err)
+	}
+	if bundle.Version != Version {
+		return nil, fmt.Errorf("Unsupported backup version %d", bundle.Version)
+	}
+	return bundle, nil
+}
+
+// sign returns the HMAC-SHA256 of the name and the sealed envelope
+func sign(key []byte, name string, sealed []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write(sealed)
+	return mac.Sum(nil)
+}
+
+// Name returns the name of a backup written by a SentinelBackup at the given time
+func Name(backup string, t time.Time) string {
+	return backup + "-" + t.UTC().Format(timeFormat) + nameSuffix
+}
+
+// parseName splits a backup name into the SentinelBackup and the time, it fails for the
+// names of other objects
+func parseName(name string) (string, time.Time, bool) {
+	stamp := len(name) - len(nameSuffix) - len(timeFormat)
+	if !strings.HasSuffix(name, nameSuffix) || stamp < 2 || name[stamp-1] != '-' {
+		return "", time.Time{}, false
+	}
+	t, err := time.Parse(timeFormat, name[stamp:len(name)-len(nameSuffix)])
+	return name[:stamp-1], t, err == nil
+}
+
+// Newest returns the backups of the names, the newest first
+func Newest(names []string) []string {
+	backups := []string{}
+	times := map[string]time.Time{}
+	for _, name := range names {
+		if _, t, ok := parseName(name); ok {
+			backups = append(backups, name)
+			times[name] = t
+		}
+	}
+	sort.SliceStable(backups, func(i, j int) bool { return times[backups[i]].After(times[backups[j]]) })
+	return backups
+}
+
+// Expired returns the backups of a SentinelBackup beyond the newest keep ones
+func Expired(names []string, backup string, keep int) []string {
+	own := []string{}
+	for _, name := range names {
+		if owner, _, ok := parseName(name); ok && owner == backup {
+			own = append(own, name)
+		}
+	}
+	if len(own) <= keep {
+		return nil
+	}
+	// The names of a single SentinelBackup sort in time order
+	sort.Strings(own)
+	return own[:len(own)-keep]
+}
@@ -0,0 +1,374 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxBackupSize limits the backups read from a store
+const maxBackupSize = 64 << 20
+
+// Store holds the backups as named blobs
+type Store interface {
+	// Put writes a backup
+	Put(ctx context.Context, name string, data []byte) error
+	// Get reads a backup
+	Get(ctx context.Context, name string) ([]byte, error)
+	// List returns the names of all objects in the store
+	List(ctx context.Context) ([]string, error)
+	// Delete removes a backup
+	Delete(ctx context.Context, name string) error
+}
+
+// validName keeps the names of the backups inside the store
+func validName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("Invalid backup name %q", name)
+	}
+	return nil
+}
+
+// Dir stores the backups as files of a directory
+type Dir struct {
+	Dir string
+}
+
+// VolumeDir returns the directory of a PersistentVolumeClaim mounted into the operator at
+// root/claimName. It fails when the path leaves the volume, also through symbolic links.
+func VolumeDir(root, claimName, path string) (string, error) {
+	if root == "" {
+		return "", fmt.Errorf("Volume backup locations are disabled")
+	}
+	if err := validName(claimName); err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("Backup path %s must be relative", path)
+	}
+	volume, err := filepath.EvalSymlinks(filepath.Join(root, claimName))
+	if err != nil {
+		return "", fmt.Errorf("Volume %s is not mounted into the operator: %w", claimName, err)
+	}
+	dir := filepath.Join(volume, path)
+	if dir != volume && !strings.HasPrefix(dir, volume+string(filepath.Separator)) {
+		return "", fmt.Errorf("Backup path %s is outside of the volume %s", path, claimName)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	if resolved != volume && !strings.HasPrefix(resolved, volume+string(filepath.Separator)) {
+		return "", fmt.Errorf("Backup path %s is outside of the volume %s", path, claimName)
+	}
+	return resolved, nil
+}
+
+// Put implements Store, the file is renamed into place once it is complete
+func (d *Dir) Put(_ context.Context, name string, data []byte) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(d.Dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(d.Dir, name))
+}
+
+// Get implements Store
+func (d *Dir) Get(_ context.Context, name string) ([]byte, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(d.Dir, name))
+}
+
+// List implements Store
+func (d *Dir) List(_ context.Context) ([]string, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// Delete implements Store
+func (d *Dir) Delete(_ context.Context, name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(d.Dir, name))
+}
+
+// S3 stores the backups as objects of a bucket of an S3 compatible store. The requests are
+// signed with AWS Signature Version 4 and address the bucket in the path style, which every
+// S3 compatible store supports.
+type S3 struct {
+	Endpoint        string
+	Bucket          string
+	Prefix          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Client defaults to a client with a timeout of one minute
+	Client *http.Client
+}
+
+// Put implements Store
+func (s *S3) Put(ctx context.Context, name string, data []byte) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	response, err := s.do(ctx, http.MethodPut, s.key(name), nil, data)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// Get implements Store
+func (s *S3) Get(ctx context.Context, name string) ([]byte, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	response, err := s.do(ctx, http.MethodGet, s.key(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	return io.ReadAll(io.LimitReader(response.Body, maxBackupSize))
+}
+
+// List implements Store, it pages through ListObjectsV2
+func (s *S3) List(ctx context.Context) ([]string, error) {
+	names := []string{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.key("")}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		response, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		result := struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}{}
+		err = xml.NewDecoder(io.LimitReader(response.Body, maxBackupSize)).Decode(&result)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, content := range result.Contents {
+			name := strings.TrimPrefix(content.Key, s.key(""))
+			if validName(name) == nil {
+				names = append(names, name)
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return names, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Delete implements Store
+func (s *S3) Delete(ctx context.Context, name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	response, err := s.do(ctx, http.MethodDelete, s.key(name), nil, nil)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// key returns the object key of a backup
+func (s *S3) key(name string) string {
+	prefix := strings.Trim(s.Prefix, "/")
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
+
+// do sends a signed request for an object key, the bucket itself when the key is empty
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	path := "/" + s.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	endpoint.Path = strings.TrimRight(endpoint.Path, "/") + path
+	endpoint.RawPath = strings.TrimRight(endpoint.RawPath, "/") + escapePath(path)
+	endpoint.RawQuery = canonicalQuery(query)
+
+	request, err := http.NewRequestWithContext(ctx, method, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	SignV4(request, body, s.AccessKeyID, s.SecretAccessKey, region, "s3", time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		defer response.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, fmt.Errorf("S3 %s %s failed with %s: %s", method, path, response.Status, strings.TrimSpace(string(message)))
+	}
+	return response, nil
+}
+
+// SignV4 signs a request with AWS Signature Version 4. It signs the host and every header
+// already set on the request and adds the x-amz-date and x-amz-content-sha256 headers.
+func SignV4(request *http.Request, body []byte, accessKeyID, secretAccessKey, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256.Sum256(body)
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes a query sorted by key with the URI encoding of AWS
+func canonicalQuery(query url.Values) string {
+	pairs := []string{}
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, escape(key, true)+"="+escape(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// escapePath encodes every segment of a path with the URI encoding of AWS
+func escapePath(path string) string {
+	return escape(path, false)
+}
+
+// escape keeps the unreserved characters and percent encodes every other byte, a slash is
+// kept in paths
+func escape(s string, encodeSlash bool) string {
+	out := strings.Builder{}
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/' && !encodeSlash:
+			out.WriteByte(b)
+		default:
+			fmt.Fprintf(&out, "%%%02X", b)
+		}
+	}
+	return out.String()
+}
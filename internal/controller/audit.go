@@ -0,0 +1,48 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+)
+
+// auditLog records an operation in the audit log when one is configured. A failing sink is
+// logged and never blocks the reconciliation, the record reaches the other sinks.
+func auditLog(logger *audit.Logger, ctx context.Context, record audit.Record) {
+	if logger == nil {
+		return
+	}
+	if err := logger.Log(ctx, record); err != nil {
+		log.FromContext(ctx).Error(err, "Audit Record Failed.", "action", record.Action, "object", record.Object.Name)
+	}
+}
+
+// auditObject identifies a Sentinel or another object of the operator in the audit log
+func auditObject(obj client.Object, kind string) audit.Object {
+	return audit.Object{
+		APIVersion: secopsv1alpha1.GroupVersion.String(),
+		Kind:       kind,
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
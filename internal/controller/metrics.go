@@ -0,0 +1,34 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	secretEncryptedAtRest = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sentinel_secret_encrypted_at_rest",
+		Help: "Whether the secret of an encrypted Sentinel type is stored encrypted in etcd (1) or in plaintext (0).",
+	}, []string{"namespace", "sentinel", "secret"})
+)
+
+func init() {
+	// Served on the metrics endpoint of the manager
+	metrics.Registry.MustRegister(secretEncryptedAtRest)
+}
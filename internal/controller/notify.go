@@ -0,0 +1,46 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/notify"
+)
+
+// notifySentinel sends a notification about a Sentinel when a notifier is configured. A failing
+// notification is logged and never blocks the reconciliation.
+func notifySentinel(notifier notify.Notifier, ctx context.Context, sentinel *secopsv1alpha1.Sentinel,
+	notificationType, message string, details map[string]string) {
+	if notifier == nil {
+		return
+	}
+	if err := notifier.Notify(ctx, notify.Notification{
+		Type:      notificationType,
+		Sentinel:  sentinel.Name,
+		Namespace: sentinel.Namespace,
+		Message:   message,
+		Details:   details,
+		Time:      time.Now(),
+	}); err != nil {
+		log.FromContext(ctx).Error(err, "Notification Failed.", "type", notificationType, "Sentinel.Name", sentinel.Name)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+)
+
+// RevealEventSelector limits the cache of events to the SecretRevealed events
+var RevealEventSelector = fields.OneTermEqualSelector("reason", secopsv1alpha1.SecretRevealedReason)
+
+// SecretRevealAuditReconciler records the SecretRevealed events of kubectl sentinel reveal in the
+// audit log. Recorded events are annotated, so a restart does not record them again.
+type SecretRevealAuditReconciler struct {
+	client.Client
+	Audit *audit.Logger
+}
+
+//+kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;patch
+
+// Reconcile records a SecretRevealed event once
+func (r *SecretRevealAuditReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	event := &corev1.Event{}
+	if err := r.Get(ctx, req.NamespacedName, event); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !isUnauditedReveal(event) {
+		return ctrl.Result{}, nil
+	}
+
+	// Anyone allowed to create events can write the user of the annotation, the record keeps it
+	// as the claimed actor and names the source of the event as the actor
+	actor := event.Source.Component
+	if actor == "" {
+		actor = "unknown"
+	}
+	details := map[string]string{"event": event.Name, "message": event.Message}
+	if claimed := event.Annotations[secopsv1alpha1.RevealedByAnnotation]; claimed != "" {
+		details["claimedActor"] = claimed
+	}
+	if keys := event.Annotations[secopsv1alpha1.RevealedKeysAnnotation]; keys != "" {
+		details["keys"] = keys
+	}
+	auditLog(r.Audit, ctx, audit.Record{
+		Actor:  actor,
+		Action: audit.ActionReveal,
+		Object: audit.Object{
+			APIVersion: secopsv1alpha1.GroupVersion.String(),
+			Kind:       "Sentinel",
+			Namespace:  event.InvolvedObject.Namespace,
+			Name:       event.InvolvedObject.Name,
+		},
+		Details: details,
+	})
+
+	patch := client.MergeFrom(event.DeepCopy())
+	if event.Annotations == nil {
+		event.Annotations = map[string]string{}
+	}
+	event.Annotations[secopsv1alpha1.AuditedAnnotation] = "true"
+	if err := r.Patch(ctx, event, patch); err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to mark the SecretRevealed event as audited")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+func isUnauditedReveal(event *corev1.Event) bool {
+	return event.Reason == secopsv1alpha1.SecretRevealedReason &&
+		event.InvolvedObject.Kind == "Sentinel" &&
+		strings.HasPrefix(event.InvolvedObject.APIVersion, secopsv1alpha1.GroupVersion.Group+"/") &&
+		event.Annotations[secopsv1alpha1.AuditedAnnotation] == ""
+}
+
+// SetupWithManager sets up the controller with the Manager. The cache of the manager should only
+// hold the SecretRevealed events, see RevealEventSelector.
+func (r *SecretRevealAuditReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("secretrevealaudit").
+		For(&corev1.Event{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			event, ok := obj.(*corev1.Event)
+			return ok && isUnauditedReveal(event)
+		}))).
+		Complete(r)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+)
+
+// recordingSink keeps the records written to the audit log
+type recordingSink []audit.Record
+
+func (s *recordingSink) Write(ctx context.Context, record *audit.Record, line []byte) error {
+	*s = append(*s, *record)
+	return nil
+}
+
+func TestSecretRevealAudit(t *testing.T) {
+	ctx := context.Background()
+	// A user allowed to create events claims the reveal was made by someone else
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders.1", Namespace: "prod", Annotations: map[string]string{
+			secopsv1alpha1.RevealedByAnnotation:   "alice",
+			secopsv1alpha1.RevealedKeysAnnotation: "password",
+		}},
+		InvolvedObject: corev1.ObjectReference{APIVersion: secopsv1alpha1.GroupVersion.String(), Kind: "Sentinel",
+			Namespace: "prod", Name: "orders"},
+		Reason:  secopsv1alpha1.SecretRevealedReason,
+		Message: "Secret orders-db (keys password) revealed to alice with kubectl-sentinel",
+		Source:  corev1.EventSource{Component: "kubectl-sentinel"},
+	}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(event).Build()
+	sink := &recordingSink{}
+	r := &SecretRevealAuditReconciler{Client: c, Audit: audit.NewLogger(nil, sink)}
+
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(event)}
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(*sink) != 1 {
+		t.Fatalf("%d audit records, want 1", len(*sink))
+	}
+	record := (*sink)[0]
+	if record.Actor != "kubectl-sentinel" || record.Details["claimedActor"] != "alice" ||
+		record.Action != audit.ActionReveal || record.Object.Name != "orders" || record.Details["keys"] != "password" {
+		t.Errorf("audit record %+v", record)
+	}
+	if err := c.Get(ctx, req.NamespacedName, event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Annotations[secopsv1alpha1.AuditedAnnotation] != "true" {
+		t.Error("event not marked as audited")
+	}
+}
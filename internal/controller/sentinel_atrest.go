@@ -0,0 +1,86 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// isEncryptedSecretType reports whether the secret type promises encryption at rest
+func isEncryptedSecretType(secretType string) bool {
+	switch secretType {
+	case typeSecretLocalEncryted, typeSecretLocalEncrytedRbac, typeSecretKmsEncrypted, typeSecretKmsEncryptedRbac:
+		return true
+	}
+	return false
+}
+
+// verifyEncryptionAtRest reads the raw etcd value of the secret of an encrypted Sentinel type
+// and reports a plaintext value as a Degraded condition. A failing verification is logged,
+// it must not stop the reconciliation of the secret.
+func (r *SentinelReconciler) verifyEncryptionAtRest(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	log := log.FromContext(ctx)
+
+	if r.AtRestVerifier == nil {
+		return ctrl.Result{}, nil
+	}
+	if !isEncryptedSecretType(sentinel.Spec.SecretType) {
+		secretEncryptedAtRest.DeleteLabelValues(sentinel.Namespace, sentinel.Name, sentinel.Spec.SecretName)
+		return ctrl.Result{}, nil
+	}
+
+	// Values sealed by the envelope encryption are ciphertext whatever the apiserver stores
+	if sentinel.Spec.Envelope != nil {
+		secretEncryptedAtRest.WithLabelValues(sentinel.Namespace, sentinel.Name, sentinel.Spec.SecretName).Set(1)
+		meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typeDegradedSentinel,
+			Status: metav1.ConditionFalse, Reason: "EnvelopeEncrypted",
+			Message: fmt.Sprintf("Secret %s is encrypted by the envelope encryption of the operator", sentinel.Spec.SecretName)})
+		return ctrl.Result{}, nil
+	}
+
+	result, err := r.AtRestVerifier.Verify(ctx, sentinel.Namespace, sentinel.Spec.SecretName)
+	if err != nil {
+		log.Error(err, "Failed to verify the encryption at rest of the secret")
+		return ctrl.Result{}, nil
+	}
+
+	if !result.Encrypted {
+		secretEncryptedAtRest.WithLabelValues(sentinel.Namespace, sentinel.Name, sentinel.Spec.SecretName).Set(0)
+		message := fmt.Sprintf("Secret %s of type %s is stored in plaintext in etcd", sentinel.Spec.SecretName, sentinel.Spec.SecretType)
+		log.Info(message, "Sentinel.Name", sentinel.Name)
+
+		meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typeDegradedSentinel,
+			Status: metav1.ConditionTrue, Reason: "PlaintextAtRest", Message: message})
+		return ctrl.Result{}, nil
+	}
+
+	secretEncryptedAtRest.WithLabelValues(sentinel.Namespace, sentinel.Name, sentinel.Spec.SecretName).Set(1)
+	meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typeDegradedSentinel,
+		Status: metav1.ConditionFalse, Reason: "EncryptedAtRest",
+		Message: fmt.Sprintf("Secret %s is stored encrypted in etcd by the %s provider", sentinel.Spec.SecretName, result.Provider)})
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/atrest"
+)
+
+// rawKV serves raw etcd values, a missing key fails like an unreachable etcd
+type rawKV map[string]string
+
+func (kv rawKV) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, ok := kv[key]
+	if !ok {
+		return nil, false, errors.New("etcd unavailable")
+	}
+	return []byte(value), true, nil
+}
+
+func TestVerifyEncryptionAtRest(t *testing.T) {
+	r := &SentinelReconciler{AtRestVerifier: &atrest.Verifier{KV: rawKV{
+		"/registry/secrets/prod/plain":     "k8s\x00\n\x0c\n\x02v1\x12\x06Secret",
+		"/registry/secrets/prod/encrypted": "k8s:enc:aescbc:v1:key1:\x8f\x02",
+	}}}
+
+	tests := []struct {
+		name       string
+		secretName string
+		secretType string
+		envelope   bool
+		degraded   metav1.ConditionStatus
+		metric     float64
+		series     int
+	}{
+		{name: "plaintext", secretName: "plain", secretType: typeSecretLocalEncryted,
+			degraded: metav1.ConditionTrue, metric: 0, series: 1},
+		{name: "encrypted", secretName: "encrypted", secretType: typeSecretKmsEncryptedRbac,
+			degraded: metav1.ConditionFalse, metric: 1, series: 1},
+		{name: "envelope", secretName: "plain", secretType: typeSecretLocalEncryted, envelope: true,
+			degraded: metav1.ConditionFalse, metric: 1, series: 1},
+		// A failed read is logged and keeps the last result
+		{name: "unavailable", secretName: "missing", secretType: typeSecretLocalEncryted, metric: 1, series: 1},
+		// Types without encryption at rest are not verified and drop their series
+		{name: "base", secretName: "plain", secretType: typeSecretBase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secretEncryptedAtRest.Reset()
+			sentinel := &secopsv1alpha1.Sentinel{
+				ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "prod"},
+				Spec:       secopsv1alpha1.SentinelSpec{SecretName: tt.secretName, SecretType: tt.secretType},
+			}
+			if tt.envelope {
+				sentinel.Spec.Envelope = &secopsv1alpha1.EnvelopeSpec{}
+			}
+			// The series of a previous type is removed when the type changes
+			secretEncryptedAtRest.WithLabelValues("prod", "orders", tt.secretName).Set(1)
+
+			if _, err := r.verifyEncryptionAtRest(sentinel, context.Background(), ctrl.Request{}); err != nil {
+				t.Fatal(err)
+			}
+
+			condition := meta.FindStatusCondition(sentinel.Status.Conditions, typeDegradedSentinel)
+			if tt.degraded == "" {
+				if condition != nil {
+					t.Errorf("Degraded condition %+v", condition)
+				}
+			} else if condition == nil || condition.Status != tt.degraded {
+				t.Errorf("Degraded condition %+v, want %s", condition, tt.degraded)
+			}
+
+			if got := testutil.CollectAndCount(secretEncryptedAtRest); got != tt.series {
+				t.Fatalf("%d series, want %d", got, tt.series)
+			}
+			if tt.series > 0 {
+				if got := testutil.ToFloat64(secretEncryptedAtRest.WithLabelValues("prod", "orders", tt.secretName)); got != tt.metric {
+					t.Errorf("metric = %v, want %v", got, tt.metric)
+				}
+			}
+		})
+	}
+}
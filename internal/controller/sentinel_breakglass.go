@@ -0,0 +1,192 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/notify"
+)
+
+const (
+	// breakGlassAnnotation carries the justification on the SentinelAccessGrant of a break-glass access
+	breakGlassAnnotation = "secops.kavinduxo.com/break-glass"
+
+	defaultBreakGlassDuration = 15 * time.Minute
+	maxBreakGlassDuration     = time.Hour
+	maxBreakGlassHistory      = 20
+)
+
+// breakGlassForSentinel applies the emergency access of spec.breakGlass once and keeps
+// the break-glass history of the status in sync with the access grants. The record of a new
+// access is persisted before it is announced, the name of its grant identifies it, so a
+// retried reconciliation neither records nor notifies the same access twice.
+func (r *SentinelReconciler) breakGlassForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	log := log.FromContext(ctx)
+
+	for i := range sentinel.Status.BreakGlassHistory {
+		record := &sentinel.Status.BreakGlassHistory[i]
+		if record.Phase == accessGrantPhaseExpired {
+			continue
+		}
+		grant := &secopsv1alpha1.SentinelAccessGrant{}
+		err := r.Get(ctx, types.NamespacedName{Name: record.AccessGrant, Namespace: sentinel.Namespace}, grant)
+		if err != nil && apierrors.IsNotFound(err) {
+			record.Phase = accessGrantPhaseExpired
+			continue
+		} else if err != nil {
+			return ctrl.Result{}, err
+		}
+		if grant.Status.Phase != "" {
+			record.Phase = grant.Status.Phase
+		}
+		if grant.Status.ExpiresAt != nil {
+			record.ExpiresAt = *grant.Status.ExpiresAt
+		}
+	}
+
+	breakGlass := sentinel.Spec.BreakGlass
+	if breakGlass == nil {
+		return ctrl.Result{}, nil
+	}
+
+	grantName := breakGlassGrantName(sentinel, breakGlass)
+	if findBreakGlassRecord(sentinel, grantName) != nil {
+		// This break-glass access was already applied
+		return ctrl.Result{}, nil
+	}
+
+	duration := defaultBreakGlassDuration
+	if breakGlass.Duration != nil && breakGlass.Duration.Duration > 0 {
+		duration = breakGlass.Duration.Duration
+	}
+	if duration > maxBreakGlassDuration {
+		duration = maxBreakGlassDuration
+	}
+
+	newGrant := &secopsv1alpha1.SentinelAccessGrant{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      grantName,
+			Namespace: sentinel.Namespace,
+			Annotations: map[string]string{
+				breakGlassAnnotation: breakGlass.Justification,
+			},
+		},
+		Spec: secopsv1alpha1.SentinelAccessGrantSpec{
+			SentinelName: sentinel.Name,
+			Subject:      breakGlass.Subject,
+			Duration:     metav1.Duration{Duration: duration},
+		},
+	}
+
+	// Set Sentinel instance as the owner of the SentinelAccessGrant
+	if err := controllerutil.SetControllerReference(sentinel, newGrant, r.Scheme); err != nil {
+		log.Error(err, "Setting Sentinel instance as the owner of the SentinelAccessGrant Failed.")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.Create(ctx, newGrant); err != nil && !apierrors.IsAlreadyExists(err) {
+		log.Error(err, "Break-glass SentinelAccessGrant Creation Failed.")
+		return ctrl.Result{}, err
+	}
+
+	now := metav1.Now()
+	sentinel.Status.BreakGlassHistory = append(sentinel.Status.BreakGlassHistory, secopsv1alpha1.BreakGlassRecord{
+		AccessGrant:   grantName,
+		Subject:       breakGlass.Subject,
+		Justification: breakGlass.Justification,
+		Duration:      metav1.Duration{Duration: duration},
+		GrantedAt:     now,
+		ExpiresAt:     metav1.NewTime(now.Add(duration)),
+		Phase:         accessGrantPhaseActive,
+	})
+	if len(sentinel.Status.BreakGlassHistory) > maxBreakGlassHistory {
+		sentinel.Status.BreakGlassHistory = sentinel.Status.BreakGlassHistory[len(sentinel.Status.BreakGlassHistory)-maxBreakGlassHistory:]
+	}
+	if err := r.Status().Update(ctx, sentinel); err != nil {
+		log.Error(err, "Failed to record the break-glass access")
+		return ctrl.Result{}, err
+	}
+
+	message := fmt.Sprintf("Break-glass access to secret %s granted to %s %s for %s: %s",
+		sentinel.Spec.SecretName, breakGlass.Subject.Kind, breakGlass.Subject.Name, duration, breakGlass.Justification)
+	log.Info("Break-glass access applied", "Sentinel.Name", sentinel.Name, "SentinelAccessGrant.Name", grantName)
+
+	if r.Recorder != nil {
+		r.Recorder.Event(sentinel, corev1.EventTypeWarning, "BreakGlass", message)
+	}
+
+	notifySentinel(r.Notifier, ctx, sentinel, notify.TypeBreakGlass, message, map[string]string{
+		"subjectKind":   breakGlass.Subject.Kind,
+		"subjectName":   breakGlass.Subject.Name,
+		"justification": breakGlass.Justification,
+		"accessGrant":   grantName,
+		"expiresAt":     now.Add(duration).UTC().Format(time.RFC3339),
+	})
+
+	return ctrl.Result{}, nil
+}
+
+// isBreakGlassGrant reports whether the grant was created for a recorded break-glass access of the
+// Sentinel and still grants the recorded subject for the recorded duration. A grant edited after
+// the access was recorded is not a break-glass access anymore.
+func isBreakGlassGrant(grant *secopsv1alpha1.SentinelAccessGrant, sentinel *secopsv1alpha1.Sentinel) bool {
+	if !metav1.IsControlledBy(grant, sentinel) {
+		return false
+	}
+	record := findBreakGlassRecord(sentinel, grant.Name)
+	return record != nil &&
+		grant.Spec.Subject == record.Subject &&
+		grant.Spec.Duration == record.Duration &&
+		grant.Spec.Duration.Duration <= maxBreakGlassDuration
+}
+
+func findBreakGlassRecord(sentinel *secopsv1alpha1.Sentinel, grantName string) *secopsv1alpha1.BreakGlassRecord {
+	for i := range sentinel.Status.BreakGlassHistory {
+		if sentinel.Status.BreakGlassHistory[i].AccessGrant == grantName {
+			return &sentinel.Status.BreakGlassHistory[i]
+		}
+	}
+	return nil
+}
+
+// breakGlassGrantName derives a stable name from the break-glass spec, so that
+// every new justification or subject results in a new emergency access.
+func breakGlassGrantName(sentinel *secopsv1alpha1.Sentinel, breakGlass *secopsv1alpha1.BreakGlassSpec) string {
+	duration := ""
+	if breakGlass.Duration != nil {
+		duration = breakGlass.Duration.Duration.String()
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%s/%s/%s", breakGlass.Subject.Kind, breakGlass.Subject.Namespace,
+		breakGlass.Subject.Name, breakGlass.Justification, duration)))
+	return fmt.Sprintf("%s-breakglass-%s", sentinel.Name, hex.EncodeToString(sum[:])[:8])
+}
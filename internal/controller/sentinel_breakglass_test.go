@@ -0,0 +1,182 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/notify"
+)
+
+// recordingNotifier keeps the notifications it received
+type recordingNotifier struct {
+	notifications []notify.Notification
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification notify.Notification) error {
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func (n *recordingNotifier) count(notificationType string) int {
+	count := 0
+	for _, notification := range n.notifications {
+		if notification.Type == notificationType {
+			count++
+		}
+	}
+	return count
+}
+
+func breakGlassSentinel(secretType string) *secopsv1alpha1.Sentinel {
+	return &secopsv1alpha1.Sentinel{
+		TypeMeta:   metav1.TypeMeta{Kind: "Sentinel", APIVersion: secopsv1alpha1.GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "prod"},
+		Spec: secopsv1alpha1.SentinelSpec{
+			SecretName: "orders-db", SecretType: secretType, Data: map[string]string{"password": "s3cr3t"},
+			BreakGlass: &secopsv1alpha1.BreakGlassSpec{
+				Subject:       rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "oncall"},
+				Justification: "INC-1234 orders database down",
+			},
+		},
+	}
+}
+
+func TestBreakGlass(t *testing.T) {
+	ctx := context.Background()
+	sentinel := breakGlassSentinel(typeSecretBase)
+	scheme := newTestScheme(t)
+	// The final status update of the first two passes conflicts, as it does when the
+	// Sentinel is changed during the reconciliation
+	conflicts := 2
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(sentinel, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod"}}).
+		WithStatusSubresource(&secopsv1alpha1.Sentinel{}, &secopsv1alpha1.SentinelAccessGrant{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+				s, ok := obj.(*secopsv1alpha1.Sentinel)
+				if ok && conflicts > 0 && meta.IsStatusConditionTrue(s.Status.Conditions, typeAvailableSentinel) {
+					conflicts--
+					return apierrors.NewConflict(schema.GroupResource{Resource: "sentinels"}, s.Name, nil)
+				}
+				return c.SubResource(subResource).Update(ctx, obj, opts...)
+			},
+		}).Build()
+	notifier := &recordingNotifier{}
+	r := &SentinelReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(100), Notifier: notifier}
+
+	// Issue, the conflicts are retried without a second record or notification
+	for i := 0; i < 3; i++ {
+		_, _ = reconcileSentinel(t, r, "orders")
+	}
+	if conflicts != 0 {
+		t.Fatalf("%d conflicts left", conflicts)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(sentinel), sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if len(sentinel.Status.BreakGlassHistory) != 1 || notifier.count(notify.TypeBreakGlass) != 1 {
+		t.Fatalf("%d records and %d notifications, want one each",
+			len(sentinel.Status.BreakGlassHistory), notifier.count(notify.TypeBreakGlass))
+	}
+	record := sentinel.Status.BreakGlassHistory[0]
+	grant := &secopsv1alpha1.SentinelAccessGrant{}
+	if err := c.Get(ctx, types.NamespacedName{Name: record.AccessGrant, Namespace: "prod"}, grant); err != nil {
+		t.Fatal(err)
+	}
+	if !isBreakGlassGrant(grant, sentinel) || grant.Spec.Duration.Duration != defaultBreakGlassDuration {
+		t.Errorf("grant %+v", grant)
+	}
+
+	// A grant edited after the access was recorded is not a break-glass grant
+	for name, edit := range map[string]func(*secopsv1alpha1.SentinelAccessGrant){
+		"subject":  func(g *secopsv1alpha1.SentinelAccessGrant) { g.Spec.Subject.Name = "mallory" },
+		"duration": func(g *secopsv1alpha1.SentinelAccessGrant) { g.Spec.Duration.Duration = 24 * time.Hour },
+	} {
+		edited := grant.DeepCopy()
+		edit(edited)
+		if isBreakGlassGrant(edited, sentinel) {
+			t.Errorf("grant with an edited %s accepted", name)
+		}
+	}
+
+	// Expiry of the grant is recorded in the history
+	grant.Status.Phase = accessGrantPhaseExpired
+	if err := c.Status().Update(ctx, grant); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reconcileSentinel(t, r, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(sentinel), sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if phase := sentinel.Status.BreakGlassHistory[0].Phase; phase != accessGrantPhaseExpired {
+		t.Errorf("record phase %s", phase)
+	}
+
+	// A new justification is a new emergency access
+	sentinel.Spec.BreakGlass.Justification = "INC-1235 orders database down again"
+	if err := c.Update(ctx, sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reconcileSentinel(t, r, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(sentinel), sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if len(sentinel.Status.BreakGlassHistory) != 2 || notifier.count(notify.TypeBreakGlass) != 2 {
+		t.Errorf("%d records and %d notifications, want two each",
+			len(sentinel.Status.BreakGlassHistory), notifier.count(notify.TypeBreakGlass))
+	}
+}
+
+func TestBreakGlassNotBlockedByFailures(t *testing.T) {
+	ctx := context.Background()
+	// The spec validation fails for the unknown secret type
+	sentinel := breakGlassSentinel("UnknownSecret")
+	r := newTestSentinelReconciler(t, sentinel)
+	if _, err := reconcileSentinel(t, r, "orders"); err == nil {
+		t.Fatal("unknown secret type accepted")
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sentinel), sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if len(sentinel.Status.BreakGlassHistory) != 1 {
+		t.Fatalf("break-glass history %+v", sentinel.Status.BreakGlassHistory)
+	}
+	grantKey := types.NamespacedName{Name: sentinel.Status.BreakGlassHistory[0].AccessGrant, Namespace: "prod"}
+	if err := r.Get(ctx, grantKey, &secopsv1alpha1.SentinelAccessGrant{}); err != nil {
+		t.Errorf("break-glass grant not created: %v", err)
+	}
+}
@@ -44,6 +44,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/atrest"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+	"github.com/kavinduxo/sentinel-operator/internal/notify"
+	"github.com/kavinduxo/sentinel-operator/internal/policy"
 )
 
 const sentinelFinalizer = "secops.kavinduxo.com/finalizer"
@@ -56,6 +60,12 @@ const (
 	typeDegradedSentinel     = "Degraded"
 	typeRbacIssueSentinel    = "RBAC-Failed"
 	typeEncryptIssueSentinel = "Encryption-Failed"
+	// typePolicyCompliantSentinel represents whether the Sentinel follows the SentinelPolicies
+	typePolicyCompliantSentinel = "PolicyCompliant"
+	// typeDataFromSyncedSentinel represents whether the DataFrom sources of the Sentinel could be read
+	typeDataFromSyncedSentinel = "DataFromSynced"
+	// typeSecretTypeCompliantSentinel represents whether the secret type reaches the minimum of the namespace
+	typeSecretTypeCompliantSentinel = "SecretTypeCompliant"
 )
 
 const (
@@ -72,11 +82,33 @@ type SentinelReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+	// Notifier is optional and receives the security relevant events, break-glass access,
+	// RBAC failures, drift corrections and rotation failures
+	Notifier notify.Notifier
+	// Policy is optional and enforces the SentinelPolicies on existing Sentinels
+	Policy *policy.Evaluator
+	// AtRestVerifier is optional and checks that the secrets of encrypted types are encrypted in etcd
+	AtRestVerifier *atrest.Verifier
+	// Audit is optional and records the operations on the secrets
+	Audit *audit.Logger
+	// DataFromFileRoot is the directory the file DataFrom sources are resolved in, they are disabled when empty
+	DataFromFileRoot string
+	// AllowedURLs are the URLs below which the HTTP and Vault addresses of the Sentinels and the
+	// servers of the replication targets may be
+	AllowedURLs []string
+	// PushToFileRoot is the directory the file PushTo targets are resolved in, they are disabled when empty
+	PushToFileRoot string
+	// RemoteClient builds the clients of the replication targets, NewRemoteClient is used when nil
+	RemoteClient RemoteClientFunc
 }
 
 //+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinels,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinels/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinels/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -161,11 +193,10 @@ func (r *SentinelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 
 			// Perform all operations required before remove the finalizer and allow
 			// the Kubernetes API to remove the custom resource.
-			r.doFinalizerOperationsForSentinel(sentinel)
-
-			// TODO(user): If you add operations to the doFinalizerOperationsForSentinel method
-			// then you need to ensure that all worked fine before deleting and updating the Downgrade status
-			// otherwise, you should requeue here.
+			if err := r.doFinalizerOperationsForSentinel(sentinel, ctx); err != nil {
+				log.Error(err, "Failed to perform finalizer operations for Sentinel")
+				return ctrl.Result{Requeue: true}, err
+			}
 
 			// Re-fetch the sentinel Custom Resource before update the status
 			// so that we have the latest state of the resource on the cluster and we will avoid
@@ -199,6 +230,27 @@ func (r *SentinelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, nil
 	}
 
+	// Apply and record the emergency access of the Sentinel first, so that a failure of
+	// the following steps does not block it
+	if breakGlassRes, err := r.breakGlassForSentinel(sentinel, ctx, req); err != nil {
+		return breakGlassRes, err
+	}
+
+	// Report a secret type below the minimum of the namespace
+	if err := r.secretTypeFloorForSentinel(sentinel, ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Enforce the SentinelPolicies before the secret is written
+	if policyRes, err := r.policyForSentinel(sentinel, ctx, req); err != nil {
+		return policyRes, err
+	}
+
+	// Create the ServiceAccount the Sentinel owns, also when it was deleted or requested later
+	if serviceAccountRes, err := r.serviceAccountForSentinel(sentinel, ctx, req); err != nil {
+		return serviceAccountRes, err
+	}
+
 	secret, secretForSentinelRes, err := r.secretForSentinel(sentinel, ctx, req)
 	if err != nil {
 		return secretForSentinelRes, err
@@ -207,6 +259,34 @@ func (r *SentinelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	log.Info("Secret is Available now",
 		"Secret.Namespace", secret.Namespace, "Seret.Name", secret.Name)
 
+	// Issue the token of the ServiceAccount the Sentinel owns
+	if tokenRes, err := r.tokenSecretForSentinel(sentinel, ctx, req); err != nil {
+		return tokenRes, err
+	}
+
+	// Rewrite the secret data when a rotation was requested
+	if rotateRes, err := r.rotateSecretForSentinel(sentinel, secret, ctx, req); err != nil {
+		return rotateRes, err
+	}
+
+	// Follow the external secret stores, the result requeues the next poll
+	dataFromRes, err := r.syncDataFromForSentinel(sentinel, secret, ctx, req)
+	if err != nil {
+		return dataFromRes, err
+	}
+
+	// Write the secret to the external stores that have not received its current data
+	pushRes, err := r.pushSecretForSentinel(sentinel, secret, ctx, req)
+	if err != nil {
+		return pushRes, err
+	}
+
+	// Copy the secret into the remote clusters
+	replicationRes, err := r.replicateSecretForSentinel(sentinel, secret, ctx, req)
+	if err != nil {
+		return replicationRes, err
+	}
+
 	// Secret created successfully
 	// We will requeue the reconciliation so that we can ensure the state
 	// and move forward for the next operations
@@ -216,6 +296,11 @@ func (r *SentinelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return validateRes, err
 	}
 
+	// Check the storage layer for the encrypted secret types
+	if atRestRes, err := r.verifyEncryptionAtRest(sentinel, ctx, req); err != nil {
+		return atRestRes, err
+	}
+
 	// The following implementation will update the status
 	meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{
 		Type:   typeAvailableSentinel,
@@ -228,7 +313,7 @@ func (r *SentinelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return earliestResult(dataFromRes, pushRes, replicationRes), nil
 
 }
 
@@ -296,7 +381,7 @@ func (r *SentinelReconciler) validateSecretType(
 }
 
 // finalizeSentinel will perform the required operations before delete the CR.
-func (r *SentinelReconciler) doFinalizerOperationsForSentinel(cr *secopsv1alpha1.Sentinel) {
+func (r *SentinelReconciler) doFinalizerOperationsForSentinel(cr *secopsv1alpha1.Sentinel, ctx context.Context) error {
 	// TODO(user): Add the cleanup steps that the operator
 	// needs to do before the CR can be deleted. Examples
 	// of finalizers include performing backups and deleting
@@ -313,6 +398,26 @@ func (r *SentinelReconciler) doFinalizerOperationsForSentinel(cr *secopsv1alpha1
 	// 	fmt.Sprintf("Custom Resource %s is being deleted from the namespace %s",
 	// 		cr.Name,
 	// 		cr.Namespace))
+
+	secretEncryptedAtRest.DeleteLabelValues(cr.Namespace, cr.Name, cr.Spec.SecretName)
+
+	if err := r.deletePushedSecretsForSentinel(cr, ctx); err != nil {
+		return err
+	}
+
+	if err := r.deleteReplicasForSentinel(cr, ctx); err != nil {
+		return err
+	}
+
+	// The ServiceAccount created by the operator may hold a long lived token Secret,
+	// so it is removed here rather than waiting for the garbage collector.
+	if cr.Spec.CreateServiceAccount && cr.Spec.ServiceAccount != "" {
+		if err := r.deleteOwnedServiceAccount(cr, ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (r *SentinelReconciler) secretForSentinel(
@@ -330,32 +435,42 @@ func (r *SentinelReconciler) secretForSentinel(
 	if err != nil && apierrors.IsNotFound(err) {
 		// Secret does not exist, create a new one
 
-		// Create the Secret data
-		secretData := map[string][]byte{}
-		for key, value := range sentinel.Spec.Data {
-			// Encoding the secret value in order to achive the K8s default behaviour.
-			//secretData[key] = []byte(base64.StdEncoding.EncodeToString([]byte(value)))
-			secretData[key] = []byte(value)
-		}
+		// The envelope encryption of the operator replaces the EncryptionConfiguration of the apiserver
+		localEncryption := sentinel.Spec.Envelope == nil
 
 		//Check the type of the secret
 		if secretType == typeSecretBaseRbac {
 			if validateRbacSecretRes, err := r.validateRbacSecret(sentinel, ctx, req); err != nil {
+				r.notifyRbacFailure(sentinel, ctx, err)
 				return nil, validateRbacSecretRes, err
 			}
-		} else if secretType == typeSecretLocalEncryted {
+		} else if secretType == typeSecretLocalEncryted && localEncryption {
 			if validateLocalEncryptedSecretRes, err := r.validateLocalEncryptedSecret(sentinel, ctx, req); err != nil {
 				return nil, validateLocalEncryptedSecretRes, err
 			}
 		} else if secretType == typeSecretLocalEncrytedRbac {
-			if validateLocalEncryptedSecretRes, err := r.validateLocalEncryptedSecret(sentinel, ctx, req); err != nil {
-				return nil, validateLocalEncryptedSecretRes, err
+			if localEncryption {
+				if validateLocalEncryptedSecretRes, err := r.validateLocalEncryptedSecret(sentinel, ctx, req); err != nil {
+					return nil, validateLocalEncryptedSecretRes, err
+				}
 			}
 			if validateRbacSecretRes, err := r.validateRbacSecret(sentinel, ctx, req); err != nil {
+				r.notifyRbacFailure(sentinel, ctx, err)
 				return nil, validateRbacSecretRes, err
 			}
 		}
 
+		// Create the Secret data
+		secretData, err := r.secretDataForSentinel(sentinel, ctx)
+		if err != nil {
+			log.Error(err, "Secret Data Creation Failed.")
+			// Keep the DataFrom condition of a failed provider
+			if statusErr := r.Status().Update(ctx, sentinel); statusErr != nil {
+				log.Error(statusErr, "Failed to update Sentinel status")
+			}
+			return nil, ctrl.Result{}, err
+		}
+
 		newSecret := &corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      secretName,
@@ -376,6 +491,24 @@ func (r *SentinelReconciler) secretForSentinel(
 			log.Error(err, "Secret Creation Final Step Failed.")
 			return nil, ctrl.Result{}, err
 		}
+		// A missing secret of an available Sentinel was deleted outside of the operator
+		if meta.IsStatusConditionTrue(sentinel.Status.Conditions, typeAvailableSentinel) {
+			message := fmt.Sprintf("Secret %s of Sentinel %s was missing and has been recreated", secretName, sentinel.Name)
+			log.Info(message)
+			if r.Recorder != nil {
+				r.Recorder.Event(sentinel, corev1.EventTypeWarning, "DriftCorrected", message)
+			}
+			notifySentinel(r.Notifier, ctx, sentinel, notify.TypeDriftCorrected, message, map[string]string{"secret": secretName})
+		}
+		if r.Audit != nil {
+			auditLog(r.Audit, ctx, audit.Record{
+				Actor:          audit.ActorOperator,
+				Action:         audit.ActionCreate,
+				Object:         auditObject(sentinel, "Sentinel"),
+				NewContentHash: r.Audit.ContentHash(newSecret.Data),
+				Details:        map[string]string{"secret": newSecret.Name},
+			})
+		}
 
 		return newSecret, ctrl.Result{}, nil
 	} else if err != nil {
@@ -386,6 +519,16 @@ func (r *SentinelReconciler) secretForSentinel(
 	return existSecret, ctrl.Result{}, nil
 }
 
+// notifyRbacFailure sends the failed RBAC validation of a Sentinel
+func (r *SentinelReconciler) notifyRbacFailure(sentinel *secopsv1alpha1.Sentinel, ctx context.Context, err error) {
+	notifySentinel(r.Notifier, ctx, sentinel, notify.TypeRBACFailure,
+		fmt.Sprintf("RBAC validation of Sentinel %s failed: %s", sentinel.Name, err), map[string]string{
+			"role":           sentinel.Spec.Role,
+			"roleBinding":    sentinel.Spec.RoleBinding,
+			"serviceAccount": sentinel.Spec.ServiceAccount,
+		})
+}
+
 func (r *SentinelReconciler) validateRbacSecret(
 	sentinel *secopsv1alpha1.Sentinel, ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
@@ -395,7 +538,7 @@ func (r *SentinelReconciler) validateRbacSecret(
 	inputRole := sentinel.Spec.Role
 	inputRoleBinding := sentinel.Spec.RoleBinding
 	inputNamespace := sentinel.Namespace
-	inputUserType := sentinel.ObjectMeta.GetLabels()["usertype"]
+	inputUserType := subjectKindForSentinel(sentinel)
 
 	if inputRole == "" {
 		inpRoErr := fmt.Errorf("Defining your Role is must under Spec.Role.")
@@ -425,7 +568,8 @@ func (r *SentinelReconciler) validateRbacSecret(
 			// Check if SA exists
 			sa := &corev1.ServiceAccount{}
 			saErr := r.Get(context.TODO(), types.NamespacedName{Name: inputServiceAccount, Namespace: inputNamespace}, sa)
-			if saErr != nil {
+			// An owned ServiceAccount is created by serviceAccountForSentinel, the cache may not hold it yet
+			if saErr != nil && !(apierrors.IsNotFound(saErr) && sentinel.Spec.CreateServiceAccount) {
 				log.Error(saErr, "Service Account must create!")
 
 				meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typeRbacIssueSentinel,
@@ -453,19 +597,7 @@ func (r *SentinelReconciler) validateRbacSecret(
 	if roleErr != nil && apierrors.IsNotFound(roleErr) {
 
 		// Role does not exist, create a new one
-		newRole := &rbacv1.Role{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      inputRole,
-				Namespace: inputNamespace,
-			},
-			Rules: []rbacv1.PolicyRule{
-				{
-					APIGroups: []string{""},        // Empty string represents the core API group
-					Resources: []string{"secrets"}, // Resource types that this Role has access to
-					Verbs:     []string{"get", "list", "watch"},
-				},
-			},
-		}
+		newRole := roleForSentinel(sentinel)
 
 		// Set Sentinel instance as the owner of the Secret
 		if err := controllerutil.SetControllerReference(sentinel, newRole, r.Scheme); err != nil {
@@ -488,25 +620,7 @@ func (r *SentinelReconciler) validateRbacSecret(
 	if roleBindingErr != nil && apierrors.IsNotFound(roleBindingErr) {
 
 		// RoleBinding does not exist, create a new one
-		newRole := &rbacv1.RoleBinding{
-			TypeMeta: metav1.TypeMeta{},
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      inputRoleBinding,
-				Namespace: inputNamespace,
-			},
-			Subjects: []rbacv1.Subject{
-				{
-					Kind:     inputUserType,
-					APIGroup: "",
-					Name:     inputServiceAccount,
-				},
-			},
-			RoleRef: rbacv1.RoleRef{
-				APIGroup: "",
-				Kind:     "Role",
-				Name:     inputRole,
-			},
-		}
+		newRole := roleBindingForSentinel(sentinel, inputUserType)
 
 		// Set Sentinel instance as the owner of the Secret
 		if err := controllerutil.SetControllerReference(sentinel, newRole, r.Scheme); err != nil {
@@ -531,6 +645,220 @@ func (r *SentinelReconciler) validateRbacSecret(
 	return ctrl.Result{}, nil
 }
 
+// serviceAccountForSentinel creates the ServiceAccount of a Sentinel with createServiceAccount
+// when it is missing. It runs on every reconciliation, so the ServiceAccount is also created when
+// the option is turned on for an existing Sentinel or the ServiceAccount was deleted.
+func (r *SentinelReconciler) serviceAccountForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	log := log.FromContext(ctx)
+
+	if !sentinel.Spec.CreateServiceAccount || sentinel.Spec.ServiceAccount == "" {
+		return ctrl.Result{}, nil
+	}
+
+	err := r.Get(ctx, types.NamespacedName{Name: sentinel.Spec.ServiceAccount, Namespace: sentinel.Namespace},
+		&corev1.ServiceAccount{})
+	if err == nil {
+		return ctrl.Result{}, nil
+	} else if !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	newServiceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sentinel.Spec.ServiceAccount,
+			Namespace: sentinel.Namespace,
+			Labels:    labelsForSentinel(sentinel.Name),
+		},
+		AutomountServiceAccountToken: sentinel.Spec.AutomountServiceAccountToken,
+	}
+
+	// Set Sentinel instance as the owner of the ServiceAccount
+	if err := controllerutil.SetControllerReference(sentinel, newServiceAccount, r.Scheme); err != nil {
+		log.Error(err, "Setting Sentinel instance as the owner of the Service Account Failed.")
+		return ctrl.Result{}, err
+	}
+
+	// Create the ServiceAccount
+	if err := r.Create(ctx, newServiceAccount); err != nil && !apierrors.IsAlreadyExists(err) {
+		log.Error(err, "Service Account Creation Final Step Failed.")
+
+		meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typeRbacIssueSentinel,
+			Status: metav1.ConditionFalse, Reason: "CreateFailed",
+			Message: fmt.Sprintf("Service Account Creation Failed (%s): (%s)", sentinel.Name, err)})
+
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// tokenSecretForSentinel creates the token Secret of the ServiceAccount the Sentinel owns when
+// it is missing. It runs on every reconciliation, so a failed creation is retried and a token
+// Secret requested after the ServiceAccount was created is still created.
+func (r *SentinelReconciler) tokenSecretForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	log := log.FromContext(ctx)
+
+	if !sentinel.Spec.CreateServiceAccount || sentinel.Spec.ServiceAccount == "" ||
+		sentinel.Spec.ServiceAccountTokenSecret == "" {
+		return ctrl.Result{}, nil
+	}
+
+	// Tokens are only issued for the ServiceAccount the operator created
+	sa := &corev1.ServiceAccount{}
+	err := r.Get(ctx, types.NamespacedName{Name: sentinel.Spec.ServiceAccount, Namespace: sentinel.Namespace}, sa)
+	if apierrors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !metav1.IsControlledBy(sa, sentinel) {
+		return ctrl.Result{}, nil
+	}
+
+	tokenSecret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: sentinel.Spec.ServiceAccountTokenSecret, Namespace: sentinel.Namespace}, tokenSecret)
+	if err == nil {
+		return ctrl.Result{}, nil
+	} else if !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	// Bound token Secret, the token controller fills the data for the ServiceAccount
+	newTokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sentinel.Spec.ServiceAccountTokenSecret,
+			Namespace: sentinel.Namespace,
+			Labels:    labelsForSentinel(sentinel.Name),
+			Annotations: map[string]string{
+				corev1.ServiceAccountNameKey: sa.Name,
+			},
+		},
+		Type: corev1.SecretTypeServiceAccountToken,
+	}
+
+	// Set Sentinel instance as the owner of the token Secret
+	if err := controllerutil.SetControllerReference(sentinel, newTokenSecret, r.Scheme); err != nil {
+		log.Error(err, "Setting Sentinel instance as the owner of the Token Secret Failed.")
+		return ctrl.Result{}, err
+	}
+
+	// Create the token Secret
+	if err := r.Create(ctx, newTokenSecret); err != nil && !apierrors.IsAlreadyExists(err) {
+		log.Error(err, "Token Secret Creation Final Step Failed.")
+
+		meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typeRbacIssueSentinel,
+			Status: metav1.ConditionFalse, Reason: "CreateFailed",
+			Message: fmt.Sprintf("Token Secret Creation Failed (%s): (%s)", sentinel.Name, err)})
+
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// deleteOwnedServiceAccount removes the ServiceAccount and the token Secret only when
+// they are controlled by the given Sentinel, user managed objects are never touched.
+func (r *SentinelReconciler) deleteOwnedServiceAccount(cr *secopsv1alpha1.Sentinel, ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	if cr.Spec.ServiceAccountTokenSecret != "" {
+		tokenSecret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: cr.Spec.ServiceAccountTokenSecret, Namespace: cr.Namespace}, tokenSecret)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err == nil && metav1.IsControlledBy(tokenSecret, cr) {
+			log.Info("Deleting Token Secret owned by Sentinel", "Secret.Name", tokenSecret.Name)
+			if err := r.Delete(ctx, tokenSecret); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	sa := &corev1.ServiceAccount{}
+	err := r.Get(ctx, types.NamespacedName{Name: cr.Spec.ServiceAccount, Namespace: cr.Namespace}, sa)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !metav1.IsControlledBy(sa, cr) {
+		return nil
+	}
+
+	log.Info("Deleting Service Account owned by Sentinel", "ServiceAccount.Name", sa.Name)
+	if err := r.Delete(ctx, sa); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// roleForSentinel returns the Role of spec.role the Sentinel creates
+func roleForSentinel(sentinel *secopsv1alpha1.Sentinel) *rbacv1.Role {
+	return &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sentinel.Spec.Role,
+			Namespace: sentinel.Namespace,
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{""},        // Empty string represents the core API group
+				Resources: []string{"secrets"}, // Resource types that this Role has access to
+				Verbs:     []string{"get", "list", "watch"},
+			},
+		},
+	}
+}
+
+// roleBindingForSentinel returns the RoleBinding of spec.roleBinding the Sentinel creates for
+// the subject of the given kind
+func roleBindingForSentinel(sentinel *secopsv1alpha1.Sentinel, kind string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sentinel.Spec.RoleBinding,
+			Namespace: sentinel.Namespace,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      kind,
+				APIGroup:  "",
+				Name:      sentinel.Spec.ServiceAccount,
+				Namespace: subjectNamespace(kind, sentinel.Namespace),
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "",
+			Kind:     "Role",
+			Name:     sentinel.Spec.Role,
+		},
+	}
+}
+
+// subjectKindForSentinel returns the kind of the RoleBinding subject from the usertype label,
+// the operator owned ServiceAccount is always a ServiceAccount subject
+func subjectKindForSentinel(sentinel *secopsv1alpha1.Sentinel) string {
+	kind := sentinel.ObjectMeta.GetLabels()["usertype"]
+	if sentinel.Spec.CreateServiceAccount && kind == "" {
+		kind = rbacv1.ServiceAccountKind
+	}
+	return kind
+}
+
+// subjectNamespace returns the namespace of a RoleBinding subject, only
+// ServiceAccount subjects are namespaced.
+func subjectNamespace(kind string, namespace string) string {
+	if kind == rbacv1.ServiceAccountKind {
+		return namespace
+	}
+	return ""
+}
+
 func (r *SentinelReconciler) validateLocalEncryptedSecret(
 	sentinel *secopsv1alpha1.Sentinel, ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
@@ -631,7 +959,11 @@ func labelsForSentinel(name string) map[string]string {
 	var imageTag string
 	image, err := imageForSentinel()
 	if err == nil {
-		imageTag = strings.Split(image, ":")[1]
+		// The tag follows the last colon after the registry host, an image without a tag has none
+		image, _, _ = strings.Cut(image, "@")
+		if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+			imageTag = image[i+1:]
+		}
 	}
 	return map[string]string{"app.kubernetes.io/name": "Sentinel",
 		"app.kubernetes.io/instance":   name,
@@ -657,5 +989,10 @@ func (r *SentinelReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&secopsv1alpha1.Sentinel{}).
 		Owns(&appsv1.Deployment{}).
+		// Changes of the secret are pushed to the PushTo targets
+		Owns(&corev1.Secret{}).
+		// A deleted ServiceAccount of the Sentinel is created again
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&secopsv1alpha1.SentinelAccessGrant{}).
 		Complete(r)
 }
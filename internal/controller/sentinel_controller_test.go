@@ -0,0 +1,172 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// newTestScheme returns a scheme with the built-in types and the secops types
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := secopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+// newTestSentinelReconciler returns a SentinelReconciler on a fake client that holds the
+// namespace prod and the given objects
+func newTestSentinelReconciler(t *testing.T, objects ...client.Object) *SentinelReconciler {
+	t.Helper()
+	scheme := newTestScheme(t)
+	objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod"}})
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+		WithStatusSubresource(&secopsv1alpha1.Sentinel{}).Build()
+	return &SentinelReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(100)}
+}
+
+// reconcileSentinel runs the reconciliation of a Sentinel of the namespace prod until it
+// does not ask for an immediate requeue, the finalizer and the first status take passes
+func reconcileSentinel(t *testing.T, r *SentinelReconciler, name string) (ctrl.Result, error) {
+	t.Helper()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: "prod"}}
+	var result ctrl.Result
+	var err error
+	for i := 0; i < 3; i++ {
+		if result, err = r.Reconcile(context.Background(), req); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func TestServiceAccountForSentinel(t *testing.T) {
+	ctx := context.Background()
+	sentinel := &secopsv1alpha1.Sentinel{
+		TypeMeta:   metav1.TypeMeta{Kind: "Sentinel", APIVersion: secopsv1alpha1.GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "prod"},
+		Spec: secopsv1alpha1.SentinelSpec{
+			SecretName: "orders-db", SecretType: typeSecretBaseRbac, Data: map[string]string{"password": "s3cr3t"},
+			ServiceAccount: "orders-api", Role: "orders-reader", RoleBinding: "orders-reader",
+			CreateServiceAccount: true,
+		},
+	}
+	r := newTestSentinelReconciler(t, sentinel)
+	if _, err := reconcileSentinel(t, r, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sentinel), sentinel); err != nil {
+		t.Fatal(err)
+	}
+	sa := &corev1.ServiceAccount{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "orders-api", Namespace: "prod"}, sa); err != nil {
+		t.Fatal(err)
+	}
+	if !metav1.IsControlledBy(sa, sentinel) {
+		t.Error("ServiceAccount not owned by the Sentinel")
+	}
+
+	// A deleted ServiceAccount is created again
+	if err := r.Delete(ctx, sa); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reconcileSentinel(t, r, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sa), sa); err != nil {
+		t.Fatalf("ServiceAccount not recreated: %v", err)
+	}
+
+	// A token Secret requested after the ServiceAccount exists is still created
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sentinel), sentinel); err != nil {
+		t.Fatal(err)
+	}
+	sentinel.Spec.ServiceAccountTokenSecret = "orders-api-token"
+	if err := r.Update(ctx, sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reconcileSentinel(t, r, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	token := &corev1.Secret{}
+	tokenKey := types.NamespacedName{Name: "orders-api-token", Namespace: "prod"}
+	if err := r.Get(ctx, tokenKey, token); err != nil {
+		t.Fatal(err)
+	}
+	if token.Type != corev1.SecretTypeServiceAccountToken || token.Annotations[corev1.ServiceAccountNameKey] != "orders-api" {
+		t.Errorf("token Secret %+v", token)
+	}
+
+	// A deleted token Secret is created again
+	if err := r.Delete(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reconcileSentinel(t, r, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, tokenKey, &corev1.Secret{}); err != nil {
+		t.Fatalf("token Secret not recreated: %v", err)
+	}
+
+	// The finalizer removes the ServiceAccount and its token
+	if err := r.Delete(ctx, sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reconcileSentinel(t, r, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, tokenKey, &corev1.Secret{}); !apierrors.IsNotFound(err) {
+		t.Errorf("token Secret not deleted: %v", err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sa), &corev1.ServiceAccount{}); !apierrors.IsNotFound(err) {
+		t.Errorf("ServiceAccount not deleted: %v", err)
+	}
+}
+
+func TestLabelsForSentinel(t *testing.T) {
+	for image, version := range map[string]string{
+		"sentinel:1.2.0":                         "1.2.0",
+		"sentinel":                               "",
+		"registry.local:5000/sentinel":           "",
+		"registry.local:5000/sentinel:1.2.0":     "1.2.0",
+		"registry.local/sentinel@sha256:0123abc": "",
+	} {
+		t.Setenv("SENTINEL_IMAGE", image)
+		if got := labelsForSentinel("orders")["app.kubernetes.io/version"]; got != version {
+			t.Errorf("%s: version %q", image, got)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+	"github.com/kavinduxo/sentinel-operator/internal/provider"
+)
+
+// defaultRefreshInterval is used when a Sentinel with DataFrom sources has no RefreshInterval
+const defaultRefreshInterval = 5 * time.Minute
+
+// refreshIntervalForSentinel is the wait between two polls of the DataFrom sources
+func refreshIntervalForSentinel(sentinel *secopsv1alpha1.Sentinel) time.Duration {
+	if sentinel.Spec.RefreshInterval != nil && sentinel.Spec.RefreshInterval.Duration > 0 {
+		return sentinel.Spec.RefreshInterval.Duration
+	}
+	return defaultRefreshInterval
+}
+
+// dataFromSourcesForSentinel builds the providers of the DataFrom sources of a Sentinel
+func (r *SentinelReconciler) dataFromSourcesForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context) ([]provider.Source, error) {
+
+	sources := make([]provider.Source, 0, len(sentinel.Spec.DataFrom))
+	for i, spec := range sentinel.Spec.DataFrom {
+		source := provider.Source{Prefix: spec.Prefix}
+		switch {
+		case spec.Vault != nil && spec.HTTP == nil && spec.File == nil:
+			if err := r.checkURLAllowed("DataFrom Vault address", spec.Vault.Address); err != nil {
+				return nil, err
+			}
+			token, err := r.secretKeyValue(ctx, sentinel.Namespace, &spec.Vault.TokenSecretRef)
+			if err != nil {
+				return nil, err
+			}
+			source.Provider = &provider.VaultKV{
+				Address: spec.Vault.Address,
+				Mount:   spec.Vault.Mount,
+				Path:    spec.Vault.Path,
+				Version: int(spec.Vault.Version),
+				Token:   string(token),
+			}
+
+		case spec.HTTP != nil && spec.Vault == nil && spec.File == nil:
+			if err := r.checkURLAllowed("DataFrom URL", spec.HTTP.URL); err != nil {
+				return nil, err
+			}
+			httpProvider := &provider.HTTPJSON{URL: spec.HTTP.URL, Path: spec.HTTP.Path}
+			if spec.HTTP.TokenSecretRef != nil {
+				token, err := r.secretKeyValue(ctx, sentinel.Namespace, spec.HTTP.TokenSecretRef)
+				if err != nil {
+					return nil, err
+				}
+				httpProvider.Token = string(token)
+			}
+			source.Provider = httpProvider
+
+		case spec.File != nil && spec.Vault == nil && spec.HTTP == nil:
+			dir, err := provider.ResolvePath(r.DataFromFileRoot, spec.File.Path)
+			if err != nil {
+				return nil, err
+			}
+			source.Provider = &provider.FileDir{Dir: dir}
+
+		default:
+			return nil, fmt.Errorf("DataFrom source %d of Sentinel %s must set exactly one of vault, http and file", i, sentinel.Name)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// checkURLAllowed returns an error unless the URL is below one of the allowed URLs. The operator
+// reaches networks the Sentinel authors can not, so every address it connects to is allow listed.
+func (r *SentinelReconciler) checkURLAllowed(what string, rawURL string) error {
+	if !urlAllowed(rawURL, r.AllowedURLs) {
+		return fmt.Errorf("%s %s is not allowed by the operator", what, rawURL)
+	}
+	return nil
+}
+
+// urlAllowed reports whether the scheme and the host of the URL equal those of an allowed URL
+// and its path is the path of the allowed URL or below it
+func urlAllowed(rawURL string, allowedURLs []string) bool {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Host == "" || target.User != nil {
+		return false
+	}
+	targetPath := path.Clean("/" + target.Path)
+	for _, rawAllowed := range allowedURLs {
+		allowed, err := url.Parse(strings.TrimSpace(rawAllowed))
+		if err != nil || allowed.Host == "" {
+			continue
+		}
+		if !strings.EqualFold(allowed.Scheme, target.Scheme) || !strings.EqualFold(allowed.Host, target.Host) {
+			continue
+		}
+		allowedPath := strings.TrimSuffix(path.Clean("/"+allowed.Path), "/")
+		if allowedPath == "" || targetPath == allowedPath || strings.HasPrefix(targetPath, allowedPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// dataFromForSentinel reads the DataFrom sources of a Sentinel. A failure is reported on
+// the DataFromSynced condition.
+func (r *SentinelReconciler) dataFromForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context) (map[string][]byte, error) {
+
+	sources, err := r.dataFromSourcesForSentinel(sentinel, ctx)
+	if err == nil {
+		var data map[string][]byte
+		if data, err = provider.Merge(ctx, sources); err == nil {
+			return data, nil
+		}
+	}
+
+	meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typeDataFromSyncedSentinel,
+		Status: metav1.ConditionFalse, Reason: "ProviderError", Message: err.Error()})
+	if r.Recorder != nil {
+		r.Recorder.Event(sentinel, corev1.EventTypeWarning, "DataFromFailed", err.Error())
+	}
+	return nil, err
+}
+
+// syncDataFromForSentinel rewrites the secret when the data of the DataFrom sources changed and
+// requeues the Sentinel for the next poll. A provider error keeps the current data of the
+// secret, it is reported on the DataFromSynced condition without failing the reconciliation.
+func (r *SentinelReconciler) syncDataFromForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, secret *corev1.Secret, ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	log := log.FromContext(ctx)
+
+	if len(sentinel.Spec.DataFrom) == 0 {
+		meta.RemoveStatusCondition(&sentinel.Status.Conditions, typeDataFromSyncedSentinel)
+		return ctrl.Result{}, nil
+	}
+	result := ctrl.Result{RequeueAfter: refreshIntervalForSentinel(sentinel)}
+
+	desired, err := r.plainSecretDataForSentinel(sentinel, ctx)
+	if err != nil {
+		log.Error(err, "Data From Sync Failed.")
+		return result, nil
+	}
+
+	current, err := r.openSecretData(sentinel, secret, ctx)
+	if err != nil {
+		log.Error(err, "Data From Sync Failed.")
+		return ctrl.Result{}, err
+	}
+
+	if !equalSecretData(current, desired) {
+		secretData := desired
+		if sentinel.Spec.Envelope != nil {
+			if secretData, err = r.sealSecretData(sentinel, ctx, desired); err != nil {
+				log.Error(err, "Data From Sync Failed.")
+				return ctrl.Result{}, err
+			}
+		}
+		previousData := secret.Data
+		secret.Data = secretData
+		if err := r.Update(ctx, secret); err != nil {
+			log.Error(err, "Data From Sync Failed.")
+			return ctrl.Result{}, err
+		}
+
+		message := fmt.Sprintf("Secret %s updated from %d DataFrom sources", secret.Name, len(sentinel.Spec.DataFrom))
+		log.Info(message, "Sentinel.Name", sentinel.Name)
+		if r.Recorder != nil {
+			r.Recorder.Event(sentinel, corev1.EventTypeNormal, "DataFromSynced", message)
+		}
+		if r.Audit != nil {
+			auditLog(r.Audit, ctx, audit.Record{
+				Actor:               audit.ActorOperator,
+				Action:              audit.ActionUpdate,
+				Object:              auditObject(sentinel, "Sentinel"),
+				PreviousContentHash: r.Audit.ContentHash(previousData),
+				NewContentHash:      r.Audit.ContentHash(secretData),
+				Details:             map[string]string{"secret": secret.Name, "source": "dataFrom"},
+			})
+		}
+	}
+
+	meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typeDataFromSyncedSentinel,
+		Status: metav1.ConditionTrue, Reason: "Synced",
+		Message: fmt.Sprintf("Secret %s follows %d DataFrom sources", secret.Name, len(sentinel.Spec.DataFrom))})
+	return result, nil
+}
+
+// equalSecretData compares the keys and values of two secret data maps
+func equalSecretData(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		other, ok := b[key]
+		if !ok || !bytes.Equal(value, other) {
+			return false
+		}
+	}
+	return true
+}
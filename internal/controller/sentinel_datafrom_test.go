@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+func TestURLAllowed(t *testing.T) {
+	allowed := []string{"https://vault.corp", "https://config.example.com/api/", " http://vault.vault.svc:8200 "}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://vault.corp", want: true},
+		{url: "https://vault.corp/v1/secret", want: true},
+		{url: "https://VAULT.corp/", want: true},
+		{url: "https://vault.corp.attacker.net", want: false},
+		{url: "https://vault.corp:8443", want: false},
+		{url: "http://vault.corp", want: false},
+		{url: "https://vault.corp@attacker.net", want: false},
+		{url: "https://attacker.net@vault.corp", want: false},
+		{url: "https://config.example.com/api", want: true},
+		{url: "https://config.example.com/api/payments.json", want: true},
+		{url: "https://config.example.com/apikeys", want: false},
+		{url: "https://config.example.com/api/../admin", want: false},
+		{url: "https://config.example.com/api/%2e%2e/admin", want: false},
+		{url: "http://vault.vault.svc:8200", want: true},
+		{url: "http://vault.vault.svc", want: false},
+		{url: "vault.corp", want: false},
+		{url: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := urlAllowed(tt.url, allowed); got != tt.want {
+			t.Errorf("urlAllowed(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	if urlAllowed("https://vault.corp", nil) {
+		t.Error("urlAllowed() without allowed URLs")
+	}
+}
+
+func TestVaultAddressesAllowed(t *testing.T) {
+	ctx := context.Background()
+	token := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "vault-token", Namespace: "prod"},
+		Data: map[string][]byte{"token": []byte("s.token")}}
+	tokenRef := corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "vault-token"}, Key: "token"}
+	r := newTestSentinelReconciler(t, token)
+	r.AllowedURLs = []string{"https://vault.corp"}
+
+	sentinel := &secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "prod"},
+		Spec: secopsv1alpha1.SentinelSpec{
+			DataFrom: []secopsv1alpha1.DataFromSource{{Vault: &secopsv1alpha1.VaultKVSource{
+				Address: "https://vault.corp.attacker.net", Path: "orders", TokenSecretRef: tokenRef}}},
+			Envelope: &secopsv1alpha1.EnvelopeSpec{VaultTransit: &secopsv1alpha1.VaultTransitSpec{
+				Address: "https://vault.corp.attacker.net", KeyName: "orders", TokenSecretRef: tokenRef}},
+		},
+	}
+	target := &secopsv1alpha1.PushTarget{Name: "legacy", Vault: &secopsv1alpha1.VaultKVTarget{
+		Address: "https://vault.corp.attacker.net", Path: "orders", TokenSecretRef: tokenRef}}
+
+	if _, err := r.dataFromSourcesForSentinel(sentinel, ctx); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("DataFrom Vault address: %v", err)
+	}
+	if _, err := r.keyWrapperForSentinel(sentinel, ctx); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("Envelope Vault address: %v", err)
+	}
+	if _, err := r.storeForPushTarget(sentinel, target, ctx); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("PushTo Vault address: %v", err)
+	}
+
+	sentinel.Spec.DataFrom[0].Vault.Address = "https://vault.corp"
+	sentinel.Spec.Envelope.VaultTransit.Address = "https://vault.corp"
+	target.Vault.Address = "https://vault.corp"
+	if _, err := r.dataFromSourcesForSentinel(sentinel, ctx); err != nil {
+		t.Errorf("allowed DataFrom Vault address: %v", err)
+	}
+	if _, err := r.keyWrapperForSentinel(sentinel, ctx); err != nil {
+		t.Errorf("allowed Envelope Vault address: %v", err)
+	}
+	if _, err := r.storeForPushTarget(sentinel, target, ctx); err != nil {
+		t.Errorf("allowed PushTo Vault address: %v", err)
+	}
+}
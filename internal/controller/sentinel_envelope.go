@@ -0,0 +1,99 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+// keyWrapperForSentinel builds the master key of the envelope encryption of a Sentinel
+func (r *SentinelReconciler) keyWrapperForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context) (envelope.KeyWrapper, error) {
+
+	spec := sentinel.Spec.Envelope
+	switch {
+	case spec.MasterKeySecretRef != nil && spec.VaultTransit != nil:
+		return nil, fmt.Errorf("Envelope of Sentinel %s sets both masterKeySecretRef and vaultTransit", sentinel.Name)
+
+	case spec.MasterKeySecretRef != nil:
+		key, err := r.secretKeyValue(ctx, sentinel.Namespace, spec.MasterKeySecretRef)
+		if err != nil {
+			return nil, err
+		}
+		return envelope.NewMasterKey(key)
+
+	case spec.VaultTransit != nil:
+		if err := r.checkURLAllowed("Envelope Vault address", spec.VaultTransit.Address); err != nil {
+			return nil, err
+		}
+		token, err := r.secretKeyValue(ctx, sentinel.Namespace, &spec.VaultTransit.TokenSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		return &envelope.VaultTransit{
+			Address: spec.VaultTransit.Address,
+			Mount:   spec.VaultTransit.Mount,
+			KeyName: spec.VaultTransit.KeyName,
+			Token:   string(token),
+		}, nil
+	}
+	return nil, fmt.Errorf("Envelope of Sentinel %s sets neither masterKeySecretRef nor vaultTransit", sentinel.Name)
+}
+
+// secretKeyValue reads the value selected by a SecretKeySelector
+func (r *SentinelReconciler) secretKeyValue(ctx context.Context, namespace string, selector *corev1.SecretKeySelector) ([]byte, error) {
+	return readSecretKey(ctx, r.Client, namespace, selector)
+}
+
+// readSecretKey reads the value selected by a SecretKeySelector in a namespace
+func readSecretKey(ctx context.Context, reader client.Reader, namespace string, selector *corev1.SecretKeySelector) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := reader.Get(ctx, types.NamespacedName{Name: selector.Name, Namespace: namespace}, secret); err != nil {
+		return nil, fmt.Errorf("Failed to get Secret %s: %w", selector.Name, err)
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return nil, fmt.Errorf("Key %s not found in Secret %s", selector.Key, selector.Name)
+	}
+	return value, nil
+}
+
+// sealSecretData encrypts the secret data with the envelope of the Sentinel
+func (r *SentinelReconciler) sealSecretData(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context, secretData map[string][]byte) (map[string][]byte, error) {
+
+	wrapper, err := r.keyWrapperForSentinel(sentinel, ctx)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := envelope.Seal(ctx, wrapper, secretData)
+	if err != nil {
+		return nil, err
+	}
+	r.Recorder.Event(sentinel, corev1.EventTypeNormal, "EnvelopeEncrypted",
+		fmt.Sprintf("Secret %s is encrypted with a data key wrapped by %s key %s",
+			sentinel.Spec.SecretName, wrapper.Provider(), wrapper.KeyID()))
+	return sealed, nil
+}
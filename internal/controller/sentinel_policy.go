@@ -0,0 +1,122 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/policy"
+)
+
+// policyForSentinel enforces the SentinelPolicies on the Sentinel and lists the violations
+// in the status. The secret is not created or updated while an enforced rule is violated.
+func (r *SentinelReconciler) policyForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	log := log.FromContext(ctx)
+
+	if r.Policy == nil {
+		return ctrl.Result{}, nil
+	}
+
+	violations, err := r.Policy.Evaluate(ctx, sentinel, nil)
+	if err != nil {
+		log.Error(err, "Failed to evaluate the SentinelPolicies")
+		return ctrl.Result{}, err
+	}
+	sentinel.Status.PolicyViolations = violations
+
+	enforced := policy.WithAction(violations, secopsv1alpha1.PolicyActionEnforce)
+	if warned := policy.WithAction(violations, secopsv1alpha1.PolicyActionWarn); len(warned) > 0 && r.Recorder != nil {
+		r.Recorder.Event(sentinel, corev1.EventTypeWarning, "PolicyWarning", policy.Join(warned))
+	}
+
+	if len(enforced) == 0 {
+		meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typePolicyCompliantSentinel,
+			Status: metav1.ConditionTrue, Reason: "Compliant",
+			Message: fmt.Sprintf("Sentinel %s follows all enforced SentinelPolicies, %d warn or audit violations",
+				sentinel.Name, len(violations))})
+		return ctrl.Result{}, nil
+	}
+
+	message := policy.Join(enforced)
+	policyErr := fmt.Errorf("Sentinel %s violates the SentinelPolicies: %s", sentinel.Name, message)
+	log.Error(policyErr, "Policy Violation!")
+
+	meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typePolicyCompliantSentinel,
+		Status: metav1.ConditionFalse, Reason: "PolicyViolation", Message: message})
+	meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typeAvailableSentinel,
+		Status: metav1.ConditionFalse, Reason: "PolicyViolation",
+		Message: fmt.Sprintf("Secret for custom resource is not reconciled (%s): (%s)", sentinel.Name, policyErr)})
+
+	if r.Recorder != nil {
+		r.Recorder.Event(sentinel, corev1.EventTypeWarning, "PolicyViolation", message)
+	}
+
+	if err := r.Status().Update(ctx, sentinel); err != nil {
+		log.Error(err, "Failed to update Sentinel status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, policyErr
+}
+
+// secretTypeFloorForSentinel reports on the SecretTypeCompliant condition whether the secret
+// type of the Sentinel reaches the minimum of its namespace. New Sentinels below the minimum
+// are rejected by the validating webhook. Existing ones keep their spec and secret, their
+// owners are asked to raise the type.
+func (r *SentinelReconciler) secretTypeFloorForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context) error {
+
+	log := log.FromContext(ctx)
+
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: sentinel.Namespace}, namespace); err != nil {
+		log.Error(err, "Failed to get the namespace of the sentinel")
+		return err
+	}
+
+	if policy.MinimumSecretType(namespace) == "" {
+		meta.RemoveStatusCondition(&sentinel.Status.Conditions, typeSecretTypeCompliantSentinel)
+		return nil
+	}
+
+	violation := policy.CheckSecretType(namespace, sentinel.Spec.SecretType)
+	if violation == nil {
+		meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typeSecretTypeCompliantSentinel,
+			Status: metav1.ConditionTrue, Reason: "Compliant",
+			Message: fmt.Sprintf("Secret type %s reaches the minimum of namespace %s",
+				sentinel.Spec.SecretType, sentinel.Namespace)})
+		return nil
+	}
+
+	if !meta.IsStatusConditionFalse(sentinel.Status.Conditions, typeSecretTypeCompliantSentinel) && r.Recorder != nil {
+		r.Recorder.Event(sentinel, corev1.EventTypeWarning, "SecretTypeBelowMinimum", violation.Message)
+	}
+	meta.SetStatusCondition(&sentinel.Status.Conditions, metav1.Condition{Type: typeSecretTypeCompliantSentinel,
+		Status: metav1.ConditionFalse, Reason: "BelowMinimum", Message: violation.Message})
+	return nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+func TestSecretTypeFloorForSentinel(t *testing.T) {
+	ctx := context.Background()
+	sentinel := &secopsv1alpha1.Sentinel{
+		TypeMeta:   metav1.TypeMeta{Kind: "Sentinel", APIVersion: secopsv1alpha1.GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "prod"},
+		Spec: secopsv1alpha1.SentinelSpec{
+			SecretName: "orders-db", SecretType: typeSecretBase, Data: map[string]string{"password": "s3cr3t"},
+		},
+	}
+	r := newTestSentinelReconciler(t, sentinel)
+	if _, err := reconcileSentinel(t, r, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	secretKey := types.NamespacedName{Name: "orders-db", Namespace: "prod"}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
+		t.Fatal(err)
+	}
+
+	// The namespace raises its minimum above the type of the existing Sentinel
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "prod"}, namespace); err != nil {
+		t.Fatal(err)
+	}
+	namespace.Annotations = map[string]string{secopsv1alpha1.MinimumSecretTypeAnnotation: typeSecretLocalEncryted}
+	if err := r.Update(ctx, namespace); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reconcileSentinel(t, r, "orders"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sentinel), sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if sentinel.Spec.SecretType != typeSecretBase {
+		t.Errorf("secret type changed to %s", sentinel.Spec.SecretType)
+	}
+	current := &corev1.Secret{}
+	if err := r.Get(ctx, secretKey, current); err != nil {
+		t.Fatalf("secret of the Sentinel below the minimum: %v", err)
+	}
+	if current.UID != secret.UID || string(current.Data["password"]) != "s3cr3t" {
+		t.Errorf("secret replaced: %+v", current)
+	}
+	condition := meta.FindStatusCondition(sentinel.Status.Conditions, typeSecretTypeCompliantSentinel)
+	if condition == nil || condition.Status != metav1.ConditionFalse ||
+		!strings.Contains(condition.Message, "use "+typeSecretLocalEncryted) {
+		t.Errorf("SecretTypeCompliant condition %+v", condition)
+	}
+
+	// Without the minimum the condition goes away
+	namespace.Annotations = nil
+	if err := r.Update(ctx, namespace); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reconcileSentinel(t, r, "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sentinel), sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if condition := meta.FindStatusCondition(sentinel.Status.Conditions, typeSecretTypeCompliantSentinel); condition != nil {
+		t.Errorf("SecretTypeCompliant condition without minimum %+v", condition)
+	}
+}
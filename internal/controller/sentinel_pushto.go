@@ -0,0 +1,254 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+	"github.com/kavinduxo/sentinel-operator/internal/provider"
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+// pushRetryInterval is the wait before a failed push is retried
+const pushRetryInterval = time.Minute
+
+// storeForPushTarget builds the store of a PushTo target of a Sentinel
+func (r *SentinelReconciler) storeForPushTarget(
+	sentinel *secopsv1alpha1.Sentinel, target *secopsv1alpha1.PushTarget, ctx context.Context) (provider.Store, error) {
+
+	switch {
+	case target.Vault != nil && target.File == nil:
+		if err := r.checkURLAllowed("PushTo Vault address", target.Vault.Address); err != nil {
+			return nil, err
+		}
+		token, err := r.secretKeyValue(ctx, sentinel.Namespace, &target.Vault.TokenSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		return &provider.VaultKV{
+			Address: target.Vault.Address,
+			Mount:   target.Vault.Mount,
+			Path:    target.Vault.Path,
+			Token:   string(token),
+		}, nil
+
+	case target.File != nil && target.Vault == nil:
+		dir, err := provider.ResolvePath(r.PushToFileRoot, target.File.Path)
+		if err != nil {
+			return nil, err
+		}
+		return &provider.FileDir{Dir: dir}, nil
+	}
+	return nil, fmt.Errorf("PushTo target %s of Sentinel %s must set exactly one of vault and file", target.Name, sentinel.Name)
+}
+
+// openSecretData returns the plaintext data of the secret of a Sentinel, sealed data is opened
+// with the envelope of the Sentinel
+func (r *SentinelReconciler) openSecretData(
+	sentinel *secopsv1alpha1.Sentinel, secret *corev1.Secret, ctx context.Context) (map[string][]byte, error) {
+
+	if sentinel.Spec.Envelope == nil || !envelope.IsSealed(secret.Data) {
+		return secret.Data, nil
+	}
+	wrapper, err := r.keyWrapperForSentinel(sentinel, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Open(ctx, wrapper, secret.Data)
+}
+
+// pushSecretForSentinel writes the data of the secret to the PushTo targets that have not
+// received the current version of the secret yet and records the result per target. The data
+// of targets removed from the spec with the Delete policy is removed. A failed push or removal
+// does not fail the reconciliation, it is retried after pushRetryInterval.
+func (r *SentinelReconciler) pushSecretForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, secret *corev1.Secret, ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	log := log.FromContext(ctx)
+
+	result := ctrl.Result{}
+	var data map[string][]byte
+	statuses := make([]secopsv1alpha1.PushTargetStatus, 0, len(sentinel.Spec.PushTo))
+	for _, status := range removedPushTargets(sentinel) {
+		if err := r.deletePushedSecret(sentinel, status.Pushed, ctx); err != nil {
+			log.Error(err, "Pushed Secret Deletion Failed.", "target", status.Name)
+			// The status keeps the removed target until its data is deleted
+			status.Synced = false
+			status.Message = fmt.Sprintf("Target removed from the spec, deleting its data failed: %s", err)
+			statuses = append(statuses, status)
+			if r.Recorder != nil {
+				r.Recorder.Event(sentinel, corev1.EventTypeWarning, "PushDeleteFailed",
+					fmt.Sprintf("Deletion of the data of removed target %s failed: %s", status.Name, err))
+			}
+			result.RequeueAfter = pushRetryInterval
+			continue
+		}
+		if r.Recorder != nil {
+			r.Recorder.Event(sentinel, corev1.EventTypeNormal, "PushedSecretDeleted",
+				fmt.Sprintf("Data of removed target %s deleted", status.Name))
+		}
+	}
+
+	for i := range sentinel.Spec.PushTo {
+		target := &sentinel.Spec.PushTo[i]
+		status := secopsv1alpha1.PushTargetStatus{Name: target.Name}
+		for _, previous := range sentinel.Status.PushTargets {
+			if previous.Name == target.Name {
+				status = previous
+			}
+		}
+		if status.Synced && status.SecretResourceVersion == secret.ResourceVersion &&
+			status.ObservedGeneration == sentinel.Generation {
+			statuses = append(statuses, status)
+			continue
+		}
+
+		store, err := r.storeForPushTarget(sentinel, target, ctx)
+		if err == nil && data == nil {
+			data, err = r.openSecretData(sentinel, secret, ctx)
+		}
+		if err == nil {
+			err = store.Push(ctx, data)
+		}
+
+		if err != nil {
+			log.Error(err, "Secret Push Failed.", "target", target.Name)
+			status.Synced = false
+			status.Message = err.Error()
+			if r.Recorder != nil {
+				r.Recorder.Event(sentinel, corev1.EventTypeWarning, "PushFailed",
+					fmt.Sprintf("Push of secret %s to %s failed: %s", secret.Name, target.Name, err))
+			}
+			result.RequeueAfter = pushRetryInterval
+		} else {
+			now := metav1.Now()
+			status.Synced = true
+			status.Message = fmt.Sprintf("Pushed %d keys to %s", len(data), store.Name())
+			status.SecretResourceVersion = secret.ResourceVersion
+			status.ObservedGeneration = sentinel.Generation
+			status.LastPushTime = &now
+			status.Pushed = target.DeepCopy()
+			log.Info(status.Message, "Sentinel.Name", sentinel.Name, "target", target.Name)
+			if r.Recorder != nil {
+				r.Recorder.Event(sentinel, corev1.EventTypeNormal, "SecretPushed",
+					fmt.Sprintf("Secret %s pushed to %s", secret.Name, target.Name))
+			}
+			if r.Audit != nil {
+				auditLog(r.Audit, ctx, audit.Record{
+					Actor:          audit.ActorOperator,
+					Action:         audit.ActionPush,
+					Object:         auditObject(sentinel, "Sentinel"),
+					NewContentHash: r.Audit.ContentHash(data),
+					Details:        map[string]string{"secret": secret.Name, "target": target.Name, "store": store.Name()},
+				})
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	if len(statuses) == 0 {
+		statuses = nil
+	}
+	sentinel.Status.PushTargets = statuses
+	return result, nil
+}
+
+// removedPushTargets returns the status of the targets that are no longer in the spec and were
+// pushed to with the Delete policy
+func removedPushTargets(sentinel *secopsv1alpha1.Sentinel) []secopsv1alpha1.PushTargetStatus {
+	var removed []secopsv1alpha1.PushTargetStatus
+	for _, status := range sentinel.Status.PushTargets {
+		if status.Pushed == nil || status.Pushed.DeletionPolicy != secopsv1alpha1.PushDeletionDelete {
+			continue
+		}
+		inSpec := false
+		for i := range sentinel.Spec.PushTo {
+			inSpec = inSpec || sentinel.Spec.PushTo[i].Name == status.Name
+		}
+		if !inSpec {
+			removed = append(removed, status)
+		}
+	}
+	return removed
+}
+
+// deletePushedSecret removes the data of a target from its store
+func (r *SentinelReconciler) deletePushedSecret(
+	sentinel *secopsv1alpha1.Sentinel, target *secopsv1alpha1.PushTarget, ctx context.Context) error {
+
+	store, err := r.storeForPushTarget(sentinel, target, ctx)
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(ctx); err != nil {
+		return fmt.Errorf("Failed to delete the pushed secret of target %s: %w", target.Name, err)
+	}
+	log.FromContext(ctx).Info("Pushed secret deleted", "Sentinel.Name", sentinel.Name, "target", target.Name)
+	return nil
+}
+
+// deletePushedSecretsForSentinel removes the data of the PushTo targets with the Delete policy,
+// including removed targets whose data is not deleted yet. Targets whose store can not be built
+// any more, for example after their token was deleted with the namespace, are skipped so that
+// they do not block the deletion of the Sentinel.
+func (r *SentinelReconciler) deletePushedSecretsForSentinel(sentinel *secopsv1alpha1.Sentinel, ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	targets := make([]*secopsv1alpha1.PushTarget, 0, len(sentinel.Spec.PushTo))
+	for i := range sentinel.Spec.PushTo {
+		targets = append(targets, &sentinel.Spec.PushTo[i])
+	}
+	for _, status := range removedPushTargets(sentinel) {
+		targets = append(targets, status.Pushed)
+	}
+
+	for _, target := range targets {
+		if target.DeletionPolicy != secopsv1alpha1.PushDeletionDelete {
+			continue
+		}
+		store, err := r.storeForPushTarget(sentinel, target, ctx)
+		if err != nil {
+			log.Error(err, "Pushed Secret Deletion Skipped.", "target", target.Name)
+			continue
+		}
+		if err := store.Delete(ctx); err != nil {
+			return fmt.Errorf("Failed to delete the pushed secret of target %s: %w", target.Name, err)
+		}
+		log.Info("Pushed secret deleted", "Sentinel.Name", sentinel.Name, "target", target.Name)
+	}
+	return nil
+}
+
+// earliestResult combines the results of the steps that requeue the Sentinel, the earliest requeue wins
+func earliestResult(results ...ctrl.Result) ctrl.Result {
+	combined := ctrl.Result{}
+	for _, result := range results {
+		combined.Requeue = combined.Requeue || result.Requeue
+		if result.RequeueAfter > 0 && (combined.RequeueAfter == 0 || result.RequeueAfter < combined.RequeueAfter) {
+			combined.RequeueAfter = result.RequeueAfter
+		}
+	}
+	return combined
+}
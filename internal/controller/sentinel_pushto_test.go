@@ -0,0 +1,120 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+func TestPushSecretForSentinel(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	for _, dir := range []string{"legacy", "shared"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	readPushed := func(dir string) string {
+		t.Helper()
+		value, err := os.ReadFile(filepath.Join(root, dir, "password"))
+		if os.IsNotExist(err) {
+			return ""
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		return string(value)
+	}
+
+	sentinel := &secopsv1alpha1.Sentinel{
+		TypeMeta:   metav1.TypeMeta{Kind: "Sentinel", APIVersion: secopsv1alpha1.GroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{Name: "billing", Namespace: "prod"},
+		Spec: secopsv1alpha1.SentinelSpec{
+			SecretName: "billing-db", SecretType: typeSecretBase, Data: map[string]string{"password": "hello678"},
+			PushTo: []secopsv1alpha1.PushTarget{
+				{Name: "legacy", File: &secopsv1alpha1.FileSource{Path: "legacy"}, DeletionPolicy: secopsv1alpha1.PushDeletionDelete},
+				{Name: "shared", File: &secopsv1alpha1.FileSource{Path: "shared"}},
+				{Name: "broken", File: &secopsv1alpha1.FileSource{Path: "missing"}},
+			},
+		},
+	}
+	r := newTestSentinelReconciler(t, sentinel)
+	r.PushToFileRoot = root
+	result, err := reconcileSentinel(t, r, "billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter == 0 || result.RequeueAfter > pushRetryInterval {
+		t.Errorf("failed push requeued after %v", result.RequeueAfter)
+	}
+	if readPushed("legacy") != "hello678" || readPushed("shared") != "hello678" {
+		t.Fatalf("pushed %q and %q", readPushed("legacy"), readPushed("shared"))
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sentinel), sentinel); err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[string]secopsv1alpha1.PushTargetStatus{}
+	for _, status := range sentinel.Status.PushTargets {
+		statuses[status.Name] = status
+	}
+	if len(statuses) != 3 || !statuses["legacy"].Synced || !statuses["shared"].Synced || statuses["broken"].Synced ||
+		statuses["broken"].Message == "" || statuses["legacy"].Pushed == nil {
+		t.Fatalf("push target status %+v", sentinel.Status.PushTargets)
+	}
+
+	// A rotation is pushed to every target
+	sentinel.Spec.Data["password"] = "rotated9"
+	sentinel.Spec.PushTo = sentinel.Spec.PushTo[:2]
+	sentinel.Annotations = map[string]string{secopsv1alpha1.RotateAnnotation: "2024-05-01T10:00:00Z"}
+	if err := r.Update(ctx, sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reconcileSentinel(t, r, "billing"); err != nil {
+		t.Fatal(err)
+	}
+	if readPushed("legacy") != "rotated9" || readPushed("shared") != "rotated9" {
+		t.Fatalf("rotation pushed %q and %q", readPushed("legacy"), readPushed("shared"))
+	}
+
+	// Removed targets lose their data with the Delete policy and keep it with Retain
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sentinel), sentinel); err != nil {
+		t.Fatal(err)
+	}
+	sentinel.Spec.PushTo = nil
+	if err := r.Update(ctx, sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reconcileSentinel(t, r, "billing"); err != nil {
+		t.Fatal(err)
+	}
+	if readPushed("legacy") != "" || readPushed("shared") != "rotated9" {
+		t.Errorf("after removal %q and %q", readPushed("legacy"), readPushed("shared"))
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sentinel), sentinel); err != nil {
+		t.Fatal(err)
+	}
+	if len(sentinel.Status.PushTargets) != 0 {
+		t.Errorf("push target status after removal %+v", sentinel.Status.PushTargets)
+	}
+}
@@ -0,0 +1,399 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+)
+
+const (
+	// replicationRetryInterval is the wait before a failed replication is retried
+	replicationRetryInterval = time.Minute
+	// replicationResyncInterval is the wait before synced copies are rewritten, which
+	// reverts changes made to them in the remote cluster
+	replicationResyncInterval = 10 * time.Minute
+	// remoteClientTimeout bounds the requests to a remote cluster
+	remoteClientTimeout = 30 * time.Second
+)
+
+// RemoteClientFunc builds the client of a remote cluster from its kubeconfig
+type RemoteClientFunc func(kubeconfig []byte) (client.Client, error)
+
+// NewRemoteClient builds the client of a remote cluster from its kubeconfig. The kubeconfig
+// comes from a namespaced Secret, so everything that would run a command or read a file of the
+// operator is refused: exec plugins, auth providers and certificate, key and token files.
+func NewRemoteClient(kubeconfig []byte) (client.Client, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the kubeconfig: %w", err)
+	}
+	for name, auth := range config.AuthInfos {
+		switch {
+		case auth.Exec != nil:
+			return nil, fmt.Errorf("User %s of the kubeconfig uses an exec plugin, credentials must be embedded", name)
+		case auth.AuthProvider != nil:
+			return nil, fmt.Errorf("User %s of the kubeconfig uses an auth provider, credentials must be embedded", name)
+		case auth.TokenFile != "" || auth.ClientCertificate != "" || auth.ClientKey != "":
+			return nil, fmt.Errorf("User %s of the kubeconfig references files, credentials must be embedded", name)
+		}
+	}
+	for name, cluster := range config.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return nil, fmt.Errorf("Cluster %s of the kubeconfig references a file, the CA must be embedded", name)
+		}
+	}
+	restConfig, err := clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the kubeconfig: %w", err)
+	}
+	restConfig.Timeout = remoteClientTimeout
+	return client.New(restConfig, client.Options{Scheme: clientgoscheme.Scheme})
+}
+
+// remoteClientForTarget builds the client of the cluster of a replication target
+func (r *SentinelReconciler) remoteClientForTarget(
+	sentinel *secopsv1alpha1.Sentinel, target *secopsv1alpha1.ReplicationTarget, ctx context.Context) (client.Client, error) {
+
+	kubeconfig, err := readSecretKey(ctx, r.Client, sentinel.Namespace, &target.KubeconfigSecretRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkKubeconfigAllowed(kubeconfig); err != nil {
+		return nil, err
+	}
+	newClient := r.RemoteClient
+	if newClient == nil {
+		newClient = NewRemoteClient
+	}
+	return newClient(kubeconfig)
+}
+
+// checkKubeconfigAllowed returns an error unless the server and the proxy of every cluster of
+// the kubeconfig are allowed, the operator connects to them like to the other external stores
+func (r *SentinelReconciler) checkKubeconfigAllowed(kubeconfig []byte) error {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("Failed to parse the kubeconfig: %w", err)
+	}
+	for name, cluster := range config.Clusters {
+		if err := r.checkURLAllowed("Server of cluster "+name, cluster.Server); err != nil {
+			return err
+		}
+		if cluster.ProxyURL == "" {
+			continue
+		}
+		if err := r.checkURLAllowed("Proxy of cluster "+name, cluster.ProxyURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replicaNamespace is the namespace of the copies of a replication target
+func replicaNamespace(sentinel *secopsv1alpha1.Sentinel, target *secopsv1alpha1.ReplicationTarget) string {
+	if target.Namespace != "" {
+		return target.Namespace
+	}
+	return sentinel.Namespace
+}
+
+// replicaOf is the value of the ReplicaOfAnnotation for the copies of a Sentinel
+func replicaOf(sentinel *secopsv1alpha1.Sentinel) string {
+	return sentinel.Namespace + "/" + sentinel.Name
+}
+
+// applyReplica creates or updates an object in a remote cluster, mutate sets its desired state.
+// An existing object that is not a copy of the Sentinel is left untouched.
+func applyReplica(ctx context.Context, remote client.Client, sentinel *secopsv1alpha1.Sentinel,
+	obj client.Object, mutate func()) error {
+
+	_, err := controllerutil.CreateOrUpdate(ctx, remote, obj, func() error {
+		annotations := obj.GetAnnotations()
+		if obj.GetResourceVersion() != "" && annotations[secopsv1alpha1.ReplicaOfAnnotation] != replicaOf(sentinel) {
+			return fmt.Errorf("%s/%s exists in the remote cluster and is not a copy of Sentinel %s",
+				obj.GetNamespace(), obj.GetName(), sentinel.Name)
+		}
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[secopsv1alpha1.ReplicaOfAnnotation] = replicaOf(sentinel)
+		obj.SetAnnotations(annotations)
+		mutate()
+		return nil
+	})
+	return err
+}
+
+// replicateToTarget writes the secret and, when requested, the Role and the RoleBinding of a
+// Sentinel into the cluster of a replication target
+func (r *SentinelReconciler) replicateToTarget(sentinel *secopsv1alpha1.Sentinel, secret *corev1.Secret,
+	target *secopsv1alpha1.ReplicationTarget, ctx context.Context) error {
+
+	remote, err := r.remoteClientForTarget(sentinel, target, ctx)
+	if err != nil {
+		return err
+	}
+	namespace := replicaNamespace(sentinel, target)
+
+	replica := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secret.Name, Namespace: namespace}}
+	if err := applyReplica(ctx, remote, sentinel, replica, func() {
+		replica.Type = secret.Type
+		replica.Data = secret.Data
+	}); err != nil {
+		return fmt.Errorf("Failed to replicate Secret %s: %w", secret.Name, err)
+	}
+
+	if !target.RBAC || sentinel.Spec.Role == "" || sentinel.Spec.RoleBinding == "" {
+		return nil
+	}
+	role := &rbacv1.Role{}
+	if err := r.Get(ctx, types.NamespacedName{Name: sentinel.Spec.Role, Namespace: sentinel.Namespace}, role); err != nil {
+		return fmt.Errorf("Failed to get Role %s: %w", sentinel.Spec.Role, err)
+	}
+	roleReplica := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: role.Name, Namespace: namespace}}
+	if err := applyReplica(ctx, remote, sentinel, roleReplica, func() {
+		roleReplica.Rules = role.Rules
+	}); err != nil {
+		return fmt.Errorf("Failed to replicate Role %s: %w", role.Name, err)
+	}
+
+	roleBinding := &rbacv1.RoleBinding{}
+	if err := r.Get(ctx, types.NamespacedName{Name: sentinel.Spec.RoleBinding, Namespace: sentinel.Namespace}, roleBinding); err != nil {
+		return fmt.Errorf("Failed to get RoleBinding %s: %w", sentinel.Spec.RoleBinding, err)
+	}
+	// ServiceAccounts of the namespace of the Sentinel are bound in the remote namespace
+	subjects := make([]rbacv1.Subject, len(roleBinding.Subjects))
+	for i, subject := range roleBinding.Subjects {
+		if subject.Kind == rbacv1.ServiceAccountKind && subject.Namespace == sentinel.Namespace {
+			subject.Namespace = namespace
+		}
+		subjects[i] = subject
+	}
+	bindingReplica := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: roleBinding.Name, Namespace: namespace}}
+	if err := applyReplica(ctx, remote, sentinel, bindingReplica, func() {
+		bindingReplica.Subjects = subjects
+		bindingReplica.RoleRef = roleBinding.RoleRef
+	}); err != nil {
+		return fmt.Errorf("Failed to replicate RoleBinding %s: %w", roleBinding.Name, err)
+	}
+	return nil
+}
+
+// replicateSecretForSentinel copies the secret into the clusters of the replication targets
+// and records the result per target. Copies are rewritten when the secret or the Sentinel
+// changed and every replicationResyncInterval. The copies of targets removed from the spec are
+// deleted. A failed replication or removal does not fail the reconciliation, it is retried
+// after replicationRetryInterval.
+func (r *SentinelReconciler) replicateSecretForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, secret *corev1.Secret, ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	log := log.FromContext(ctx)
+
+	result := ctrl.Result{}
+	if len(sentinel.Spec.Replication) > 0 {
+		result.RequeueAfter = replicationResyncInterval
+	}
+	statuses := make([]secopsv1alpha1.ReplicationStatus, 0, len(sentinel.Spec.Replication))
+	for _, status := range removedReplicationTargets(sentinel) {
+		if err := r.deleteReplicasOfTarget(sentinel, status.Replicated, ctx); err != nil {
+			log.Error(err, "Replica Deletion Failed.", "target", status.Name)
+			// The status keeps the removed target until its copies are deleted
+			status.Synced = false
+			status.Message = fmt.Sprintf("Target removed from the spec, deleting its copies failed: %s", err)
+			statuses = append(statuses, status)
+			if r.Recorder != nil {
+				r.Recorder.Event(sentinel, corev1.EventTypeWarning, "ReplicaDeleteFailed",
+					fmt.Sprintf("Deletion of the copies of removed target %s failed: %s", status.Name, err))
+			}
+			result.RequeueAfter = replicationRetryInterval
+			continue
+		}
+		if r.Recorder != nil {
+			r.Recorder.Event(sentinel, corev1.EventTypeNormal, "ReplicasDeleted",
+				fmt.Sprintf("Copies of removed target %s deleted", status.Name))
+		}
+	}
+
+	for i := range sentinel.Spec.Replication {
+		target := &sentinel.Spec.Replication[i]
+		status := secopsv1alpha1.ReplicationStatus{Name: target.Name}
+		for _, previous := range sentinel.Status.Replication {
+			if previous.Name == target.Name {
+				status = previous
+			}
+		}
+		if status.Synced && status.SecretResourceVersion == secret.ResourceVersion &&
+			status.ObservedGeneration == sentinel.Generation &&
+			status.LastSyncTime != nil && time.Since(status.LastSyncTime.Time) < replicationResyncInterval {
+			statuses = append(statuses, status)
+			continue
+		}
+
+		if err := r.replicateToTarget(sentinel, secret, target, ctx); err != nil {
+			log.Error(err, "Secret Replication Failed.", "target", target.Name)
+			status.Synced = false
+			status.Message = err.Error()
+			if r.Recorder != nil {
+				r.Recorder.Event(sentinel, corev1.EventTypeWarning, "ReplicationFailed",
+					fmt.Sprintf("Replication of secret %s to %s failed: %s", secret.Name, target.Name, err))
+			}
+			result.RequeueAfter = replicationRetryInterval
+		} else {
+			changed := status.SecretResourceVersion != secret.ResourceVersion || status.ObservedGeneration != sentinel.Generation
+			now := metav1.Now()
+			status.Synced = true
+			status.Message = fmt.Sprintf("Replicated to namespace %s", replicaNamespace(sentinel, target))
+			status.SecretResourceVersion = secret.ResourceVersion
+			status.ObservedGeneration = sentinel.Generation
+			status.LastSyncTime = &now
+			status.Replicated = target.DeepCopy()
+			log.Info(status.Message, "Sentinel.Name", sentinel.Name, "target", target.Name)
+			// The periodic resync is not an operation worth an event or an audit record
+			if changed && r.Recorder != nil {
+				r.Recorder.Event(sentinel, corev1.EventTypeNormal, "SecretReplicated",
+					fmt.Sprintf("Secret %s replicated to %s", secret.Name, target.Name))
+			}
+			if changed && r.Audit != nil {
+				auditLog(r.Audit, ctx, audit.Record{
+					Actor:          audit.ActorOperator,
+					Action:         audit.ActionReplicate,
+					Object:         auditObject(sentinel, "Sentinel"),
+					NewContentHash: r.Audit.ContentHash(secret.Data),
+					Details: map[string]string{"secret": secret.Name, "target": target.Name,
+						"namespace": replicaNamespace(sentinel, target)},
+				})
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	if len(statuses) == 0 {
+		statuses = nil
+	}
+	sentinel.Status.Replication = statuses
+	return result, nil
+}
+
+// removedReplicationTargets returns the status of the targets that are no longer in the spec
+// and were replicated to
+func removedReplicationTargets(sentinel *secopsv1alpha1.Sentinel) []secopsv1alpha1.ReplicationStatus {
+	var removed []secopsv1alpha1.ReplicationStatus
+	for _, status := range sentinel.Status.Replication {
+		if status.Replicated == nil {
+			continue
+		}
+		inSpec := false
+		for i := range sentinel.Spec.Replication {
+			inSpec = inSpec || sentinel.Spec.Replication[i].Name == status.Name
+		}
+		if !inSpec {
+			removed = append(removed, status)
+		}
+	}
+	return removed
+}
+
+// deleteReplica removes an object from a remote cluster when it is a copy of the Sentinel
+func deleteReplica(ctx context.Context, remote client.Client, sentinel *secopsv1alpha1.Sentinel, obj client.Object) error {
+	if err := remote.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if obj.GetAnnotations()[secopsv1alpha1.ReplicaOfAnnotation] != replicaOf(sentinel) {
+		return nil
+	}
+	if err := remote.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// deleteReplicasOfTarget removes the copies of a Sentinel from the cluster of a replication target
+func (r *SentinelReconciler) deleteReplicasOfTarget(
+	sentinel *secopsv1alpha1.Sentinel, target *secopsv1alpha1.ReplicationTarget, ctx context.Context) error {
+
+	remote, err := r.remoteClientForTarget(sentinel, target, ctx)
+	if err != nil {
+		return err
+	}
+	return deleteReplicas(ctx, remote, sentinel, target)
+}
+
+// deleteReplicas removes the copies of a Sentinel from a remote cluster
+func deleteReplicas(ctx context.Context, remote client.Client,
+	sentinel *secopsv1alpha1.Sentinel, target *secopsv1alpha1.ReplicationTarget) error {
+
+	namespace := replicaNamespace(sentinel, target)
+	replicas := []client.Object{
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: sentinel.Spec.SecretName, Namespace: namespace}},
+	}
+	if target.RBAC && sentinel.Spec.Role != "" && sentinel.Spec.RoleBinding != "" {
+		replicas = append(replicas,
+			&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: sentinel.Spec.RoleBinding, Namespace: namespace}},
+			&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: sentinel.Spec.Role, Namespace: namespace}})
+	}
+	for _, replica := range replicas {
+		if err := deleteReplica(ctx, remote, sentinel, replica); err != nil {
+			return fmt.Errorf("Failed to delete the replica %s of target %s: %w", replica.GetName(), target.Name, err)
+		}
+	}
+	log.FromContext(ctx).Info("Replicas deleted", "Sentinel.Name", sentinel.Name, "target", target.Name)
+	return nil
+}
+
+// deleteReplicasForSentinel removes the copies of a Sentinel from the clusters of its
+// replication targets, including removed targets whose copies are not deleted yet. Targets
+// whose client can not be built any more, for example after their kubeconfig was deleted with
+// the namespace, are skipped so that they do not block the deletion of the Sentinel.
+func (r *SentinelReconciler) deleteReplicasForSentinel(sentinel *secopsv1alpha1.Sentinel, ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	targets := make([]*secopsv1alpha1.ReplicationTarget, 0, len(sentinel.Spec.Replication))
+	for i := range sentinel.Spec.Replication {
+		targets = append(targets, &sentinel.Spec.Replication[i])
+	}
+	for _, status := range removedReplicationTargets(sentinel) {
+		targets = append(targets, status.Replicated)
+	}
+
+	for _, target := range targets {
+		remote, err := r.remoteClientForTarget(sentinel, target, ctx)
+		if err != nil {
+			log.Error(err, "Replica Deletion Skipped.", "target", target.Name)
+			continue
+		}
+		if err := deleteReplicas(ctx, remote, sentinel, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// westKubeconfig is the kubeconfig of the replication targets of the tests
+const westKubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+- name: west
+  cluster:
+    server: https://west.example.com
+contexts:
+- name: west
+  context: {cluster: west, user: admin}
+current-context: west
+users:
+- name: admin
+  user:
+    token: abc`
+
+func TestReplication(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	kubeconfigRef := corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "west-kubeconfig"}, Key: "kubeconfig"}
+	sentinel := &secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod", Generation: 1},
+		Spec: secopsv1alpha1.SentinelSpec{
+			SecretName: "db-credentials", SecretType: "RbacBaseSecret",
+			Role: "db-reader", RoleBinding: "db-reader", ServiceAccount: "api",
+			Replication: []secopsv1alpha1.ReplicationTarget{
+				{Name: "west", RBAC: true, KubeconfigSecretRef: kubeconfigRef},
+				{Name: "east", Namespace: "other", KubeconfigSecretRef: kubeconfigRef},
+			},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-credentials", Namespace: "prod", ResourceVersion: "7"},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"password": []byte("s3cr3t")},
+	}
+	local := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "west-kubeconfig", Namespace: "prod"},
+			Data:       map[string][]byte{"kubeconfig": []byte(westKubeconfig)},
+		},
+		&rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-reader", Namespace: "prod"},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-reader", Namespace: "prod"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "api", Namespace: "prod"}},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "db-reader"},
+		},
+	).Build()
+	// The namespace of the east target holds a Secret of the same name that is not a copy
+	foreign := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-credentials", Namespace: "other"},
+		Data:       map[string][]byte{"password": []byte("theirs")},
+	}
+	remote := fake.NewClientBuilder().WithScheme(scheme).WithObjects(foreign).Build()
+	r := &SentinelReconciler{
+		Client: local, Scheme: scheme, Recorder: record.NewFakeRecorder(10),
+		AllowedURLs: []string{"https://west.example.com"},
+		RemoteClient: func(kubeconfig []byte) (client.Client, error) {
+			if string(kubeconfig) != westKubeconfig {
+				t.Errorf("kubeconfig %q", kubeconfig)
+			}
+			return remote, nil
+		},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "db", Namespace: "prod"}}
+
+	result, err := r.replicateSecretForSentinel(sentinel, secret, ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter != replicationRetryInterval {
+		t.Errorf("requeue after %v", result.RequeueAfter)
+	}
+	west, east := sentinel.Status.Replication[0], sentinel.Status.Replication[1]
+	if !west.Synced || west.SecretResourceVersion != "7" || west.LastSyncTime == nil || west.Replicated == nil {
+		t.Errorf("west status %+v", west)
+	}
+	if east.Synced || !strings.Contains(east.Message, "not a copy") || east.Replicated != nil {
+		t.Errorf("east status %+v", east)
+	}
+
+	replica := &corev1.Secret{}
+	if err := remote.Get(ctx, types.NamespacedName{Name: "db-credentials", Namespace: "prod"}, replica); err != nil {
+		t.Fatal(err)
+	}
+	if string(replica.Data["password"]) != "s3cr3t" || replica.Annotations[secopsv1alpha1.ReplicaOfAnnotation] != "prod/db" {
+		t.Errorf("replica %+v", replica)
+	}
+	binding := &rbacv1.RoleBinding{}
+	if err := remote.Get(ctx, types.NamespacedName{Name: "db-reader", Namespace: "prod"}, binding); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Get(ctx, types.NamespacedName{Name: "db-credentials", Namespace: "other"}, foreign); err != nil {
+		t.Fatal(err)
+	}
+	if string(foreign.Data["password"]) != "theirs" {
+		t.Error("foreign secret overwritten")
+	}
+
+	// A synced target is not written again before the resync
+	if err := remote.Delete(ctx, replica); err != nil {
+		t.Fatal(err)
+	}
+	targets := sentinel.Spec.Replication
+	sentinel.Spec.Replication = targets[:1]
+	if _, err := r.replicateSecretForSentinel(sentinel, secret, ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Get(ctx, client.ObjectKeyFromObject(replica), &corev1.Secret{}); !apierrors.IsNotFound(err) {
+		t.Errorf("synced target written again: %v", err)
+	}
+	// Drift is reverted by the resync
+	sentinel.Status.Replication[0].LastSyncTime.Time = sentinel.Status.Replication[0].LastSyncTime.Add(-replicationResyncInterval)
+	if _, err := r.replicateSecretForSentinel(sentinel, secret, ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Get(ctx, client.ObjectKeyFromObject(replica), &corev1.Secret{}); err != nil {
+		t.Errorf("drift not reverted: %v", err)
+	}
+
+	// The copies of a target removed from the spec are deleted, the foreign secret is left
+	sentinel.Spec.Replication = targets[1:]
+	if _, err := r.replicateSecretForSentinel(sentinel, secret, ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range []client.Object{&corev1.Secret{}, &rbacv1.Role{}, &rbacv1.RoleBinding{}} {
+		key := types.NamespacedName{Name: "db-reader", Namespace: "prod"}
+		if _, ok := obj.(*corev1.Secret); ok {
+			key.Name = "db-credentials"
+		}
+		if err := remote.Get(ctx, key, obj); !apierrors.IsNotFound(err) {
+			t.Errorf("%T %s of the removed target not deleted: %v", obj, key, err)
+		}
+	}
+	if len(sentinel.Status.Replication) != 1 || sentinel.Status.Replication[0].Name != "east" {
+		t.Errorf("status after the removal %+v", sentinel.Status.Replication)
+	}
+	if err := remote.Get(ctx, client.ObjectKeyFromObject(foreign), foreign); err != nil {
+		t.Errorf("foreign secret deleted: %v", err)
+	}
+
+	// The finalizer also removes the copies of removed targets the status still holds
+	sentinel.Spec.Replication = targets
+	if _, err := r.replicateSecretForSentinel(sentinel, secret, ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	sentinel.Spec.Replication = nil
+	if err := r.deleteReplicasForSentinel(sentinel, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Get(ctx, client.ObjectKeyFromObject(replica), &corev1.Secret{}); !apierrors.IsNotFound(err) {
+		t.Errorf("replica of the removed target not deleted: %v", err)
+	}
+
+	// Servers that are not allowed are not connected to
+	r.AllowedURLs = []string{"https://east.example.com"}
+	sentinel.Spec.Replication = targets[:1]
+	sentinel.Status.Replication = nil
+	if _, err := r.replicateSecretForSentinel(sentinel, secret, ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if status := sentinel.Status.Replication[0]; status.Synced || !strings.Contains(status.Message, "not allowed") {
+		t.Errorf("status of a server that is not allowed %+v", status)
+	}
+}
+
+func TestNewRemoteClient(t *testing.T) {
+	for name, kubeconfig := range map[string]string{
+		"exec": `
+users:
+- name: admin
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: /bin/sh`,
+		"token file": `
+users:
+- name: admin
+  user:
+    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token`,
+		"client key file": `
+users:
+- name: admin
+  user:
+    client-key: /etc/kubernetes/pki/admin.key`,
+		"ca file": `
+clusters:
+- name: west
+  cluster:
+    server: https://west.example.com
+    certificate-authority: /etc/kubernetes/pki/ca.crt`,
+	} {
+		if _, err := NewRemoteClient([]byte("apiVersion: v1\nkind: Config\n" + kubeconfig)); err == nil {
+			t.Errorf("%s: kubeconfig accepted", name)
+		}
+	}
+	embedded := `
+apiVersion: v1
+kind: Config
+clusters:
+- name: west
+  cluster:
+    server: https://west.example.com
+contexts:
+- name: west
+  context: {cluster: west, user: admin}
+current-context: west
+users:
+- name: admin
+  user:
+    token: abc`
+	if _, err := NewRemoteClient([]byte(embedded)); err != nil {
+		t.Errorf("embedded kubeconfig refused: %s", err)
+	}
+}
+
+var _ = Describe("Sentinel replication", Ordered, func() {
+	ctx := context.Background()
+	var remoteEnv *envtest.Environment
+	var remote client.Client
+	var reconciler *SentinelReconciler
+	var sentinel *secopsv1alpha1.Sentinel
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders-db", Namespace: "replication"},
+		Data:       map[string][]byte{"password": []byte("s3cr3t")},
+	}
+
+	// The remote cluster is a second API server, the suite's is the local one
+	BeforeAll(func() {
+		remoteEnv = &envtest.Environment{}
+		remoteCfg, err := remoteEnv.Start()
+		Expect(err).NotTo(HaveOccurred())
+		remote, err = client.New(remoteCfg, client.Options{Scheme: clientgoscheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		user, err := remoteEnv.AddUser(envtest.User{Name: "replicator", Groups: []string{"system:masters"}}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		kubeconfig, err := user.KubeConfig()
+		Expect(err).NotTo(HaveOccurred())
+		config, err := clientcmd.Load(kubeconfig)
+		Expect(err).NotTo(HaveOccurred())
+		allowedURLs := []string{}
+		for _, cluster := range config.Clusters {
+			allowedURLs = append(allowedURLs, cluster.Server)
+		}
+
+		for _, name := range []string{"replication", "batch"} {
+			Expect(remote.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})).To(Succeed())
+		}
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "replication"}})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "west-kubeconfig", Namespace: "replication"},
+			Data:       map[string][]byte{"kubeconfig": kubeconfig},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+
+		kubeconfigRef := corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "west-kubeconfig"}, Key: "kubeconfig"}
+		sentinel = &secopsv1alpha1.Sentinel{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "replication"},
+			Spec: secopsv1alpha1.SentinelSpec{
+				SecretName: "orders-db", SecretType: typeSecretBaseRbac,
+				ServiceAccount: "orders-api", Role: "orders-reader", RoleBinding: "orders-reader",
+				Replication: []secopsv1alpha1.ReplicationTarget{
+					{Name: "west", RBAC: true, KubeconfigSecretRef: kubeconfigRef},
+					{Name: "west-batch", Namespace: "batch", KubeconfigSecretRef: kubeconfigRef},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, roleForSentinel(sentinel))).To(Succeed())
+		Expect(k8sClient.Create(ctx, roleBindingForSentinel(sentinel, rbacv1.ServiceAccountKind))).To(Succeed())
+
+		reconciler = &SentinelReconciler{
+			Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: record.NewFakeRecorder(100), AllowedURLs: allowedURLs,
+		}
+	})
+
+	AfterAll(func() {
+		Expect(remoteEnv.Stop()).To(Succeed())
+	})
+
+	replicate := func() {
+		_, err := reconciler.replicateSecretForSentinel(sentinel, secret, ctx, ctrl.Request{})
+		Expect(err).NotTo(HaveOccurred())
+		for _, status := range sentinel.Status.Replication {
+			Expect(status.Synced).To(BeTrue(), status.Message)
+		}
+	}
+	remoteSecret := func(namespace string) error {
+		return remote.Get(ctx, types.NamespacedName{Name: "orders-db", Namespace: namespace}, &corev1.Secret{})
+	}
+
+	It("copies the secret and its RBAC into the remote cluster", func() {
+		replicate()
+
+		replica := &corev1.Secret{}
+		Expect(remote.Get(ctx, types.NamespacedName{Name: "orders-db", Namespace: "replication"}, replica)).To(Succeed())
+		Expect(replica.Data).To(HaveKeyWithValue("password", []byte("s3cr3t")))
+		Expect(replica.Annotations).To(HaveKeyWithValue(secopsv1alpha1.ReplicaOfAnnotation, "replication/orders"))
+		Expect(remote.Get(ctx, types.NamespacedName{Name: "orders-reader", Namespace: "replication"}, &rbacv1.Role{})).To(Succeed())
+		binding := &rbacv1.RoleBinding{}
+		Expect(remote.Get(ctx, types.NamespacedName{Name: "orders-reader", Namespace: "replication"}, binding)).To(Succeed())
+		Expect(binding.Subjects[0].Namespace).To(Equal("replication"))
+		Expect(remoteSecret("batch")).To(Succeed())
+	})
+
+	It("deletes the copies of a target removed from the spec", func() {
+		sentinel.Spec.Replication = sentinel.Spec.Replication[:1]
+		replicate()
+
+		Expect(apierrors.IsNotFound(remoteSecret("batch"))).To(BeTrue())
+		Expect(remoteSecret("replication")).To(Succeed())
+		Expect(sentinel.Status.Replication).To(HaveLen(1))
+	})
+
+	It("deletes the copies with the Sentinel", func() {
+		Expect(reconciler.deleteReplicasForSentinel(sentinel, ctx)).To(Succeed())
+
+		Expect(apierrors.IsNotFound(remoteSecret("replication"))).To(BeTrue())
+		err := remote.Get(ctx, types.NamespacedName{Name: "orders-reader", Namespace: "replication"}, &rbacv1.Role{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
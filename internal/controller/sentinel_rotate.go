@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+	"github.com/kavinduxo/sentinel-operator/internal/notify"
+)
+
+// secretDataForSentinel builds the data of the secret from the spec, sealed when the Sentinel
+// uses the envelope encryption
+func (r *SentinelReconciler) secretDataForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context) (map[string][]byte, error) {
+
+	secretData, err := r.plainSecretDataForSentinel(sentinel, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if sentinel.Spec.Envelope == nil {
+		return secretData, nil
+	}
+	return r.sealSecretData(sentinel, ctx, secretData)
+}
+
+// plainSecretDataForSentinel merges the data of the DataFrom sources and the data of the spec
+func (r *SentinelReconciler) plainSecretDataForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context) (map[string][]byte, error) {
+
+	secretData := map[string][]byte{}
+	if len(sentinel.Spec.DataFrom) > 0 {
+		external, err := r.dataFromForSentinel(sentinel, ctx)
+		if err != nil {
+			return nil, err
+		}
+		secretData = external
+	}
+	for key, value := range sentinel.Spec.Data {
+		secretData[key] = []byte(value)
+	}
+	return secretData, nil
+}
+
+// rotateSecretForSentinel rewrites the data of an existing secret when the rotate annotation of
+// the Sentinel differs from the rotation the secret was last written for. Sealed secrets get a
+// fresh data key on every rotation.
+func (r *SentinelReconciler) rotateSecretForSentinel(
+	sentinel *secopsv1alpha1.Sentinel, secret *corev1.Secret, ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	log := log.FromContext(ctx)
+
+	rotation := sentinel.Annotations[secopsv1alpha1.RotateAnnotation]
+	if rotation == "" || secret.Annotations[secopsv1alpha1.RotatedAnnotation] == rotation {
+		return ctrl.Result{}, nil
+	}
+
+	secretData, err := r.secretDataForSentinel(sentinel, ctx)
+	if err != nil {
+		log.Error(err, "Secret Rotation Failed.")
+		r.notifyRotationFailure(sentinel, ctx, secret, rotation, err)
+		return ctrl.Result{}, err
+	}
+	previousData := secret.Data
+	secret.Data = secretData
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[secopsv1alpha1.RotatedAnnotation] = rotation
+	if err := r.Update(ctx, secret); err != nil {
+		log.Error(err, "Secret Rotation Failed.")
+		r.notifyRotationFailure(sentinel, ctx, secret, rotation, err)
+		return ctrl.Result{}, err
+	}
+
+	now := metav1.Now()
+	sentinel.Status.LastRotationTime = &now
+	message := fmt.Sprintf("Secret %s rotated (%s)", secret.Name, rotation)
+	log.Info(message, "Sentinel.Name", sentinel.Name)
+	r.Recorder.Event(sentinel, corev1.EventTypeNormal, "SecretRotated", message)
+	if r.Audit != nil {
+		auditLog(r.Audit, ctx, audit.Record{
+			Actor:               audit.ActorOperator,
+			Action:              audit.ActionRotate,
+			Object:              auditObject(sentinel, "Sentinel"),
+			PreviousContentHash: r.Audit.ContentHash(previousData),
+			NewContentHash:      r.Audit.ContentHash(secretData),
+			Details:             map[string]string{"secret": secret.Name, "rotation": rotation},
+		})
+	}
+	return ctrl.Result{}, nil
+}
+
+// notifyRotationFailure sends the failed rotation of the secret of a Sentinel
+func (r *SentinelReconciler) notifyRotationFailure(
+	sentinel *secopsv1alpha1.Sentinel, ctx context.Context, secret *corev1.Secret, rotation string, err error) {
+	notifySentinel(r.Notifier, ctx, sentinel, notify.TypeRotationFailed,
+		fmt.Sprintf("Rotation of secret %s failed: %s", secret.Name, err),
+		map[string]string{"secret": secret.Name, "rotation": rotation})
+}
@@ -0,0 +1,365 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+)
+
+// Phases of a SentinelAccessGrant
+const (
+	accessGrantPhasePending = "Pending"
+	accessGrantPhaseActive  = "Active"
+	accessGrantPhaseExpired = "Expired"
+	accessGrantPhaseFailed  = "Failed"
+)
+
+// SentinelAccessGrantReconciler reconciles a SentinelAccessGrant object
+type SentinelAccessGrantReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Audit is optional and records the grants and revocations
+	Audit *audit.Logger
+}
+
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelaccessgrants,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelaccessgrants/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelaccessgrants/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+// Reconcile creates a RoleBinding scoped to the secret of the referenced Sentinel
+// and revokes it again once the grant reached status.expiresAt.
+func (r *SentinelAccessGrantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	grant := &secopsv1alpha1.SentinelAccessGrant{}
+	if err := r.Get(ctx, req.NamespacedName, grant); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("sentinelaccessgrant resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get sentinelaccessgrant")
+		return ctrl.Result{}, err
+	}
+
+	// Expired grants are kept as a record, there is nothing left to do
+	if grant.Status.Phase == accessGrantPhaseExpired {
+		return ctrl.Result{}, nil
+	}
+
+	if len(grant.Status.Conditions) == 0 {
+		grant.Status.Phase = accessGrantPhasePending
+		meta.SetStatusCondition(&grant.Status.Conditions, metav1.Condition{Type: typeAvailableSentinel, Status: metav1.ConditionUnknown, Reason: "Reconciling", Message: "Starting reconciliation"})
+		if err := r.Status().Update(ctx, grant); err != nil {
+			log.Error(err, "Failed to update SentinelAccessGrant status")
+			return ctrl.Result{}, err
+		}
+
+		if err := r.Get(ctx, req.NamespacedName, grant); err != nil {
+			log.Error(err, "Failed to re-fetch sentinelaccessgrant")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if grant.Status.ExpiresAt != nil && !time.Now().Before(grant.Status.ExpiresAt.Time) {
+		return r.revokeAccessGrant(grant, ctx)
+	}
+
+	if grant.Spec.Duration.Duration <= 0 {
+		durationErr := fmt.Errorf("Duration must be greater than zero: %s", grant.Spec.Duration.Duration)
+		log.Error(durationErr, "Invalid Duration!")
+		return ctrl.Result{}, r.failAccessGrant(grant, ctx, "Invalid", durationErr)
+	}
+
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := r.Get(ctx, types.NamespacedName{Name: grant.Spec.SentinelName, Namespace: grant.Namespace}, sentinel); err != nil {
+		log.Error(err, "Sentinel of the access grant not found!")
+		return ctrl.Result{}, r.failAccessGrant(grant, ctx, "NotFound", err)
+	}
+
+	// Secrets that need an approval are only granted through an approved SentinelAccessRequest
+	if requiresApproval(sentinel) {
+		approved, err := isApprovedGrant(r.Client, grant, ctx)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !approved && metav1.IsControlledBy(grant, sentinel) {
+			// Break-glass access is applied without approval once it is recorded on the Sentinel
+			if findBreakGlassRecord(sentinel, grant.Name) == nil {
+				log.Info("Waiting for the break-glass record of the Sentinel", "Sentinel.Name", sentinel.Name)
+				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+			}
+			approved = isBreakGlassGrant(grant, sentinel)
+		}
+		if !approved {
+			approvalErr := fmt.Errorf("Sentinel %s of type %s requires an approved SentinelAccessRequest", sentinel.Name, sentinel.Spec.SecretType)
+			log.Error(approvalErr, "Access grant is not approved!")
+			// A grant that lost its approval keeps no access
+			if err := r.deleteAccessGrantRBAC(grant, ctx); err != nil {
+				return ctrl.Result{}, err
+			}
+			if grant.Status.RoleBinding != "" {
+				r.Recorder.Event(grant, corev1.EventTypeWarning, "AccessRevoked",
+					fmt.Sprintf("Revoked %s %s access granted by Sentinel %s, it is no longer approved",
+						grant.Spec.Subject.Kind, grant.Spec.Subject.Name, grant.Spec.SentinelName))
+				r.auditAccessRevoke(grant, ctx)
+				grant.Status.RoleBinding = ""
+			}
+			return ctrl.Result{}, r.failAccessGrant(grant, ctx, "ApprovalRequired", approvalErr)
+		}
+	}
+
+	if err := r.bindingForAccessGrant(grant, sentinel, ctx); err != nil {
+		return ctrl.Result{}, r.failAccessGrant(grant, ctx, "BindingFailed", err)
+	}
+
+	if grant.Status.GrantedAt == nil {
+		now := metav1.Now()
+		expiresAt := metav1.NewTime(now.Add(grant.Spec.Duration.Duration))
+		grant.Status.GrantedAt = &now
+		grant.Status.ExpiresAt = &expiresAt
+
+		r.Recorder.Event(grant, corev1.EventTypeNormal, "AccessGranted",
+			fmt.Sprintf("Granted %s %s read access to secret %s of Sentinel %s until %s",
+				grant.Spec.Subject.Kind, grant.Spec.Subject.Name, sentinel.Spec.SecretName, sentinel.Name,
+				expiresAt.UTC().Format(time.RFC3339)))
+		if r.Audit != nil {
+			auditLog(r.Audit, ctx, audit.Record{
+				Actor:   r.accessGrantApprover(grant, ctx),
+				Action:  audit.ActionAccessGrant,
+				Object:  auditObject(sentinel, "Sentinel"),
+				Details: accessGrantAuditDetails(grant),
+			})
+		}
+	}
+
+	grant.Status.Phase = accessGrantPhaseActive
+	grant.Status.RoleBinding = accessGrantBindingName(grant)
+	meta.SetStatusCondition(&grant.Status.Conditions, metav1.Condition{Type: typeAvailableSentinel,
+		Status: metav1.ConditionTrue, Reason: "Granted",
+		Message: fmt.Sprintf("Access to secret %s is granted until %s", sentinel.Spec.SecretName,
+			grant.Status.ExpiresAt.UTC().Format(time.RFC3339))})
+
+	if err := r.Status().Update(ctx, grant); err != nil {
+		log.Error(err, "Failed to update SentinelAccessGrant status")
+		return ctrl.Result{}, err
+	}
+
+	// Come back exactly when the grant expires
+	return ctrl.Result{RequeueAfter: time.Until(grant.Status.ExpiresAt.Time)}, nil
+}
+
+// bindingForAccessGrant makes sure that the Role limited to the Sentinel secret and the
+// RoleBinding for the subject of the grant exist and match the grant. Objects of the same
+// name that the grant does not own are refused rather than taken over.
+func (r *SentinelAccessGrantReconciler) bindingForAccessGrant(
+	grant *secopsv1alpha1.SentinelAccessGrant, sentinel *secopsv1alpha1.Sentinel, ctx context.Context) error {
+
+	log := log.FromContext(ctx)
+	name := accessGrantBindingName(grant)
+
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: grant.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, role, func() error {
+		if role.ResourceVersion != "" && !metav1.IsControlledBy(role, grant) {
+			return fmt.Errorf("Role %s exists and is not owned by SentinelAccessGrant %s", name, grant.Name)
+		}
+		role.Rules = []rbacv1.PolicyRule{
+			{
+				APIGroups:     []string{""},
+				Resources:     []string{"secrets"},
+				ResourceNames: []string{sentinel.Spec.SecretName},
+				Verbs:         []string{"get"},
+			},
+		}
+		return controllerutil.SetControllerReference(grant, role, r.Scheme)
+	}); err != nil {
+		log.Error(err, "Access Grant Role Creation Failed.")
+		return err
+	}
+
+	subject := grant.Spec.Subject
+	if subject.Kind == rbacv1.ServiceAccountKind && subject.Namespace == "" {
+		subject.Namespace = grant.Namespace
+	}
+
+	roleBinding := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: grant.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, roleBinding, func() error {
+		if roleBinding.ResourceVersion != "" && !metav1.IsControlledBy(roleBinding, grant) {
+			return fmt.Errorf("RoleBinding %s exists and is not owned by SentinelAccessGrant %s", name, grant.Name)
+		}
+		// The subject follows the grant, the role reference of an existing binding is immutable
+		roleBinding.Subjects = []rbacv1.Subject{subject}
+		roleBinding.RoleRef = rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     name,
+		}
+		return controllerutil.SetControllerReference(grant, roleBinding, r.Scheme)
+	}); err != nil {
+		log.Error(err, "Access Grant RoleBinding Creation Failed.")
+		return err
+	}
+
+	return nil
+}
+
+// revokeAccessGrant removes the RoleBinding and the Role of an expired grant.
+func (r *SentinelAccessGrantReconciler) revokeAccessGrant(
+	grant *secopsv1alpha1.SentinelAccessGrant, ctx context.Context) (ctrl.Result, error) {
+
+	log := log.FromContext(ctx)
+
+	if err := r.deleteAccessGrantRBAC(grant, ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.Recorder.Event(grant, corev1.EventTypeNormal, "AccessRevoked",
+		fmt.Sprintf("Revoked %s %s access granted by Sentinel %s, it expired at %s",
+			grant.Spec.Subject.Kind, grant.Spec.Subject.Name, grant.Spec.SentinelName,
+			grant.Status.ExpiresAt.UTC().Format(time.RFC3339)))
+	r.auditAccessRevoke(grant, ctx)
+
+	grant.Status.Phase = accessGrantPhaseExpired
+	grant.Status.RoleBinding = ""
+	meta.SetStatusCondition(&grant.Status.Conditions, metav1.Condition{Type: typeAvailableSentinel,
+		Status: metav1.ConditionFalse, Reason: "Expired",
+		Message: fmt.Sprintf("Access grant %s expired and was revoked", grant.Name)})
+
+	if err := r.Status().Update(ctx, grant); err != nil {
+		log.Error(err, "Failed to update SentinelAccessGrant status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// deleteAccessGrantRBAC deletes the RoleBinding and the Role of a grant. Objects of the same
+// name that the grant does not own are left untouched.
+func (r *SentinelAccessGrantReconciler) deleteAccessGrantRBAC(grant *secopsv1alpha1.SentinelAccessGrant, ctx context.Context) error {
+	log := log.FromContext(ctx)
+	name := accessGrantBindingName(grant)
+
+	for _, obj := range []client.Object{&rbacv1.RoleBinding{}, &rbacv1.Role{}} {
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: grant.Namespace}, obj)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !metav1.IsControlledBy(obj, grant) {
+			log.Info("Skipping the revocation of an object the access grant does not own", "Name", name)
+			continue
+		}
+		if err := r.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			log.Error(err, "Failed to revoke the access grant RBAC object")
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SentinelAccessGrantReconciler) auditAccessRevoke(grant *secopsv1alpha1.SentinelAccessGrant, ctx context.Context) {
+	if r.Audit == nil {
+		return
+	}
+	sentinel := &secopsv1alpha1.Sentinel{ObjectMeta: metav1.ObjectMeta{Name: grant.Spec.SentinelName, Namespace: grant.Namespace}}
+	auditLog(r.Audit, ctx, audit.Record{
+		Actor:   audit.ActorOperator,
+		Action:  audit.ActionAccessRevoke,
+		Object:  auditObject(sentinel, "Sentinel"),
+		Details: accessGrantAuditDetails(grant),
+	})
+}
+
+// failAccessGrant records the error on the grant status and returns it so that the request is retried.
+func (r *SentinelAccessGrantReconciler) failAccessGrant(
+	grant *secopsv1alpha1.SentinelAccessGrant, ctx context.Context, reason string, cause error) error {
+
+	grant.Status.Phase = accessGrantPhaseFailed
+	meta.SetStatusCondition(&grant.Status.Conditions, metav1.Condition{Type: typeAvailableSentinel,
+		Status: metav1.ConditionFalse, Reason: reason,
+		Message: fmt.Sprintf("Failed to grant access for the custom resource (%s): (%s)", grant.Name, cause)})
+
+	if err := r.Status().Update(ctx, grant); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update SentinelAccessGrant status")
+	}
+
+	return cause
+}
+
+// accessGrantBindingName returns the name shared by the Role and RoleBinding of a grant
+func accessGrantBindingName(grant *secopsv1alpha1.SentinelAccessGrant) string {
+	return fmt.Sprintf("%s-access-grant", grant.Name)
+}
+
+// accessGrantApprover returns the approver of the SentinelAccessRequest a grant was created for.
+// Break-glass grants and grants of types without approval are applied by the operator.
+func (r *SentinelAccessGrantReconciler) accessGrantApprover(grant *secopsv1alpha1.SentinelAccessGrant, ctx context.Context) string {
+	owner := metav1.GetControllerOf(grant)
+	if owner == nil || owner.Kind != "SentinelAccessRequest" {
+		return audit.ActorOperator
+	}
+	accessRequest := &secopsv1alpha1.SentinelAccessRequest{}
+	if err := r.Get(ctx, types.NamespacedName{Name: owner.Name, Namespace: grant.Namespace}, accessRequest); err != nil {
+		return audit.ActorOperator
+	}
+	if approver := accessRequest.Annotations[secopsv1alpha1.AccessRequestApprovedByAnnotation]; approver != "" {
+		return approver
+	}
+	return audit.ActorOperator
+}
+
+func accessGrantAuditDetails(grant *secopsv1alpha1.SentinelAccessGrant) map[string]string {
+	details := map[string]string{
+		"accessGrant": grant.Name,
+		"subjectKind": grant.Spec.Subject.Kind,
+		"subjectName": grant.Spec.Subject.Name,
+		"duration":    grant.Spec.Duration.Duration.String(),
+	}
+	if grant.Status.ExpiresAt != nil {
+		details["expiresAt"] = grant.Status.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return details
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SentinelAccessGrantReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&secopsv1alpha1.SentinelAccessGrant{}).
+		Owns(&rbacv1.Role{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Complete(r)
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+func TestAccessGrant(t *testing.T) {
+	ctx := context.Background()
+	scheme := newTestScheme(t)
+	sentinel := &secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "prod"},
+		Spec:       secopsv1alpha1.SentinelSpec{SecretName: "orders-db", SecretType: typeSecretBase},
+	}
+	grant := &secopsv1alpha1.SentinelAccessGrant{
+		ObjectMeta: metav1.ObjectMeta{Name: "oncall", Namespace: "prod"},
+		Spec: secopsv1alpha1.SentinelAccessGrantSpec{
+			SentinelName: "orders",
+			Subject:      rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "alice"},
+			Duration:     metav1.Duration{Duration: time.Hour},
+		},
+	}
+	// A RoleBinding of the name the grant other would use, owned by nobody
+	foreign := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "other-access-grant", Namespace: "prod"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: "admins"}},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"},
+	}
+	other := grant.DeepCopy()
+	other.Name = "other"
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sentinel, grant, other, foreign).
+		WithStatusSubresource(&secopsv1alpha1.SentinelAccessGrant{}).Build()
+	r := &SentinelAccessGrantReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(100)}
+	reconcile := func(name string) (ctrl.Result, error) {
+		return r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: "prod"}})
+	}
+	bindingKey := types.NamespacedName{Name: "oncall-access-grant", Namespace: "prod"}
+
+	// Issue
+	result, err := reconcile("oncall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter <= 59*time.Minute || result.RequeueAfter > time.Hour {
+		t.Errorf("requeue after %v, want the expiry", result.RequeueAfter)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(grant), grant); err != nil {
+		t.Fatal(err)
+	}
+	if grant.Status.Phase != accessGrantPhaseActive || grant.Status.ExpiresAt == nil {
+		t.Fatalf("grant status %+v", grant.Status)
+	}
+	role := &rbacv1.Role{}
+	if err := c.Get(ctx, bindingKey, role); err != nil {
+		t.Fatal(err)
+	}
+	if !metav1.IsControlledBy(role, grant) || role.Rules[0].ResourceNames[0] != "orders-db" {
+		t.Errorf("role %+v", role)
+	}
+
+	// Changed rules and subjects are reconciled
+	role.Rules[0].Verbs = []string{"get", "list"}
+	if err := c.Update(ctx, role); err != nil {
+		t.Fatal(err)
+	}
+	grant.Spec.Subject.Name = "bob"
+	if err := c.Update(ctx, grant); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reconcile("oncall"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, bindingKey, role); err != nil {
+		t.Fatal(err)
+	}
+	if len(role.Rules[0].Verbs) != 1 {
+		t.Errorf("role rules not reconciled: %+v", role.Rules)
+	}
+	roleBinding := &rbacv1.RoleBinding{}
+	if err := c.Get(ctx, bindingKey, roleBinding); err != nil {
+		t.Fatal(err)
+	}
+	if roleBinding.Subjects[0].Name != "bob" {
+		t.Errorf("subjects not reconciled: %+v", roleBinding.Subjects)
+	}
+
+	// A binding the grant does not own is not claimed as issued
+	if _, err := reconcile("other"); err == nil {
+		t.Error("foreign RoleBinding accepted")
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(other), other); err != nil {
+		t.Fatal(err)
+	}
+	if other.Status.Phase != accessGrantPhaseFailed {
+		t.Errorf("grant other phase %s", other.Status.Phase)
+	}
+
+	// Revoke on expiry, foreign objects are left alone
+	for _, g := range []*secopsv1alpha1.SentinelAccessGrant{grant, other} {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(g), g); err != nil {
+			t.Fatal(err)
+		}
+		expired := metav1.NewTime(time.Now().Add(-time.Minute))
+		g.Status.ExpiresAt = &expired
+		if err := c.Status().Update(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reconcile(g.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Get(ctx, bindingKey, &rbacv1.RoleBinding{}); !apierrors.IsNotFound(err) {
+		t.Errorf("RoleBinding not revoked: %v", err)
+	}
+	if err := c.Get(ctx, bindingKey, &rbacv1.Role{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Role not revoked: %v", err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(foreign), foreign); err != nil {
+		t.Errorf("foreign RoleBinding deleted: %v", err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(grant), grant); err != nil {
+		t.Fatal(err)
+	}
+	if grant.Status.Phase != accessGrantPhaseExpired {
+		t.Errorf("grant phase %s", grant.Status.Phase)
+	}
+}
+
+func TestAccessGrantApprovalWithdrawn(t *testing.T) {
+	ctx := context.Background()
+	scheme := newTestScheme(t)
+	sentinel := &secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod"},
+		Spec:       secopsv1alpha1.SentinelSpec{SecretName: "db-credentials", SecretType: typeSecretLocalEncrytedRbac},
+	}
+	accessRequest := &secopsv1alpha1.SentinelAccessRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "prod", UID: "request-uid", Annotations: map[string]string{
+			secopsv1alpha1.AccessRequestRequestedByAnnotation: "bob",
+			secopsv1alpha1.AccessRequestApprovalAnnotation:    secopsv1alpha1.AccessRequestApproved,
+			secopsv1alpha1.AccessRequestApprovedByAnnotation:  "alice",
+		}},
+		Spec: secopsv1alpha1.SentinelAccessRequestSpec{
+			SentinelName: "db",
+			Subject:      rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "bob"},
+			Duration:     metav1.Duration{Duration: time.Hour},
+		},
+	}
+	grant := &secopsv1alpha1.SentinelAccessGrant{
+		ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "prod"},
+		Spec: secopsv1alpha1.SentinelAccessGrantSpec{
+			SentinelName: "db", Subject: accessRequest.Spec.Subject, Duration: accessRequest.Spec.Duration,
+		},
+	}
+	if err := ctrl.SetControllerReference(accessRequest, grant, scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sentinel, accessRequest, grant).
+		WithStatusSubresource(&secopsv1alpha1.SentinelAccessGrant{}).Build()
+	r := &SentinelAccessGrantReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(100)}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(grant)}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	bindingKey := types.NamespacedName{Name: "debug-access-grant", Namespace: "prod"}
+	if err := c.Get(ctx, bindingKey, &rbacv1.RoleBinding{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Withdrawing the approval revokes the binding that was already issued
+	delete(accessRequest.Annotations, secopsv1alpha1.AccessRequestApprovalAnnotation)
+	if err := c.Update(ctx, accessRequest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err == nil {
+		t.Error("unapproved grant reconciled without an error")
+	}
+	if err := c.Get(ctx, bindingKey, &rbacv1.RoleBinding{}); !apierrors.IsNotFound(err) {
+		t.Errorf("RoleBinding not revoked: %v", err)
+	}
+	if err := c.Get(ctx, req.NamespacedName, grant); err != nil {
+		t.Fatal(err)
+	}
+	if grant.Status.Phase != accessGrantPhaseFailed || grant.Status.RoleBinding != "" {
+		t.Errorf("grant status %+v", grant.Status)
+	}
+}
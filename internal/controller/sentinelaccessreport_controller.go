@@ -0,0 +1,159 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/analyzer"
+)
+
+// defaultAccessReportInterval is used when the reconciler has no Interval
+const defaultAccessReportInterval = 10 * time.Minute
+
+// SentinelAccessReportReconciler keeps a SentinelAccessReport for every Sentinel that
+// lists the subjects able to read the managed secret.
+type SentinelAccessReportReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Interval between two analyses of the cluster RBAC
+	Interval time.Duration
+}
+
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelaccessreports,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelaccessreports/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelaccessreports/finalizers,verbs=update
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=get;list;watch
+
+// Reconcile analyzes the cluster RBAC for the secret of a Sentinel. The request
+// names the Sentinel, its report shares the name and is owned by it.
+func (r *SentinelAccessReportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := r.Get(ctx, req.NamespacedName, sentinel); err != nil {
+		if apierrors.IsNotFound(err) {
+			// The report is removed together with the Sentinel
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get sentinel")
+		return ctrl.Result{}, err
+	}
+	if sentinel.GetDeletionTimestamp() != nil {
+		return ctrl.Result{}, nil
+	}
+
+	report := &secopsv1alpha1.SentinelAccessReport{}
+	err := r.Get(ctx, req.NamespacedName, report)
+	if err != nil && apierrors.IsNotFound(err) {
+		report = &secopsv1alpha1.SentinelAccessReport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      sentinel.Name,
+				Namespace: sentinel.Namespace,
+			},
+			Spec: secopsv1alpha1.SentinelAccessReportSpec{
+				SentinelName: sentinel.Name,
+			},
+		}
+
+		// Set Sentinel instance as the owner of the SentinelAccessReport
+		if err := controllerutil.SetControllerReference(sentinel, report, r.Scheme); err != nil {
+			log.Error(err, "Setting Sentinel instance as the owner of the SentinelAccessReport Failed.")
+			return ctrl.Result{}, err
+		}
+
+		if err := r.Create(ctx, report); err != nil {
+			log.Error(err, "SentinelAccessReport Creation Failed.")
+			return ctrl.Result{}, err
+		}
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	snapshot, err := analyzer.Load(ctx, r.Client)
+	if err != nil {
+		log.Error(err, "Failed to load the cluster RBAC")
+		meta.SetStatusCondition(&report.Status.Conditions, metav1.Condition{Type: typeAvailableSentinel,
+			Status: metav1.ConditionFalse, Reason: "AnalysisFailed",
+			Message: fmt.Sprintf("Failed to load the cluster RBAC (%s): (%s)", sentinel.Name, err)})
+		if err := r.Status().Update(ctx, report); err != nil {
+			log.Error(err, "Failed to update SentinelAccessReport status")
+		}
+		return ctrl.Result{}, err
+	}
+
+	now := metav1.Now()
+	report.Status.SecretName = sentinel.Spec.SecretName
+	report.Status.Subjects = subjectAccessesForReport(snapshot.WhoCanReadSecret(sentinel.Namespace, sentinel.Spec.SecretName))
+	report.Status.LastAnalyzed = &now
+	meta.SetStatusCondition(&report.Status.Conditions, metav1.Condition{Type: typeAvailableSentinel,
+		Status: metav1.ConditionTrue, Reason: "Analyzed",
+		Message: fmt.Sprintf("%d subjects can read secret %s", len(report.Status.Subjects), sentinel.Spec.SecretName)})
+
+	if err := r.Status().Update(ctx, report); err != nil {
+		log.Error(err, "Failed to update SentinelAccessReport status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: r.interval()}, nil
+}
+
+func (r *SentinelAccessReportReconciler) interval() time.Duration {
+	if r.Interval > 0 {
+		return r.Interval
+	}
+	return defaultAccessReportInterval
+}
+
+// subjectAccessesForReport converts the analyzer result to the API type
+func subjectAccessesForReport(accesses []analyzer.SubjectAccess) []secopsv1alpha1.SubjectAccess {
+	subjects := make([]secopsv1alpha1.SubjectAccess, 0, len(accesses))
+	for _, access := range accesses {
+		subjects = append(subjects, secopsv1alpha1.SubjectAccess{
+			Kind:      access.Subject.Kind,
+			Name:      access.Subject.Name,
+			Namespace: access.Subject.Namespace,
+			Verbs:     access.Verbs,
+			Via:       access.Via,
+		})
+	}
+	return subjects
+}
+
+// SetupWithManager sets up the controller with the Manager. Status updates are
+// filtered, the periodic requeue takes care of changes to the cluster RBAC.
+func (r *SentinelAccessReportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("sentinelaccessreport").
+		For(&secopsv1alpha1.Sentinel{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&secopsv1alpha1.SentinelAccessReport{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
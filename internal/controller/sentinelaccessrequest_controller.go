@@ -0,0 +1,244 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// Phases of a SentinelAccessRequest
+const (
+	accessRequestPhasePending  = "Pending"
+	accessRequestPhaseApproved = "Approved"
+	accessRequestPhaseDenied   = "Denied"
+	accessRequestPhaseFailed   = "Failed"
+)
+
+// SentinelAccessRequestReconciler reconciles a SentinelAccessRequest object
+type SentinelAccessRequestReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// ApprovalWebhook reports whether the admission webhook that records the requester and the
+	// approver is served. Without it the annotations are set by the clients themselves, so
+	// decisions are refused and requests stay unapproved.
+	ApprovalWebhook bool
+}
+
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelaccessrequests,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelaccessrequests/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelaccessrequests/finalizers,verbs=update
+
+// Reconcile keeps a SentinelAccessRequest Pending until the admission webhook recorded
+// a decision of an approver and materializes an approved request as a SentinelAccessGrant.
+func (r *SentinelAccessRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	accessRequest := &secopsv1alpha1.SentinelAccessRequest{}
+	if err := r.Get(ctx, req.NamespacedName, accessRequest); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("sentinelaccessrequest resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get sentinelaccessrequest")
+		return ctrl.Result{}, err
+	}
+
+	annotations := accessRequest.GetAnnotations()
+	accessRequest.Status.RequestedBy = annotations[secopsv1alpha1.AccessRequestRequestedByAnnotation]
+	accessRequest.Status.DecidedBy = annotations[secopsv1alpha1.AccessRequestApprovedByAnnotation]
+
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := r.Get(ctx, types.NamespacedName{Name: accessRequest.Spec.SentinelName, Namespace: accessRequest.Namespace}, sentinel); err != nil {
+		log.Error(err, "Sentinel of the access request not found!")
+		return ctrl.Result{}, r.updateAccessRequestStatus(accessRequest, ctx, accessRequestPhaseFailed, "NotFound",
+			fmt.Sprintf("Sentinel %s not found: (%s)", accessRequest.Spec.SentinelName, err), err)
+	}
+
+	if !requiresApproval(sentinel) {
+		approvalErr := fmt.Errorf("Sentinel %s of type %s does not use access approvals", sentinel.Name, sentinel.Spec.SecretType)
+		log.Error(approvalErr, "Invalid access request!")
+		return ctrl.Result{}, r.updateAccessRequestStatus(accessRequest, ctx, accessRequestPhaseFailed, "Invalid",
+			approvalErr.Error(), nil)
+	}
+
+	decision := annotations[secopsv1alpha1.AccessRequestApprovalAnnotation]
+	decidedBy := accessRequest.Status.DecidedBy
+
+	if decision != "" && !r.ApprovalWebhook {
+		approvalErr := fmt.Errorf("decision %q on access request %s can not be verified, the approval webhook is disabled", decision, accessRequest.Name)
+		log.Error(approvalErr, "Unverified approval!")
+		return ctrl.Result{}, r.updateAccessRequestStatus(accessRequest, ctx, accessRequestPhaseFailed, "WebhookRequired",
+			approvalErr.Error(), nil)
+	}
+
+	// The webhook guarantees who decided, this only rejects objects that bypassed it
+	if decision != "" && (decidedBy == "" || decidedBy == accessRequest.Status.RequestedBy) {
+		approvalErr := fmt.Errorf("decision %q on access request %s has no valid approver", decision, accessRequest.Name)
+		log.Error(approvalErr, "Invalid approval!")
+		return ctrl.Result{}, r.updateAccessRequestStatus(accessRequest, ctx, accessRequestPhaseFailed, "InvalidApproval",
+			approvalErr.Error(), nil)
+	}
+
+	switch decision {
+	case "":
+		return ctrl.Result{}, r.updateAccessRequestStatus(accessRequest, ctx, accessRequestPhasePending, "AwaitingApproval",
+			fmt.Sprintf("Waiting for an approver of Sentinel %s", sentinel.Name), nil)
+
+	case secopsv1alpha1.AccessRequestDenied:
+		if accessRequest.Status.Phase != accessRequestPhaseDenied {
+			r.Recorder.Event(accessRequest, corev1.EventTypeNormal, "AccessDenied",
+				fmt.Sprintf("Access request of %s was denied by %s", accessRequest.Status.RequestedBy, decidedBy))
+		}
+		return ctrl.Result{}, r.updateAccessRequestStatus(accessRequest, ctx, accessRequestPhaseDenied, "Denied",
+			fmt.Sprintf("Access request was denied by %s", decidedBy), nil)
+
+	case secopsv1alpha1.AccessRequestApproved:
+		if err := r.grantForAccessRequest(accessRequest, ctx); err != nil {
+			return ctrl.Result{}, r.updateAccessRequestStatus(accessRequest, ctx, accessRequestPhaseFailed, "GrantFailed",
+				fmt.Sprintf("Failed to create the access grant: (%s)", err), err)
+		}
+		if accessRequest.Status.Phase != accessRequestPhaseApproved {
+			r.Recorder.Event(accessRequest, corev1.EventTypeNormal, "AccessApproved",
+				fmt.Sprintf("Access request of %s was approved by %s", accessRequest.Status.RequestedBy, decidedBy))
+		}
+		accessRequest.Status.AccessGrant = accessRequest.Name
+		return ctrl.Result{}, r.updateAccessRequestStatus(accessRequest, ctx, accessRequestPhaseApproved, "Approved",
+			fmt.Sprintf("Access request was approved by %s", decidedBy), nil)
+
+	default:
+		decisionErr := fmt.Errorf("unknown decision %q", decision)
+		return ctrl.Result{}, r.updateAccessRequestStatus(accessRequest, ctx, accessRequestPhaseFailed, "InvalidApproval",
+			decisionErr.Error(), nil)
+	}
+}
+
+// grantForAccessRequest creates the SentinelAccessGrant owned by an approved request. An approval
+// issues one grant, a grant deleted after it was recorded in the status is not created again.
+func (r *SentinelAccessRequestReconciler) grantForAccessRequest(
+	accessRequest *secopsv1alpha1.SentinelAccessRequest, ctx context.Context) error {
+
+	log := log.FromContext(ctx)
+
+	if accessRequest.Status.AccessGrant != "" {
+		return nil
+	}
+
+	grant := &secopsv1alpha1.SentinelAccessGrant{}
+	err := r.Get(ctx, types.NamespacedName{Name: accessRequest.Name, Namespace: accessRequest.Namespace}, grant)
+	if err == nil || !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	newGrant := &secopsv1alpha1.SentinelAccessGrant{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      accessRequest.Name,
+			Namespace: accessRequest.Namespace,
+		},
+		Spec: secopsv1alpha1.SentinelAccessGrantSpec{
+			SentinelName: accessRequest.Spec.SentinelName,
+			Subject:      accessRequest.Spec.Subject,
+			Duration:     accessRequest.Spec.Duration,
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(accessRequest, newGrant, r.Scheme); err != nil {
+		log.Error(err, "Setting SentinelAccessRequest instance as the owner of the SentinelAccessGrant Failed.")
+		return err
+	}
+
+	if err := r.Create(ctx, newGrant); err != nil {
+		log.Error(err, "SentinelAccessGrant Creation Failed.")
+		return err
+	}
+
+	return nil
+}
+
+// updateAccessRequestStatus writes the phase and condition of the request and passes the cause through
+func (r *SentinelAccessRequestReconciler) updateAccessRequestStatus(accessRequest *secopsv1alpha1.SentinelAccessRequest,
+	ctx context.Context, phase string, reason string, message string, cause error) error {
+
+	status := metav1.ConditionFalse
+	if phase == accessRequestPhaseApproved {
+		status = metav1.ConditionTrue
+	}
+
+	accessRequest.Status.Phase = phase
+	meta.SetStatusCondition(&accessRequest.Status.Conditions, metav1.Condition{Type: typeAvailableSentinel,
+		Status: status, Reason: reason, Message: message})
+
+	if err := r.Status().Update(ctx, accessRequest); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update SentinelAccessRequest status")
+		return err
+	}
+
+	return cause
+}
+
+// requiresApproval reports whether access to the secret of the Sentinel must be approved
+func requiresApproval(sentinel *secopsv1alpha1.Sentinel) bool {
+	return sentinel.Spec.SecretType == typeSecretLocalEncrytedRbac || sentinel.Spec.SecretType == typeSecretKmsEncryptedRbac
+}
+
+// isApprovedGrant reports whether the grant was created for an approved SentinelAccessRequest
+func isApprovedGrant(c client.Client, grant *secopsv1alpha1.SentinelAccessGrant, ctx context.Context) (bool, error) {
+	owner := metav1.GetControllerOf(grant)
+	if owner == nil || owner.Kind != "SentinelAccessRequest" || owner.APIVersion != secopsv1alpha1.GroupVersion.String() {
+		return false, nil
+	}
+
+	accessRequest := &secopsv1alpha1.SentinelAccessRequest{}
+	if err := c.Get(ctx, types.NamespacedName{Name: owner.Name, Namespace: grant.Namespace}, accessRequest); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	annotations := accessRequest.GetAnnotations()
+	return accessRequest.UID == owner.UID &&
+		annotations[secopsv1alpha1.AccessRequestApprovalAnnotation] == secopsv1alpha1.AccessRequestApproved &&
+		annotations[secopsv1alpha1.AccessRequestApprovedByAnnotation] != "" &&
+		annotations[secopsv1alpha1.AccessRequestApprovedByAnnotation] != annotations[secopsv1alpha1.AccessRequestRequestedByAnnotation] &&
+		accessRequest.Spec.SentinelName == grant.Spec.SentinelName &&
+		accessRequest.Spec.Subject == grant.Spec.Subject &&
+		accessRequest.Spec.Duration == grant.Spec.Duration, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SentinelAccessRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&secopsv1alpha1.SentinelAccessRequest{}).
+		Owns(&secopsv1alpha1.SentinelAccessGrant{}).
+		Complete(r)
+}
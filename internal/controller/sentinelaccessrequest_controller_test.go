@@ -0,0 +1,125 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+func TestAccessRequestApproval(t *testing.T) {
+	ctx := context.Background()
+	sentinel := &secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod"},
+		Spec: secopsv1alpha1.SentinelSpec{
+			SecretName: "db-credentials", SecretType: typeSecretLocalEncrytedRbac, Approvers: []string{"alice"},
+		},
+	}
+	decided := func(approval, approvedBy string) map[string]string {
+		annotations := map[string]string{secopsv1alpha1.AccessRequestRequestedByAnnotation: "bob"}
+		if approval != "" {
+			annotations[secopsv1alpha1.AccessRequestApprovalAnnotation] = approval
+			annotations[secopsv1alpha1.AccessRequestApprovedByAnnotation] = approvedBy
+		}
+		return annotations
+	}
+
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		webhook     bool
+		phase       string
+		grant       bool
+	}{
+		{"pending", decided("", ""), true, accessRequestPhasePending, false},
+		{"approved", decided(secopsv1alpha1.AccessRequestApproved, "alice"), true, accessRequestPhaseApproved, true},
+		{"denied", decided(secopsv1alpha1.AccessRequestDenied, "alice"), true, accessRequestPhaseDenied, false},
+		{"self approved", decided(secopsv1alpha1.AccessRequestApproved, "bob"), true, accessRequestPhaseFailed, false},
+		{"webhook disabled", decided(secopsv1alpha1.AccessRequestApproved, "alice"), false, accessRequestPhaseFailed, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := newTestScheme(t)
+			accessRequest := &secopsv1alpha1.SentinelAccessRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "prod", Annotations: tc.annotations},
+				Spec: secopsv1alpha1.SentinelAccessRequestSpec{
+					SentinelName: "db",
+					Subject:      rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "bob"},
+					Duration:     metav1.Duration{Duration: time.Hour},
+				},
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sentinel, accessRequest).
+				WithStatusSubresource(accessRequest).Build()
+			r := &SentinelAccessRequestReconciler{
+				Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10), ApprovalWebhook: tc.webhook,
+			}
+			if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(accessRequest)}); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(accessRequest), accessRequest); err != nil {
+				t.Fatal(err)
+			}
+			if accessRequest.Status.Phase != tc.phase {
+				t.Errorf("phase %s, want %s", accessRequest.Status.Phase, tc.phase)
+			}
+
+			grant := &secopsv1alpha1.SentinelAccessGrant{}
+			err := c.Get(ctx, types.NamespacedName{Name: "debug", Namespace: "prod"}, grant)
+			if !tc.grant {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("grant created: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approved, err := isApprovedGrant(c, grant, ctx); err != nil || !approved {
+				t.Errorf("grant approved %v: %v", approved, err)
+			}
+
+			// A grant with a longer duration than the approved one is not approved
+			longer := grant.DeepCopy()
+			longer.Spec.Duration = metav1.Duration{Duration: 24 * time.Hour}
+			if approved, err := isApprovedGrant(c, longer, ctx); err != nil || approved {
+				t.Errorf("grant with a changed duration approved %v: %v", approved, err)
+			}
+
+			// A deleted grant is not issued again by the same approval
+			if err := c.Delete(ctx, grant); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(accessRequest)}); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(grant), grant); !apierrors.IsNotFound(err) {
+				t.Errorf("grant issued again: %v", err)
+			}
+		})
+	}
+}
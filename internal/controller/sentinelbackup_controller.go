@@ -0,0 +1,392 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+	"github.com/kavinduxo/sentinel-operator/internal/backup"
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+const (
+	// defaultBackupInterval is used when a SentinelBackup has no interval
+	defaultBackupInterval = 24 * time.Hour
+	// backupRetryInterval is the wait before a failed backup is tried again
+	backupRetryInterval = 5 * time.Minute
+	// typeReadyBackup reports the result of the latest backup
+	typeReadyBackup = "Ready"
+)
+
+// SentinelBackupReconciler writes the encrypted backups of the SentinelBackups
+type SentinelBackupReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Audit is optional and records the backups
+	Audit *audit.Logger
+	// Namespace holds the secrets the SentinelBackups reference, the namespace of the operator
+	Namespace string
+	// VolumeRoot is the directory the backup volumes are mounted below, volume locations
+	// are disabled when empty
+	VolumeRoot string
+}
+
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelbackups,verbs=get;list;watch
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelbackups/status,verbs=get;update;patch
+
+// Reconcile writes a backup when the interval of the SentinelBackup passed
+func (r *SentinelBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	sentinelBackup := &secopsv1alpha1.SentinelBackup{}
+	if err := r.Get(ctx, req.NamespacedName, sentinelBackup); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get SentinelBackup")
+		return ctrl.Result{}, err
+	}
+	if sentinelBackup.Spec.Suspend {
+		return ctrl.Result{}, nil
+	}
+
+	interval := defaultBackupInterval
+	if sentinelBackup.Spec.Interval != nil && sentinelBackup.Spec.Interval.Duration > 0 {
+		interval = sentinelBackup.Spec.Interval.Duration
+	}
+	now := time.Now()
+	if last := sentinelBackup.Status.LastBackupTime; last != nil && now.Before(last.Add(interval)) {
+		return ctrl.Result{RequeueAfter: last.Add(interval).Sub(now)}, nil
+	}
+
+	name, count, err := r.writeBackup(sentinelBackup, ctx, now)
+	if err != nil {
+		log.Error(err, "Backup Failed.", "SentinelBackup.Name", sentinelBackup.Name)
+		r.Recorder.Event(sentinelBackup, corev1.EventTypeWarning, "BackupFailed", err.Error())
+		meta.SetStatusCondition(&sentinelBackup.Status.Conditions, metav1.Condition{
+			Type: typeReadyBackup, Status: metav1.ConditionFalse, Reason: "BackupFailed",
+			Message: err.Error(), ObservedGeneration: sentinelBackup.Generation,
+		})
+		if err := r.Status().Update(ctx, sentinelBackup); err != nil {
+			log.Error(err, "Failed to update SentinelBackup status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: backupRetryInterval}, nil
+	}
+
+	message := fmt.Sprintf("Backup %s holds %d Sentinels", name, count)
+	r.Recorder.Event(sentinelBackup, corev1.EventTypeNormal, "BackupCompleted", message)
+	auditLog(r.Audit, ctx, audit.Record{
+		Actor:   audit.ActorOperator,
+		Action:  audit.ActionBackup,
+		Object:  auditObject(sentinelBackup, "SentinelBackup"),
+		Details: map[string]string{"backup": name, "sentinels": fmt.Sprint(count)},
+	})
+
+	sentinelBackup.Status.LastBackupTime = &metav1.Time{Time: now}
+	sentinelBackup.Status.LastBackup = name
+	sentinelBackup.Status.Sentinels = int32(count)
+	meta.SetStatusCondition(&sentinelBackup.Status.Conditions, metav1.Condition{
+		Type: typeReadyBackup, Status: metav1.ConditionTrue, Reason: "BackupCompleted",
+		Message: message, ObservedGeneration: sentinelBackup.Generation,
+	})
+	if err := r.Status().Update(ctx, sentinelBackup); err != nil {
+		log.Error(err, "Failed to update SentinelBackup status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// writeBackup exports the selected Sentinels, writes the sealed bundle and removes the
+// backups beyond the retention. It returns the name of the backup and the number of Sentinels.
+func (r *SentinelBackupReconciler) writeBackup(
+	sentinelBackup *secopsv1alpha1.SentinelBackup, ctx context.Context, now time.Time) (string, int, error) {
+
+	wrapper, err := r.backupKeyWrapper(ctx, &sentinelBackup.Spec.Encryption)
+	if err != nil {
+		return "", 0, err
+	}
+	signingKey, err := readSecretKey(ctx, r.Client, r.Namespace, &sentinelBackup.Spec.SigningKeySecretRef)
+	if err != nil {
+		return "", 0, err
+	}
+	store, err := storeForLocation(ctx, r.Client, r.Namespace, r.VolumeRoot, &sentinelBackup.Spec.Location)
+	if err != nil {
+		return "", 0, err
+	}
+	bundle, err := r.exportSentinels(sentinelBackup, ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	bundle.Created = metav1.Time{Time: now}
+	name := backup.Name(sentinelBackup.Name, now)
+	sealed, err := backup.Seal(ctx, wrapper, signingKey, name, bundle)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := store.Put(ctx, name, sealed); err != nil {
+		return "", 0, err
+	}
+
+	// A failed cleanup keeps more backups than asked for, the next backup tries again
+	keep := int(sentinelBackup.Spec.KeepLast)
+	if keep < 1 {
+		keep = 7
+	}
+	if names, err := store.List(ctx); err != nil {
+		log.FromContext(ctx).Error(err, "Listing Backups Failed.")
+	} else {
+		for _, expired := range backup.Expired(names, sentinelBackup.Name, keep) {
+			if err := store.Delete(ctx, expired); err != nil {
+				log.FromContext(ctx).Error(err, "Deleting Expired Backup Failed.", "backup", expired)
+			}
+		}
+	}
+	return name, len(bundle.Entries), nil
+}
+
+// exportSentinels collects the selected Sentinels and the objects they control
+func (r *SentinelBackupReconciler) exportSentinels(
+	sentinelBackup *secopsv1alpha1.SentinelBackup, ctx context.Context) (*backup.Bundle, error) {
+
+	listOptions := []client.ListOption{}
+	if sentinelBackup.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(sentinelBackup.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+		listOptions = append(listOptions, client.MatchingLabelsSelector{Selector: selector})
+	}
+	namespaceSelector := labels.Everything()
+	if sentinelBackup.Spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(sentinelBackup.Spec.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		namespaceSelector = selector
+	}
+
+	sentinels := &secopsv1alpha1.SentinelList{}
+	if err := r.List(ctx, sentinels, listOptions...); err != nil {
+		return nil, err
+	}
+	bundle := &backup.Bundle{Version: backup.Version}
+	selected := map[string]bool{}
+	for i := range sentinels.Items {
+		sentinel := &sentinels.Items[i]
+		if sentinel.DeletionTimestamp != nil {
+			continue
+		}
+		if !namespaceSelector.Empty() {
+			ok, known := selected[sentinel.Namespace]
+			if !known {
+				namespace := &corev1.Namespace{}
+				if err := r.Get(ctx, types.NamespacedName{Name: sentinel.Namespace}, namespace); err != nil {
+					return nil, err
+				}
+				ok = namespaceSelector.Matches(labels.Set(namespace.Labels))
+				selected[sentinel.Namespace] = ok
+			}
+			if !ok {
+				continue
+			}
+		}
+		entry, err := r.exportSentinel(sentinel, ctx)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Entries = append(bundle.Entries, *entry)
+	}
+	return bundle, nil
+}
+
+// exportSentinel returns the Sentinel and the objects named in its spec that it controls,
+// without the metadata of this cluster
+func (r *SentinelBackupReconciler) exportSentinel(sentinel *secopsv1alpha1.Sentinel, ctx context.Context) (*backup.Entry, error) {
+	entry := &backup.Entry{Sentinel: *sentinel.DeepCopy()}
+	entry.Sentinel.ObjectMeta = exportedMeta(sentinel.ObjectMeta)
+	entry.Sentinel.Status = secopsv1alpha1.SentinelStatus{}
+
+	// get returns whether the named object exists and is controlled by the Sentinel
+	get := func(name string, obj client.Object) (bool, error) {
+		if name == "" {
+			return false, nil
+		}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: sentinel.Namespace}, obj)
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		return metav1.IsControlledBy(obj, sentinel), nil
+	}
+
+	for _, name := range []string{sentinel.Spec.SecretName, sentinel.Spec.ServiceAccountTokenSecret} {
+		secret := &corev1.Secret{}
+		if ok, err := get(name, secret); err != nil {
+			return nil, err
+		} else if ok {
+			secret.ObjectMeta = exportedMeta(secret.ObjectMeta)
+			if secret.Type == corev1.SecretTypeServiceAccountToken {
+				// Tokens are only valid in this cluster, the restored secret receives a new one
+				// for the restored ServiceAccount
+				secret.Data = nil
+				delete(secret.Annotations, corev1.ServiceAccountUIDKey)
+			}
+			entry.Secrets = append(entry.Secrets, *secret)
+		}
+	}
+	serviceAccount := &corev1.ServiceAccount{}
+	if ok, err := get(sentinel.Spec.ServiceAccount, serviceAccount); err != nil {
+		return nil, err
+	} else if ok {
+		serviceAccount.ObjectMeta = exportedMeta(serviceAccount.ObjectMeta)
+		serviceAccount.Secrets = nil
+		entry.ServiceAccounts = append(entry.ServiceAccounts, *serviceAccount)
+	}
+	role := &rbacv1.Role{}
+	if ok, err := get(sentinel.Spec.Role, role); err != nil {
+		return nil, err
+	} else if ok {
+		role.ObjectMeta = exportedMeta(role.ObjectMeta)
+		entry.Roles = append(entry.Roles, *role)
+	}
+	roleBinding := &rbacv1.RoleBinding{}
+	if ok, err := get(sentinel.Spec.RoleBinding, roleBinding); err != nil {
+		return nil, err
+	} else if ok {
+		roleBinding.ObjectMeta = exportedMeta(roleBinding.ObjectMeta)
+		entry.RoleBindings = append(entry.RoleBindings, *roleBinding)
+	}
+	return entry, nil
+}
+
+// exportedMeta keeps the metadata that is meaningful in another cluster
+func exportedMeta(objectMeta metav1.ObjectMeta) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:        objectMeta.Name,
+		Namespace:   objectMeta.Namespace,
+		Labels:      objectMeta.Labels,
+		Annotations: objectMeta.Annotations,
+	}
+}
+
+// backupKeyWrapper builds the wrapper the backups are encrypted for
+func (r *SentinelBackupReconciler) backupKeyWrapper(
+	ctx context.Context, encryption *secopsv1alpha1.BackupEncryption) (envelope.KeyWrapper, error) {
+
+	switch {
+	case encryption.Recipient != "" && encryption.VaultTransit != nil:
+		return nil, fmt.Errorf("Encryption sets both recipient and vaultTransit")
+	case encryption.Recipient != "":
+		return envelope.NewX25519Recipient([]byte(encryption.Recipient))
+	case encryption.VaultTransit != nil:
+		wrapper, err := vaultTransitWrapper(ctx, r.Client, r.Namespace, encryption.VaultTransit)
+		if err != nil {
+			return nil, err
+		}
+		// A token that can also decrypt would let the operator open the backups it writes
+		canDecrypt, err := wrapper.CanDecrypt(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to check the capabilities of the Vault token: %w", err)
+		}
+		if canDecrypt {
+			return nil, fmt.Errorf("The Vault token can decrypt with transit key %s, grant it only the encrypt capability",
+				wrapper.KeyID())
+		}
+		return wrapper, nil
+	}
+	return nil, fmt.Errorf("Encryption sets neither recipient nor vaultTransit")
+}
+
+// vaultTransitWrapper builds the wrapper of a transit key whose token is kept in the namespace
+func vaultTransitWrapper(
+	ctx context.Context, reader client.Reader, namespace string, spec *secopsv1alpha1.VaultTransitSpec) (*envelope.VaultTransit, error) {
+
+	token, err := readSecretKey(ctx, reader, namespace, &spec.TokenSecretRef)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope.VaultTransit{Address: spec.Address, Mount: spec.Mount, KeyName: spec.KeyName, Token: string(token)}, nil
+}
+
+// storeForLocation builds the store of a backup location, the S3 credentials are read from
+// the namespace of the operator
+func storeForLocation(
+	ctx context.Context, reader client.Reader, namespace, volumeRoot string, location *secopsv1alpha1.BackupLocation) (backup.Store, error) {
+
+	switch {
+	case location.S3 != nil && location.Volume != nil:
+		return nil, fmt.Errorf("Location sets both s3 and volume")
+	case location.S3 != nil:
+		ref := location.S3.CredentialsSecretRef
+		accessKeyID, err := readSecretKey(ctx, reader, namespace,
+			&corev1.SecretKeySelector{LocalObjectReference: ref, Key: "accessKeyID"})
+		if err != nil {
+			return nil, err
+		}
+		secretAccessKey, err := readSecretKey(ctx, reader, namespace,
+			&corev1.SecretKeySelector{LocalObjectReference: ref, Key: "secretAccessKey"})
+		if err != nil {
+			return nil, err
+		}
+		return &backup.S3{
+			Endpoint:        location.S3.Endpoint,
+			Bucket:          location.S3.Bucket,
+			Prefix:          location.S3.Prefix,
+			Region:          location.S3.Region,
+			AccessKeyID:     string(accessKeyID),
+			SecretAccessKey: string(secretAccessKey),
+		}, nil
+	case location.Volume != nil:
+		dir, err := backup.VolumeDir(volumeRoot, location.Volume.ClaimName, location.Volume.Path)
+		if err != nil {
+			return nil, err
+		}
+		return &backup.Dir{Dir: dir}, nil
+	}
+	return nil, fmt.Errorf("Location sets neither s3 nor volume")
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SentinelBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// The status updates of the backups must not trigger the next backup
+		For(&secopsv1alpha1.SentinelBackup{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
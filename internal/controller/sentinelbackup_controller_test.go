@@ -0,0 +1,258 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/backup"
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := secopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "backups"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	private, public, err := envelope.GenerateX25519()
+	if err != nil {
+		t.Fatal(err)
+	}
+	location := secopsv1alpha1.BackupLocation{Volume: &secopsv1alpha1.VolumeLocation{ClaimName: "backups", Path: "prod"}}
+
+	signingKey := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup-signing", Namespace: "sentinel-system"},
+		Data:       map[string][]byte{"key": []byte("0123456789abcdef0123456789abcdef")},
+	}
+	signingKeyRef := corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "backup-signing"}, Key: "key"}
+
+	// The source cluster holds a Sentinel with its secret and Role, the Role was widened and
+	// an unrelated secret was made owned by the Sentinel
+	sentinel := &secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod", UID: "old-uid"},
+		Spec:       secopsv1alpha1.SentinelSpec{SecretName: "db-credentials", SecretType: "RbacSecuredSecret", Role: "db-reader"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-credentials", Namespace: "prod"},
+		Data:       map[string][]byte{"password": []byte("s3cr3t")},
+	}
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-reader", Namespace: "prod"},
+		Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+	}
+	other := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "prod"}}
+	for _, obj := range []client.Object{secret, role, other} {
+		if err := controllerutil.SetControllerReference(sentinel, obj, scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sentinelBackup := &secopsv1alpha1.SentinelBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+		Spec: secopsv1alpha1.SentinelBackupSpec{
+			Encryption:          secopsv1alpha1.BackupEncryption{Recipient: public},
+			Location:            location,
+			SigningKeySecretRef: signingKeyRef,
+			KeepLast:            1,
+		},
+	}
+	source := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(sentinel, secret, role, other, signingKey, sentinelBackup).
+		WithStatusSubresource(sentinelBackup).Build()
+	backupReconciler := &SentinelBackupReconciler{
+		Client: source, Scheme: scheme, Recorder: record.NewFakeRecorder(10), Namespace: "sentinel-system", VolumeRoot: root,
+	}
+	result, err := backupReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "nightly"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter != defaultBackupInterval {
+		t.Errorf("next backup in %v", result.RequeueAfter)
+	}
+	if err := source.Get(ctx, types.NamespacedName{Name: "nightly"}, sentinelBackup); err != nil {
+		t.Fatal(err)
+	}
+	if sentinelBackup.Status.Sentinels != 1 || sentinelBackup.Status.LastBackup == "" {
+		t.Fatalf("backup status %+v", sentinelBackup.Status)
+	}
+
+	// A newer file planted in the location is not signed and skipped
+	dir, err := backup.VolumeDir(root, "backups", "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	planted := backup.Name("nightly", time.Now().Add(time.Hour))
+	if err := os.WriteFile(filepath.Join(dir, planted), []byte(`{"name":"`+planted+`"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The target cluster restores with the private key
+	identity := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup-identity", Namespace: "sentinel-system"},
+		Data:       map[string][]byte{"key": []byte(private)},
+	}
+	restore := &secopsv1alpha1.SentinelRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore"},
+		Spec: secopsv1alpha1.SentinelRestoreSpec{
+			Location: location,
+			Decryption: secopsv1alpha1.BackupDecryption{IdentitySecretRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "backup-identity"}, Key: "key"}},
+			SigningKeySecretRef: signingKeyRef,
+		},
+	}
+	target := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(identity, signingKey.DeepCopy(), restore).WithStatusSubresource(restore).Build()
+	restoreReconciler := &SentinelRestoreReconciler{
+		Client: target, Scheme: scheme, Recorder: record.NewFakeRecorder(10), Namespace: "sentinel-system", VolumeRoot: root,
+	}
+	if _, err := restoreReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "restore"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Get(ctx, types.NamespacedName{Name: "restore"}, restore); err != nil {
+		t.Fatal(err)
+	}
+	if restore.Status.Phase != secopsv1alpha1.RestorePhaseCompleted || len(restore.Status.Restored) != 1 ||
+		restore.Status.Backup != sentinelBackup.Status.LastBackup {
+		t.Fatalf("restore status %+v", restore.Status)
+	}
+
+	restored := &secopsv1alpha1.Sentinel{}
+	if err := target.Get(ctx, types.NamespacedName{Name: "db", Namespace: "prod"}, restored); err != nil {
+		t.Fatal(err)
+	}
+	restoredSecret := &corev1.Secret{}
+	if err := target.Get(ctx, types.NamespacedName{Name: "db-credentials", Namespace: "prod"}, restoredSecret); err != nil {
+		t.Fatal(err)
+	}
+	if string(restoredSecret.Data["password"]) != "s3cr3t" {
+		t.Errorf("restored secret data %v", restoredSecret.Data)
+	}
+	if !metav1.IsControlledBy(restoredSecret, restored) {
+		t.Errorf("restored secret owners %+v", restoredSecret.OwnerReferences)
+	}
+	restoredRole := &rbacv1.Role{}
+	if err := target.Get(ctx, types.NamespacedName{Name: "db-reader", Namespace: "prod"}, restoredRole); err != nil {
+		t.Fatal(err)
+	}
+	if !metav1.IsControlledBy(restoredRole, restored) {
+		t.Errorf("restored role owners %+v", restoredRole.OwnerReferences)
+	}
+	if !reflect.DeepEqual(restoredRole.Rules, roleForSentinel(restored).Rules) {
+		t.Errorf("restored role rules %+v", restoredRole.Rules)
+	}
+	if err := target.Get(ctx, types.NamespacedName{Name: "other", Namespace: "prod"}, &corev1.Secret{}); !apierrors.IsNotFound(err) {
+		t.Errorf("secret the Sentinel does not create was restored: %v", err)
+	}
+
+	// The planted backup is refused when it is named
+	forged := &secopsv1alpha1.SentinelRestore{ObjectMeta: metav1.ObjectMeta{Name: "forged"}, Spec: restore.Spec}
+	forged.Spec.Backup = planted
+	if err := target.Create(ctx, forged); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restoreReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "forged"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Get(ctx, types.NamespacedName{Name: "forged"}, forged); err != nil {
+		t.Fatal(err)
+	}
+	if forged.Status.Phase != secopsv1alpha1.RestorePhaseFailed || !strings.Contains(forged.Status.Message, "signature does not verify") {
+		t.Errorf("forged restore status %+v", forged.Status)
+	}
+
+	// A second restore keeps the existing Sentinel
+	second := &secopsv1alpha1.SentinelRestore{ObjectMeta: metav1.ObjectMeta{Name: "second"}, Spec: restore.Spec}
+	if err := target.Create(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restoreReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "second"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Get(ctx, types.NamespacedName{Name: "second"}, second); err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Status.Skipped) != 1 || len(second.Status.Restored) != 0 {
+		t.Errorf("second restore status %+v", second.Status)
+	}
+}
+
+func TestBackupKeyWrapperVaultTransit(t *testing.T) {
+	// The fake Vault reports the capabilities of each token on the decrypt path
+	capabilities := map[string][]string{"encrypt-only": {"deny"}, "encrypt-decrypt": {"update"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sys/capabilities-self" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string][]string{
+			"transit/decrypt/backup": capabilities[r.Header.Get("X-Vault-Token")]})
+	}))
+	defer server.Close()
+
+	for token, wantErr := range map[string]bool{"encrypt-only": false, "encrypt-decrypt": true} {
+		t.Run(token, func(t *testing.T) {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "vault-token", Namespace: "sentinel-system"},
+				Data:       map[string][]byte{"token": []byte(token)},
+			}
+			r := &SentinelBackupReconciler{
+				Client: fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(secret).Build(), Namespace: "sentinel-system",
+			}
+			_, err := r.backupKeyWrapper(context.Background(), &secopsv1alpha1.BackupEncryption{
+				VaultTransit: &secopsv1alpha1.VaultTransitSpec{
+					Address: server.URL, KeyName: "backup",
+					TokenSecretRef: corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "vault-token"}, Key: "token"},
+				},
+			})
+			if (err != nil) != wantErr {
+				t.Errorf("wrapper error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/notify"
+)
+
+// notifierRecheckInterval is the wait before an invalid SentinelNotifier is checked again,
+// its secrets are not watched
+const notifierRecheckInterval = time.Minute
+
+// SentinelNotifierReconciler validates the channel configuration of the SentinelNotifiers.
+// The notifications themselves are sent by the notify.Dispatcher, which skips the
+// notifiers that are not Ready.
+type SentinelNotifierReconciler struct {
+	client.Client
+	// Namespace holds the secrets the SentinelNotifiers reference, the namespace of the operator
+	Namespace string
+}
+
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelnotifiers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelnotifiers/status,verbs=get;update;patch
+
+// Reconcile sets the Ready condition of a SentinelNotifier
+func (r *SentinelNotifierReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	notifier := &secopsv1alpha1.SentinelNotifier{}
+	if err := r.Get(ctx, req.NamespacedName, notifier); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get SentinelNotifier")
+		return ctrl.Result{}, err
+	}
+
+	condition := metav1.Condition{
+		Type:               notify.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Valid",
+		Message:            "The channel is configured",
+		ObservedGeneration: notifier.Generation,
+	}
+	result := ctrl.Result{}
+	if _, err := notify.ChannelFor(ctx, r.Client, r.Namespace, notifier); err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Invalid"
+		condition.Message = err.Error()
+		result.RequeueAfter = notifierRecheckInterval
+	} else if notifier.Spec.NamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(notifier.Spec.NamespaceSelector); err != nil {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "Invalid"
+			condition.Message = err.Error()
+		}
+	}
+
+	if current := meta.FindStatusCondition(notifier.Status.Conditions, notify.ConditionReady); current != nil &&
+		current.Status == condition.Status && current.Message == condition.Message &&
+		current.ObservedGeneration == condition.ObservedGeneration {
+		return result, nil
+	}
+	meta.SetStatusCondition(&notifier.Status.Conditions, condition)
+	if err := r.Status().Update(ctx, notifier); err != nil {
+		log.Error(err, "Failed to update SentinelNotifier status")
+		return ctrl.Result{}, err
+	}
+	return result, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SentinelNotifierReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// The dispatcher updates the delivery counters of the status
+		For(&secopsv1alpha1.SentinelNotifier{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/notify"
+)
+
+var _ = Describe("SentinelNotifier controller", func() {
+	const namespace = "notifier-system"
+	ctx := context.Background()
+	var reconciler *SentinelNotifierReconciler
+
+	BeforeEach(func() {
+		reconciler = &SentinelNotifierReconciler{Client: k8sClient, Namespace: namespace}
+	})
+
+	// reconcile runs one pass and returns the Ready condition of the notifier
+	reconcile := func(name string) (ctrl.Result, *metav1.Condition) {
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+		Expect(err).NotTo(HaveOccurred())
+		notifier := &secopsv1alpha1.SentinelNotifier{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name}, notifier)).To(Succeed())
+		condition := meta.FindStatusCondition(notifier.Status.Conditions, notify.ConditionReady)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.ObservedGeneration).To(Equal(notifier.Generation))
+		return result, condition
+	}
+
+	It("is Ready once the secret of the channel exists", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &secopsv1alpha1.SentinelNotifier{
+			ObjectMeta: metav1.ObjectMeta{Name: "slack"},
+			Spec: secopsv1alpha1.SentinelNotifierSpec{Slack: &secopsv1alpha1.SlackChannel{
+				URLSecretRef: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "slack-webhook"}, Key: "url"}}},
+		})).To(Succeed())
+
+		// The secrets are not watched, an invalid notifier is checked again
+		result, condition := reconcile("slack")
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("Invalid"))
+		Expect(result.RequeueAfter).To(Equal(notifierRecheckInterval))
+
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "slack-webhook", Namespace: namespace},
+			Data:       map[string][]byte{"url": []byte("https://hooks.slack.com/services/T0/B0/x")},
+		})).To(Succeed())
+		result, condition = reconcile("slack")
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(result.RequeueAfter).To(BeZero())
+
+		// An unchanged condition is not written again
+		notifier := &secopsv1alpha1.SentinelNotifier{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "slack"}, notifier)).To(Succeed())
+		reconcile("slack")
+		unchanged := &secopsv1alpha1.SentinelNotifier{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "slack"}, unchanged)).To(Succeed())
+		Expect(unchanged.ResourceVersion).To(Equal(notifier.ResourceVersion))
+	})
+
+	It("is not Ready with more than one channel", func() {
+		Expect(k8sClient.Create(ctx, &secopsv1alpha1.SentinelNotifier{
+			ObjectMeta: metav1.ObjectMeta{Name: "two-channels"},
+			Spec: secopsv1alpha1.SentinelNotifierSpec{
+				Webhook: &secopsv1alpha1.WebhookChannel{URL: "https://hooks.example.com/sentinel"},
+				SMTP:    &secopsv1alpha1.SMTPChannel{Host: "mail.example.com", From: "sentinel@example.com", To: []string{"secops@example.com"}},
+			},
+		})).To(Succeed())
+
+		_, condition := reconcile("two-channels")
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Message).To(ContainSubstring("Exactly one"))
+	})
+
+	It("is not Ready with an invalid namespace selector", func() {
+		Expect(k8sClient.Create(ctx, &secopsv1alpha1.SentinelNotifier{
+			ObjectMeta: metav1.ObjectMeta{Name: "bad-selector"},
+			Spec: secopsv1alpha1.SentinelNotifierSpec{
+				Webhook: &secopsv1alpha1.WebhookChannel{URL: "https://hooks.example.com/sentinel"},
+				NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "env", Operator: "Near", Values: []string{"prod"}}}},
+			},
+		})).To(Succeed())
+
+		result, condition := reconcile("bad-selector")
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("Invalid"))
+		// Only the secrets can change without a new generation
+		Expect(result.RequeueAfter).To(BeZero())
+	})
+})
@@ -0,0 +1,351 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+	"github.com/kavinduxo/sentinel-operator/internal/backup"
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+// SentinelRestoreReconciler re-creates the Sentinels of a backup signed with the signing key.
+// The objects a Sentinel owned are created before the Sentinel, so that the Sentinel controller
+// finds its secret and does not generate new values, and are adopted by the new Sentinel
+// afterwards. Only the objects the Sentinel controller would create for the Sentinel are
+// restored, the backup can not add other objects or permissions to the cluster.
+type SentinelRestoreReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Audit is optional and records the restores
+	Audit *audit.Logger
+	// Namespace holds the secrets the SentinelRestores reference, the namespace of the operator
+	Namespace string
+	// VolumeRoot is the directory the backup volumes are mounted below, volume locations
+	// are disabled when empty
+	VolumeRoot string
+}
+
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelrestores,verbs=get;list;watch
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelrestores/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=update
+
+// Reconcile runs a SentinelRestore once
+func (r *SentinelRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	restore := &secopsv1alpha1.SentinelRestore{}
+	if err := r.Get(ctx, req.NamespacedName, restore); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get SentinelRestore")
+		return ctrl.Result{}, err
+	}
+	if restore.Status.Phase != "" {
+		return ctrl.Result{}, nil
+	}
+
+	err := r.restoreBackup(restore, ctx)
+	now := metav1.Now()
+	restore.Status.CompletionTime = &now
+	if err != nil {
+		log.Error(err, "Restore Failed.", "SentinelRestore.Name", restore.Name)
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "RestoreFailed", err.Error())
+		restore.Status.Phase = secopsv1alpha1.RestorePhaseFailed
+		restore.Status.Message = err.Error()
+	} else {
+		restore.Status.Phase = secopsv1alpha1.RestorePhaseCompleted
+		restore.Status.Message = fmt.Sprintf("Restored %d and skipped %d Sentinels of backup %s",
+			len(restore.Status.Restored), len(restore.Status.Skipped), restore.Status.Backup)
+		r.Recorder.Event(restore, corev1.EventTypeNormal, "RestoreCompleted", restore.Status.Message)
+	}
+	if restore.Status.Backup != "" {
+		auditLog(r.Audit, ctx, audit.Record{
+			Actor:  audit.ActorOperator,
+			Action: audit.ActionRestore,
+			Object: auditObject(restore, "SentinelRestore"),
+			Details: map[string]string{
+				"backup":   restore.Status.Backup,
+				"phase":    restore.Status.Phase,
+				"restored": strings.Join(restore.Status.Restored, ","),
+			},
+		})
+	}
+	if err := r.Status().Update(ctx, restore); err != nil {
+		log.Error(err, "Failed to update SentinelRestore status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// restoreBackup opens the backup and restores its Sentinels, the progress is kept in the
+// status. The Sentinels that fail do not stop the others.
+func (r *SentinelRestoreReconciler) restoreBackup(restore *secopsv1alpha1.SentinelRestore, ctx context.Context) error {
+	wrapper, err := r.restoreKeyWrapper(ctx, &restore.Spec.Decryption)
+	if err != nil {
+		return err
+	}
+	signingKey, err := readSecretKey(ctx, r.Client, r.Namespace, &restore.Spec.SigningKeySecretRef)
+	if err != nil {
+		return err
+	}
+	store, err := storeForLocation(ctx, r.Client, r.Namespace, r.VolumeRoot, &restore.Spec.Location)
+	if err != nil {
+		return err
+	}
+	names := []string{restore.Spec.Backup}
+	if restore.Spec.Backup == "" {
+		listed, err := store.List(ctx)
+		if err != nil {
+			return err
+		}
+		if names = backup.Newest(listed); len(names) == 0 {
+			return fmt.Errorf("No backup found in the location")
+		}
+	}
+
+	// Without a name the newest backup that verifies is restored, planted files are skipped
+	var bundle *backup.Bundle
+	for _, name := range names {
+		data, err := store.Get(ctx, name)
+		if err != nil {
+			return err
+		}
+		bundle, err = backup.Open(ctx, wrapper, signingKey, name, data)
+		if errors.Is(err, backup.ErrUnverified) && restore.Spec.Backup == "" {
+			r.Recorder.Event(restore, corev1.EventTypeWarning, "UnverifiedBackup",
+				fmt.Sprintf("Skipped backup %s, it is not signed with the signing key", name))
+			continue
+		}
+		restore.Status.Backup = name
+		if err != nil {
+			return fmt.Errorf("Failed to open backup %s: %w", name, err)
+		}
+		break
+	}
+	if bundle == nil {
+		return fmt.Errorf("No backup in the location is signed with the signing key")
+	}
+
+	namespaces := map[string]bool{}
+	for _, namespace := range restore.Spec.Namespaces {
+		namespaces[namespace] = true
+	}
+	overwrite := restore.Spec.ExistingPolicy == secopsv1alpha1.RestoreExistingOverwrite
+	var errs []error
+	for i := range bundle.Entries {
+		entry := &bundle.Entries[i]
+		key := entry.Sentinel.Namespace + "/" + entry.Sentinel.Name
+		if len(namespaces) > 0 && !namespaces[entry.Sentinel.Namespace] {
+			continue
+		}
+		restored, err := r.restoreEntry(entry, ctx, overwrite)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("Sentinel %s: %w", key, err))
+		case restored:
+			restore.Status.Restored = append(restore.Status.Restored, key)
+		default:
+			restore.Status.Skipped = append(restore.Status.Skipped, key)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// restoreEntry restores a Sentinel and the objects it owned. It reports false when the
+// Sentinel exists and is kept.
+func (r *SentinelRestoreReconciler) restoreEntry(entry *backup.Entry, ctx context.Context, overwrite bool) (bool, error) {
+	sentinel := &secopsv1alpha1.Sentinel{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(&entry.Sentinel), sentinel)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	exists := err == nil
+	if exists && !overwrite {
+		return false, nil
+	}
+
+	owned := restoredObjects(entry, ctx)
+	for i, obj := range owned {
+		if owned[i], err = r.restoreObject(ctx, obj, entry.Sentinel.Name, overwrite); err != nil {
+			return false, err
+		}
+	}
+
+	if !exists {
+		sentinel = &secopsv1alpha1.Sentinel{
+			ObjectMeta: exportedMeta(entry.Sentinel.ObjectMeta),
+			Spec:       *entry.Sentinel.Spec.DeepCopy(),
+		}
+		if err := r.Create(ctx, sentinel); err != nil {
+			return false, err
+		}
+	} else {
+		sentinel.Labels = entry.Sentinel.Labels
+		sentinel.Annotations = entry.Sentinel.Annotations
+		sentinel.Spec = entry.Sentinel.Spec
+		if err := r.Update(ctx, sentinel); err != nil {
+			return false, err
+		}
+	}
+
+	for _, obj := range owned {
+		if err := r.adopt(sentinel, ctx, obj); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// restoredObjects returns the objects of an entry the Sentinel controller would create for the
+// Sentinel: the Opaque secret of spec.secretName with the data of the backup, and the Role and
+// RoleBinding of the spec when the Sentinel owned them, built from the spec like the Sentinel
+// controller does. The ServiceAccount and its token secret are created by the Sentinel controller.
+// Everything else in the entry is ignored.
+func restoredObjects(entry *backup.Entry, ctx context.Context) []client.Object {
+	log := log.FromContext(ctx)
+	sentinel := &entry.Sentinel
+	belongs := func(kind string, objectMeta metav1.ObjectMeta, name string) bool {
+		if name != "" && objectMeta.Name == name && objectMeta.Namespace == sentinel.Namespace {
+			return true
+		}
+		log.Info("Skipping an object of the backup the Sentinel does not create",
+			"kind", kind, "namespace", objectMeta.Namespace, "name", objectMeta.Name, "Sentinel.Name", sentinel.Name)
+		return false
+	}
+
+	objects := []client.Object{}
+	for _, secret := range entry.Secrets {
+		if secret.Type != corev1.SecretTypeOpaque && secret.Type != "" {
+			// The token secret is issued again for the restored ServiceAccount
+			continue
+		}
+		if belongs("Secret", secret.ObjectMeta, sentinel.Spec.SecretName) {
+			objects = append(objects, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secret.Name, Namespace: secret.Namespace, Labels: secret.Labels},
+				Type:       corev1.SecretTypeOpaque,
+				Data:       secret.Data,
+			})
+		}
+	}
+	for _, role := range entry.Roles {
+		if belongs("Role", role.ObjectMeta, sentinel.Spec.Role) {
+			objects = append(objects, roleForSentinel(sentinel))
+		}
+	}
+	for _, roleBinding := range entry.RoleBindings {
+		kind := subjectKindForSentinel(sentinel)
+		if kind != rbacv1.UserKind && kind != rbacv1.ServiceAccountKind {
+			log.Info("Skipping the RoleBinding of a Sentinel without a valid usertype", "Sentinel.Name", sentinel.Name)
+			continue
+		}
+		if belongs("RoleBinding", roleBinding.ObjectMeta, sentinel.Spec.RoleBinding) {
+			objects = append(objects, roleBindingForSentinel(sentinel, kind))
+		}
+	}
+	return objects
+}
+
+// restoreObject creates an object of the backup. An existing object is replaced when
+// overwrite is set and it is not controlled by anything but a Sentinel of the name, it is
+// returned as it is otherwise.
+func (r *SentinelRestoreReconciler) restoreObject(
+	ctx context.Context, obj client.Object, sentinelName string, overwrite bool) (client.Object, error) {
+
+	existing := obj.DeepCopyObject().(client.Object)
+	err := r.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if apierrors.IsNotFound(err) {
+		return obj, r.Create(ctx, obj)
+	} else if err != nil {
+		return nil, err
+	}
+	controller := metav1.GetControllerOf(existing)
+	if !overwrite || (controller != nil && (controller.Kind != "Sentinel" || controller.Name != sentinelName)) {
+		return existing, nil
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	obj.SetOwnerReferences(existing.GetOwnerReferences())
+	return obj, r.Update(ctx, obj)
+}
+
+// adopt makes the Sentinel the controller of a restored object. References to an earlier
+// Sentinel of the same name are dropped, objects controlled by others are left alone.
+func (r *SentinelRestoreReconciler) adopt(sentinel *secopsv1alpha1.Sentinel, ctx context.Context, obj client.Object) error {
+	references := []metav1.OwnerReference{}
+	for _, reference := range obj.GetOwnerReferences() {
+		if reference.Kind == "Sentinel" && reference.Name == sentinel.Name && reference.UID != sentinel.UID {
+			continue
+		}
+		references = append(references, reference)
+	}
+	obj.SetOwnerReferences(references)
+	if controller := metav1.GetControllerOf(obj); controller != nil {
+		if controller.UID != sentinel.UID {
+			log.FromContext(ctx).Info("Restored object is controlled by another owner",
+				"object", obj.GetName(), "owner", controller.Name)
+		}
+		return nil
+	}
+	if err := controllerutil.SetControllerReference(sentinel, obj, r.Scheme); err != nil {
+		return err
+	}
+	return r.Update(ctx, obj)
+}
+
+// restoreKeyWrapper builds the wrapper the backup is opened with
+func (r *SentinelRestoreReconciler) restoreKeyWrapper(
+	ctx context.Context, decryption *secopsv1alpha1.BackupDecryption) (envelope.KeyWrapper, error) {
+
+	switch {
+	case decryption.IdentitySecretRef != nil && decryption.VaultTransit != nil:
+		return nil, fmt.Errorf("Decryption sets both identitySecretRef and vaultTransit")
+	case decryption.IdentitySecretRef != nil:
+		key, err := readSecretKey(ctx, r.Client, r.Namespace, decryption.IdentitySecretRef)
+		if err != nil {
+			return nil, err
+		}
+		return envelope.NewX25519Identity(key)
+	case decryption.VaultTransit != nil:
+		return vaultTransitWrapper(ctx, r.Client, r.Namespace, decryption.VaultTransit)
+	}
+	return nil, fmt.Errorf("Decryption sets neither identitySecretRef nor vaultTransit")
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SentinelRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&secopsv1alpha1.SentinelRestore{}).
+		Complete(r)
+}
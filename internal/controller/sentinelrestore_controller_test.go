@@ -0,0 +1,175 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/backup"
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+var _ = Describe("SentinelRestore controller", Ordered, func() {
+	const operatorNamespace = "restore-system"
+	ctx := context.Background()
+	signingKey := []byte("0123456789abcdef0123456789abcdef")
+	location := secopsv1alpha1.BackupLocation{Volume: &secopsv1alpha1.VolumeLocation{ClaimName: "backups", Path: "prod"}}
+	var reconciler *SentinelRestoreReconciler
+	var dir, signed, planted string
+
+	// The backup holds a Sentinel whose entry was widened: its Role grants everything, its
+	// RoleBinding binds cluster-admin and a secret of another namespace was added
+	BeforeAll(func() {
+		root, err := os.MkdirTemp("", "sentinel-restore")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, root)
+		Expect(os.Mkdir(filepath.Join(root, "backups"), 0o700)).To(Succeed())
+		dir, err = backup.VolumeDir(root, "backups", "prod")
+		Expect(err).NotTo(HaveOccurred())
+
+		private, public, err := envelope.GenerateX25519()
+		Expect(err).NotTo(HaveOccurred())
+		recipient, err := envelope.NewX25519Recipient([]byte(public))
+		Expect(err).NotTo(HaveOccurred())
+		bundle := &backup.Bundle{Version: backup.Version, Entries: []backup.Entry{{
+			Sentinel: secopsv1alpha1.Sentinel{
+				ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "restore",
+					Labels: map[string]string{"usertype": rbacv1.ServiceAccountKind}},
+				Spec: secopsv1alpha1.SentinelSpec{
+					SecretName: "orders-db", SecretType: typeSecretBaseRbac,
+					ServiceAccount: "orders-api", Role: "orders-reader", RoleBinding: "orders-reader",
+				},
+			},
+			Secrets: []corev1.Secret{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "orders-db", Namespace: "restore"},
+					Type:       corev1.SecretTypeOpaque,
+					Data:       map[string][]byte{"password": []byte("s3cr3t")},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "planted", Namespace: "default"},
+					Data:       map[string][]byte{"token": []byte("x")},
+				},
+			},
+			Roles: []rbacv1.Role{{
+				ObjectMeta: metav1.ObjectMeta{Name: "orders-reader", Namespace: "restore"},
+				Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+			}},
+			RoleBindings: []rbacv1.RoleBinding{{
+				ObjectMeta: metav1.ObjectMeta{Name: "orders-reader", Namespace: "restore"},
+				Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "orders-api", Namespace: "restore"}},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "cluster-admin"},
+			}},
+		}}}
+		signed = backup.Name("nightly", time.Now())
+		sealed, err := backup.Seal(ctx, recipient, signingKey, signed, bundle)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dir, signed), sealed, 0o600)).To(Succeed())
+		// A newer file that is not signed with the key
+		planted = backup.Name("nightly", time.Now().Add(time.Hour))
+		Expect(os.WriteFile(filepath.Join(dir, planted), []byte(`{"name":"`+planted+`"}`), 0o600)).To(Succeed())
+
+		for _, name := range []string{operatorNamespace, "restore"} {
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})).To(Succeed())
+		}
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "backup-identity", Namespace: operatorNamespace},
+			Data:       map[string][]byte{"key": []byte(private)},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "backup-signing", Namespace: operatorNamespace},
+			Data:       map[string][]byte{"key": signingKey},
+		})).To(Succeed())
+
+		reconciler = &SentinelRestoreReconciler{
+			Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: record.NewFakeRecorder(100),
+			Namespace: operatorNamespace, VolumeRoot: root,
+		}
+	})
+
+	// restore creates a SentinelRestore, runs it and returns its status
+	restore := func(name string, backupName string) secopsv1alpha1.SentinelRestoreStatus {
+		sentinelRestore := &secopsv1alpha1.SentinelRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: secopsv1alpha1.SentinelRestoreSpec{
+				Location: location,
+				Backup:   backupName,
+				Decryption: secopsv1alpha1.BackupDecryption{IdentitySecretRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "backup-identity"}, Key: "key"}},
+				SigningKeySecretRef: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "backup-signing"}, Key: "key"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, sentinelRestore)).To(Succeed())
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name}, sentinelRestore)).To(Succeed())
+		return sentinelRestore.Status
+	}
+
+	It("restores the newest signed backup and only the objects the Sentinel creates", func() {
+		status := restore("latest", "")
+		Expect(status.Phase).To(Equal(secopsv1alpha1.RestorePhaseCompleted), status.Message)
+		Expect(status.Backup).To(Equal(signed))
+		Expect(status.Restored).To(ConsistOf("restore/orders"))
+
+		sentinel := &secopsv1alpha1.Sentinel{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "orders", Namespace: "restore"}, sentinel)).To(Succeed())
+		secret := &corev1.Secret{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "orders-db", Namespace: "restore"}, secret)).To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue("password", []byte("s3cr3t")))
+		Expect(metav1.IsControlledBy(secret, sentinel)).To(BeTrue())
+
+		role := &rbacv1.Role{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "orders-reader", Namespace: "restore"}, role)).To(Succeed())
+		Expect(role.Rules).To(Equal(roleForSentinel(sentinel).Rules))
+		Expect(metav1.IsControlledBy(role, sentinel)).To(BeTrue())
+		roleBinding := &rbacv1.RoleBinding{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "orders-reader", Namespace: "restore"}, roleBinding)).To(Succeed())
+		Expect(roleBinding.RoleRef).To(Equal(rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "orders-reader"}))
+
+		err := k8sClient.Get(ctx, types.NamespacedName{Name: "planted", Namespace: "default"}, &corev1.Secret{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("skips the Sentinels that exist", func() {
+		status := restore("again", signed)
+		Expect(status.Phase).To(Equal(secopsv1alpha1.RestorePhaseCompleted), status.Message)
+		Expect(status.Restored).To(BeEmpty())
+		Expect(status.Skipped).To(ConsistOf("restore/orders"))
+	})
+
+	It("refuses a named backup that is not signed", func() {
+		status := restore("planted", planted)
+		Expect(status.Phase).To(Equal(secopsv1alpha1.RestorePhaseFailed))
+		Expect(status.Message).To(ContainSubstring("signature does not verify"))
+	})
+})
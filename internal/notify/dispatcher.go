@@ -0,0 +1,384 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// Defaults of the delivery of a SentinelNotifier
+const (
+	defaultMaxAttempts         = 5
+	defaultInitialBackoff      = 5 * time.Second
+	defaultMaxBackoff          = 5 * time.Minute
+	defaultDeduplicationWindow = 24 * time.Hour
+	queueSize                  = 256
+)
+
+// ConditionReady is set on a SentinelNotifier when its channel configuration is valid
+const ConditionReady = "Ready"
+
+// Dispatcher routes the notifications to the matching SentinelNotifiers. Notify only queues
+// the notification, the deliveries including their retries run in the background, so that
+// a slow channel never holds up a reconciliation. It is added to the manager as a Runnable.
+type Dispatcher struct {
+	Client client.Client
+	// Namespace holds the secrets the SentinelNotifiers reference, the namespace of the operator
+	Namespace string
+	// Default receives every notification in addition to the SentinelNotifiers
+	Default Notifier
+
+	queue chan Notification
+	mu    sync.Mutex
+	// sent maps the deduplication keys of the sent notifications to the end of their window
+	sent map[string]time.Time
+}
+
+// NewDispatcher returns a Dispatcher reading the SentinelNotifiers and their secrets with the client
+func NewDispatcher(c client.Client, namespace string, defaultNotifier Notifier) *Dispatcher {
+	return &Dispatcher{
+		Client:    c,
+		Namespace: namespace,
+		Default:   defaultNotifier,
+		queue:     make(chan Notification, queueSize),
+		sent:      map[string]time.Time{},
+	}
+}
+
+// Notify implements Notifier, it fails when the queue is full
+func (d *Dispatcher) Notify(ctx context.Context, n Notification) error {
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	select {
+	case d.queue <- n:
+		return nil
+	default:
+		return fmt.Errorf("Notification queue is full, dropped %s notification", n.Type)
+	}
+}
+
+// Start implements manager.Runnable
+func (d *Dispatcher) Start(ctx context.Context) error {
+	var deliveries sync.WaitGroup
+	defer deliveries.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-d.queue:
+			d.dispatch(ctx, n, &deliveries)
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, the notifications are
+// queued by whichever replica observed the event
+func (d *Dispatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// dispatch starts a delivery for every SentinelNotifier the notification is routed to
+func (d *Dispatcher) dispatch(ctx context.Context, n Notification, deliveries *sync.WaitGroup) {
+	log := log.FromContext(ctx).WithName("notify")
+
+	if d.Default != nil {
+		key := "default/" + deduplicationKey(n)
+		if d.claim(key, n.Time, defaultDeduplicationWindow) {
+			deliveries.Add(1)
+			go func() {
+				defer deliveries.Done()
+				if err := deliver(ctx, d.Default, n, nil); err != nil {
+					d.release(key)
+					log.Error(err, "Notification Failed.", "type", n.Type)
+				}
+			}()
+		}
+	}
+
+	notifiers := &secopsv1alpha1.SentinelNotifierList{}
+	if err := d.Client.List(ctx, notifiers); err != nil {
+		log.Error(err, "Failed to list SentinelNotifiers")
+		return
+	}
+
+	var namespaceLabels labels.Set
+	if n.Namespace != "" {
+		namespace := &corev1.Namespace{}
+		if err := d.Client.Get(ctx, types.NamespacedName{Name: n.Namespace}, namespace); err != nil {
+			log.Error(err, "Failed to get namespace", "namespace", n.Namespace)
+			return
+		}
+		namespaceLabels = namespace.Labels
+	}
+
+	for i := range notifiers.Items {
+		notifier := &notifiers.Items[i]
+		if notifier.DeletionTimestamp != nil ||
+			meta.IsStatusConditionFalse(notifier.Status.Conditions, ConditionReady) {
+			continue
+		}
+		if ok, err := Routes(notifier, n, namespaceLabels); err != nil {
+			log.Error(err, "Invalid namespace selector", "SentinelNotifier", notifier.Name)
+			continue
+		} else if !ok {
+			continue
+		}
+
+		window := defaultDeduplicationWindow
+		if notifier.Spec.DeduplicationWindow != nil {
+			window = notifier.Spec.DeduplicationWindow.Duration
+		}
+		key := notifier.Name + "/" + deduplicationKey(n)
+		if !d.claim(key, n.Time, window) {
+			continue
+		}
+
+		channel, err := ChannelFor(ctx, d.Client, d.Namespace, notifier)
+		if err != nil {
+			d.release(key)
+			d.record(ctx, notifier.Name, err)
+			log.Error(err, "Notification Failed.", "SentinelNotifier", notifier.Name, "type", n.Type)
+			continue
+		}
+
+		name, retryPolicy := notifier.Name, notifier.Spec.Retry
+		deliveries.Add(1)
+		go func() {
+			defer deliveries.Done()
+			err := deliver(ctx, channel, n, retryPolicy)
+			if err != nil {
+				d.release(key)
+				log.Error(err, "Notification Failed.", "SentinelNotifier", name, "type", n.Type)
+			}
+			if ctx.Err() == nil {
+				d.record(ctx, name, err)
+			}
+		}()
+	}
+}
+
+// Routes reports whether a notification about an object in a namespace with the labels
+// is sent to the SentinelNotifier
+func Routes(notifier *secopsv1alpha1.SentinelNotifier, n Notification, namespaceLabels labels.Set) (bool, error) {
+	if len(notifier.Spec.Events) > 0 {
+		found := false
+		for _, event := range notifier.Spec.Events {
+			if string(event) == n.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	if notifier.Spec.NamespaceSelector == nil {
+		return true, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(notifier.Spec.NamespaceSelector)
+	if err != nil {
+		return false, err
+	}
+	if selector.Empty() {
+		return true, nil
+	}
+	// Events about cluster scoped objects only reach the notifiers of all namespaces
+	return n.Namespace != "" && selector.Matches(namespaceLabels), nil
+}
+
+// ChannelFor builds the Notifier of the channel of a SentinelNotifier, the referenced
+// secrets are read from the namespace of the operator
+func ChannelFor(ctx context.Context, c client.Reader, namespace string, notifier *secopsv1alpha1.SentinelNotifier) (Notifier, error) {
+	spec := notifier.Spec
+	channels := 0
+	for _, set := range []bool{spec.Webhook != nil, spec.Slack != nil, spec.SMTP != nil} {
+		if set {
+			channels++
+		}
+	}
+	if channels != 1 {
+		return nil, fmt.Errorf("Exactly one of webhook, slack and smtp has to be set, found %d", channels)
+	}
+
+	switch {
+	case spec.Webhook != nil:
+		webhook := NewWebhookNotifier(spec.Webhook.URL)
+		if spec.Webhook.HMACSecretRef != nil {
+			secret, err := secretKeyValue(ctx, c, namespace, spec.Webhook.HMACSecretRef)
+			if err != nil {
+				return nil, err
+			}
+			webhook.Secret = secret
+		}
+		return webhook, nil
+	case spec.Slack != nil:
+		url, err := secretKeyValue(ctx, c, namespace, &spec.Slack.URLSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		return NewSlackNotifier(string(url)), nil
+	default:
+		smtpSpec := spec.SMTP
+		mailer := &SMTPNotifier{
+			Host:     smtpSpec.Host,
+			Port:     int(smtpSpec.Port),
+			From:     smtpSpec.From,
+			To:       smtpSpec.To,
+			Username: smtpSpec.Username,
+			Insecure: smtpSpec.Insecure,
+		}
+		if mailer.Port == 0 {
+			mailer.Port = 587
+		}
+		if smtpSpec.PasswordSecretRef != nil {
+			password, err := secretKeyValue(ctx, c, namespace, smtpSpec.PasswordSecretRef)
+			if err != nil {
+				return nil, err
+			}
+			mailer.Password = string(password)
+		}
+		return mailer, nil
+	}
+}
+
+// secretKeyValue reads one key of a secret in the namespace
+func secretKeyValue(ctx context.Context, c client.Reader, namespace string, selector *corev1.SecretKeySelector) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: selector.Name, Namespace: namespace}, secret); err != nil {
+		return nil, err
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok || len(value) == 0 {
+		return nil, fmt.Errorf("Secret %s/%s has no key %s", namespace, selector.Name, selector.Key)
+	}
+	return value, nil
+}
+
+// deliver sends the notification, retrying with an exponential backoff
+func deliver(ctx context.Context, notifier Notifier, n Notification, policy *secopsv1alpha1.NotifierRetry) error {
+	attempts, backoff, maxBackoff := int32(defaultMaxAttempts), defaultInitialBackoff, defaultMaxBackoff
+	if policy != nil {
+		if policy.MaxAttempts > 0 {
+			attempts = policy.MaxAttempts
+		}
+		if policy.InitialBackoff != nil {
+			backoff = policy.InitialBackoff.Duration
+		}
+		if policy.MaxBackoff != nil {
+			maxBackoff = policy.MaxBackoff.Duration
+		}
+	}
+
+	var err error
+	for attempt := int32(1); ; attempt++ {
+		if err = notifier.Notify(ctx, n); err == nil || attempt >= attempts {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// claim reserves the deduplication key, it reports false when the key was sent within the window
+func (d *Dispatcher) claim(key string, now time.Time, window time.Duration) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for k, expires := range d.sent {
+		if !now.Before(expires) {
+			delete(d.sent, k)
+		}
+	}
+	if _, ok := d.sent[key]; ok {
+		return false
+	}
+	d.sent[key] = now.Add(window)
+	return true
+}
+
+// release forgets a key whose delivery failed, so that the next occurrence is sent again
+func (d *Dispatcher) release(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.sent, key)
+}
+
+// record counts the delivery in the status of the SentinelNotifier
+func (d *Dispatcher) record(ctx context.Context, name string, deliveryErr error) {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		notifier := &secopsv1alpha1.SentinelNotifier{}
+		if err := d.Client.Get(ctx, types.NamespacedName{Name: name}, notifier); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if deliveryErr != nil {
+			notifier.Status.Failed++
+			notifier.Status.LastError = deliveryErr.Error()
+		} else {
+			now := metav1.Now()
+			notifier.Status.Delivered++
+			notifier.Status.LastDeliveryTime = &now
+		}
+		return d.Client.Status().Update(ctx, notifier)
+	})
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update SentinelNotifier status", "SentinelNotifier", name)
+	}
+}
+
+// deduplicationKey identifies notifications with the same content
+func deduplicationKey(n Notification) string {
+	hash := sha256.New()
+	for _, part := range []string{n.Type, n.Namespace, n.Sentinel, n.Message} {
+		fmt.Fprintf(hash, "%d:%s", len(part), part)
+	}
+	keys := make([]string, 0, len(n.Details))
+	for key := range n.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%d:%s%d:%s", len(key), key, len(n.Details[key]), n.Details[key])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package notify sends security relevant Sentinel events to systems outside of the cluster.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of notifications, the values of the events of a SentinelNotifier
+const (
+	TypeBreakGlass          = "BreakGlass"
+	TypeRBACFailure         = "RBACFailure"
+	TypeDriftCorrected      = "DriftCorrected"
+	TypeCertificateExpiring = "CertificateExpiring"
+	TypeRotationFailed      = "RotationFailed"
+)
+
+// Headers of the signed webhook requests
+const (
+	SignatureHeader = "X-Sentinel-Signature"
+	TimestampHeader = "X-Sentinel-Timestamp"
+	EventHeader     = "X-Sentinel-Event"
+)
+
+// Notification is the payload that is sent for a security relevant event. Sentinel is
+// empty for events about other objects, the Details name them.
+type Notification struct {
+	Type      string            `json:"type"`
+	Sentinel  string            `json:"sentinel"`
+	Namespace string            `json:"namespace"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+	Time      time.Time         `json:"time"`
+}
+
+// Notifier delivers notifications
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Text renders the notification for humans, the details are sorted by key
+func (n Notification) Text() string {
+	var b strings.Builder
+	b.WriteString(n.Message)
+	keys := make([]string, 0, len(n.Details))
+	for key := range n.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "\n%s: %s", key, n.Details[key])
+	}
+	return b.String()
+}
+
+// Subject is the one line summary of the notification
+func (n Notification) Subject() string {
+	if n.Sentinel == "" {
+		if n.Namespace == "" {
+			return fmt.Sprintf("[Sentinel] %s", n.Type)
+		}
+		return fmt.Sprintf("[Sentinel] %s in %s", n.Type, n.Namespace)
+	}
+	return fmt.Sprintf("[Sentinel] %s %s/%s", n.Type, n.Namespace, n.Sentinel)
+}
+
+// WebhookNotifier posts notifications as JSON to a HTTP endpoint
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+	// Secret signs the payloads when set, see Sign
+	Secret []byte
+}
+
+// NewWebhookNotifier returns a WebhookNotifier with a bounded request timeout
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify implements Notifier
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, n.Type)
+	if len(w.Secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(w.Secret, timestamp, body))
+	}
+
+	return post(w.Client, req, "notification webhook "+w.URL)
+}
+
+// Sign returns the signature header value of a webhook payload, the HMAC-SHA256 of the
+// timestamp and the body joined by a dot. Receivers reject old timestamps to stop replays.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// post sends the request and fails on a status outside of 2xx
+func post(client *http.Client, req *http.Request, target string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", target, resp.Status)
+	}
+	return nil
+}
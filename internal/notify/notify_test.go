@@ -0,0 +1,186 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	secret := []byte("hmac-key")
+	received := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(SignatureHeader), Sign(secret, r.Header.Get(TimestampHeader), body); got != want {
+			received <- errors.New("signature " + got + " does not match " + want)
+		} else if r.Header.Get(EventHeader) != TypeDriftCorrected {
+			received <- errors.New("unexpected event header " + r.Header.Get(EventHeader))
+		} else {
+			received <- nil
+		}
+	}))
+	defer server.Close()
+
+	webhook := NewWebhookNotifier(server.URL)
+	webhook.Secret = secret
+	if err := webhook.Notify(context.Background(), Notification{Type: TypeDriftCorrected, Sentinel: "db", Namespace: "prod"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-received; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	notifier := &secopsv1alpha1.SentinelNotifier{Spec: secopsv1alpha1.SentinelNotifierSpec{
+		Events:            []secopsv1alpha1.NotificationEvent{TypeBreakGlass, TypeRBACFailure},
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+	}}
+	prod := labels.Set{"env": "prod"}
+
+	for _, tc := range []struct {
+		name   string
+		n      Notification
+		labels labels.Set
+		want   bool
+	}{
+		{"matching", Notification{Type: TypeBreakGlass, Namespace: "prod"}, prod, true},
+		{"other event", Notification{Type: TypeRotationFailed, Namespace: "prod"}, prod, false},
+		{"other namespace", Notification{Type: TypeRBACFailure, Namespace: "dev"}, labels.Set{"env": "dev"}, false},
+		{"cluster scoped", Notification{Type: TypeRBACFailure}, nil, false},
+	} {
+		if got, err := Routes(notifier, tc.n, tc.labels); err != nil || got != tc.want {
+			t.Errorf("%s: Routes() = %v, %v, want %v", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+// recorder counts the notifications and fails the first Failures deliveries
+type recorder struct {
+	mu       sync.Mutex
+	Failures int
+	Calls    int
+	Sent     []Notification
+}
+
+func (r *recorder) Notify(ctx context.Context, n Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Calls++
+	if r.Calls <= r.Failures {
+		return errors.New("unavailable")
+	}
+	r.Sent = append(r.Sent, n)
+	return nil
+}
+
+func TestDeliverRetries(t *testing.T) {
+	policy := &secopsv1alpha1.NotifierRetry{
+		MaxAttempts:    3,
+		InitialBackoff: &metav1.Duration{Duration: time.Millisecond},
+	}
+
+	flaky := &recorder{Failures: 2}
+	if err := deliver(context.Background(), flaky, Notification{Type: TypeBreakGlass}, policy); err != nil {
+		t.Fatalf("deliver failed after %d calls: %v", flaky.Calls, err)
+	}
+
+	down := &recorder{Failures: 10}
+	if err := deliver(context.Background(), down, Notification{Type: TypeBreakGlass}, policy); err == nil {
+		t.Fatal("deliver succeeded with an unavailable notifier")
+	}
+	if down.Calls != 3 {
+		t.Errorf("deliver made %d attempts, want 3", down.Calls)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = secopsv1alpha1.AddToScheme(scheme)
+
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithStatusSubresource(&secopsv1alpha1.SentinelNotifier{}).
+		WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"env": "prod"}}},
+			&secopsv1alpha1.SentinelNotifier{
+				ObjectMeta: metav1.ObjectMeta{Name: "siem"},
+				Spec: secopsv1alpha1.SentinelNotifierSpec{
+					Events:  []secopsv1alpha1.NotificationEvent{TypeRBACFailure},
+					Webhook: &secopsv1alpha1.WebhookChannel{URL: server.URL},
+				},
+			},
+		).Build()
+
+	fallback := &recorder{}
+	d := NewDispatcher(c, "sentinel-operator-system", fallback)
+	ctx := context.Background()
+	var deliveries sync.WaitGroup
+	now := time.Now()
+	for _, n := range []Notification{
+		{Type: TypeRBACFailure, Namespace: "prod", Sentinel: "db", Message: "Role Not Found", Time: now},
+		// A repeat within the deduplication window
+		{Type: TypeRBACFailure, Namespace: "prod", Sentinel: "db", Message: "Role Not Found", Time: now.Add(time.Minute)},
+		// Not routed to the SentinelNotifier
+		{Type: TypeRotationFailed, Namespace: "prod", Sentinel: "db", Message: "Rotation failed", Time: now},
+	} {
+		d.dispatch(ctx, n, &deliveries)
+	}
+	deliveries.Wait()
+
+	if len(bodies) != 1 {
+		t.Errorf("webhook received %d notifications, want 1", len(bodies))
+	}
+	if len(fallback.Sent) != 2 {
+		t.Errorf("default notifier received %d notifications, want 2", len(fallback.Sent))
+	}
+
+	notifier := &secopsv1alpha1.SentinelNotifier{}
+	if err := c.Get(ctx, client.ObjectKey{Name: "siem"}, notifier); err != nil {
+		t.Fatal(err)
+	}
+	if notifier.Status.Delivered != 1 || notifier.Status.LastDeliveryTime == nil {
+		t.Errorf("status = %+v, want one delivery", notifier.Status)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+)
+
+// Variables of the rule expressions, named after those of the ValidatingAdmissionPolicies
+const (
+	objectVariable          = "object"
+	namespaceObjectVariable = "namespaceObject"
+	requestVariable         = "request"
+)
+
+// Limits of the rule expressions. They are evaluated in the admission webhook, so a
+// SentinelPolicy author must not be able to stall it or grow the memory of the operator.
+const (
+	// celCostLimit is the runtime cost an evaluation may reach, the limit of a
+	// ValidatingAdmissionPolicy expression in the apiserver
+	celCostLimit = 1000000
+	// celEvalTimeout bounds the wall time of an evaluation
+	celEvalTimeout = 100 * time.Millisecond
+	// celInterruptCheckFrequency is the number of comprehension iterations between the
+	// checks of the timeout
+	celInterruptCheckFrequency = 100
+	// maxPrograms is the number of compiled expressions that are cached, the least
+	// recently used one is evicted when a new expression is compiled
+	maxPrograms = 256
+)
+
+// cachedProgram is an entry of the program cache
+type cachedProgram struct {
+	expression string
+	program    cel.Program
+}
+
+// programCache compiles every rule expression once and keeps the recently used ones
+type programCache struct {
+	mu       sync.Mutex
+	env      *cel.Env
+	programs map[string]*list.Element
+	// recent orders the entries from the most to the least recently used
+	recent *list.List
+}
+
+func newProgramCache() (*programCache, error) {
+	env, err := cel.NewEnv(
+		cel.Variable(objectVariable, cel.DynType),
+		cel.Variable(namespaceObjectVariable, cel.DynType),
+		cel.Variable(requestVariable, cel.DynType),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &programCache{env: env, programs: map[string]*list.Element{}, recent: list.New()}, nil
+}
+
+// program returns the compiled program of the expression
+func (c *programCache) program(expression string) (cel.Program, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.programs[expression]; ok {
+		c.recent.MoveToFront(element)
+		return element.Value.(*cachedProgram).program, nil
+	}
+
+	ast, issues := c.env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("Expression must evaluate to a bool, not %s", ast.OutputType())
+	}
+	program, err := c.env.Program(ast,
+		cel.CostLimit(celCostLimit),
+		cel.InterruptCheckFrequency(celInterruptCheckFrequency))
+	if err != nil {
+		return nil, err
+	}
+
+	c.programs[expression] = c.recent.PushFront(&cachedProgram{expression: expression, program: program})
+	for c.recent.Len() > maxPrograms {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.programs, oldest.Value.(*cachedProgram).expression)
+	}
+	return program, nil
+}
+
+// evaluate returns whether the expression holds for the variables. Evaluations that exceed
+// the cost limit or celEvalTimeout fail.
+func (c *programCache) evaluate(ctx context.Context, expression string, variables map[string]interface{}) (bool, error) {
+	program, err := c.program(expression)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, celEvalTimeout)
+	defer cancel()
+	out, _, err := program.ContextEval(ctx, variables)
+	if err != nil {
+		return false, err
+	}
+	result, ok := out.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("Expression evaluated to %v, not a bool", out)
+	}
+	return bool(result), nil
+}
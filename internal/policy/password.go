@@ -0,0 +1,140 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// hashPrefixLength is the number of hex characters the breached password file is keyed by
+const hashPrefixLength = 5
+
+// Sizes of the character classes used to estimate the entropy of a password
+const (
+	lowercaseCharacters = 26
+	uppercaseCharacters = 26
+	digitCharacters     = 10
+	symbolCharacters    = 33
+)
+
+// BreachedPasswords is a breached password hash prefix file, keyed by the first 5 hex
+// characters of the upper case SHA-1 of a password. Only the prefix of a checked
+// password is used for the lookup, the full hash is compared in memory.
+type BreachedPasswords map[string]string
+
+// Contains reports whether the password is in the list
+func (b BreachedPasswords) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, ok := b[hash[:hashPrefixLength]]
+	if !ok {
+		return false
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(suffixes))
+	for scanner.Scan() {
+		suffix, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(suffix, hash[hashPrefixLength:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPassword returns the rules of the policy the password violates. The
+// messages never contain the password, they end up in events and conditions.
+func CheckPassword(policy *secopsv1alpha1.PasswordPolicy, breached BreachedPasswords, password string) []string {
+	var violations []string
+
+	length := len([]rune(password))
+	if length < policy.MinLength {
+		violations = append(violations, fmt.Sprintf("is shorter than %d characters", policy.MinLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if policy.RequireUppercase && !upper {
+		violations = append(violations, "has no upper case letter")
+	}
+	if policy.RequireLowercase && !lower {
+		violations = append(violations, "has no lower case letter")
+	}
+	if policy.RequireDigit && !digit {
+		violations = append(violations, "has no digit")
+	}
+	if policy.RequireSymbol && !symbol {
+		violations = append(violations, "has no symbol")
+	}
+
+	if policy.MinEntropyBits > 0 && entropyBits(length, upper, lower, digit, symbol) < float64(policy.MinEntropyBits) {
+		violations = append(violations, fmt.Sprintf("has less than %d bits of entropy", policy.MinEntropyBits))
+	}
+
+	lowered := strings.ToLower(password)
+	for _, word := range policy.BannedWords {
+		if word != "" && strings.Contains(lowered, strings.ToLower(word)) {
+			violations = append(violations, fmt.Sprintf("contains the banned word %q", word))
+		}
+	}
+
+	if breached != nil && breached.Contains(password) {
+		violations = append(violations, "is a known breached password")
+	}
+
+	return violations
+}
+
+// entropyBits estimates the entropy of a password as its length times the bits
+// per character of the character classes it uses
+func entropyBits(length int, upper bool, lower bool, digit bool, symbol bool) float64 {
+	pool := 0
+	if upper {
+		pool += uppercaseCharacters
+	}
+	if lower {
+		pool += lowercaseCharacters
+	}
+	if digit {
+		pool += digitCharacters
+	}
+	if symbol {
+		pool += symbolCharacters
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(length) * math.Log2(float64(pool))
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package policy evaluates the SentinelPolicies for a Sentinel. It is shared by the
+// admission webhook and the Sentinel controller, so both enforce the same rules.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// passwordRule names the violations of the password rules, they are always enforced
+const passwordRule = "password"
+
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+// Join returns the violations as one message
+func Join(violations []secopsv1alpha1.PolicyViolation) string {
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		messages = append(messages, fmt.Sprintf("%s/%s: %s", violation.Policy, violation.Rule, violation.Message))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// WithAction returns the violations of rules with the given action
+func WithAction(violations []secopsv1alpha1.PolicyViolation, action string) []secopsv1alpha1.PolicyViolation {
+	var filtered []secopsv1alpha1.PolicyViolation
+	for _, violation := range violations {
+		if violation.Action == action {
+			filtered = append(filtered, violation)
+		}
+	}
+	return filtered
+}
+
+// Evaluator evaluates the SentinelPolicies of the cluster
+type Evaluator struct {
+	// Reader reads the policies, namespaces and breached password lists. An uncached
+	// reader avoids keeping every ConfigMap of the cluster in memory.
+	Reader client.Reader
+
+	programs *programCache
+}
+
+// NewEvaluator returns an Evaluator reading through the given reader
+func NewEvaluator(reader client.Reader) (*Evaluator, error) {
+	programs, err := newProgramCache()
+	if err != nil {
+		return nil, err
+	}
+	return &Evaluator{Reader: reader, programs: programs}, nil
+}
+
+// Evaluate returns the violations of the minimum secret type of the namespace of the Sentinel
+// and of all policies that apply to the namespace.
+// The user is the requesting user on admission and nil for the controller.
+func (e *Evaluator) Evaluate(ctx context.Context, sentinel *secopsv1alpha1.Sentinel,
+	user *authenticationv1.UserInfo) ([]secopsv1alpha1.PolicyViolation, error) {
+
+	namespace := &corev1.Namespace{}
+	if err := e.Reader.Get(ctx, types.NamespacedName{Name: sentinel.Namespace}, namespace); err != nil {
+		return nil, err
+	}
+
+	var violations []secopsv1alpha1.PolicyViolation
+	if violation := CheckSecretType(namespace, sentinel.Spec.SecretType); violation != nil {
+		violations = append(violations, *violation)
+	}
+
+	policies := &secopsv1alpha1.SentinelPolicyList{}
+	if err := e.Reader.List(ctx, policies); err != nil {
+		return nil, err
+	}
+
+	var variables map[string]interface{}
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		applies, err := appliesTo(policy, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid namespaceSelector of SentinelPolicy %s: %w", policy.Name, err)
+		}
+		if !applies {
+			continue
+		}
+
+		if policy.Spec.Password != nil {
+			passwordViolations, err := e.evaluatePassword(ctx, policy, sentinel)
+			if err != nil {
+				return nil, err
+			}
+			violations = append(violations, passwordViolations...)
+		}
+
+		if len(policy.Spec.Rules) > 0 {
+			if variables == nil {
+				if variables, err = ruleVariables(sentinel, namespace, user); err != nil {
+					return nil, err
+				}
+			}
+			violations = append(violations, e.evaluateRules(ctx, policy, variables)...)
+		}
+	}
+	return violations, nil
+}
+
+// evaluateRules returns the violations of the CEL rules of the policy. A rule that fails to
+// compile or to evaluate counts as violated, a broken policy must not open a gap.
+func (e *Evaluator) evaluateRules(ctx context.Context, policy *secopsv1alpha1.SentinelPolicy,
+	variables map[string]interface{}) []secopsv1alpha1.PolicyViolation {
+
+	var violations []secopsv1alpha1.PolicyViolation
+	for _, rule := range policy.Spec.Rules {
+		action := rule.Action
+		if action == "" {
+			action = secopsv1alpha1.PolicyActionEnforce
+		}
+
+		ok, err := e.programs.evaluate(ctx, rule.Expression, variables)
+		if err == nil && ok {
+			continue
+		}
+
+		message := rule.Message
+		if err != nil {
+			message = fmt.Sprintf("Failed to evaluate the expression: %s", err)
+		} else if message == "" {
+			message = fmt.Sprintf("failed expression: %s", rule.Expression)
+		}
+		violations = append(violations, secopsv1alpha1.PolicyViolation{
+			Policy:  policy.Name,
+			Rule:    rule.Name,
+			Action:  action,
+			Message: message,
+		})
+	}
+	return violations
+}
+
+// ruleVariables returns the variables of the rule expressions
+func ruleVariables(sentinel *secopsv1alpha1.Sentinel, namespace *corev1.Namespace,
+	user *authenticationv1.UserInfo) (map[string]interface{}, error) {
+
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(sentinel)
+	if err != nil {
+		return nil, err
+	}
+	namespaceObject, err := runtime.DefaultUnstructuredConverter.ToUnstructured(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	userInfo := map[string]interface{}{"username": "", "groups": []interface{}{}}
+	if user != nil {
+		groups := make([]interface{}, 0, len(user.Groups))
+		for _, group := range user.Groups {
+			groups = append(groups, group)
+		}
+		userInfo = map[string]interface{}{"username": user.Username, "groups": groups}
+	}
+
+	return map[string]interface{}{
+		objectVariable:          object,
+		namespaceObjectVariable: namespaceObject,
+		requestVariable:         map[string]interface{}{"userInfo": userInfo},
+	}, nil
+}
+
+func (e *Evaluator) evaluatePassword(ctx context.Context, policy *secopsv1alpha1.SentinelPolicy,
+	sentinel *secopsv1alpha1.Sentinel) ([]secopsv1alpha1.PolicyViolation, error) {
+
+	var breached BreachedPasswords
+	if ref := policy.Spec.Password.BreachedPasswords; ref != nil {
+		configMap := &corev1.ConfigMap{}
+		if err := e.Reader.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, configMap); err != nil {
+			return nil, fmt.Errorf("Failed to load the breached passwords of SentinelPolicy %s: %w", policy.Name, err)
+		}
+		breached = BreachedPasswords(configMap.Data)
+	}
+
+	keys := policy.Spec.Password.Keys
+	if len(keys) == 0 {
+		for key := range sentinel.Spec.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	var violations []secopsv1alpha1.PolicyViolation
+	for _, key := range keys {
+		value, ok := sentinel.Spec.Data[key]
+		if !ok {
+			continue
+		}
+		for _, message := range CheckPassword(policy.Spec.Password, breached, value) {
+			violations = append(violations, secopsv1alpha1.PolicyViolation{
+				Policy:  policy.Name,
+				Rule:    passwordRule,
+				Action:  secopsv1alpha1.PolicyActionEnforce,
+				Message: fmt.Sprintf("data %s %s", key, message),
+			})
+		}
+	}
+	return violations, nil
+}
+
+// appliesTo reports whether the namespace is selected by the policy
+func appliesTo(policy *secopsv1alpha1.SentinelPolicy, namespace *corev1.Namespace) (bool, error) {
+	if policy.Spec.NamespaceSelector == nil {
+		return true, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(policy.Spec.NamespaceSelector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(namespace.Labels)), nil
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+func TestCheckPassword(t *testing.T) {
+	passwordPolicy := &secopsv1alpha1.PasswordPolicy{
+		MinLength:        12,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		MinEntropyBits:   60,
+		BannedWords:      []string{"Hello"},
+	}
+	// SHA-1 of hello123 is 4233137D1C510F2E55BA5CB220B864B11033F156
+	breached := BreachedPasswords{"42331": "0000000000000000000000000000000000A:1\n37D1C510F2E55BA5CB220B864B11033F156:2215\n"}
+
+	tests := []struct {
+		password string
+		want     []string
+	}{
+		{
+			password: "hello123",
+			want: []string{
+				"is shorter than 12 characters",
+				"has no upper case letter",
+				"has no symbol",
+				"has less than 60 bits of entropy",
+				"contains the banned word \"Hello\"",
+				"is a known breached password",
+			},
+		},
+		{
+			password: "c0rrect-Horse-battery",
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := CheckPassword(passwordPolicy, breached, tt.password); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CheckPassword(%q) =\n%v\nwant\n%v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	scheme := newScheme(t)
+	objects := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"env": "prod"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dev"}},
+		&secopsv1alpha1.SentinelPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod-passwords"},
+			Spec: secopsv1alpha1.SentinelPolicySpec{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+				Password:          &secopsv1alpha1.PasswordPolicy{Keys: []string{"password"}, MinLength: 12},
+			},
+		},
+	}
+	evaluator, err := NewEvaluator(fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentinel := &secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod"},
+		Spec: secopsv1alpha1.SentinelSpec{Data: map[string]string{
+			"username": "admin",
+			"password": "hello123",
+		}},
+	}
+	violations, err := evaluator.Evaluate(context.Background(), sentinel, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []secopsv1alpha1.PolicyViolation{{Policy: "prod-passwords", Rule: passwordRule,
+		Action: secopsv1alpha1.PolicyActionEnforce, Message: "data password is shorter than 12 characters"}}
+	if !reflect.DeepEqual(violations, want) {
+		t.Fatalf("Evaluate() = %v, want %v", violations, want)
+	}
+
+	sentinel.Namespace = "dev"
+	violations, err = evaluator.Evaluate(context.Background(), sentinel, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 0 {
+		t.Fatalf("Evaluate() in an unselected namespace = %v", violations)
+	}
+}
+
+func TestEvaluateRules(t *testing.T) {
+	objects := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"env": "prod"}}},
+		&secopsv1alpha1.SentinelPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "secret-types"},
+			Spec: secopsv1alpha1.SentinelPolicySpec{Rules: []secopsv1alpha1.PolicyRule{
+				{
+					Name: "kms-in-prod",
+					Expression: "!has(namespaceObject.metadata.labels) || namespaceObject.metadata.labels['env'] != 'prod' || " +
+						"object.spec.secretType == 'RbacKMSSecuredSecret'",
+					Message: "RbacKMSSecuredSecret is required in prod",
+				},
+				{
+					Name:       "no-base-secret",
+					Expression: "object.spec.secretType != 'BaseSecret'",
+					Action:     secopsv1alpha1.PolicyActionWarn,
+				},
+				{
+					Name:       "operators-only",
+					Expression: "'sentinel-operators' in request.userInfo.groups",
+					Message:    "Sentinels are created by the sentinel-operators",
+					Action:     secopsv1alpha1.PolicyActionAudit,
+				},
+				{
+					Name:       "broken",
+					Expression: "object.spec.secretType ==",
+					Action:     secopsv1alpha1.PolicyActionAudit,
+				},
+			}},
+		},
+	}
+	evaluator, err := NewEvaluator(fake.NewClientBuilder().WithScheme(newScheme(t)).WithRuntimeObjects(objects...).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentinel := &secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod"},
+		Spec:       secopsv1alpha1.SentinelSpec{SecretName: "db", SecretType: "BaseSecret"},
+	}
+	user := &authenticationv1.UserInfo{Username: "alice", Groups: []string{"sentinel-operators"}}
+	violations, err := evaluator.Evaluate(context.Background(), sentinel, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, violation := range violations {
+		got = append(got, violation.Rule+" "+violation.Action)
+	}
+	want := []string{
+		"kms-in-prod " + secopsv1alpha1.PolicyActionEnforce,
+		"no-base-secret " + secopsv1alpha1.PolicyActionWarn,
+		"broken " + secopsv1alpha1.PolicyActionAudit,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Evaluate() = %v, want %v", got, want)
+	}
+
+	// The controller has no requesting user
+	violations, err = evaluator.Evaluate(context.Background(), sentinel, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audited := WithAction(violations, secopsv1alpha1.PolicyActionAudit); len(audited) != 2 {
+		t.Fatalf("Evaluate() without user = %v", audited)
+	}
+}
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := secopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func TestProgramCacheLimits(t *testing.T) {
+	cache, err := newProgramCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nested comprehensions of 10^8 iterations stop at the cost limit long before they finish
+	list := "[0, 1, 2, 3, 4, 5, 6, 7, 8, 9]"
+	expression := "true"
+	for i := 0; i < 8; i++ {
+		expression = fmt.Sprintf("%s.all(x%d, %s)", list, i, expression)
+	}
+	start := time.Now()
+	if _, err := cache.evaluate(context.Background(), expression, map[string]interface{}{}); err == nil {
+		t.Error("expensive expression evaluated")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expensive expression ran for %s", elapsed)
+	}
+
+	// The least recently used programs are evicted
+	for i := 0; i < maxPrograms+10; i++ {
+		if _, err := cache.program(fmt.Sprintf("object.spec.secretType != 'Type%d'", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(cache.programs) != maxPrograms || cache.recent.Len() != maxPrograms {
+		t.Errorf("%d programs cached, want %d", len(cache.programs), maxPrograms)
+	}
+	if _, ok := cache.programs["object.spec.secretType != 'Type0'"]; ok {
+		t.Error("oldest program not evicted")
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// minimumSecretTypeRule names the violations of the minimum secret type of a namespace
+const minimumSecretTypeRule = "minimum-secret-type"
+
+// secretTypes lists the secret types from the lowest to the highest security level
+var secretTypes = []string{
+	"BaseSecret",
+	"RbacBaseSecret",
+	"SecuredSecret",
+	"RbacSecuredSecret",
+	"KMSSecuredSecret",
+	"RbacKMSSecuredSecret",
+}
+
+// SecretTypeLevel returns the security level of the secret type
+func SecretTypeLevel(secretType string) (int, bool) {
+	for level, t := range secretTypes {
+		if t == secretType {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+// MinimumSecretType returns the minimum secret type of the namespace, empty when it has none
+func MinimumSecretType(namespace *corev1.Namespace) string {
+	return namespace.Annotations[secopsv1alpha1.MinimumSecretTypeAnnotation]
+}
+
+// CheckSecretType returns the violation of the minimum secret type of the namespace, if any
+func CheckSecretType(namespace *corev1.Namespace, secretType string) *secopsv1alpha1.PolicyViolation {
+	minimum := MinimumSecretType(namespace)
+	if minimum == "" {
+		return nil
+	}
+
+	violation := &secopsv1alpha1.PolicyViolation{
+		Policy: fmt.Sprintf("namespace/%s", namespace.Name),
+		Rule:   minimumSecretTypeRule,
+		Action: secopsv1alpha1.PolicyActionEnforce,
+	}
+
+	minimumLevel, ok := SecretTypeLevel(minimum)
+	if !ok {
+		// A broken minimum must not open a gap
+		violation.Message = fmt.Sprintf("Invalid %s annotation: %s", secopsv1alpha1.MinimumSecretTypeAnnotation, minimum)
+		return violation
+	}
+	level, ok := SecretTypeLevel(secretType)
+	if ok && level >= minimumLevel {
+		return nil
+	}
+
+	violation.Message = fmt.Sprintf("secret type %s is below the minimum %s of the namespace", secretType, minimum)
+	if upgraded, ok := UpgradeSecretType(secretType, minimum); ok {
+		violation.Message += fmt.Sprintf(", use %s", upgraded)
+	}
+	return violation
+}
+
+// UpgradeSecretType returns the lowest secret type at or above the minimum that keeps the
+// RBAC setting of the given type, the type suggested to the authors of a Sentinel below it. A type without RBAC is never upgraded to an RBAC type,
+// as those need a Role and a RoleBinding in the spec.
+func UpgradeSecretType(secretType string, minimum string) (string, bool) {
+	minimumLevel, ok := SecretTypeLevel(minimum)
+	if !ok {
+		return "", false
+	}
+	level, ok := SecretTypeLevel(secretType)
+	if !ok || level >= minimumLevel {
+		return "", false
+	}
+
+	rbac := isRbacSecretType(secretType)
+	for _, t := range secretTypes[minimumLevel:] {
+		if isRbacSecretType(t) == rbac {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+func isRbacSecretType(secretType string) bool {
+	level, _ := SecretTypeLevel(secretType)
+	// The RBAC types take the odd levels
+	return level%2 == 1
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+func TestCheckSecretType(t *testing.T) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Annotations: map[string]string{
+		secopsv1alpha1.MinimumSecretTypeAnnotation: "SecuredSecret",
+	}}}
+
+	tests := []struct {
+		secretType string
+		violated   bool
+	}{
+		{secretType: "BaseSecret", violated: true},
+		{secretType: "RbacBaseSecret", violated: true},
+		{secretType: "SecuredSecret", violated: false},
+		{secretType: "RbacKMSSecuredSecret", violated: false},
+		{secretType: "UnknownSecret", violated: true},
+	}
+	for _, tt := range tests {
+		if got := CheckSecretType(namespace, tt.secretType); (got != nil) != tt.violated {
+			t.Errorf("CheckSecretType(%s) = %v, want violated %v", tt.secretType, got, tt.violated)
+		}
+	}
+
+	if got := CheckSecretType(&corev1.Namespace{}, "BaseSecret"); got != nil {
+		t.Errorf("CheckSecretType() without minimum = %v", got)
+	}
+}
+
+func TestUpgradeSecretType(t *testing.T) {
+	tests := []struct {
+		secretType string
+		minimum    string
+		want       string
+		ok         bool
+	}{
+		{secretType: "BaseSecret", minimum: "SecuredSecret", want: "SecuredSecret", ok: true},
+		{secretType: "BaseSecret", minimum: "RbacSecuredSecret", want: "KMSSecuredSecret", ok: true},
+		{secretType: "RbacBaseSecret", minimum: "SecuredSecret", want: "RbacSecuredSecret", ok: true},
+		{secretType: "BaseSecret", minimum: "RbacKMSSecuredSecret", ok: false},
+		{secretType: "KMSSecuredSecret", minimum: "SecuredSecret", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := UpgradeSecretType(tt.secretType, tt.minimum)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("UpgradeSecretType(%s, %s) = %s, %v, want %s, %v", tt.secretType, tt.minimum, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package provider reads the data of a Sentinel from secret stores outside of the cluster
+// and pushes it to them.
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+// maxResponseSize bounds the responses of the HTTP based providers
+const maxResponseSize = 1 << 20
+
+// Provider reads key-value data from an external secret store
+type Provider interface {
+	// Name identifies the provider in errors and conditions
+	Name() string
+	Fetch(ctx context.Context) (map[string][]byte, error)
+}
+
+// VaultKV reads a secret of the Vault KV version 2 secrets engine
+type VaultKV struct {
+	// Address of Vault, for example https://vault.vault.svc:8200
+	Address string
+	// Mount path of the KV engine, defaults to secret
+	Mount string
+	// Path of the secret below the mount
+	Path string
+	// Version of the secret, the latest version when zero
+	Version int
+	// Token authenticates the requests to Vault
+	Token string
+	// Client defaults to a client with a 10s timeout
+	Client *http.Client
+}
+
+// Name implements Provider
+func (v *VaultKV) Name() string { return "vault:" + v.mount() + "/" + strings.Trim(v.Path, "/") }
+
+// Fetch implements Provider
+func (v *VaultKV) Fetch(ctx context.Context) (map[string][]byte, error) {
+	url := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimRight(v.Address, "/"), v.mount(), strings.Trim(v.Path, "/"))
+	if v.Version > 0 {
+		url += fmt.Sprintf("?version=%d", v.Version)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Vault-Token", v.Token)
+
+	response := struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}{}
+	if err := getJSON(v.Client, request, v.Name(), &response); err != nil {
+		return nil, err
+	}
+	if response.Data.Data == nil {
+		// A deleted or destroyed version has no data
+		return nil, fmt.Errorf("%s has no data", v.Name())
+	}
+	return flatten(response.Data.Data), nil
+}
+
+func (v *VaultKV) mount() string {
+	if v.Mount == "" {
+		return "secret"
+	}
+	return strings.Trim(v.Mount, "/")
+}
+
+// HTTPJSON reads a JSON object from a HTTP endpoint
+type HTTPJSON struct {
+	URL string
+	// Token is sent as bearer token when set
+	Token string
+	// Path selects a nested object with dot separated keys, the whole document when empty
+	Path string
+	// Client defaults to a client with a 10s timeout
+	Client *http.Client
+}
+
+// Name implements Provider
+func (h *HTTPJSON) Name() string { return "http:" + h.URL }
+
+// Fetch implements Provider
+func (h *HTTPJSON) Fetch(ctx context.Context) (map[string][]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	if h.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+
+	var document interface{}
+	if err := getJSON(h.Client, request, h.Name(), &document); err != nil {
+		return nil, err
+	}
+	if h.Path != "" {
+		for _, key := range strings.Split(h.Path, ".") {
+			object, ok := document.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s has no object at %s", h.Name(), h.Path)
+			}
+			document = object[key]
+		}
+	}
+	object, ok := document.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s did not return a JSON object", h.Name())
+	}
+	return flatten(object), nil
+}
+
+// FileDir reads the files of a directory, for example a volume mounted into the operator.
+// Every file becomes a key.
+type FileDir struct {
+	Dir string
+}
+
+// Name implements Provider
+func (f *FileDir) Name() string { return "file:" + f.Dir }
+
+// Fetch implements Provider
+func (f *FileDir) Fetch(_ context.Context) (map[string][]byte, error) {
+	return envelope.ReadDir(f.Dir)
+}
+
+// ResolvePath joins a relative path to the root and fails when the result leaves the root,
+// also through symbolic links
+func ResolvePath(root, path string) (string, error) {
+	if root == "" {
+		return "", fmt.Errorf("File data sources are disabled")
+	}
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("File data source path %s must be relative", path)
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(resolvedRoot, path))
+	if err != nil {
+		return "", err
+	}
+	if resolved != resolvedRoot && !strings.HasPrefix(resolved, resolvedRoot+string(filepath.Separator)) {
+		return "", fmt.Errorf("File data source path %s is outside of %s", path, root)
+	}
+	if info, err := os.Stat(resolved); err != nil {
+		return "", err
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("File data source path %s is not a directory", path)
+	}
+	return resolved, nil
+}
+
+// Source is a provider and the prefix added to its keys
+type Source struct {
+	Provider Provider
+	Prefix   string
+}
+
+// Merge fetches the sources in order, the keys of later sources replace the keys of earlier
+// ones. It fails on the first provider error and on keys that are no valid secret keys.
+func Merge(ctx context.Context, sources []Source) (map[string][]byte, error) {
+	data := map[string][]byte{}
+	for _, source := range sources {
+		values, err := source.Provider.Fetch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source.Provider.Name(), err)
+		}
+		for key, value := range values {
+			key = source.Prefix + key
+			if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+				return nil, fmt.Errorf("%s: invalid key %q: %s", source.Provider.Name(), key, strings.Join(errs, ", "))
+			}
+			data[key] = value
+		}
+	}
+	return data, nil
+}
+
+// getJSON sends the request and decodes a 200 response
+func getJSON(client *http.Client, request *http.Request, name string, into interface{}) error {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("%s returned %s: %s", name, response.Status, strings.TrimSpace(string(message)))
+	}
+	return json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(into)
+}
+
+// flatten turns the values of a JSON object into secret values, strings are kept as they
+// are and every other value is stored as JSON
+func flatten(object map[string]interface{}) map[string][]byte {
+	data := make(map[string][]byte, len(object))
+	for key, value := range object {
+		switch v := value.(type) {
+		case string:
+			data[key] = []byte(v)
+		case nil:
+			continue
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				continue
+			}
+			data[key] = encoded
+		}
+	}
+	return data
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVaultKV(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/kv/data/apps/db" || r.URL.Query().Get("version") != "2" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"data":{"username":"app","password":"s3cr3t","port":5432},"metadata":{"version":2}}}`)
+	}))
+	defer server.Close()
+
+	vault := &VaultKV{Address: server.URL, Mount: "kv", Path: "/apps/db/", Version: 2, Token: "root"}
+	data, err := vault.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{"username": []byte("app"), "password": []byte("s3cr3t"), "port": []byte("5432")}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("Fetch() = %q, want %q", data, want)
+	}
+
+	vault.Token = "wrong"
+	if _, err := vault.Fetch(context.Background()); err == nil {
+		t.Error("Fetch() succeeded with a wrong token")
+	}
+}
+
+// TestVaultKVDevServer runs against a Vault dev server, for example
+// vault server -dev -dev-root-token-id=root with VAULT_ADDR=http://127.0.0.1:8200 VAULT_TOKEN=root
+func TestVaultKVDevServer(t *testing.T) {
+	address, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if address == "" || token == "" {
+		t.Skip("VAULT_ADDR and VAULT_TOKEN are not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The dev server mounts KV version 2 at secret/
+	path := fmt.Sprintf("sentinel-operator-test/%d", time.Now().UnixNano())
+	body, _ := json.Marshal(map[string]interface{}{"data": map[string]string{"password": "dev-s3cr3t"}})
+	request, _ := http.NewRequestWithContext(ctx, http.MethodPost, address+"/v1/secret/data/"+path, bytes.NewReader(body))
+	request.Header.Set("X-Vault-Token", token)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("writing the test secret returned %s", response.Status)
+	}
+
+	data, err := (&VaultKV{Address: address, Path: path, Token: token}).Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data["password"]) != "dev-s3cr3t" {
+		t.Errorf("Fetch() = %q", data)
+	}
+}
+
+func TestHTTPJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"service":{"credentials":{"apiKey":"abc","scopes":["read"],"expires":null}}}`)
+	}))
+	defer server.Close()
+
+	source := &HTTPJSON{URL: server.URL, Token: "token", Path: "service.credentials"}
+	data, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{"apiKey": []byte("abc"), "scopes": []byte(`["read"]`)}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("Fetch() = %q, want %q", data, want)
+	}
+
+	source.Path = "service.credentials.apiKey"
+	if _, err := source.Fetch(context.Background()); err == nil {
+		t.Error("Fetch() accepted a path to a string")
+	}
+}
+
+func TestFileDirAndResolvePath(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "db")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "password"), []byte("s3cr3t"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := ResolvePath(root, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := (&FileDir{Dir: resolved}).Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data["password"]) != "s3cr3t" {
+		t.Errorf("Fetch() = %q", data)
+	}
+
+	for _, path := range []string{"../", "db/../..", "escape", "/etc", "db/password"} {
+		if _, err := ResolvePath(root, path); err == nil {
+			t.Errorf("ResolvePath(%q) succeeded", path)
+		}
+	}
+	if _, err := ResolvePath("", "db"); err == nil {
+		t.Error("ResolvePath succeeded without a root")
+	}
+}
+
+type staticProvider map[string][]byte
+
+func (s staticProvider) Name() string { return "static" }
+
+func (s staticProvider) Fetch(context.Context) (map[string][]byte, error) { return s, nil }
+
+func TestMerge(t *testing.T) {
+	data, err := Merge(context.Background(), []Source{
+		{Provider: staticProvider{"user": []byte("a"), "password": []byte("1")}},
+		{Provider: staticProvider{"password": []byte("2")}},
+		{Provider: staticProvider{"key": []byte("k")}, Prefix: "api-"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{"user": []byte("a"), "password": []byte("2"), "api-key": []byte("k")}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("Merge() = %q, want %q", data, want)
+	}
+
+	if _, err := Merge(context.Background(), []Source{{Provider: staticProvider{"../x": nil}}}); err == nil {
+		t.Error("Merge() accepted an invalid key")
+	}
+}
+
+func TestVaultKVPush(t *testing.T) {
+	stored := map[string]map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/secret/data/legacy/db":
+			body := struct {
+				Data map[string]string `json:"data"`
+			}{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			stored["legacy/db"] = body.Data
+			fmt.Fprint(w, `{"data":{"version":1}}`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/v1/secret/metadata/legacy/db":
+			delete(stored, "legacy/db")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	vault := &VaultKV{Address: server.URL, Path: "legacy/db", Token: "root"}
+	if err := vault.Push(context.Background(), map[string][]byte{"password": []byte("s3cr3t")}); err != nil {
+		t.Fatal(err)
+	}
+	if stored["legacy/db"]["password"] != "s3cr3t" {
+		t.Errorf("Vault holds %v", stored)
+	}
+	if err := vault.Delete(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored["legacy/db"]; ok {
+		t.Error("Delete() kept the secret")
+	}
+}
+
+func TestFileDirPush(t *testing.T) {
+	dir := t.TempDir()
+	store := &FileDir{Dir: dir}
+	ctx := context.Background()
+
+	if err := store.Push(ctx, map[string][]byte{"user": []byte("app"), "password": []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Push(ctx, map[string][]byte{"password": []byte("2")}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := store.Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]byte{"password": []byte("2")}; !reflect.DeepEqual(data, want) {
+		t.Errorf("directory holds %q, want %q", data, want)
+	}
+
+	if err := store.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := store.Fetch(ctx); len(data) != 0 {
+		t.Errorf("Delete() kept %q", data)
+	}
+}
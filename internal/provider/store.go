@@ -0,0 +1,119 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kavinduxo/sentinel-operator/pkg/envelope"
+)
+
+// Store receives the data of a Sentinel, the reverse direction of a Provider
+type Store interface {
+	// Name identifies the store in errors and the push status
+	Name() string
+	// Push replaces the data held by the store
+	Push(ctx context.Context, data map[string][]byte) error
+	// Delete removes the data from the store
+	Delete(ctx context.Context) error
+}
+
+// Push implements Store, it writes a new version of the secret
+func (v *VaultKV) Push(ctx context.Context, data map[string][]byte) error {
+	values := make(map[string]string, len(data))
+	for key, value := range data {
+		values[key] = string(value)
+	}
+	payload, err := json.Marshal(map[string]interface{}{"data": values})
+	if err != nil {
+		return err
+	}
+	return v.send(ctx, http.MethodPost, "data", bytes.NewReader(payload))
+}
+
+// Delete implements Store, it removes every version and the metadata of the secret
+func (v *VaultKV) Delete(ctx context.Context) error {
+	return v.send(ctx, http.MethodDelete, "metadata", nil)
+}
+
+func (v *VaultKV) send(ctx context.Context, method, endpoint string, body io.Reader) error {
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", strings.TrimRight(v.Address, "/"), v.mount(), endpoint, strings.Trim(v.Path, "/"))
+	request, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Vault-Token", v.Token)
+
+	client := v.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNoContent {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("%s returned %s: %s", v.Name(), response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Push implements Store, every key is written to a file and the files of removed keys
+// are deleted
+func (f *FileDir) Push(_ context.Context, data map[string][]byte) error {
+	if err := envelope.WriteDir(f.Dir, data, 0o600); err != nil {
+		return err
+	}
+	return f.removeFiles(func(name string) bool {
+		_, keep := data[name]
+		return !keep
+	})
+}
+
+// Delete implements Store, it removes the files but keeps the directory
+func (f *FileDir) Delete(_ context.Context) error {
+	return f.removeFiles(func(string) bool { return true })
+}
+
+func (f *FileDir) removeFiles(remove func(name string) bool) error {
+	entries, err := os.ReadDir(f.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), "..") || !remove(entry.Name()) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(f.Dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
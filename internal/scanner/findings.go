@@ -0,0 +1,109 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// FindingName returns the stable object name of a finding, the same check on the same
+// resource always maps to the same SentinelFinding. The message is not part of the name,
+// so a finding keeps its history when its text changes.
+func FindingName(finding secopsv1alpha1.SentinelFindingSpec) string {
+	ref := finding.Resource
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%s/%s", ref.Kind, ref.Namespace, ref.Name, ref.FieldPath)))
+	return fmt.Sprintf("%s-%s-%s", finding.Scanner, finding.Rule, hex.EncodeToString(sum[:])[:8])
+}
+
+// SyncFindings makes the SentinelFindings of the scanner match the findings of the latest
+// scan. New findings are created, known ones are refreshed and resolved ones are deleted.
+func SyncFindings(ctx context.Context, c client.Client, scanner string, findings []secopsv1alpha1.SentinelFindingSpec) error {
+	existing := &secopsv1alpha1.SentinelFindingList{}
+	if err := c.List(ctx, existing, client.MatchingLabels{secopsv1alpha1.FindingScannerLabel: scanner}); err != nil {
+		return err
+	}
+	current := map[string]*secopsv1alpha1.SentinelFinding{}
+	for i := range existing.Items {
+		current[existing.Items[i].Name] = &existing.Items[i]
+	}
+
+	now := metav1.Now()
+	seen := map[string]bool{}
+	for _, spec := range findings {
+		spec.Scanner = scanner
+		name := FindingName(spec)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		finding, ok := current[name]
+		if !ok {
+			finding = &secopsv1alpha1.SentinelFinding{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   name,
+					Labels: map[string]string{secopsv1alpha1.FindingScannerLabel: scanner},
+				},
+				Spec: spec,
+			}
+			err := c.Create(ctx, finding)
+			if apierrors.IsAlreadyExists(err) {
+				// Created since the list, continue with the stored finding
+				err = c.Get(ctx, client.ObjectKeyFromObject(finding), finding)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if !equality.Semantic.DeepEqual(finding.Spec, spec) {
+			finding.Spec = spec
+			if err := c.Update(ctx, finding); err != nil {
+				return err
+			}
+		}
+
+		if finding.Status.FirstSeen == nil {
+			finding.Status.FirstSeen = &now
+		}
+		finding.Status.LastSeen = &now
+		if err := c.Status().Update(ctx, finding); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	// Findings that were not reported again are resolved
+	for name, finding := range current {
+		if seen[name] {
+			continue
+		}
+		if err := c.Delete(ctx, finding); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+func TestSyncFindings(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	if err := secopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	// The first list misses a finding that another scan created, as a stale cache does
+	staleList := false
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithStatusSubresource(&secopsv1alpha1.SentinelFinding{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				if staleList {
+					staleList = false
+					return nil
+				}
+				return c.List(ctx, list, opts...)
+			},
+		}).Build()
+	finding := secopsv1alpha1.SentinelFindingSpec{
+		Rule:     RuleWildcardVerbs,
+		Severity: secopsv1alpha1.SeverityMedium,
+		Resource: secopsv1alpha1.FindingResourceRef{Kind: "Role", Namespace: "dev", Name: "everything"},
+		Message:  "Role everything allows all verbs through a wildcard",
+	}
+	get := func() *secopsv1alpha1.SentinelFinding {
+		t.Helper()
+		stored := &secopsv1alpha1.SentinelFinding{}
+		finding.Scanner = RBACScannerName
+		if err := c.Get(ctx, client.ObjectKey{Name: FindingName(finding)}, stored); err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+
+	if err := SyncFindings(ctx, c, RBACScannerName, []secopsv1alpha1.SentinelFindingSpec{finding}); err != nil {
+		t.Fatal(err)
+	}
+	first := get()
+	if first.Status.FirstSeen == nil || first.Status.LastSeen == nil {
+		t.Fatalf("status %+v", first.Status)
+	}
+
+	// A new message keeps the finding and its first sighting
+	firstSeen := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	first.Status.FirstSeen = &firstSeen
+	if err := c.Status().Update(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	finding.Message = "Role everything allows every verb through a wildcard"
+	staleList = true
+	if err := SyncFindings(ctx, c, RBACScannerName, []secopsv1alpha1.SentinelFindingSpec{finding}); err != nil {
+		t.Fatal(err)
+	}
+	second := get()
+	if second.Name != first.Name {
+		t.Errorf("finding renamed from %s to %s", first.Name, second.Name)
+	}
+	if second.Spec.Message != finding.Message {
+		t.Errorf("message %q", second.Spec.Message)
+	}
+	if !second.Status.FirstSeen.Equal(&firstSeen) {
+		t.Errorf("first seen reset from %v to %v", firstSeen, second.Status.FirstSeen)
+	}
+
+	// Resolved findings are deleted
+	if err := SyncFindings(ctx, c, RBACScannerName, nil); err != nil {
+		t.Fatal(err)
+	}
+	list := &secopsv1alpha1.SentinelFindingList{}
+	if err := c.List(ctx, list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("%d findings left", len(list.Items))
+	}
+}
@@ -0,0 +1,306 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// LeakScannerName is the scanner label of the leak findings
+const LeakScannerName = "leak"
+
+// Rules of the leak scanner
+const (
+	RuleSentinelSecretCopy = "sentinel-secret-copy"
+	RuleCredentialFormat   = "credential-format"
+	RuleHighEntropyString  = "high-entropy-string"
+)
+
+// minComparedSecretLength keeps short values such as "true" or "8080" from matching everywhere
+const minComparedSecretLength = 8
+
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch,resources=jobs;cronjobs,verbs=get;list;watch
+
+// LeakScanner looks for credentials in plain text in pod templates and ConfigMaps
+type LeakScanner struct {
+	// Namespaces to scan, all namespaces when empty
+	Namespaces []string
+	// CompareSentinelSecrets also reports copies of the values of Sentinel managed secrets
+	CompareSentinelSecrets bool
+}
+
+// Name implements Scanner
+func (s *LeakScanner) Name() string {
+	return LeakScannerName
+}
+
+// podTemplate is a pod spec together with the object and the path it is found at
+type podTemplate struct {
+	Ref  secopsv1alpha1.FindingResourceRef
+	Path string
+	Spec *corev1.PodSpec
+}
+
+// sentinelValue is a value of a Sentinel managed secret
+type sentinelValue struct {
+	Sentinel string
+	Key      string
+	Value    string
+}
+
+// Scan implements Scanner
+func (s *LeakScanner) Scan(ctx context.Context, c client.Reader) ([]secopsv1alpha1.SentinelFindingSpec, error) {
+	namespaces := s.Namespaces
+	if len(namespaces) == 0 {
+		// An empty namespace lists all namespaces at once
+		namespaces = []string{""}
+	}
+
+	var findings []secopsv1alpha1.SentinelFindingSpec
+	for _, namespace := range namespaces {
+		templates, err := loadPodTemplates(ctx, c, namespace)
+		if err != nil {
+			return nil, err
+		}
+		configMaps := &corev1.ConfigMapList{}
+		if err := c.List(ctx, configMaps, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+
+		var values []sentinelValue
+		if s.CompareSentinelSecrets {
+			if values, err = loadSentinelValues(ctx, c, namespace); err != nil {
+				return nil, err
+			}
+		}
+
+		findings = append(findings, scanLeaks(templates, configMaps.Items, values)...)
+	}
+	return findings, nil
+}
+
+// loadPodTemplates returns the pod specs of all workloads. ReplicaSets, Jobs and Pods
+// created by a controller are skipped, their owner holds the same template.
+func loadPodTemplates(ctx context.Context, c client.Reader, namespace string) ([]podTemplate, error) {
+	var templates []podTemplate
+	add := func(apiVersion string, kind string, obj metav1.Object, path string, spec *corev1.PodSpec) {
+		templates = append(templates, podTemplate{
+			Ref: secopsv1alpha1.FindingResourceRef{APIVersion: apiVersion, Kind: kind,
+				Namespace: obj.GetNamespace(), Name: obj.GetName()},
+			Path: path,
+			Spec: spec,
+		})
+	}
+
+	deployments := &appsv1.DeploymentList{}
+	if err := c.List(ctx, deployments, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		add("apps/v1", "Deployment", &deployments.Items[i], "spec.template.spec", &deployments.Items[i].Spec.Template.Spec)
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := c.List(ctx, statefulSets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range statefulSets.Items {
+		add("apps/v1", "StatefulSet", &statefulSets.Items[i], "spec.template.spec", &statefulSets.Items[i].Spec.Template.Spec)
+	}
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := c.List(ctx, daemonSets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range daemonSets.Items {
+		add("apps/v1", "DaemonSet", &daemonSets.Items[i], "spec.template.spec", &daemonSets.Items[i].Spec.Template.Spec)
+	}
+
+	cronJobs := &batchv1.CronJobList{}
+	if err := c.List(ctx, cronJobs, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range cronJobs.Items {
+		add("batch/v1", "CronJob", &cronJobs.Items[i], "spec.jobTemplate.spec.template.spec",
+			&cronJobs.Items[i].Spec.JobTemplate.Spec.Template.Spec)
+	}
+
+	jobs := &batchv1.JobList{}
+	if err := c.List(ctx, jobs, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range jobs.Items {
+		if metav1.GetControllerOf(&jobs.Items[i]) == nil {
+			add("batch/v1", "Job", &jobs.Items[i], "spec.template.spec", &jobs.Items[i].Spec.Template.Spec)
+		}
+	}
+
+	pods := &corev1.PodList{}
+	if err := c.List(ctx, pods, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		if metav1.GetControllerOf(&pods.Items[i]) == nil {
+			add("v1", "Pod", &pods.Items[i], "spec", &pods.Items[i].Spec)
+		}
+	}
+
+	return templates, nil
+}
+
+// loadSentinelValues returns the values of the secrets managed by the Sentinels of the namespace
+func loadSentinelValues(ctx context.Context, c client.Reader, namespace string) ([]sentinelValue, error) {
+	sentinels := &secopsv1alpha1.SentinelList{}
+	if err := c.List(ctx, sentinels, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	var values []sentinelValue
+	for _, sentinel := range sentinels.Items {
+		if sentinel.Spec.SecretName == "" {
+			continue
+		}
+		secret := &corev1.Secret{}
+		err := c.Get(ctx, types.NamespacedName{Name: sentinel.Spec.SecretName, Namespace: sentinel.Namespace}, secret)
+		if err != nil && apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, key := range sortedKeys(secret.Data) {
+			value := secret.Data[key]
+			if len(value) < minComparedSecretLength {
+				continue
+			}
+			values = append(values, sentinelValue{
+				Sentinel: sentinel.Namespace + "/" + sentinel.Name,
+				Key:      key,
+				Value:    string(value),
+			})
+		}
+	}
+	return values, nil
+}
+
+// scanLeaks returns the findings of the pod templates and ConfigMaps
+func scanLeaks(templates []podTemplate, configMaps []corev1.ConfigMap, values []sentinelValue) []secopsv1alpha1.SentinelFindingSpec {
+	var findings []secopsv1alpha1.SentinelFindingSpec
+	check := func(ref secopsv1alpha1.FindingResourceRef, fieldPath string, field string, value string) {
+		ref.FieldPath = fieldPath
+		if finding, ok := detectLeak(ref, field, value, values); ok {
+			findings = append(findings, finding)
+		}
+	}
+
+	for _, template := range templates {
+		containerGroups := []struct {
+			Field      string
+			Containers []corev1.Container
+		}{
+			{Field: "initContainers", Containers: template.Spec.InitContainers},
+			{Field: "containers", Containers: template.Spec.Containers},
+		}
+		for _, group := range containerGroups {
+			for i, container := range group.Containers {
+				path := fmt.Sprintf("%s.%s[%d]", template.Path, group.Field, i)
+				for j, env := range container.Env {
+					check(template.Ref, fmt.Sprintf("%s.env[%d].value", path, j),
+						fmt.Sprintf("env %s of container %s", env.Name, container.Name), env.Value)
+				}
+				for j, arg := range container.Command {
+					check(template.Ref, fmt.Sprintf("%s.command[%d]", path, j),
+						fmt.Sprintf("command of container %s", container.Name), arg)
+				}
+				for j, arg := range container.Args {
+					check(template.Ref, fmt.Sprintf("%s.args[%d]", path, j),
+						fmt.Sprintf("args of container %s", container.Name), arg)
+				}
+			}
+		}
+	}
+
+	for _, configMap := range configMaps {
+		ref := secopsv1alpha1.FindingResourceRef{APIVersion: "v1", Kind: "ConfigMap",
+			Namespace: configMap.Namespace, Name: configMap.Name}
+		for _, key := range sortedStringKeys(configMap.Data) {
+			check(ref, fmt.Sprintf("data[%s]", key), fmt.Sprintf("key %s", key), configMap.Data[key])
+		}
+	}
+
+	return findings
+}
+
+// detectLeak reports the most severe leak in the value. The value itself is never
+// part of the finding, SentinelFindings are readable by far more subjects.
+func detectLeak(ref secopsv1alpha1.FindingResourceRef, field string, value string,
+	values []sentinelValue) (secopsv1alpha1.SentinelFindingSpec, bool) {
+
+	finding := secopsv1alpha1.SentinelFindingSpec{Resource: ref}
+	where := fmt.Sprintf("%s %s/%s", ref.Kind, ref.Namespace, ref.Name)
+
+	for _, v := range values {
+		if strings.Contains(value, v.Value) {
+			finding.Rule = RuleSentinelSecretCopy
+			finding.Severity = secopsv1alpha1.SeverityCritical
+			finding.Message = fmt.Sprintf("The %s of %s holds key %s of the secret of Sentinel %s", field, where, v.Key, v.Sentinel)
+			finding.Remediation = "Reference the Sentinel secret with secretKeyRef and rotate the exposed value"
+			return finding, true
+		}
+	}
+
+	if name, ok := matchCredentialPattern(credentialPatterns, value); ok {
+		finding.Rule = RuleCredentialFormat
+		finding.Severity = secopsv1alpha1.SeverityHigh
+		finding.Message = fmt.Sprintf("The %s of %s holds a %s", field, where, name)
+		finding.Remediation = "Move the credential to a Sentinel managed secret, reference it with secretKeyRef and rotate it"
+		return finding, true
+	}
+
+	if token, ok := highEntropyToken(value); ok {
+		finding.Rule = RuleHighEntropyString
+		finding.Severity = secopsv1alpha1.SeverityMedium
+		finding.Message = fmt.Sprintf("The %s of %s holds a random looking string of %d characters", field, where, len(token))
+		finding.Remediation = "Move the value to a Sentinel managed secret if it is a credential"
+		return finding, true
+	}
+
+	return finding, false
+}
+
+func sortedStringKeys(data map[string]string) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scanner
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+var (
+	findingsTotal = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sentinel_scanner_findings",
+		Help: "Number of findings of the latest scan by scanner, rule and severity.",
+	}, []string{"scanner", "rule", "severity"})
+
+	lastScanTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sentinel_scanner_last_scan_timestamp_seconds",
+		Help: "Time of the latest successful scan by scanner.",
+	}, []string{"scanner"})
+
+	secretsScanned = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sentinel_secret_hygiene_secrets",
+		Help: "Number of secrets inspected by the latest secret hygiene scan by namespace.",
+	}, []string{"namespace"})
+)
+
+func init() {
+	// Served on the metrics endpoint of the manager
+	metrics.Registry.MustRegister(findingsTotal, lastScanTimestamp, secretsScanned)
+}
+
+// recordFindings replaces the finding counts of the scanner with the latest scan
+func recordFindings(scanner string, findings []secopsv1alpha1.SentinelFindingSpec) {
+	findingsTotal.DeletePartialMatch(prometheus.Labels{"scanner": scanner})
+	for _, finding := range findings {
+		findingsTotal.WithLabelValues(scanner, finding.Rule, finding.Severity).Inc()
+	}
+	lastScanTimestamp.WithLabelValues(scanner).SetToCurrentTime()
+}
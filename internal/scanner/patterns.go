@@ -0,0 +1,119 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scanner
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// credentialPattern is a known credential format
+type credentialPattern struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// privateKeyPattern matches the header of PEM encoded private keys
+var privateKeyPattern = regexp.MustCompile(`-----BEGIN ([A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`)
+
+// certificatePattern matches PEM encoded certificates, they are public and not reported
+var certificatePattern = regexp.MustCompile(`(?s)-----BEGIN CERTIFICATE-----.*?-----END CERTIFICATE-----`)
+
+// cloudCredentialPatterns match the credentials of the common cloud providers
+var cloudCredentialPatterns = []credentialPattern{
+	{Name: "AWS access key", Pattern: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Name: "GCP service account key", Pattern: regexp.MustCompile(`"type"\s*:\s*"service_account"`)},
+	{Name: "Azure storage account key", Pattern: regexp.MustCompile(`AccountKey=[A-Za-z0-9+/]{40,}={0,2}`)},
+}
+
+// credentialPatterns match every known credential format, they are looked
+// for in places that should never hold a credential in plain text.
+var credentialPatterns = append([]credentialPattern{
+	{Name: "private key", Pattern: privateKeyPattern},
+	{Name: "JSON web token", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`)},
+	{Name: "connection string with password", Pattern: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]+:[^@/\s]+@`)},
+	{Name: "password in connection string", Pattern: regexp.MustCompile(`(?i)\b(password|pwd)\s*=\s*[^;\s]{4,}`)},
+}, cloudCredentialPatterns...)
+
+// matchCredentialPattern returns the name of the first pattern the value matches
+func matchCredentialPattern(patterns []credentialPattern, value string) (string, bool) {
+	for _, p := range patterns {
+		if p.Pattern.MatchString(value) {
+			return p.Name, true
+		}
+	}
+	return "", false
+}
+
+const (
+	// minEntropyTokenLength is the length a token needs to be checked for its entropy
+	minEntropyTokenLength = 20
+	// minTokenEntropy is the Shannon entropy in bits per character above which a token
+	// looks random, hex digests stay below while base64 encoded keys exceed it
+	minTokenEntropy = 4.2
+)
+
+// highEntropyToken returns the first token of the value that looks like a random key
+func highEntropyToken(value string) (string, bool) {
+	value = certificatePattern.ReplaceAllString(value, "")
+	tokens := strings.FieldsFunc(value, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("+/=_-", r))
+	})
+	for _, token := range tokens {
+		if len(token) < minEntropyTokenLength || !mixedCharacters(token) {
+			continue
+		}
+		if shannonEntropy(token) >= minTokenEntropy {
+			return token, true
+		}
+	}
+	return "", false
+}
+
+// shannonEntropy returns the entropy of the string in bits per character
+func shannonEntropy(value string) float64 {
+	counts := map[rune]int{}
+	for _, r := range value {
+		counts[r]++
+	}
+	length := float64(len([]rune(value)))
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// mixedCharacters reports whether the token holds upper case letters, lower case
+// letters and digits, which words, identifiers and paths seldom do together.
+func mixedCharacters(token string) bool {
+	var upper, lower, digit bool
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return upper && lower && digit
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/analyzer"
+)
+
+// RBACScannerName is the scanner label of the RBAC findings
+const RBACScannerName = "rbac"
+
+// Rules of the RBAC scanner
+const (
+	RuleWildcardVerbs         = "wildcard-verbs"
+	RuleWildcardResources     = "wildcard-resources"
+	RuleEscalationVerbs       = "escalation-verbs"
+	RuleClusterSecretsRead    = "cluster-secrets-read"
+	RuleAnonymousBinding      = "anonymous-binding"
+	RuleDefaultServiceAccount = "default-service-account"
+)
+
+// bootstrappingLabel marks the default roles and bindings of the API server
+const bootstrappingLabel = "kubernetes.io/bootstrapping"
+
+// escalationVerbs allow a subject to gain permissions it does not hold
+var escalationVerbs = []string{"escalate", "bind", "impersonate"}
+
+// RBACScanner checks Roles, ClusterRoles and their bindings against RBAC best practices
+type RBACScanner struct{}
+
+// Name implements Scanner
+func (s *RBACScanner) Name() string {
+	return RBACScannerName
+}
+
+// Scan implements Scanner
+func (s *RBACScanner) Scan(ctx context.Context, c client.Reader) ([]secopsv1alpha1.SentinelFindingSpec, error) {
+	snapshot, err := analyzer.Load(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return ScanRBAC(snapshot), nil
+}
+
+// ScanRBAC returns the findings of the RBAC snapshot. The default roles and
+// bindings of the API server are skipped, they cannot be changed anyway.
+func ScanRBAC(snapshot *analyzer.Snapshot) []secopsv1alpha1.SentinelFindingSpec {
+	var findings []secopsv1alpha1.SentinelFindingSpec
+
+	for _, role := range snapshot.Roles {
+		if isBootstrapped(role.Name, role.Labels) {
+			continue
+		}
+		ref := secopsv1alpha1.FindingResourceRef{APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind: "Role", Namespace: role.Namespace, Name: role.Name}
+		findings = append(findings, ruleFindings(ref, role.Rules, secopsv1alpha1.SeverityMedium)...)
+	}
+
+	for _, clusterRole := range snapshot.ClusterRoles {
+		if isBootstrapped(clusterRole.Name, clusterRole.Labels) {
+			continue
+		}
+		// Only the own rules, aggregated rules are reported on the role that holds them
+		ref := secopsv1alpha1.FindingResourceRef{APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind: "ClusterRole", Name: clusterRole.Name}
+		findings = append(findings, ruleFindings(ref, clusterRole.Rules, secopsv1alpha1.SeverityHigh)...)
+	}
+
+	for _, binding := range snapshot.ClusterRoleBindings {
+		if isBootstrapped(binding.Name, binding.Labels) {
+			continue
+		}
+		ref := secopsv1alpha1.FindingResourceRef{APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind: "ClusterRoleBinding", Name: binding.Name}
+
+		if binding.RoleRef.Kind == "ClusterRole" {
+			verbs := clusterWideSecretVerbs(snapshot.ClusterRoleRules(binding.RoleRef.Name))
+			if len(verbs) > 0 {
+				findings = append(findings, secopsv1alpha1.SentinelFindingSpec{
+					Rule:     RuleClusterSecretsRead,
+					Severity: secopsv1alpha1.SeverityHigh,
+					Resource: ref,
+					Message: fmt.Sprintf("ClusterRole %s grants %s on secrets in all namespaces to %s",
+						binding.RoleRef.Name, strings.Join(verbs, ", "), subjectNames(binding.Subjects, "")),
+					Remediation: "Bind the role with RoleBindings in the namespaces that need it, or restrict it to named secrets",
+				})
+			}
+		}
+		findings = append(findings, subjectFindings(ref, binding.RoleRef, binding.Subjects, "")...)
+	}
+
+	for _, binding := range snapshot.RoleBindings {
+		if isBootstrapped(binding.Name, binding.Labels) {
+			continue
+		}
+		ref := secopsv1alpha1.FindingResourceRef{APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind: "RoleBinding", Namespace: binding.Namespace, Name: binding.Name}
+		findings = append(findings, subjectFindings(ref, binding.RoleRef, binding.Subjects, binding.Namespace)...)
+	}
+
+	return findings
+}
+
+// ruleFindings checks the policy rules of a Role or ClusterRole
+func ruleFindings(ref secopsv1alpha1.FindingResourceRef, rules []rbacv1.PolicyRule, severity string) []secopsv1alpha1.SentinelFindingSpec {
+	var findings []secopsv1alpha1.SentinelFindingSpec
+	var wildcardVerbs, wildcardResources bool
+	var escalation []string
+
+	for _, rule := range rules {
+		// Non-resource URLs are not about objects
+		if len(rule.Resources) == 0 {
+			continue
+		}
+		if contains(rule.Verbs, rbacv1.VerbAll) {
+			wildcardVerbs = true
+		}
+		if contains(rule.Resources, rbacv1.ResourceAll) {
+			wildcardResources = true
+		}
+		for _, verb := range escalationVerbs {
+			if contains(rule.Verbs, verb) && !contains(escalation, verb) {
+				escalation = append(escalation, verb)
+			}
+		}
+	}
+
+	if wildcardVerbs {
+		findings = append(findings, secopsv1alpha1.SentinelFindingSpec{
+			Rule:        RuleWildcardVerbs,
+			Severity:    severity,
+			Resource:    ref,
+			Message:     fmt.Sprintf("%s %s allows all verbs through a wildcard", ref.Kind, ref.Name),
+			Remediation: "List the verbs the subjects need explicitly",
+		})
+	}
+	if wildcardResources {
+		findings = append(findings, secopsv1alpha1.SentinelFindingSpec{
+			Rule:        RuleWildcardResources,
+			Severity:    severity,
+			Resource:    ref,
+			Message:     fmt.Sprintf("%s %s allows all resources through a wildcard", ref.Kind, ref.Name),
+			Remediation: "List the resources the subjects need explicitly",
+		})
+	}
+	if len(escalation) > 0 {
+		findings = append(findings, secopsv1alpha1.SentinelFindingSpec{
+			Rule:     RuleEscalationVerbs,
+			Severity: secopsv1alpha1.SeverityHigh,
+			Resource: ref,
+			Message: fmt.Sprintf("%s %s allows the privilege escalation verbs %s",
+				ref.Kind, ref.Name, strings.Join(escalation, ", ")),
+			Remediation: "Remove the verbs or restrict them with resourceNames",
+		})
+	}
+	return findings
+}
+
+// subjectFindings checks the subjects of a RoleBinding or ClusterRoleBinding
+func subjectFindings(ref secopsv1alpha1.FindingResourceRef, roleRef rbacv1.RoleRef,
+	subjects []rbacv1.Subject, bindingNamespace string) []secopsv1alpha1.SentinelFindingSpec {
+
+	var findings []secopsv1alpha1.SentinelFindingSpec
+	for i, subject := range subjects {
+		// Every subject is a finding of its own
+		ref := ref
+		ref.FieldPath = fmt.Sprintf("subjects[%d]", i)
+		switch {
+		case subject.Kind == rbacv1.UserKind && subject.Name == "system:anonymous",
+			subject.Kind == rbacv1.GroupKind && subject.Name == "system:unauthenticated":
+			findings = append(findings, secopsv1alpha1.SentinelFindingSpec{
+				Rule:        RuleAnonymousBinding,
+				Severity:    secopsv1alpha1.SeverityCritical,
+				Resource:    ref,
+				Message:     fmt.Sprintf("%s %s binds %s %s to unauthenticated requests", ref.Kind, ref.Name, roleRef.Kind, roleRef.Name),
+				Remediation: "Remove the anonymous subject from the binding",
+			})
+		case subject.Kind == rbacv1.ServiceAccountKind && subject.Name == "default":
+			namespace := subject.Namespace
+			if namespace == "" {
+				namespace = bindingNamespace
+			}
+			findings = append(findings, secopsv1alpha1.SentinelFindingSpec{
+				Rule:     RuleDefaultServiceAccount,
+				Severity: secopsv1alpha1.SeverityMedium,
+				Resource: ref,
+				Message: fmt.Sprintf("%s %s binds %s %s to the default ServiceAccount of namespace %s",
+					ref.Kind, ref.Name, roleRef.Kind, roleRef.Name, namespace),
+				Remediation: "Create a dedicated ServiceAccount for the workload and bind the role to it",
+			})
+		}
+	}
+	return findings
+}
+
+// clusterWideSecretVerbs returns the list and watch verbs the rules allow on all secrets
+func clusterWideSecretVerbs(rules []rbacv1.PolicyRule) []string {
+	var unrestricted []rbacv1.PolicyRule
+	for _, rule := range rules {
+		if len(rule.ResourceNames) == 0 {
+			unrestricted = append(unrestricted, rule)
+		}
+	}
+
+	var verbs []string
+	for _, verb := range analyzer.SecretVerbs(unrestricted, "") {
+		if verb == "list" || verb == "watch" {
+			verbs = append(verbs, verb)
+		}
+	}
+	return verbs
+}
+
+func isBootstrapped(name string, labels map[string]string) bool {
+	return labels[bootstrappingLabel] == "rbac-defaults" || strings.HasPrefix(name, "system:")
+}
+
+func subjectNames(subjects []rbacv1.Subject, bindingNamespace string) string {
+	names := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		name := subject.Name
+		if subject.Kind == rbacv1.ServiceAccountKind {
+			namespace := subject.Namespace
+			if namespace == "" {
+				namespace = bindingNamespace
+			}
+			name = fmt.Sprintf("%s/%s", namespace, subject.Name)
+		}
+		names = append(names, fmt.Sprintf("%s %s", subject.Kind, name))
+	}
+	return strings.Join(names, ", ")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
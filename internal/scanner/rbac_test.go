@@ -0,0 +1,115 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scanner
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/analyzer"
+)
+
+func TestScanRBAC(t *testing.T) {
+	clusterRoles := []rbacv1.ClusterRole{
+		{
+			// Default roles are skipped
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin", Labels: map[string]string{bootstrappingLabel: "rbac-defaults"}},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-cluster-role"},
+			Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"},
+				Verbs: []string{"get", "list", "watch", "create", "update", "delete"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "role-binder"},
+			Rules: []rbacv1.PolicyRule{{APIGroups: []string{"rbac.authorization.k8s.io"},
+				Resources: []string{"clusterroles"}, Verbs: []string{"bind", "escalate"}}},
+		},
+	}
+	roles := []rbacv1.Role{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "everything", Namespace: "dev"},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"apps"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-reader", Namespace: "prod"},
+			Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"},
+				ResourceNames: []string{"db"}, Verbs: []string{"get"}}},
+		},
+	}
+	clusterRoleBindings := []rbacv1.ClusterRoleBinding{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-crb"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "api-cluster-role"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "default", Namespace: "default"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "public"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "role-binder"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "system:unauthenticated"}},
+		},
+	}
+	roleBindings := []rbacv1.RoleBinding{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "prod"},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "db-reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "app"}},
+		},
+	}
+
+	findings := ScanRBAC(analyzer.NewSnapshot(roles, clusterRoles, roleBindings, clusterRoleBindings))
+
+	var got []string
+	for _, finding := range findings {
+		got = append(got, finding.Rule+" "+finding.Severity+" "+finding.Resource.Kind+"/"+finding.Resource.Name)
+	}
+	sort.Strings(got)
+
+	want := []string{
+		RuleAnonymousBinding + " " + secopsv1alpha1.SeverityCritical + " ClusterRoleBinding/public",
+		RuleClusterSecretsRead + " " + secopsv1alpha1.SeverityHigh + " ClusterRoleBinding/api-crb",
+		RuleDefaultServiceAccount + " " + secopsv1alpha1.SeverityMedium + " ClusterRoleBinding/api-crb",
+		RuleEscalationVerbs + " " + secopsv1alpha1.SeverityHigh + " ClusterRole/role-binder",
+		RuleWildcardResources + " " + secopsv1alpha1.SeverityMedium + " Role/everything",
+		RuleWildcardVerbs + " " + secopsv1alpha1.SeverityMedium + " Role/everything",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ScanRBAC() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestFindingName(t *testing.T) {
+	finding := secopsv1alpha1.SentinelFindingSpec{
+		Scanner:  RBACScannerName,
+		Rule:     RuleWildcardVerbs,
+		Resource: secopsv1alpha1.FindingResourceRef{Kind: "Role", Namespace: "dev", Name: "everything"},
+	}
+	name := FindingName(finding)
+	if name != FindingName(finding) {
+		t.Fatalf("FindingName() is not stable")
+	}
+	if len(name) != len("rbac-wildcard-verbs-")+8 {
+		t.Fatalf("FindingName() = %s", name)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scanner runs periodic security checks against the cluster and
+// records their results as SentinelFindings.
+package scanner
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/notify"
+)
+
+// defaultInterval is used when the Runner has no Interval
+const defaultInterval = time.Hour
+
+// Scanner reports the findings of one area of the cluster
+type Scanner interface {
+	// Name is stored in the scanner label of the findings
+	Name() string
+	Scan(ctx context.Context, c client.Reader) ([]secopsv1alpha1.SentinelFindingSpec, error)
+}
+
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelfindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=secops.kavinduxo.com,resources=sentinelfindings/status,verbs=get;update;patch
+
+// Runner runs the scanners periodically, it is added to the manager as a Runnable
+type Runner struct {
+	Client client.Client
+	// Reader is used by the scanners to read the cluster, an uncached reader avoids
+	// keeping every Secret of the cluster in memory. It defaults to the Client.
+	Reader   client.Reader
+	Scanners []Scanner
+	// Interval between two scans
+	Interval time.Duration
+	// Notifier is optional and receives the findings of expiring certificates
+	Notifier notify.Notifier
+}
+
+// Start implements manager.Runnable
+func (r *Runner) Start(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.scan(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, only the leader writes findings
+func (r *Runner) NeedLeaderElection() bool {
+	return true
+}
+
+func (r *Runner) scan(ctx context.Context) {
+	log := log.FromContext(ctx).WithName("scanner")
+
+	reader := r.Reader
+	if reader == nil {
+		reader = r.Client
+	}
+
+	for _, s := range r.Scanners {
+		findings, err := s.Scan(ctx, reader)
+		if err != nil {
+			// Keep the findings of the previous scan rather than dropping them
+			log.Error(err, "Scan failed", "scanner", s.Name())
+			continue
+		}
+		if err := SyncFindings(ctx, r.Client, s.Name(), findings); err != nil {
+			log.Error(err, "Failed to record findings", "scanner", s.Name())
+			continue
+		}
+		recordFindings(s.Name(), findings)
+		r.notifyFindings(ctx, findings)
+		log.Info("Scan finished", "scanner", s.Name(), "findings", len(findings))
+	}
+}
+
+// notifyFindings sends the expiring certificates, the notifier suppresses the repeats of
+// the following scans
+func (r *Runner) notifyFindings(ctx context.Context, findings []secopsv1alpha1.SentinelFindingSpec) {
+	if r.Notifier == nil {
+		return
+	}
+	for _, finding := range findings {
+		if finding.Rule != RuleTLSExpiry {
+			continue
+		}
+		if err := r.Notifier.Notify(ctx, notify.Notification{
+			Type:      notify.TypeCertificateExpiring,
+			Namespace: finding.Resource.Namespace,
+			Message:   finding.Message,
+			Details: map[string]string{
+				"secret":      finding.Resource.Name,
+				"severity":    finding.Severity,
+				"remediation": finding.Remediation,
+			},
+			Time: time.Now(),
+		}); err != nil {
+			log.FromContext(ctx).Error(err, "Notification Failed.", "type", notify.TypeCertificateExpiring)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scanner
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// SecretHygieneScannerName is the scanner label of the secret hygiene findings
+const SecretHygieneScannerName = "secret-hygiene"
+
+// Rules of the secret hygiene scanner
+const (
+	RuleUnusedSecret           = "unused-secret"
+	RuleLegacyTokenSecret      = "legacy-token-secret"
+	RulePrivateKeyInOpaque     = "private-key-in-opaque"
+	RuleCloudCredentialsOpaque = "cloud-credentials-in-opaque"
+	RuleTLSExpiry              = "tls-expiry"
+	RuleUnmanagedSecret        = "unmanaged-secret"
+)
+
+// defaultTLSExpiryThreshold is used when the scanner has no TLSExpiryThreshold
+const defaultTLSExpiryThreshold = 30 * 24 * time.Hour
+
+// helmReleaseSecretType is the type of the secrets Helm stores releases in
+const helmReleaseSecretType corev1.SecretType = "helm.sh/release.v1"
+
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch
+
+// SecretHygieneScanner inspects every Secret of the watched namespaces
+type SecretHygieneScanner struct {
+	// Namespaces to scan, all namespaces when empty
+	Namespaces []string
+	// TLSExpiryThreshold is the time before expiry a TLS certificate is reported
+	TLSExpiryThreshold time.Duration
+}
+
+// Name implements Scanner
+func (s *SecretHygieneScanner) Name() string {
+	return SecretHygieneScannerName
+}
+
+// secretInventory holds the objects of one namespace a secret can be used by
+type secretInventory struct {
+	Secrets         []corev1.Secret
+	Pods            []corev1.Pod
+	ServiceAccounts []corev1.ServiceAccount
+	Ingresses       []networkingv1.Ingress
+	Sentinels       []secopsv1alpha1.Sentinel
+}
+
+// Scan implements Scanner
+func (s *SecretHygieneScanner) Scan(ctx context.Context, c client.Reader) ([]secopsv1alpha1.SentinelFindingSpec, error) {
+	namespaces := s.Namespaces
+	if len(namespaces) == 0 {
+		// An empty namespace lists all namespaces at once
+		namespaces = []string{""}
+	}
+
+	secretsScanned.Reset()
+
+	var findings []secopsv1alpha1.SentinelFindingSpec
+	for _, namespace := range namespaces {
+		inventory, err := loadSecretInventory(ctx, c, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range inventory.Secrets {
+			secretsScanned.WithLabelValues(secret.Namespace).Inc()
+		}
+		findings = append(findings, s.scanSecrets(inventory, time.Now())...)
+	}
+	return findings, nil
+}
+
+func loadSecretInventory(ctx context.Context, c client.Reader, namespace string) (*secretInventory, error) {
+	secrets := &corev1.SecretList{}
+	if err := c.List(ctx, secrets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	pods := &corev1.PodList{}
+	if err := c.List(ctx, pods, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	serviceAccounts := &corev1.ServiceAccountList{}
+	if err := c.List(ctx, serviceAccounts, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	ingresses := &networkingv1.IngressList{}
+	if err := c.List(ctx, ingresses, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	sentinels := &secopsv1alpha1.SentinelList{}
+	if err := c.List(ctx, sentinels, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	return &secretInventory{
+		Secrets:         secrets.Items,
+		Pods:            pods.Items,
+		ServiceAccounts: serviceAccounts.Items,
+		Ingresses:       ingresses.Items,
+		Sentinels:       sentinels.Items,
+	}, nil
+}
+
+// scanSecrets returns the findings of the secrets in the inventory at the given time
+func (s *SecretHygieneScanner) scanSecrets(inventory *secretInventory, now time.Time) []secopsv1alpha1.SentinelFindingSpec {
+	used := usedSecrets(inventory)
+	managed := map[string]bool{}
+	for _, sentinel := range inventory.Sentinels {
+		managed[sentinel.Namespace+"/"+sentinel.Spec.SecretName] = true
+	}
+
+	threshold := s.TLSExpiryThreshold
+	if threshold <= 0 {
+		threshold = defaultTLSExpiryThreshold
+	}
+
+	var findings []secopsv1alpha1.SentinelFindingSpec
+	for i := range inventory.Secrets {
+		secret := &inventory.Secrets[i]
+		key := secret.Namespace + "/" + secret.Name
+		ref := secopsv1alpha1.FindingResourceRef{APIVersion: "v1", Kind: "Secret", Namespace: secret.Namespace, Name: secret.Name}
+		finding := func(rule string, severity string, message string, remediation string) {
+			findings = append(findings, secopsv1alpha1.SentinelFindingSpec{
+				Rule:        rule,
+				Severity:    severity,
+				Resource:    ref,
+				Message:     message,
+				Remediation: remediation,
+			})
+		}
+
+		switch secret.Type {
+		case corev1.SecretTypeServiceAccountToken:
+			finding(RuleLegacyTokenSecret, secopsv1alpha1.SeverityMedium,
+				fmt.Sprintf("Secret %s holds a long-lived token of ServiceAccount %s",
+					key, secret.Annotations[corev1.ServiceAccountNameKey]),
+				"Use short-lived projected or TokenRequest tokens and delete the secret")
+			// Legacy tokens are neither mounted by name nor managed by a Sentinel
+			continue
+		case helmReleaseSecretType, corev1.SecretTypeBootstrapToken:
+			continue
+		case corev1.SecretTypeTLS:
+			if notAfter, ok := certificateNotAfter(secret.Data[corev1.TLSCertKey]); ok {
+				if !notAfter.After(now) {
+					finding(RuleTLSExpiry, secopsv1alpha1.SeverityHigh,
+						fmt.Sprintf("The certificate of secret %s expired at %s", key, notAfter.UTC().Format(time.RFC3339)),
+						"Renew the certificate")
+				} else if notAfter.Sub(now) < threshold {
+					finding(RuleTLSExpiry, secopsv1alpha1.SeverityMedium,
+						fmt.Sprintf("The certificate of secret %s expires at %s", key, notAfter.UTC().Format(time.RFC3339)),
+						"Renew the certificate before it expires")
+				}
+			}
+		case corev1.SecretTypeOpaque, "":
+			// Sorted, so that the message and the finding name are stable between scans
+			fields := sortedKeys(secret.Data)
+			for _, field := range fields {
+				if privateKeyPattern.Match(secret.Data[field]) {
+					finding(RulePrivateKeyInOpaque, secopsv1alpha1.SeverityHigh,
+						fmt.Sprintf("Opaque secret %s holds a private key in %s", key, field),
+						"Store key material in a kubernetes.io/tls or Sentinel managed secret")
+					break
+				}
+			}
+			for _, field := range fields {
+				if name, ok := matchCredentialPattern(cloudCredentialPatterns, string(secret.Data[field])); ok {
+					finding(RuleCloudCredentialsOpaque, secopsv1alpha1.SeverityHigh,
+						fmt.Sprintf("Opaque secret %s holds a %s in %s", key, name, field),
+						"Prefer workload identity over static cloud credentials or manage the secret with a Sentinel")
+					break
+				}
+			}
+		}
+
+		if !used[key] {
+			finding(RuleUnusedSecret, secopsv1alpha1.SeverityLow,
+				fmt.Sprintf("Secret %s is not used by any pod, ServiceAccount or Ingress", key),
+				"Delete the secret if it is no longer needed")
+		}
+		if !managed[key] && !isControlledBySentinel(secret) {
+			finding(RuleUnmanagedSecret, secopsv1alpha1.SeverityLow,
+				fmt.Sprintf("Secret %s is not managed by a Sentinel", key),
+				"Create a Sentinel for the secret")
+		}
+	}
+	return findings
+}
+
+// usedSecrets returns the namespace/name keys of every secret referenced
+// by a pod, a ServiceAccount or the TLS section of an Ingress.
+func usedSecrets(inventory *secretInventory) map[string]bool {
+	used := map[string]bool{}
+	use := func(namespace string, name string) {
+		if name != "" {
+			used[namespace+"/"+name] = true
+		}
+	}
+
+	for _, pod := range inventory.Pods {
+		for _, ref := range pod.Spec.ImagePullSecrets {
+			use(pod.Namespace, ref.Name)
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.Secret != nil {
+				use(pod.Namespace, volume.Secret.SecretName)
+			}
+			if volume.Projected != nil {
+				for _, source := range volume.Projected.Sources {
+					if source.Secret != nil {
+						use(pod.Namespace, source.Secret.Name)
+					}
+				}
+			}
+		}
+		forEachContainer(&pod.Spec, func(container *corev1.Container) {
+			for _, env := range container.Env {
+				if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+					use(pod.Namespace, env.ValueFrom.SecretKeyRef.Name)
+				}
+			}
+			for _, envFrom := range container.EnvFrom {
+				if envFrom.SecretRef != nil {
+					use(pod.Namespace, envFrom.SecretRef.Name)
+				}
+			}
+		})
+	}
+
+	for _, sa := range inventory.ServiceAccounts {
+		for _, ref := range sa.Secrets {
+			use(sa.Namespace, ref.Name)
+		}
+		for _, ref := range sa.ImagePullSecrets {
+			use(sa.Namespace, ref.Name)
+		}
+	}
+
+	for _, ingress := range inventory.Ingresses {
+		for _, tls := range ingress.Spec.TLS {
+			use(ingress.Namespace, tls.SecretName)
+		}
+	}
+	return used
+}
+
+// forEachContainer calls fn for every init, regular and ephemeral container of the pod
+func forEachContainer(spec *corev1.PodSpec, fn func(container *corev1.Container)) {
+	for i := range spec.InitContainers {
+		fn(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		fn(&spec.Containers[i])
+	}
+	for i := range spec.EphemeralContainers {
+		fn((*corev1.Container)(&spec.EphemeralContainers[i].EphemeralContainerCommon))
+	}
+}
+
+// certificateNotAfter returns the expiry of the first certificate of the PEM data
+func certificateNotAfter(data []byte) (time.Time, bool) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return time.Time{}, false
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return cert.NotAfter, true
+	}
+}
+
+func sortedKeys(data map[string][]byte) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isControlledBySentinel(secret *corev1.Secret) bool {
+	for _, ref := range secret.OwnerReferences {
+		if ref.Kind == "Sentinel" && ref.APIVersion == secopsv1alpha1.GroupVersion.String() {
+			return true
+		}
+	}
+	return false
+}
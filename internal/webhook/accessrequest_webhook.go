@@ -0,0 +1,149 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook contains the admission webhooks served by the sentinel operator.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// AccessRequestPath is the path the SentinelAccessRequest webhook is served on
+const AccessRequestPath = "/mutate-secops-kavinduxo-com-v1alpha1-sentinelaccessrequest"
+
+//+kubebuilder:webhook:path=/mutate-secops-kavinduxo-com-v1alpha1-sentinelaccessrequest,mutating=true,failurePolicy=fail,sideEffects=None,groups=secops.kavinduxo.com,resources=sentinelaccessrequests,verbs=create;update,versions=v1alpha1,name=msentinelaccessrequest.kb.io,admissionReviewVersions=v1
+
+// AccessRequestApprover stamps the requesting and the approving user on a SentinelAccessRequest.
+// The user names are taken from the admission request, so they can not be forged by the client,
+// and an approval is only accepted from an approver of the Sentinel that is not the requester.
+type AccessRequestApprover struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+// NewAccessRequestApprover returns the webhook handler for SentinelAccessRequests
+func NewAccessRequestApprover(c client.Client, scheme *runtime.Scheme) *AccessRequestApprover {
+	return &AccessRequestApprover{Client: c, decoder: admission.NewDecoder(scheme)}
+}
+
+// Handle implements admission.Handler
+func (a *AccessRequestApprover) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := log.FromContext(ctx)
+
+	accessRequest := &secopsv1alpha1.SentinelAccessRequest{}
+	if err := a.decoder.Decode(req, accessRequest); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if accessRequest.Annotations == nil {
+		accessRequest.Annotations = map[string]string{}
+	}
+	annotations := accessRequest.Annotations
+
+	switch req.Operation {
+	case admissionv1.Create:
+		// A new request always starts undecided and belongs to the user creating it
+		annotations[secopsv1alpha1.AccessRequestRequestedByAnnotation] = req.UserInfo.Username
+		delete(annotations, secopsv1alpha1.AccessRequestApprovalAnnotation)
+		delete(annotations, secopsv1alpha1.AccessRequestApprovedByAnnotation)
+
+	case admissionv1.Update:
+		oldRequest := &secopsv1alpha1.SentinelAccessRequest{}
+		if err := a.decoder.DecodeRaw(req.OldObject, oldRequest); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		oldAnnotations := oldRequest.GetAnnotations()
+		oldDecision := oldAnnotations[secopsv1alpha1.AccessRequestApprovalAnnotation]
+		newDecision := annotations[secopsv1alpha1.AccessRequestApprovalAnnotation]
+
+		if annotations[secopsv1alpha1.AccessRequestRequestedByAnnotation] != oldAnnotations[secopsv1alpha1.AccessRequestRequestedByAnnotation] {
+			return admission.Denied(fmt.Sprintf("%s can not be changed", secopsv1alpha1.AccessRequestRequestedByAnnotation))
+		}
+
+		if oldDecision != "" && !equality.Semantic.DeepEqual(oldRequest.Spec, accessRequest.Spec) {
+			return admission.Denied("the spec of a decided access request can not be changed")
+		}
+
+		if newDecision == oldDecision {
+			if annotations[secopsv1alpha1.AccessRequestApprovedByAnnotation] != oldAnnotations[secopsv1alpha1.AccessRequestApprovedByAnnotation] {
+				return admission.Denied(fmt.Sprintf("%s can not be changed", secopsv1alpha1.AccessRequestApprovedByAnnotation))
+			}
+			break
+		}
+
+		if oldDecision != "" {
+			return admission.Denied(fmt.Sprintf("access request was already %s", oldDecision))
+		}
+		if newDecision != secopsv1alpha1.AccessRequestApproved && newDecision != secopsv1alpha1.AccessRequestDenied {
+			return admission.Denied(fmt.Sprintf("%s must be %q or %q", secopsv1alpha1.AccessRequestApprovalAnnotation,
+				secopsv1alpha1.AccessRequestApproved, secopsv1alpha1.AccessRequestDenied))
+		}
+
+		// Separation of duties, nobody decides on their own request
+		if req.UserInfo.Username == oldAnnotations[secopsv1alpha1.AccessRequestRequestedByAnnotation] {
+			return admission.Denied("the requester of an access request can not decide on it")
+		}
+
+		sentinel := &secopsv1alpha1.Sentinel{}
+		if err := a.Client.Get(ctx, types.NamespacedName{Name: accessRequest.Spec.SentinelName, Namespace: req.Namespace}, sentinel); err != nil {
+			log.Error(err, "Sentinel of the access request not found!")
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if !IsApprover(sentinel, req.UserInfo) {
+			return admission.Denied(fmt.Sprintf("%s is not an approver of Sentinel %s", req.UserInfo.Username, sentinel.Name))
+		}
+
+		annotations[secopsv1alpha1.AccessRequestApprovedByAnnotation] = req.UserInfo.Username
+		log.Info("Access request decided", "SentinelAccessRequest.Name", accessRequest.Name,
+			"decision", newDecision, "approver", req.UserInfo.Username)
+	}
+
+	marshaled, err := json.Marshal(accessRequest)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// IsApprover reports whether the user is listed in the approvers or approver groups of the Sentinel
+func IsApprover(sentinel *secopsv1alpha1.Sentinel, user authenticationv1.UserInfo) bool {
+	for _, approver := range sentinel.Spec.Approvers {
+		if approver == user.Username {
+			return true
+		}
+	}
+	for _, approverGroup := range sentinel.Spec.ApproverGroups {
+		for _, group := range user.Groups {
+			if approverGroup == group {
+				return true
+			}
+		}
+	}
+	return false
+}
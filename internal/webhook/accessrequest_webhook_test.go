@@ -0,0 +1,141 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+func newAccessRequestApprover(t *testing.T) *AccessRequestApprover {
+	scheme := runtime.NewScheme()
+	if err := secopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	sentinel := &secopsv1alpha1.Sentinel{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod"},
+		Spec: secopsv1alpha1.SentinelSpec{
+			SecretName:     "db-credentials",
+			SecretType:     "RbacSecuredSecret",
+			Approvers:      []string{"alice"},
+			ApproverGroups: []string{"security"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sentinel).Build()
+	return NewAccessRequestApprover(c, scheme)
+}
+
+func accessRequestReview(t *testing.T, op admissionv1.Operation, user authenticationv1.UserInfo,
+	oldAnnotations map[string]string, newAnnotations map[string]string) admission.Request {
+
+	raw := func(annotations map[string]string) runtime.RawExtension {
+		accessRequest := &secopsv1alpha1.SentinelAccessRequest{
+			TypeMeta:   metav1.TypeMeta{APIVersion: secopsv1alpha1.GroupVersion.String(), Kind: "SentinelAccessRequest"},
+			ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "prod", Annotations: annotations},
+			Spec:       secopsv1alpha1.SentinelAccessRequestSpec{SentinelName: "db"},
+		}
+		b, err := json.Marshal(accessRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return runtime.RawExtension{Raw: b}
+	}
+
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: op,
+		Namespace: "prod",
+		UserInfo:  user,
+		Object:    raw(newAnnotations),
+	}}
+	if op == admissionv1.Update {
+		req.OldObject = raw(oldAnnotations)
+	}
+	return req
+}
+
+func TestAccessRequestCreateRecordsRequester(t *testing.T) {
+	a := newAccessRequestApprover(t)
+	resp := a.Handle(context.Background(), accessRequestReview(t, admissionv1.Create,
+		authenticationv1.UserInfo{Username: "bob"}, nil,
+		map[string]string{
+			secopsv1alpha1.AccessRequestRequestedByAnnotation: "alice",
+			secopsv1alpha1.AccessRequestApprovalAnnotation:    secopsv1alpha1.AccessRequestApproved,
+		}))
+	if !resp.Allowed {
+		t.Fatalf("create denied: %v", resp.Result)
+	}
+	var requestedBy, approval bool
+	for _, patch := range resp.Patches {
+		switch patch.Path {
+		case "/metadata/annotations/secops.kavinduxo.com~1requested-by":
+			requestedBy = patch.Value == "bob"
+		case "/metadata/annotations/secops.kavinduxo.com~1approval":
+			approval = patch.Operation == "remove"
+		}
+	}
+	if !requestedBy || !approval {
+		t.Fatalf("unexpected patches: %+v", resp.Patches)
+	}
+}
+
+func TestAccessRequestApproval(t *testing.T) {
+	requested := map[string]string{secopsv1alpha1.AccessRequestRequestedByAnnotation: "bob"}
+	approved := map[string]string{
+		secopsv1alpha1.AccessRequestRequestedByAnnotation: "bob",
+		secopsv1alpha1.AccessRequestApprovalAnnotation:    secopsv1alpha1.AccessRequestApproved,
+	}
+
+	tests := []struct {
+		name    string
+		user    authenticationv1.UserInfo
+		old     map[string]string
+		new     map[string]string
+		allowed bool
+	}{
+		{"approver", authenticationv1.UserInfo{Username: "alice"}, requested, approved, true},
+		{"approver group", authenticationv1.UserInfo{Username: "carol", Groups: []string{"security"}}, requested, approved, true},
+		{"not an approver", authenticationv1.UserInfo{Username: "mallory"}, requested, approved, false},
+		{"requester", authenticationv1.UserInfo{Username: "bob", Groups: []string{"security"}}, requested, approved, false},
+		{"forged approver", authenticationv1.UserInfo{Username: "bob"}, requested, map[string]string{
+			secopsv1alpha1.AccessRequestRequestedByAnnotation: "bob",
+			secopsv1alpha1.AccessRequestApprovedByAnnotation:  "alice",
+		}, false},
+		{"changed requester", authenticationv1.UserInfo{Username: "alice"}, requested, map[string]string{
+			secopsv1alpha1.AccessRequestRequestedByAnnotation: "alice",
+		}, false},
+	}
+
+	a := newAccessRequestApprover(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := a.Handle(context.Background(), accessRequestReview(t, admissionv1.Update, tt.user, tt.old, tt.new))
+			if resp.Allowed != tt.allowed {
+				t.Fatalf("allowed = %v, want %v: %v", resp.Allowed, tt.allowed, resp.Result)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+// PodInjectorPath is the path the pod injection webhook is served on
+const PodInjectorPath = "/mutate-v1-pod"
+
+// Names and paths of the injected volumes and containers
+const (
+	injectedSecretVolume    = "sentinel-secret"
+	injectedSealedVolume    = "sentinel-sealed"
+	injectedMasterKeyVolume = "sentinel-master-key"
+	decryptContainerName    = "sentinel-decrypt"
+	agentContainerName      = "sentinel-agent"
+	agentInitContainerName  = "sentinel-agent-init"
+	defaultInjectPath       = "/var/run/secrets/sentinel"
+	sealedMountPath         = "/etc/sentinel/sealed"
+	masterKeyMountPath      = "/etc/sentinel/master"
+	decryptOutputPath       = "/run/sentinel/secrets"
+)
+
+// The webhook ignores failures, pods without the inject annotation must never be blocked by the operator.
+// config/webhook limits it to the pods with the inject label outside of kube-system and the namespace
+// of the operator.
+//+kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=mpod.sentinel.kb.io,admissionReviewVersions=v1
+
+// PodInjector injects the secret of the Sentinel named by the inject annotation into a pod.
+// The ServiceAccount of the pod has to be the ServiceAccount of the Sentinel or a subject of
+// its RoleBinding, so that only the workloads the Sentinel grants access consume the secret.
+type PodInjector struct {
+	Client client.Client
+	// DecryptImage is the sentinel-decrypt image of the init container that opens sealed secrets
+	DecryptImage string
+	// AgentImage is the sentinel-agent image of the sidecar that refreshes the secret files
+	AgentImage string
+	decoder    *admission.Decoder
+}
+
+// NewPodInjector returns the webhook handler for pods
+func NewPodInjector(c client.Client, scheme *runtime.Scheme, decryptImage, agentImage string) *PodInjector {
+	return &PodInjector{Client: c, DecryptImage: decryptImage, AgentImage: agentImage, decoder: admission.NewDecoder(scheme)}
+}
+
+// Handle implements admission.Handler
+func (p *PodInjector) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := log.FromContext(ctx)
+
+	pod := &corev1.Pod{}
+	if err := p.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	name := pod.Annotations[secopsv1alpha1.InjectAnnotation]
+	if name == "" || pod.Labels[secopsv1alpha1.InjectLabel] != "true" {
+		return admission.Allowed("no injection requested")
+	}
+	if pod.Annotations[secopsv1alpha1.InjectedAnnotation] == name {
+		return admission.Allowed("already injected")
+	}
+
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := p.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: req.Namespace}, sentinel); err != nil {
+		if apierrors.IsNotFound(err) {
+			return admission.Denied(fmt.Sprintf("Sentinel %s not found in namespace %s", name, req.Namespace))
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	serviceAccount := pod.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	if err := p.authorizeServiceAccount(ctx, sentinel, serviceAccount); err != nil {
+		log.Info("Secret injection denied", "Sentinel.Name", name, "ServiceAccount", serviceAccount, "reason", err.Error())
+		return admission.Denied(err.Error())
+	}
+
+	var err error
+	switch mode := pod.Annotations[secopsv1alpha1.InjectModeAnnotation]; mode {
+	case "", secopsv1alpha1.InjectModeVolume:
+		err = p.injectVolume(pod, sentinel)
+	case secopsv1alpha1.InjectModeEnv:
+		err = injectEnv(pod, sentinel)
+	case secopsv1alpha1.InjectModeAgent:
+		err = p.injectAgent(pod, sentinel)
+	default:
+		err = fmt.Errorf("Unknown inject mode %s", mode)
+	}
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	pod.Annotations[secopsv1alpha1.InjectedAnnotation] = name
+
+	marshaled, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	log.Info("Secret injected", "Sentinel.Name", name, "Pod.GenerateName", pod.GenerateName, "Pod.Name", pod.Name)
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// authorizeServiceAccount accepts the ServiceAccount of the Sentinel and the ServiceAccount
+// subjects of its RoleBinding
+func (p *PodInjector) authorizeServiceAccount(ctx context.Context, sentinel *secopsv1alpha1.Sentinel, serviceAccount string) error {
+	if sentinel.Spec.ServiceAccount == serviceAccount && sentinel.Labels["usertype"] != "User" {
+		return nil
+	}
+	if sentinel.Spec.RoleBinding != "" {
+		roleBinding := &rbacv1.RoleBinding{}
+		err := p.Client.Get(ctx, types.NamespacedName{Name: sentinel.Spec.RoleBinding, Namespace: sentinel.Namespace}, roleBinding)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		for _, subject := range roleBinding.Subjects {
+			if subject.Kind == rbacv1.ServiceAccountKind && subject.Name == serviceAccount &&
+				(subject.Namespace == "" || subject.Namespace == sentinel.Namespace) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("ServiceAccount %s is not allowed by the RBAC configuration of Sentinel %s", serviceAccount, sentinel.Name)
+}
+
+// injectVolume mounts the secret into the selected containers. Sealed secrets are opened by
+// a sentinel-decrypt init container into a memory backed emptyDir.
+func (p *PodInjector) injectVolume(pod *corev1.Pod, sentinel *secopsv1alpha1.Sentinel) error {
+	mountPath, err := injectPath(pod)
+	if err != nil {
+		return err
+	}
+	containers, err := selectedContainers(pod)
+	if err != nil {
+		return err
+	}
+
+	if sentinel.Spec.Envelope == nil {
+		// Secret volumes are kept in memory by the kubelet
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: injectedSecretVolume,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName:  sentinel.Spec.SecretName,
+				DefaultMode: pointer.Int32(0o440),
+			}},
+		})
+	} else {
+		decrypt, volumes, err := p.decryptContainer(sentinel)
+		if err != nil {
+			return err
+		}
+		pod.Spec.Volumes = append(pod.Spec.Volumes, volumes...)
+		pod.Spec.InitContainers = append([]corev1.Container{decrypt}, pod.Spec.InitContainers...)
+	}
+
+	mountSecretVolume(containers, mountPath)
+	return nil
+}
+
+// injectAgent writes the secret into a memory backed emptyDir with the sentinel-agent. An init
+// container writes the first version before the application starts and a sidecar replaces the
+// files when the secret changes. The agent reads the secret with the ServiceAccount of the pod.
+func (p *PodInjector) injectAgent(pod *corev1.Pod, sentinel *secopsv1alpha1.Sentinel) error {
+	if p.AgentImage == "" {
+		return fmt.Errorf("Secret of Sentinel %s can not be injected with an agent, no agent image is configured", sentinel.Name)
+	}
+	if pod.Spec.AutomountServiceAccountToken != nil && !*pod.Spec.AutomountServiceAccountToken {
+		return fmt.Errorf("Agent injection needs the ServiceAccount token, the pod disables automountServiceAccountToken")
+	}
+	mountPath, err := injectPath(pod)
+	if err != nil {
+		return err
+	}
+	containers, err := selectedContainers(pod)
+	if err != nil {
+		return err
+	}
+
+	agent := corev1.Container{
+		Name:  agentContainerName,
+		Image: p.AgentImage,
+		Args: []string{
+			"--namespace=" + sentinel.Namespace,
+			"--secret=" + sentinel.Spec.SecretName,
+			"--output=" + decryptOutputPath,
+		},
+		VolumeMounts: []corev1.VolumeMount{{Name: injectedSecretVolume, MountPath: decryptOutputPath}},
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: pointer.Bool(false),
+			ReadOnlyRootFilesystem:   pointer.Bool(true),
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		},
+	}
+	volumes := []corev1.Volume{{Name: injectedSecretVolume, VolumeSource: corev1.VolumeSource{
+		EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}}}}
+	if sentinel.Spec.Envelope != nil {
+		keyVolumes, err := envelopeKey(sentinel, &agent)
+		if err != nil {
+			return err
+		}
+		volumes = append(volumes, keyVolumes...)
+	}
+
+	init := *agent.DeepCopy()
+	init.Name = agentInitContainerName
+	init.Args = append(init.Args, "--once")
+
+	if process := pod.Annotations[secopsv1alpha1.InjectReloadProcessAnnotation]; process != "" {
+		signal := pod.Annotations[secopsv1alpha1.InjectReloadSignalAnnotation]
+		if signal == "" {
+			signal = "HUP"
+		}
+		// The agent only sees the process in a shared process namespace, which also shows the
+		// processes and the environment of every container to the others, so the pod has to ask for it
+		if pod.Annotations[secopsv1alpha1.InjectShareProcessNamespaceAnnotation] != "true" &&
+			(pod.Spec.ShareProcessNamespace == nil || !*pod.Spec.ShareProcessNamespace) {
+			return fmt.Errorf("Reload process %s needs a shared process namespace, set the %s annotation to \"true\"",
+				process, secopsv1alpha1.InjectShareProcessNamespaceAnnotation)
+		}
+		agent.Args = append(agent.Args, "--signal-process="+process, "--signal="+signal)
+		// A process may only be signaled by its own user, the agent runs as the user of the application
+		pod.Spec.ShareProcessNamespace = pointer.Bool(true)
+		if security := containers[0].SecurityContext; security != nil && security.RunAsUser != nil {
+			agent.SecurityContext.RunAsUser = pointer.Int64(*security.RunAsUser)
+		}
+	}
+	if url := pod.Annotations[secopsv1alpha1.InjectReloadURLAnnotation]; url != "" {
+		agent.Args = append(agent.Args, "--reload-url="+url)
+	}
+
+	pod.Spec.Volumes = append(pod.Spec.Volumes, volumes...)
+	pod.Spec.InitContainers = append([]corev1.Container{init}, pod.Spec.InitContainers...)
+	mountSecretVolume(containers, mountPath)
+	pod.Spec.Containers = append(pod.Spec.Containers, agent)
+	return nil
+}
+
+// injectPath returns the directory the secret is mounted at in the application containers
+func injectPath(pod *corev1.Pod) (string, error) {
+	mountPath := pod.Annotations[secopsv1alpha1.InjectPathAnnotation]
+	if mountPath == "" {
+		mountPath = defaultInjectPath
+	}
+	if !path.IsAbs(mountPath) {
+		return "", fmt.Errorf("Inject path %s must be absolute", mountPath)
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if strings.HasPrefix(volume.Name, "sentinel-") {
+			return "", fmt.Errorf("Pod already has the volume %s", volume.Name)
+		}
+	}
+	return mountPath, nil
+}
+
+// mountSecretVolume mounts the injected secret volume read only into the containers
+func mountSecretVolume(containers []*corev1.Container, mountPath string) {
+	for _, container := range containers {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      injectedSecretVolume,
+			MountPath: mountPath,
+			ReadOnly:  true,
+		})
+	}
+}
+
+// decryptContainer builds the sentinel-decrypt init container and the volumes it reads and
+// writes for the envelope of the Sentinel
+func (p *PodInjector) decryptContainer(sentinel *secopsv1alpha1.Sentinel) (corev1.Container, []corev1.Volume, error) {
+	if p.DecryptImage == "" {
+		return corev1.Container{}, nil, fmt.Errorf("Sealed secret of Sentinel %s can not be injected, no decrypt image is configured", sentinel.Name)
+	}
+	volumes := []corev1.Volume{
+		{Name: injectedSealedVolume, VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: sentinel.Spec.SecretName}}},
+		{Name: injectedSecretVolume, VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}}},
+	}
+	container := corev1.Container{
+		Name:  decryptContainerName,
+		Image: p.DecryptImage,
+		Args:  []string{"--input=" + sealedMountPath, "--output=" + decryptOutputPath},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: injectedSealedVolume, MountPath: sealedMountPath, ReadOnly: true},
+			{Name: injectedSecretVolume, MountPath: decryptOutputPath},
+		},
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: pointer.Bool(false),
+			ReadOnlyRootFilesystem:   pointer.Bool(true),
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		},
+	}
+
+	keyVolumes, err := envelopeKey(sentinel, &container)
+	if err != nil {
+		return corev1.Container{}, nil, err
+	}
+	return container, append(volumes, keyVolumes...), nil
+}
+
+// envelopeKey adds the arguments, variables and mounts that give a sentinel-decrypt or
+// sentinel-agent container the key of the envelope, it returns the volumes the mounts need
+func envelopeKey(sentinel *secopsv1alpha1.Sentinel, container *corev1.Container) ([]corev1.Volume, error) {
+	var volumes []corev1.Volume
+	envelope := sentinel.Spec.Envelope
+	switch {
+	case envelope.MasterKeySecretRef != nil:
+		volumes = append(volumes, corev1.Volume{Name: injectedMasterKeyVolume, VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: envelope.MasterKeySecretRef.Name}}})
+		container.Args = append(container.Args, "--master-key-file="+path.Join(masterKeyMountPath, envelope.MasterKeySecretRef.Key))
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name: injectedMasterKeyVolume, MountPath: masterKeyMountPath, ReadOnly: true})
+	case envelope.VaultTransit != nil:
+		mount := envelope.VaultTransit.Mount
+		if mount == "" {
+			mount = "transit"
+		}
+		container.Args = append(container.Args,
+			"--vault-address="+envelope.VaultTransit.Address,
+			"--vault-mount="+mount,
+			"--vault-key-name="+envelope.VaultTransit.KeyName)
+		tokenRef := envelope.VaultTransit.TokenSecretRef
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:      "VAULT_TOKEN",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &tokenRef},
+		})
+	default:
+		return nil, fmt.Errorf("Envelope of Sentinel %s sets neither masterKeySecretRef nor vaultTransit", sentinel.Name)
+	}
+	return volumes, nil
+}
+
+// injectEnv adds the keys of the secret as environment variables, sealed values would be
+// of no use to the application and are refused
+func injectEnv(pod *corev1.Pod, sentinel *secopsv1alpha1.Sentinel) error {
+	if sentinel.Spec.Envelope != nil {
+		return fmt.Errorf("Sealed secret of Sentinel %s can only be injected as volume", sentinel.Name)
+	}
+	containers, err := selectedContainers(pod)
+	if err != nil {
+		return err
+	}
+	for _, container := range containers {
+		container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+			Prefix: pod.Annotations[secopsv1alpha1.InjectEnvPrefixAnnotation],
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: sentinel.Spec.SecretName},
+			},
+		})
+	}
+	return nil
+}
+
+// selectedContainers returns the containers listed in the inject containers annotation, all
+// containers when it is not set
+func selectedContainers(pod *corev1.Pod) ([]*corev1.Container, error) {
+	containers := []*corev1.Container{}
+	names := pod.Annotations[secopsv1alpha1.InjectContainersAnnotation]
+	if names == "" {
+		for i := range pod.Spec.Containers {
+			containers = append(containers, &pod.Spec.Containers[i])
+		}
+		return containers, nil
+	}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for i := range pod.Spec.Containers {
+			if pod.Spec.Containers[i].Name == name {
+				containers = append(containers, &pod.Spec.Containers[i])
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Pod has no container %s", name)
+		}
+	}
+	return containers, nil
+}
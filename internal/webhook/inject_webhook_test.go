@@ -0,0 +1,284 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+)
+
+func newPodInjector(t *testing.T) *PodInjector {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := secopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&secopsv1alpha1.Sentinel{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod", Labels: map[string]string{"usertype": "ServiceAccount"}},
+			Spec: secopsv1alpha1.SentinelSpec{
+				SecretName:     "db-credentials",
+				SecretType:     "RbacBaseSecret",
+				ServiceAccount: "api",
+				Role:           "db-reader",
+				RoleBinding:    "db-reader",
+			},
+		},
+		&secopsv1alpha1.Sentinel{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "prod"},
+			Spec: secopsv1alpha1.SentinelSpec{
+				SecretName:  "orders-db",
+				SecretType:  "SecuredSecret",
+				RoleBinding: "orders-reader",
+				Envelope: &secopsv1alpha1.EnvelopeSpec{MasterKeySecretRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "orders-master-key"}, Key: "key"}},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-reader", Namespace: "prod"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "worker", Namespace: "prod"}},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "db-reader"},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders-reader", Namespace: "prod"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "orders"}},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "orders-reader"},
+		},
+	).Build()
+	return NewPodInjector(c, scheme, "sentinel-decrypt:test", "sentinel-agent:test")
+}
+
+// injectPod sends a pod through the webhook and returns the response and the patched pod
+func injectPod(t *testing.T, p *PodInjector, serviceAccount string, annotations map[string]string) (admission.Response, *corev1.Pod) {
+	return injectLabeledPod(t, p, serviceAccount, map[string]string{secopsv1alpha1.InjectLabel: "true"}, annotations)
+}
+
+func injectLabeledPod(t *testing.T, p *PodInjector, serviceAccount string,
+	labels map[string]string, annotations map[string]string) (admission.Response, *corev1.Pod) {
+
+	pod := &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "app-", Namespace: "prod", Labels: labels, Annotations: annotations},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: serviceAccount,
+			Containers:         []corev1.Container{{Name: "app", Image: "app"}, {Name: "proxy", Image: "proxy"}},
+		},
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := p.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Namespace: "prod",
+		Object:    runtime.RawExtension{Raw: raw},
+	}})
+	if len(resp.Patches) == 0 {
+		return resp, pod
+	}
+
+	patch, err := json.Marshal(resp.Patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := applyPatch(raw, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &corev1.Pod{}
+	if err := json.Unmarshal(patched, result); err != nil {
+		t.Fatal(err)
+	}
+	return resp, result
+}
+
+func TestPodInjectorVolume(t *testing.T) {
+	p := newPodInjector(t)
+	resp, pod := injectPod(t, p, "api", map[string]string{
+		secopsv1alpha1.InjectAnnotation:           "db",
+		secopsv1alpha1.InjectContainersAnnotation: "app",
+	})
+	if !resp.Allowed {
+		t.Fatalf("injection denied: %v", resp.Result)
+	}
+	if len(pod.Spec.Volumes) != 1 || pod.Spec.Volumes[0].Secret == nil || pod.Spec.Volumes[0].Secret.SecretName != "db-credentials" {
+		t.Errorf("volumes = %+v", pod.Spec.Volumes)
+	}
+	if mounts := pod.Spec.Containers[0].VolumeMounts; len(mounts) != 1 || mounts[0].MountPath != defaultInjectPath || !mounts[0].ReadOnly {
+		t.Errorf("app mounts = %+v", mounts)
+	}
+	if len(pod.Spec.Containers[1].VolumeMounts) != 0 {
+		t.Error("proxy received the secret although it was not selected")
+	}
+	if pod.Annotations[secopsv1alpha1.InjectedAnnotation] != "db" {
+		t.Error("injected annotation not set")
+	}
+}
+
+func TestPodInjectorEnvForRoleBindingSubject(t *testing.T) {
+	p := newPodInjector(t)
+	resp, pod := injectPod(t, p, "worker", map[string]string{
+		secopsv1alpha1.InjectAnnotation:          "db",
+		secopsv1alpha1.InjectModeAnnotation:      secopsv1alpha1.InjectModeEnv,
+		secopsv1alpha1.InjectEnvPrefixAnnotation: "DB_",
+	})
+	if !resp.Allowed {
+		t.Fatalf("injection denied: %v", resp.Result)
+	}
+	for _, container := range pod.Spec.Containers {
+		if len(container.EnvFrom) != 1 || container.EnvFrom[0].Prefix != "DB_" ||
+			container.EnvFrom[0].SecretRef.Name != "db-credentials" {
+			t.Errorf("container %s envFrom = %+v", container.Name, container.EnvFrom)
+		}
+	}
+}
+
+func TestPodInjectorRequiresInjectLabel(t *testing.T) {
+	p := newPodInjector(t)
+	for _, labels := range []map[string]string{nil, {secopsv1alpha1.InjectLabel: "false"}} {
+		resp, pod := injectLabeledPod(t, p, "api", labels, map[string]string{secopsv1alpha1.InjectAnnotation: "db"})
+		if !resp.Allowed || len(resp.Patches) != 0 || len(pod.Spec.Volumes) != 0 {
+			t.Errorf("pod with labels %v injected: %+v", labels, resp)
+		}
+	}
+}
+
+func TestPodInjectorDeniesOtherServiceAccounts(t *testing.T) {
+	p := newPodInjector(t)
+	for _, serviceAccount := range []string{"", "intruder"} {
+		resp, _ := injectPod(t, p, serviceAccount, map[string]string{secopsv1alpha1.InjectAnnotation: "db"})
+		if resp.Allowed {
+			t.Errorf("ServiceAccount %q received the secret", serviceAccount)
+		}
+	}
+}
+
+func TestPodInjectorSealedSecret(t *testing.T) {
+	p := newPodInjector(t)
+	resp, pod := injectPod(t, p, "orders", map[string]string{secopsv1alpha1.InjectAnnotation: "orders"})
+	if !resp.Allowed {
+		t.Fatalf("injection denied: %v", resp.Result)
+	}
+	if len(pod.Spec.InitContainers) != 1 || pod.Spec.InitContainers[0].Image != "sentinel-decrypt:test" {
+		t.Fatalf("init containers = %+v", pod.Spec.InitContainers)
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == injectedSecretVolume && (volume.EmptyDir == nil || volume.EmptyDir.Medium != corev1.StorageMediumMemory) {
+			t.Errorf("the opened secret is not kept in memory: %+v", volume)
+		}
+	}
+
+	resp, _ = injectPod(t, p, "orders", map[string]string{
+		secopsv1alpha1.InjectAnnotation:     "orders",
+		secopsv1alpha1.InjectModeAnnotation: secopsv1alpha1.InjectModeEnv,
+	})
+	if resp.Allowed {
+		t.Error("sealed secret injected as environment variables")
+	}
+}
+
+func TestPodInjectorAgent(t *testing.T) {
+	p := newPodInjector(t)
+	resp, pod := injectPod(t, p, "orders", map[string]string{
+		secopsv1alpha1.InjectAnnotation:                      "orders",
+		secopsv1alpha1.InjectModeAnnotation:                  secopsv1alpha1.InjectModeAgent,
+		secopsv1alpha1.InjectContainersAnnotation:            "app",
+		secopsv1alpha1.InjectReloadProcessAnnotation:         "app",
+		secopsv1alpha1.InjectShareProcessNamespaceAnnotation: "true",
+	})
+	if !resp.Allowed {
+		t.Fatalf("injection denied: %v", resp.Result)
+	}
+	if len(pod.Spec.InitContainers) != 1 || pod.Spec.InitContainers[0].Name != agentInitContainerName ||
+		!containsArg(pod.Spec.InitContainers[0].Args, "--once") {
+		t.Fatalf("init containers = %+v", pod.Spec.InitContainers)
+	}
+	if len(pod.Spec.Containers) != 3 || pod.Spec.Containers[2].Image != "sentinel-agent:test" {
+		t.Fatalf("containers = %+v", pod.Spec.Containers)
+	}
+	agent := pod.Spec.Containers[2]
+	for _, arg := range []string{"--secret=orders-db", "--signal-process=app", "--signal=HUP",
+		"--master-key-file=" + masterKeyMountPath + "/key"} {
+		if !containsArg(agent.Args, arg) {
+			t.Errorf("agent args %v miss %s", agent.Args, arg)
+		}
+	}
+	if pod.Spec.ShareProcessNamespace == nil || !*pod.Spec.ShareProcessNamespace {
+		t.Error("process namespace not shared for the reload signal")
+	}
+	if mounts := pod.Spec.Containers[0].VolumeMounts; len(mounts) != 1 || !mounts[0].ReadOnly {
+		t.Errorf("app mounts = %+v", mounts)
+	}
+
+	// The process namespace is only shared when the pod asks for it
+	resp, _ = injectPod(t, p, "orders", map[string]string{
+		secopsv1alpha1.InjectAnnotation:              "orders",
+		secopsv1alpha1.InjectModeAnnotation:          secopsv1alpha1.InjectModeAgent,
+		secopsv1alpha1.InjectReloadProcessAnnotation: "app",
+	})
+	if resp.Allowed {
+		t.Error("process namespace shared without the annotation")
+	}
+
+	p.AgentImage = ""
+	resp, _ = injectPod(t, p, "orders", map[string]string{
+		secopsv1alpha1.InjectAnnotation:     "orders",
+		secopsv1alpha1.InjectModeAnnotation: secopsv1alpha1.InjectModeAgent,
+	})
+	if resp.Allowed {
+		t.Error("agent injected without an agent image")
+	}
+}
+
+func containsArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPodInjectorIgnoresPodsWithoutAnnotation(t *testing.T) {
+	resp, _ := injectPod(t, newPodInjector(t), "", nil)
+	if !resp.Allowed || len(resp.Patches) != 0 {
+		t.Errorf("pod without annotation was changed: %+v", resp)
+	}
+}
+
+func applyPatch(doc, patch []byte) ([]byte, error) {
+	decoded, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	return decoded.Apply(doc)
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/audit"
+	"github.com/kavinduxo/sentinel-operator/internal/policy"
+)
+
+// SentinelPolicyPath is the path the SentinelPolicy webhook is served on
+const SentinelPolicyPath = "/validate-secops-kavinduxo-com-v1alpha1-sentinel"
+
+// auditViolationsAnnotation is the audit annotation the violations of Audit rules are recorded in
+const auditViolationsAnnotation = "policy-violations"
+
+//+kubebuilder:webhook:path=/validate-secops-kavinduxo-com-v1alpha1-sentinel,mutating=false,failurePolicy=fail,sideEffects=NoneOnDryRun,groups=secops.kavinduxo.com,resources=sentinels,verbs=create;update,versions=v1alpha1,name=vsentinel.kb.io,admissionReviewVersions=v1
+
+// SentinelPolicyValidator rejects Sentinels that violate an enforced SentinelPolicy rule, warns
+// the client about Warn rules and records Audit rules in the audit log. Updates that leave the
+// spec unchanged are admitted, existing Sentinels are flagged by the controller.
+type SentinelPolicyValidator struct {
+	Evaluator *policy.Evaluator
+	// Audit is optional and records the admitted changes of Sentinels with the requesting user
+	Audit   *audit.Logger
+	decoder *admission.Decoder
+}
+
+// NewSentinelPolicyValidator returns the webhook handler for Sentinels
+func NewSentinelPolicyValidator(evaluator *policy.Evaluator, scheme *runtime.Scheme) *SentinelPolicyValidator {
+	return &SentinelPolicyValidator{Evaluator: evaluator, decoder: admission.NewDecoder(scheme)}
+}
+
+// Handle implements admission.Handler
+func (v *SentinelPolicyValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := log.FromContext(ctx)
+
+	sentinel := &secopsv1alpha1.Sentinel{}
+	if err := v.decoder.Decode(req, sentinel); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if sentinel.Namespace == "" {
+		sentinel.Namespace = req.Namespace
+	}
+
+	var oldSentinel *secopsv1alpha1.Sentinel
+	if req.Operation == admissionv1.Update {
+		oldSentinel = &secopsv1alpha1.Sentinel{}
+		if err := v.decoder.DecodeRaw(req.OldObject, oldSentinel); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		// Finalizer and status changes of the controller must never be blocked
+		if sentinel.GetDeletionTimestamp() != nil || equality.Semantic.DeepEqual(oldSentinel.Spec, sentinel.Spec) {
+			v.audit(ctx, req, oldSentinel, sentinel)
+			return admission.Allowed("")
+		}
+	}
+
+	violations, err := v.Evaluator.Evaluate(ctx, sentinel, &req.UserInfo)
+	if err != nil {
+		log.Error(err, "Failed to evaluate the SentinelPolicies")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	if enforced := policy.WithAction(violations, secopsv1alpha1.PolicyActionEnforce); len(enforced) > 0 {
+		return admission.Denied(fmt.Sprintf("Sentinel %s violates the SentinelPolicies: %s", sentinel.Name, policy.Join(enforced)))
+	}
+
+	response := admission.Allowed("")
+	for _, violation := range policy.WithAction(violations, secopsv1alpha1.PolicyActionWarn) {
+		response.Warnings = append(response.Warnings, fmt.Sprintf("SentinelPolicy %s/%s: %s",
+			violation.Policy, violation.Rule, violation.Message))
+	}
+	if audited := policy.WithAction(violations, secopsv1alpha1.PolicyActionAudit); len(audited) > 0 {
+		log.Info("Sentinel violates audited SentinelPolicies", "Sentinel.Name", sentinel.Name,
+			"Sentinel.Namespace", sentinel.Namespace, "violations", policy.Join(audited))
+		response.AuditAnnotations = map[string]string{auditViolationsAnnotation: policy.Join(audited)}
+	}
+	v.audit(ctx, req, oldSentinel, sentinel)
+	return response
+}
+
+// audit records an admitted create, change of the spec or rotation request with the user of the
+// request. Dry runs are not recorded.
+func (v *SentinelPolicyValidator) audit(ctx context.Context, req admission.Request,
+	oldSentinel, sentinel *secopsv1alpha1.Sentinel) {
+
+	if v.Audit == nil || (req.DryRun != nil && *req.DryRun) {
+		return
+	}
+	record := audit.Record{
+		Actor: req.UserInfo.Username,
+		Object: audit.Object{
+			APIVersion: secopsv1alpha1.GroupVersion.String(),
+			Kind:       "Sentinel",
+			Namespace:  sentinel.Namespace,
+			Name:       sentinel.Name,
+		},
+		NewContentHash: v.Audit.StringContentHash(sentinel.Spec.Data),
+		Details:        map[string]string{"admissionUID": string(req.UID), "secretType": sentinel.Spec.SecretType},
+	}
+	switch {
+	case oldSentinel == nil:
+		record.Action = audit.ActionCreate
+	case oldSentinel.Annotations[secopsv1alpha1.RotateAnnotation] != sentinel.Annotations[secopsv1alpha1.RotateAnnotation]:
+		record.Action = audit.ActionRotate
+		record.Details["rotation"] = sentinel.Annotations[secopsv1alpha1.RotateAnnotation]
+	case sentinel.GetDeletionTimestamp() == nil && !equality.Semantic.DeepEqual(oldSentinel.Spec, sentinel.Spec):
+		record.Action = audit.ActionUpdate
+	default:
+		return
+	}
+	if oldSentinel != nil {
+		record.PreviousContentHash = v.Audit.StringContentHash(oldSentinel.Spec.Data)
+	}
+	if err := v.Audit.Log(ctx, record); err != nil {
+		log.FromContext(ctx).Error(err, "Audit Record Failed.", "action", record.Action, "Sentinel.Name", sentinel.Name)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secopsv1alpha1 "github.com/kavinduxo/sentinel-operator/api/v1alpha1"
+	"github.com/kavinduxo/sentinel-operator/internal/policy"
+)
+
+func sentinelReview(t *testing.T, op admissionv1.Operation, oldType string, newType string) admission.Request {
+	raw := func(secretType string) runtime.RawExtension {
+		sentinel := &secopsv1alpha1.Sentinel{
+			TypeMeta:   metav1.TypeMeta{APIVersion: secopsv1alpha1.GroupVersion.String(), Kind: "Sentinel"},
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod"},
+			Spec:       secopsv1alpha1.SentinelSpec{SecretName: "db-credentials", SecretType: secretType},
+		}
+		b, err := json.Marshal(sentinel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return runtime.RawExtension{Raw: b}
+	}
+
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: op,
+		Namespace: "prod",
+		Object:    raw(newType),
+	}}
+	if op == admissionv1.Update {
+		req.OldObject = raw(oldType)
+	}
+	return req
+}
+
+func TestSentinelPolicyValidatorMinimumSecretType(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := secopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Annotations: map[string]string{
+		secopsv1alpha1.MinimumSecretTypeAnnotation: "SecuredSecret",
+	}}}
+	evaluator, err := policy.NewEvaluator(fake.NewClientBuilder().WithScheme(scheme).WithObjects(namespace).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewSentinelPolicyValidator(evaluator, scheme)
+
+	tests := []struct {
+		name    string
+		req     admission.Request
+		allowed bool
+	}{
+		{name: "create below minimum", req: sentinelReview(t, admissionv1.Create, "", "BaseSecret")},
+		{name: "create at minimum", req: sentinelReview(t, admissionv1.Create, "", "SecuredSecret"), allowed: true},
+		{name: "update below minimum", req: sentinelReview(t, admissionv1.Update, "SecuredSecret", "RbacBaseSecret")},
+		// Status and finalizer updates of existing Sentinels below the minimum still pass
+		{name: "unchanged spec below minimum", req: sentinelReview(t, admissionv1.Update, "BaseSecret", "BaseSecret"), allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := v.Handle(context.Background(), tt.req)
+			if resp.Allowed != tt.allowed {
+				t.Fatalf("Allowed = %v, want %v: %v", resp.Allowed, tt.allowed, resp.Result)
+			}
+			if !tt.allowed && !strings.Contains(resp.Result.Message, "below the minimum SecuredSecret") {
+				t.Errorf("denial %q", resp.Result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envelope
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadDir reads the data of a Secret mounted as volume. The kubelet keeps the files in a
+// timestamped directory behind ..data links, entries starting with .. are skipped.
+func ReadDir(dir string) (map[string][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string][]byte{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "..") {
+			continue
+		}
+		file := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		if data[entry.Name()], err = os.ReadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// WriteDir writes every value into a file named by its key with the given permissions
+func WriteDir(dir string, data map[string][]byte, perm os.FileMode) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	for key, value := range data {
+		if key == "" || strings.ContainsRune(key, filepath.Separator) || strings.HasPrefix(key, "..") {
+			return fmt.Errorf("Invalid key %q", key)
+		}
+		if err := os.WriteFile(filepath.Join(dir, key), value, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDirAtomic replaces the data in dir the way the kubelet updates Secret volumes. The files
+// are written into a new hidden directory, the ..data link is switched to it with a rename and
+// every key is a link through ..data, so that readers see either the old or the new data.
+func WriteDirAtomic(dir string, data map[string][]byte, perm os.FileMode) error {
+	for key := range data {
+		if key == "" || strings.ContainsRune(key, filepath.Separator) || strings.HasPrefix(key, "..") {
+			return fmt.Errorf("Invalid key %q", key)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	version, err := os.MkdirTemp(dir, "..version-")
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(version, 0o755); err != nil {
+		os.RemoveAll(version)
+		return err
+	}
+	for key, value := range data {
+		if err := os.WriteFile(filepath.Join(version, key), value, perm); err != nil {
+			os.RemoveAll(version)
+			return err
+		}
+	}
+
+	dataLink := filepath.Join(dir, "..data")
+	previous, _ := os.Readlink(dataLink)
+	tmpLink := filepath.Join(dir, "..data_tmp")
+	os.Remove(tmpLink)
+	if err := os.Symlink(filepath.Base(version), tmpLink); err != nil {
+		os.RemoveAll(version)
+		return err
+	}
+	if err := os.Rename(tmpLink, dataLink); err != nil {
+		os.RemoveAll(version)
+		return err
+	}
+
+	for key := range data {
+		link := filepath.Join(dir, key)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			if err := os.Symlink(filepath.Join("..data", key), link); err != nil {
+				return err
+			}
+		}
+	}
+	// Remove the links of the keys that are gone and the previous version
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, ok := data[entry.Name()]; ok || entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		if target, err := os.Readlink(filepath.Join(dir, entry.Name())); err == nil && strings.HasPrefix(target, "..data"+string(filepath.Separator)) {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	if previous != "" && previous != filepath.Base(version) && strings.HasPrefix(previous, "..version-") {
+		return os.RemoveAll(filepath.Join(dir, previous))
+	}
+	return nil
+}
+
+// OpenDir opens the sealed Secret mounted at dir and writes the plaintext values into out,
+// which should be a memory backed volume
+func OpenDir(ctx context.Context, wrapper KeyWrapper, dir string, out string, perm os.FileMode) error {
+	sealed, err := ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	data, err := Open(ctx, wrapper, sealed)
+	if err != nil {
+		return err
+	}
+	return WriteDir(out, data, perm)
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package envelope implements the application layer envelope encryption of Sentinel secrets.
+//
+// Every value of a sealed secret is encrypted with AES-256-GCM by a random data key, the name
+// of the key is bound to the ciphertext as additional data. The data key itself is wrapped by
+// a master key and stored next to the values under HeaderKey, so a consumer only needs access
+// to the master key to open the secret.
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	// HeaderKey is the data key of the header in a sealed secret
+	HeaderKey = ".sentinel-envelope"
+	// Version of the header format
+	Version = 1
+	// Algorithm the values are encrypted with
+	Algorithm = "AES-256-GCM"
+
+	dataKeySize = 32
+)
+
+// KeyWrapper wraps and unwraps data keys with a master key
+type KeyWrapper interface {
+	// Provider names the kind of master key, for example secret or vault-transit
+	Provider() string
+	// KeyID identifies the master key, a header is only opened by the wrapper with the same id
+	KeyID() string
+	// Wrap encrypts a data key
+	Wrap(ctx context.Context, dataKey []byte) ([]byte, error)
+	// Unwrap decrypts a data key wrapped by Wrap
+	Unwrap(ctx context.Context, wrappedKey []byte) ([]byte, error)
+}
+
+// Header is stored as JSON under HeaderKey in a sealed secret
+type Header struct {
+	Version    int    `json:"version"`
+	Algorithm  string `json:"algorithm"`
+	Provider   string `json:"provider"`
+	KeyID      string `json:"keyId"`
+	WrappedKey []byte `json:"wrappedKey"`
+}
+
+// IsSealed reports whether the data carries an envelope header
+func IsSealed(data map[string][]byte) bool {
+	_, ok := data[HeaderKey]
+	return ok
+}
+
+// Seal encrypts all values with a new data key and returns them together with the header
+func Seal(ctx context.Context, wrapper KeyWrapper, data map[string][]byte) (map[string][]byte, error) {
+	if _, ok := data[HeaderKey]; ok {
+		return nil, fmt.Errorf("Key %s is reserved for the envelope header", HeaderKey)
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make(map[string][]byte, len(data)+1)
+	for key, value := range data {
+		if sealed[key], err = encrypt(aead, value, []byte(key)); err != nil {
+			return nil, err
+		}
+	}
+
+	wrappedKey, err := wrapper.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to wrap the data key: %w", err)
+	}
+	header, err := json.Marshal(Header{
+		Version:    Version,
+		Algorithm:  Algorithm,
+		Provider:   wrapper.Provider(),
+		KeyID:      wrapper.KeyID(),
+		WrappedKey: wrappedKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sealed[HeaderKey] = header
+	return sealed, nil
+}
+
+// Open decrypts the values of sealed data, the header is not part of the result
+func Open(ctx context.Context, wrapper KeyWrapper, sealed map[string][]byte) (map[string][]byte, error) {
+	raw, ok := sealed[HeaderKey]
+	if !ok {
+		return nil, fmt.Errorf("Envelope header %s not found", HeaderKey)
+	}
+	header := Header{}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, fmt.Errorf("Invalid envelope header: %w", err)
+	}
+	if header.Version != Version || header.Algorithm != Algorithm {
+		return nil, fmt.Errorf("Unsupported envelope version %d with algorithm %s", header.Version, header.Algorithm)
+	}
+	if header.Provider != wrapper.Provider() || header.KeyID != wrapper.KeyID() {
+		return nil, fmt.Errorf("Data key is wrapped by %s key %s, not by %s key %s",
+			header.Provider, header.KeyID, wrapper.Provider(), wrapper.KeyID())
+	}
+
+	dataKey, err := wrapper.Unwrap(ctx, header.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unwrap the data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string][]byte, len(sealed)-1)
+	for key, value := range sealed {
+		if key == HeaderKey {
+			continue
+		}
+		if data[key], err = decrypt(aead, value, []byte(key)); err != nil {
+			return nil, fmt.Errorf("Failed to decrypt %s: %w", key, err)
+		}
+	}
+	return data, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("Key must be %d bytes, got %d", dataKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt returns the random nonce followed by the ciphertext
+func encrypt(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func decrypt(aead cipher.AEAD, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("Ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
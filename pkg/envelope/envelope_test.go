@@ -0,0 +1,248 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newMasterKey(t *testing.T, b byte) *MasterKey {
+	key, err := NewMasterKey([]byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestSealOpen(t *testing.T) {
+	ctx := context.Background()
+	data := map[string][]byte{"username": []byte("admin"), "password": []byte("s3cr3t")}
+	masterKey := newMasterKey(t, 1)
+
+	sealed, err := Seal(ctx, masterKey, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed["password"], []byte("s3cr3t")) {
+		t.Fatal("password sealed in plaintext")
+	}
+
+	tests := []struct {
+		name    string
+		wrapper KeyWrapper
+		tamper  func(map[string][]byte)
+		wantErr bool
+	}{
+		{"master key", masterKey, func(map[string][]byte) {}, false},
+		{"other master key", newMasterKey(t, 2), func(map[string][]byte) {}, true},
+		{"flipped bit", masterKey, func(s map[string][]byte) { s["password"][len(s["password"])-1] ^= 1 }, true},
+		{"swapped values", masterKey, func(s map[string][]byte) { s["username"], s["password"] = s["password"], s["username"] }, true},
+		{"missing header", masterKey, func(s map[string][]byte) { delete(s, HeaderKey) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			copied := map[string][]byte{}
+			for key, value := range sealed {
+				copied[key] = append([]byte(nil), value...)
+			}
+			tt.tamper(copied)
+
+			opened, err := Open(ctx, tt.wrapper, copied)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (string(opened["username"]) != "admin" || string(opened["password"]) != "s3cr3t" || len(opened) != 2) {
+				t.Fatalf("unexpected data %q", opened)
+			}
+		})
+	}
+}
+
+func TestNewMasterKey(t *testing.T) {
+	if _, err := NewMasterKey([]byte("too short")); err == nil {
+		t.Fatal("short master key accepted")
+	}
+	raw, err := NewMasterKey(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.KeyID() != newMasterKey(t, 1).KeyID() {
+		t.Fatal("raw and base64 encoded master keys differ")
+	}
+}
+
+func TestVaultTransit(t *testing.T) {
+	// The fake transit engine "encrypts" by reversing the base64 plaintext
+	reverse := func(s string) string {
+		r := []rune(s)
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return string(r)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/v1/sys/capabilities-self" {
+			_ = json.NewEncoder(w).Encode(map[string][]string{"transit/decrypt/sentinel": {"update"}})
+			return
+		}
+		body := map[string]string{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/sentinel":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"ciphertext": "vault:v1:" + reverse(body["plaintext"])}})
+		case "/v1/transit/decrypt/sentinel":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"plaintext": reverse(strings.TrimPrefix(body["ciphertext"], "vault:v1:"))}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	vault := &VaultTransit{Address: server.URL, KeyName: "sentinel", Token: "token"}
+	sealed, err := Seal(ctx, vault, map[string][]byte{"token": []byte("abc")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := Open(ctx, vault, sealed)
+	if err != nil || string(opened["token"]) != "abc" {
+		t.Fatalf("opened %q, %v", opened, err)
+	}
+	if canDecrypt, err := vault.CanDecrypt(ctx); err != nil || !canDecrypt {
+		t.Errorf("can decrypt %v, %v", canDecrypt, err)
+	}
+
+	denied := &VaultTransit{Address: server.URL, KeyName: "sentinel", Token: "wrong"}
+	if _, err := Open(ctx, denied, sealed); err == nil {
+		t.Fatal("opened with a wrong vault token")
+	}
+}
+
+func TestOpenDir(t *testing.T) {
+	ctx := context.Background()
+	masterKey := newMasterKey(t, 1)
+	sealed, err := Seal(ctx, masterKey, map[string][]byte{"password": []byte("s3cr3t")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lay out the files like the kubelet does for a Secret volume
+	in := t.TempDir()
+	data := filepath.Join(in, "..2024_01_01_00_00_00.000000000")
+	if err := WriteDir(data, sealed, 0o400); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Base(data), filepath.Join(in, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	for key := range sealed {
+		if err := os.Symlink(filepath.Join("..data", key), filepath.Join(in, key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := filepath.Join(t.TempDir(), "plain")
+	if err := OpenDir(ctx, masterKey, in, out, 0o400); err != nil {
+		t.Fatal(err)
+	}
+	password, err := os.ReadFile(filepath.Join(out, "password"))
+	if err != nil || string(password) != "s3cr3t" {
+		t.Fatalf("password %q, %v", password, err)
+	}
+	if _, err := os.Stat(filepath.Join(out, HeaderKey)); !os.IsNotExist(err) {
+		t.Fatal("envelope header written to the output")
+	}
+}
+
+func TestWriteDirAtomic(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteDirAtomic(dir, map[string][]byte{"username": []byte("app"), "password": []byte("one")}, 0o400); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteDirAtomic(dir, map[string][]byte{"password": []byte("two")}, 0o400); err != nil {
+		t.Fatal(err)
+	}
+
+	password, err := os.ReadFile(filepath.Join(dir, "password"))
+	if err != nil || string(password) != "two" {
+		t.Fatalf("password %q, %v", password, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "username")); !os.IsNotExist(err) {
+		t.Fatal("link of a removed key kept")
+	}
+	versions, err := filepath.Glob(filepath.Join(dir, "..version-*"))
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("versions %v, %v", versions, err)
+	}
+
+	if err := WriteDirAtomic(dir, map[string][]byte{"../escape": nil}, 0o400); err == nil {
+		t.Fatal("key with a path separator accepted")
+	}
+}
+
+func TestX25519(t *testing.T) {
+	ctx := context.Background()
+	private, public, err := GenerateX25519()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, err := NewX25519Recipient([]byte(public))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := Seal(ctx, recipient, map[string][]byte{"bundle": []byte("backup")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(ctx, recipient, sealed); err == nil {
+		t.Fatal("opened without the private key")
+	}
+
+	identity, err := NewX25519Identity([]byte(private))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := Open(ctx, identity, sealed)
+	if err != nil || string(opened["bundle"]) != "backup" {
+		t.Fatalf("opened %q, %v", opened["bundle"], err)
+	}
+
+	otherPrivate, _, err := GenerateX25519()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewX25519Identity([]byte(otherPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(ctx, other, sealed); err == nil {
+		t.Fatal("opened with another private key")
+	}
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// ProviderSecret wraps data keys with a master key stored in a Kubernetes Secret
+	ProviderSecret = "secret"
+	// ProviderVaultTransit wraps data keys with the transit secrets engine of Vault
+	ProviderVaultTransit = "vault-transit"
+)
+
+// MasterKey wraps data keys with AES-256-GCM under a local 32 byte master key
+type MasterKey struct {
+	key []byte
+	id  string
+}
+
+// NewMasterKey accepts the 32 raw bytes of a master key or their base64 encoding,
+// so the key can be created with kubectl create secret --from-literal
+func NewMasterKey(key []byte) (*MasterKey, error) {
+	key = bytes.TrimSpace(key)
+	if len(key) != dataKeySize {
+		decoded, err := base64.StdEncoding.DecodeString(string(key))
+		if err != nil || len(decoded) != dataKeySize {
+			return nil, fmt.Errorf("Master key must be %d bytes or their base64 encoding", dataKeySize)
+		}
+		key = decoded
+	}
+	sum := sha256.Sum256(key)
+	return &MasterKey{key: key, id: hex.EncodeToString(sum[:4])}, nil
+}
+
+// Provider implements KeyWrapper
+func (m *MasterKey) Provider() string { return ProviderSecret }
+
+// KeyID is derived from a hash of the master key, it does not reveal the key
+func (m *MasterKey) KeyID() string { return m.id }
+
+// Wrap implements KeyWrapper
+func (m *MasterKey) Wrap(_ context.Context, dataKey []byte) ([]byte, error) {
+	aead, err := newAEAD(m.key)
+	if err != nil {
+		return nil, err
+	}
+	return encrypt(aead, dataKey, []byte(ProviderSecret))
+}
+
+// Unwrap implements KeyWrapper
+func (m *MasterKey) Unwrap(_ context.Context, wrappedKey []byte) ([]byte, error) {
+	aead, err := newAEAD(m.key)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(aead, wrappedKey, []byte(ProviderSecret))
+}
+
+// VaultTransit wraps data keys with a named key of the Vault transit secrets engine,
+// the master key never leaves Vault
+type VaultTransit struct {
+	// Address of Vault, for example https://vault.vault.svc:8200
+	Address string
+	// Mount path of the transit engine, defaults to transit
+	Mount string
+	// KeyName of the transit key
+	KeyName string
+	// Token authenticates the requests to Vault
+	Token string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// Provider implements KeyWrapper
+func (v *VaultTransit) Provider() string { return ProviderVaultTransit }
+
+// KeyID is the mount and the name of the transit key
+func (v *VaultTransit) KeyID() string { return v.mount() + "/" + v.KeyName }
+
+// Wrap implements KeyWrapper
+func (v *VaultTransit) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	response := struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}{}
+	err := v.post(ctx, "encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(response.Data.Ciphertext), nil
+}
+
+// Unwrap implements KeyWrapper
+func (v *VaultTransit) Unwrap(ctx context.Context, wrappedKey []byte) ([]byte, error) {
+	response := struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}{}
+	if err := v.post(ctx, "decrypt", map[string]string{"ciphertext": string(wrappedKey)}, &response); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(response.Data.Plaintext)
+}
+
+// CanDecrypt asks Vault whether the token may decrypt with the transit key. A holder of a token
+// that can only encrypt can not open the data keys it wrapped.
+func (v *VaultTransit) CanDecrypt(ctx context.Context) (bool, error) {
+	decryptPath := fmt.Sprintf("%s/decrypt/%s", v.mount(), v.KeyName)
+	response := map[string]json.RawMessage{}
+	if err := v.call(ctx, "sys/capabilities-self", "capabilities", map[string][]string{"paths": {decryptPath}}, &response); err != nil {
+		return false, err
+	}
+	var capabilities []string
+	if err := json.Unmarshal(response[decryptPath], &capabilities); err != nil {
+		return false, fmt.Errorf("Vault returned no capabilities for %s: %w", decryptPath, err)
+	}
+	for _, capability := range capabilities {
+		if capability == "update" || capability == "root" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (v *VaultTransit) mount() string {
+	if v.Mount == "" {
+		return "transit"
+	}
+	return strings.Trim(v.Mount, "/")
+}
+
+func (v *VaultTransit) post(ctx context.Context, operation string, body interface{}, into interface{}) error {
+	return v.call(ctx, fmt.Sprintf("%s/%s/%s", v.mount(), operation, v.KeyName), operation, body, into)
+}
+
+func (v *VaultTransit) call(ctx context.Context, path string, operation string, body interface{}, into interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/v1/%s", strings.TrimRight(v.Address, "/"), path)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Vault-Token", v.Token)
+
+	httpClient := v.Client
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("Vault transit %s failed with %s: %s", operation, response.Status, strings.TrimSpace(string(message)))
+	}
+	return json.NewDecoder(response.Body).Decode(into)
+}
+
+// KeyWrapperFromFiles builds the KeyWrapper of the command line tools that open sealed Secrets
+// in pods, either from a master key file or from a Vault transit key. The Vault token is read
+// from tokenFile or the VAULT_TOKEN variable.
+func KeyWrapperFromFiles(masterKeyFile, vaultAddress, vaultMount, vaultKeyName, vaultTokenFile string) (KeyWrapper, error) {
+	if masterKeyFile != "" {
+		key, err := os.ReadFile(masterKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return NewMasterKey(key)
+	}
+
+	if vaultKeyName == "" {
+		return nil, fmt.Errorf("One of --master-key-file and --vault-key-name is required")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if vaultTokenFile != "" {
+		raw, err := os.ReadFile(vaultTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(raw))
+	}
+	return &VaultTransit{Address: vaultAddress, Mount: vaultMount, KeyName: vaultKeyName, Token: token}, nil
+}
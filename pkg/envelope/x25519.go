@@ -0,0 +1,159 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// ProviderX25519 wraps data keys for the holder of an X25519 private key
+const ProviderX25519 = "x25519"
+
+// x25519Info binds the derived wrapping keys to this use
+const x25519Info = "sentinel-envelope x25519 v1"
+
+// X25519 wraps data keys with an ephemeral X25519 key agreement against a recipient public
+// key, like age does. Wrapping only needs the public key, so data can be sealed by a party
+// that is never able to open it again. Unwrap needs the private key of the recipient.
+type X25519 struct {
+	publicKey  *ecdh.PublicKey
+	privateKey *ecdh.PrivateKey
+	id         string
+}
+
+// NewX25519Recipient returns a wrapper that seals for a 32 byte public key, raw or base64 encoded
+func NewX25519Recipient(publicKey []byte) (*X25519, error) {
+	raw, err := decodeKey(publicKey, "Public")
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	return newX25519(key, nil), nil
+}
+
+// NewX25519Identity returns a wrapper that seals and opens with a 32 byte private key, raw
+// or base64 encoded
+func NewX25519Identity(privateKey []byte) (*X25519, error) {
+	raw, err := decodeKey(privateKey, "Private")
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	return newX25519(key.PublicKey(), key), nil
+}
+
+// GenerateX25519 returns the base64 encoded private and public key of a new key pair
+func GenerateX25519() (string, string, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(key.Bytes()), base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+func newX25519(publicKey *ecdh.PublicKey, privateKey *ecdh.PrivateKey) *X25519 {
+	sum := sha256.Sum256(publicKey.Bytes())
+	return &X25519{publicKey: publicKey, privateKey: privateKey, id: hex.EncodeToString(sum[:4])}
+}
+
+func decodeKey(key []byte, kind string) ([]byte, error) {
+	key = bytes.TrimSpace(key)
+	if len(key) == dataKeySize {
+		return key, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(string(key))
+	if err != nil || len(decoded) != dataKeySize {
+		return nil, fmt.Errorf("%s key must be %d bytes or their base64 encoding", kind, dataKeySize)
+	}
+	return decoded, nil
+}
+
+// Provider implements KeyWrapper
+func (x *X25519) Provider() string { return ProviderX25519 }
+
+// KeyID is derived from a hash of the public key
+func (x *X25519) KeyID() string { return x.id }
+
+// Wrap implements KeyWrapper, the result is the ephemeral public key followed by the data
+// key encrypted under the agreed key
+func (x *X25519) Wrap(_ context.Context, dataKey []byte) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(x.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(x.wrappingKey(shared, ephemeral.PublicKey().Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := encrypt(aead, dataKey, []byte(ProviderX25519))
+	if err != nil {
+		return nil, err
+	}
+	return append(ephemeral.PublicKey().Bytes(), wrapped...), nil
+}
+
+// Unwrap implements KeyWrapper
+func (x *X25519) Unwrap(_ context.Context, wrappedKey []byte) ([]byte, error) {
+	if x.privateKey == nil {
+		return nil, fmt.Errorf("X25519 key %s has no private key", x.id)
+	}
+	if len(wrappedKey) < dataKeySize {
+		return nil, fmt.Errorf("Wrapped key too short")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(wrappedKey[:dataKeySize])
+	if err != nil {
+		return nil, err
+	}
+	shared, err := x.privateKey.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(x.wrappingKey(shared, wrappedKey[:dataKeySize]))
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(aead, wrappedKey[dataKeySize:], []byte(ProviderX25519))
+}
+
+// wrappingKey derives the AES key from the shared secret, the salt binds it to both public keys
+func (x *X25519) wrappingKey(shared, ephemeral []byte) []byte {
+	salt := append(append([]byte{}, ephemeral...), x.publicKey.Bytes()...)
+	key := make([]byte, dataKeySize)
+	// HKDF-SHA256 can derive up to 8160 bytes, reading 32 never fails
+	io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(x25519Info)), key)
+	return key
+}
@@ -0,0 +1,309 @@
+// Copyright 2024.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: secops/v1alpha1/sentinel.proto
+
+package sentinelpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	SentinelService_CreateSentinel_FullMethodName = "/secops.v1alpha1.SentinelService/CreateSentinel"
+	SentinelService_GetSentinel_FullMethodName    = "/secops.v1alpha1.SentinelService/GetSentinel"
+	SentinelService_WatchSentinel_FullMethodName  = "/secops.v1alpha1.SentinelService/WatchSentinel"
+	SentinelService_RotateSecret_FullMethodName   = "/secops.v1alpha1.SentinelService/RotateSecret"
+	SentinelService_ListFindings_FullMethodName   = "/secops.v1alpha1.SentinelService/ListFindings"
+)
+
+// SentinelServiceClient is the client API for SentinelService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SentinelServiceClient interface {
+	// CreateSentinel creates a Sentinel, it needs create on sentinels in the namespace
+	CreateSentinel(ctx context.Context, in *CreateSentinelRequest, opts ...grpc.CallOption) (*Sentinel, error)
+	// GetSentinel returns a Sentinel, it needs get on the sentinel
+	GetSentinel(ctx context.Context, in *GetSentinelRequest, opts ...grpc.CallOption) (*Sentinel, error)
+	// WatchSentinel streams the status and the events of a Sentinel, it needs watch on the sentinel
+	WatchSentinel(ctx context.Context, in *WatchSentinelRequest, opts ...grpc.CallOption) (SentinelService_WatchSentinelClient, error)
+	// RotateSecret replaces the data of the secret of a Sentinel, it needs patch on the sentinel
+	RotateSecret(ctx context.Context, in *RotateSecretRequest, opts ...grpc.CallOption) (*Sentinel, error)
+	// ListFindings lists the SentinelFindings of the scanners, it needs list on sentinelfindings
+	ListFindings(ctx context.Context, in *ListFindingsRequest, opts ...grpc.CallOption) (*ListFindingsResponse, error)
+}
+
+type sentinelServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSentinelServiceClient(cc grpc.ClientConnInterface) SentinelServiceClient {
+	return &sentinelServiceClient{cc}
+}
+
+func (c *sentinelServiceClient) CreateSentinel(ctx context.Context, in *CreateSentinelRequest, opts ...grpc.CallOption) (*Sentinel, error) {
+	out := new(Sentinel)
+	err := c.cc.Invoke(ctx, SentinelService_CreateSentinel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sentinelServiceClient) GetSentinel(ctx context.Context, in *GetSentinelRequest, opts ...grpc.CallOption) (*Sentinel, error) {
+	out := new(Sentinel)
+	err := c.cc.Invoke(ctx, SentinelService_GetSentinel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sentinelServiceClient) WatchSentinel(ctx context.Context, in *WatchSentinelRequest, opts ...grpc.CallOption) (SentinelService_WatchSentinelClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SentinelService_ServiceDesc.Streams[0], SentinelService_WatchSentinel_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sentinelServiceWatchSentinelClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SentinelService_WatchSentinelClient interface {
+	Recv() (*SentinelEvent, error)
+	grpc.ClientStream
+}
+
+type sentinelServiceWatchSentinelClient struct {
+	grpc.ClientStream
+}
+
+func (x *sentinelServiceWatchSentinelClient) Recv() (*SentinelEvent, error) {
+	m := new(SentinelEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *sentinelServiceClient) RotateSecret(ctx context.Context, in *RotateSecretRequest, opts ...grpc.CallOption) (*Sentinel, error) {
+	out := new(Sentinel)
+	err := c.cc.Invoke(ctx, SentinelService_RotateSecret_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sentinelServiceClient) ListFindings(ctx context.Context, in *ListFindingsRequest, opts ...grpc.CallOption) (*ListFindingsResponse, error) {
+	out := new(ListFindingsResponse)
+	err := c.cc.Invoke(ctx, SentinelService_ListFindings_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SentinelServiceServer is the server API for SentinelService service.
+// All implementations must embed UnimplementedSentinelServiceServer
+// for forward compatibility
+type SentinelServiceServer interface {
+	// CreateSentinel creates a Sentinel, it needs create on sentinels in the namespace
+	CreateSentinel(context.Context, *CreateSentinelRequest) (*Sentinel, error)
+	// GetSentinel returns a Sentinel, it needs get on the sentinel
+	GetSentinel(context.Context, *GetSentinelRequest) (*Sentinel, error)
+	// WatchSentinel streams the status and the events of a Sentinel, it needs watch on the sentinel
+	WatchSentinel(*WatchSentinelRequest, SentinelService_WatchSentinelServer) error
+	// RotateSecret replaces the data of the secret of a Sentinel, it needs patch on the sentinel
+	RotateSecret(context.Context, *RotateSecretRequest) (*Sentinel, error)
+	// ListFindings lists the SentinelFindings of the scanners, it needs list on sentinelfindings
+	ListFindings(context.Context, *ListFindingsRequest) (*ListFindingsResponse, error)
+	mustEmbedUnimplementedSentinelServiceServer()
+}
+
+// UnimplementedSentinelServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedSentinelServiceServer struct {
+}
+
+func (UnimplementedSentinelServiceServer) CreateSentinel(context.Context, *CreateSentinelRequest) (*Sentinel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSentinel not implemented")
+}
+func (UnimplementedSentinelServiceServer) GetSentinel(context.Context, *GetSentinelRequest) (*Sentinel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSentinel not implemented")
+}
+func (UnimplementedSentinelServiceServer) WatchSentinel(*WatchSentinelRequest, SentinelService_WatchSentinelServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchSentinel not implemented")
+}
+func (UnimplementedSentinelServiceServer) RotateSecret(context.Context, *RotateSecretRequest) (*Sentinel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateSecret not implemented")
+}
+func (UnimplementedSentinelServiceServer) ListFindings(context.Context, *ListFindingsRequest) (*ListFindingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFindings not implemented")
+}
+func (UnimplementedSentinelServiceServer) mustEmbedUnimplementedSentinelServiceServer() {}
+
+// UnsafeSentinelServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SentinelServiceServer will
+// result in compilation errors.
+type UnsafeSentinelServiceServer interface {
+	mustEmbedUnimplementedSentinelServiceServer()
+}
+
+func RegisterSentinelServiceServer(s grpc.ServiceRegistrar, srv SentinelServiceServer) {
+	s.RegisterService(&SentinelService_ServiceDesc, srv)
+}
+
+func _SentinelService_CreateSentinel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSentinelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SentinelServiceServer).CreateSentinel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SentinelService_CreateSentinel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SentinelServiceServer).CreateSentinel(ctx, req.(*CreateSentinelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SentinelService_GetSentinel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSentinelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SentinelServiceServer).GetSentinel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SentinelService_GetSentinel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SentinelServiceServer).GetSentinel(ctx, req.(*GetSentinelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SentinelService_WatchSentinel_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchSentinelRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SentinelServiceServer).WatchSentinel(m, &sentinelServiceWatchSentinelServer{stream})
+}
+
+type SentinelService_WatchSentinelServer interface {
+	Send(*SentinelEvent) error
+	grpc.ServerStream
+}
+
+type sentinelServiceWatchSentinelServer struct {
+	grpc.ServerStream
+}
+
+func (x *sentinelServiceWatchSentinelServer) Send(m *SentinelEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SentinelService_RotateSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SentinelServiceServer).RotateSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SentinelService_RotateSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SentinelServiceServer).RotateSecret(ctx, req.(*RotateSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SentinelService_ListFindings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFindingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SentinelServiceServer).ListFindings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SentinelService_ListFindings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SentinelServiceServer).ListFindings(ctx, req.(*ListFindingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SentinelService_ServiceDesc is the grpc.ServiceDesc for SentinelService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SentinelService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "secops.v1alpha1.SentinelService",
+	HandlerType: (*SentinelServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateSentinel",
+			Handler:    _SentinelService_CreateSentinel_Handler,
+		},
+		{
+			MethodName: "GetSentinel",
+			Handler:    _SentinelService_GetSentinel_Handler,
+		},
+		{
+			MethodName: "RotateSecret",
+			Handler:    _SentinelService_RotateSecret_Handler,
+		},
+		{
+			MethodName: "ListFindings",
+			Handler:    _SentinelService_ListFindings_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchSentinel",
+			Handler:       _SentinelService_WatchSentinel_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "secops/v1alpha1/sentinel.proto",
+}
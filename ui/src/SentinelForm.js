@@ -1,4 +1,4 @@
-import React, { useState } from 'react';
+import React, { useEffect, useRef, useState } from 'react';
 import { TextField, Button, Container, Typography, Box, IconButton, InputAdornment, Select, MenuItem, Snackbar } from '@mui/material';
 import { Visibility, VisibilityOff } from '@mui/icons-material';
 import MuiAlert from '@mui/material/Alert';
@@ -21,6 +21,58 @@ function SentinelForm({ onSubmit }) {
     const [snackbarOpen, setSnackbarOpen] = useState(false); // State for controlling Snackbar open/close
     const [snackbarMessage, setSnackbarMessage] = useState(''); // State for Snackbar message
     const [snackbarSeverity, setSnackbarSeverity] = useState('success'); // State for Snackbar severity (success/error)
+    const statusStream = useRef(null); // Live status of the last created Sentinel
+
+    useEffect(() => () => closeStatusStream(), []);
+
+    const closeStatusStream = () => {
+        if (statusStream.current) {
+            statusStream.current.close();
+            statusStream.current = null;
+        }
+    };
+
+    const showStatus = (severity, message) => {
+        setSnackbarSeverity(severity);
+        setSnackbarMessage(message);
+        setSnackbarOpen(true);
+    };
+
+    // Follows the Sentinel from Reconciling to Available or a failure over server-sent events
+    const watchSentinel = (namespace, sentinelName) => {
+        closeStatusStream();
+        const source = new EventSource('/api/v1/namespaces/' + encodeURIComponent(namespace) + '/sentinels/' + encodeURIComponent(sentinelName) + '/watch', { withCredentials: true });
+        statusStream.current = source;
+        source.addEventListener('status', (e) => {
+            const status = JSON.parse(e.data);
+            switch (status.phase) {
+                case 'Available':
+                    showStatus('success', 'Sentinel ' + sentinelName + ' is available');
+                    closeStatusStream();
+                    break;
+                case 'Failed':
+                    showStatus('error', status.message || 'Sentinel ' + sentinelName + ' failed');
+                    closeStatusStream();
+                    break;
+                case 'Deleted':
+                    showStatus('warning', 'Sentinel ' + sentinelName + ' was deleted');
+                    closeStatusStream();
+                    break;
+                default:
+                    showStatus('info', status.message || 'Reconciling ' + sentinelName + '...');
+            }
+        });
+        source.addEventListener('event', (e) => {
+            const event = JSON.parse(e.data);
+            showStatus(event.type === 'Warning' ? 'warning' : 'info', event.reason + ': ' + event.message);
+        });
+        source.onerror = () => {
+            // The browser reconnects on its own unless the stream was refused
+            if (source.readyState === EventSource.CLOSED) {
+                closeStatusStream();
+            }
+        };
+    };
 
     const handleSubmit = async (e) => {
         e.preventDefault();
@@ -46,28 +98,33 @@ function SentinelForm({ onSubmit }) {
         };
 
         try {
-            const response = await fetch('/apis/secops.kavinduxo.com/v1alpha1/namespaces/' + nameSpace.trim() + '/sentinels', {
+            const response = await fetch('/api/v1/namespaces/' + encodeURIComponent(nameSpace.trim()) + '/sentinels', {
                 method: 'POST',
+                credentials: 'same-origin',
                 headers: {
                     'Content-Type': 'application/json',
                 },
                 body: JSON.stringify(customResource),
             });
 
+            if (response.status === 401) {
+                // The session expired or never started, log in through sentinel-api
+                window.location.assign('/auth/login');
+                return;
+            }
             if (!response.ok) {
-                throw new Error('Failed to deploy Sentinel');
+                const status = await response.json().catch(() => ({}));
+                throw new Error(status.message || 'Failed to deploy Sentinel');
             }
 
             console.log('Sentinel deployed successfully!');
+            const created = await response.json();
             clearFormFields();
-            setSnackbarSeverity('success');
-            setSnackbarMessage('Sentinel deployed successfully!');
-            setSnackbarOpen(true);
+            showStatus('info', 'Sentinel deployed, waiting for the operator...');
+            watchSentinel(created.metadata.namespace, created.metadata.name);
         } catch (error) {
             console.error('Error deploying sentinel:', error);
-            setSnackbarSeverity('error');
-            setSnackbarMessage('Failed to deploy Sentinel');
-            setSnackbarOpen(true);
+            showStatus('error', error.message || 'Failed to deploy Sentinel');
         }
     };
 
@@ -220,7 +277,7 @@ function SentinelForm({ onSubmit }) {
                         </Button>
                     </Box>
                 </form>
-                <Snackbar open={snackbarOpen} autoHideDuration={6000} onClose={handleSnackbarClose}>
+                <Snackbar open={snackbarOpen} autoHideDuration={snackbarSeverity === 'info' ? null : 6000} onClose={handleSnackbarClose}>
                     <div>
                         <Alert onClose={handleSnackbarClose} severity={snackbarSeverity}>
                             {snackbarMessage}